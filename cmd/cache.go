@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"forgeronvirtuel/gip/internal/gocache"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cacheMaxModSize   string
+	cacheMaxBuildSize string
+)
+
+// addCacheFlags ajoute à cmd les options de taille du cache Go partagé
+func addCacheFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&cacheMaxModSize, "cache-max-mod-size", "10GiB", "Taille maximale du cache des modules Go partagé par les builds (0 = illimitée)")
+	cmd.Flags().StringVar(&cacheMaxBuildSize, "cache-max-build-size", "10GiB", "Taille maximale du cache de compilation Go partagé par les builds (0 = illimitée)")
+}
+
+// openGoCache ouvre le cache Go partagé par les builds, dans workspace/cache
+func openGoCache(workspace string) (*gocache.Cache, error) {
+	var limits gocache.Limits
+	var err error
+	if limits.ModBytes, err = gocache.ParseSize(cacheMaxModSize); err != nil {
+		return nil, fmt.Errorf("--cache-max-mod-size: %w", err)
+	}
+	if limits.BuildBytes, err = gocache.ParseSize(cacheMaxBuildSize); err != nil {
+		return nil, fmt.Errorf("--cache-max-build-size: %w", err)
+	}
+
+	cache, err := gocache.New(filepath.Join(workspace, "cache"), limits)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Str("cache", cache.Dir()).Int64("max_mod_size", limits.ModBytes).Int64("max_build_size", limits.BuildBytes).Msg("Cache Go partagé ouvert")
+	return cache, nil
+}
@@ -2,15 +2,21 @@ package cmd
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
@@ -19,8 +25,17 @@ var (
 	controlPlaneURL string
 	runnerName      string
 	runnerLabels    map[string]string
+	runnerWorkspace string
 )
 
+// registrationTokenEnv est la variable d'environnement contenant le jeton que
+// les agents présentent pour s'enregistrer auprès du control plane
+const registrationTokenEnv = "GIP_AGENT_REGISTRATION_TOKEN"
+
+// agentTokenFile est le fichier du workspace du runner où est conservé le jeton
+// délivré à l'agent, pour le réutiliser au redémarrage
+const agentTokenFile = "agent-token"
+
 type AgentRegistrationRequest struct {
 	Name   string            `json:"name"`
 	Labels map[string]string `json:"labels"`
@@ -33,6 +48,7 @@ type AgentResponse struct {
 	Status     string            `json:"status"`
 	LastSeenAt string            `json:"last_seen_at"`
 	CreatedAt  string            `json:"created_at"`
+	Token      string            `json:"token"`
 }
 
 type HeartbeatResponse struct {
@@ -43,16 +59,36 @@ type HeartbeatResponse struct {
 var runnerCmd = &cobra.Command{
 	Use:   "runner",
 	Short: "Démarre un agent runner",
-	Long:  `Démarre un agent runner qui s'enregistre auprès du control plane et envoie des heartbeats réguliers.`,
+	Long:  `Démarre un agent runner qui s'enregistre auprès du control plane, envoie des heartbeats réguliers et exécute les builds qui lui sont attribués.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Info().
 			Str("control_plane", controlPlaneURL).
 			Str("name", runnerName).
 			Interface("labels", runnerLabels).
+			Str("workspace", runnerWorkspace).
 			Msg("Démarrage du runner")
 
+		// Valider et vérifier le répertoire de workspace
+		if err := workspacemanager.ValidateWorkspaceDir(runnerWorkspace); err != nil {
+			log.Fatal().Err(err).Str("workspace", runnerWorkspace).Msg("Le répertoire de workspace est invalide ou inaccessible")
+		}
+
+		cache, err := openGoCache(runnerWorkspace)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Impossible d'ouvrir le cache Go partagé")
+		}
+
+		seedToolchains(runnerWorkspace)
+
+		// Supprimer les répertoires des builds interrompus par un arrêt précédent
+		if removed, err := workspacemanager.Cleanup(runnerWorkspace); err != nil {
+			log.Warn().Err(err).Msg("Impossible de nettoyer les répertoires de builds")
+		} else if removed > 0 {
+			log.Info().Int("count", removed).Msg("Répertoires de builds interrompus supprimés")
+		}
+
 		// Enregistrer l'agent
-		agentID, err := registerAgent(controlPlaneURL, runnerName, runnerLabels)
+		agentID, token, err := registerAgent(controlPlaneURL, runnerName, runnerLabels, runnerWorkspace)
 		if err != nil {
 			log.Fatal().Err(err).Msg("Impossible d'enregistrer l'agent")
 		}
@@ -68,7 +104,15 @@ var runnerCmd = &cobra.Command{
 
 		// Démarrer la goroutine de heartbeat
 		stopChan := make(chan struct{})
-		go startHeartbeat(controlPlaneURL, agentID, stopChan)
+		go startHeartbeat(controlPlaneURL, agentID, token, stopChan)
+
+		// Démarrer la boucle d'exécution des builds
+		jobsCtx, stopJobs := context.WithCancel(context.Background())
+		jobsDone := make(chan struct{})
+		go func() {
+			runJobs(jobsCtx, controlPlaneURL, agentID, token, runnerWorkspace, cache)
+			close(jobsDone)
+		}()
 
 		// Attendre un signal d'arrêt
 		sigChan := make(chan os.Signal, 1)
@@ -77,7 +121,9 @@ var runnerCmd = &cobra.Command{
 		<-sigChan
 		log.Info().Msg("Signal d'arrêt reçu, arrêt du runner...")
 
-		// Arrêter la goroutine de heartbeat
+		// Arrêter l'exécution des builds puis la goroutine de heartbeat
+		stopJobs()
+		<-jobsDone
 		close(stopChan)
 
 		// Mettre l'agent en OFFLINE avant de quitter
@@ -111,11 +157,17 @@ func init() {
 
 	runnerCmd.Flags().StringVarP(&runnerName, "name", "n", hostname, "Nom de l'agent (hostname par défaut)")
 	runnerCmd.Flags().StringToStringVarP(&runnerLabels, "labels", "l", defaultLabels, "Labels de l'agent (format: key1=value1,key2=value2)")
+	runnerCmd.Flags().StringVarP(&runnerWorkspace, "workspace", "w", "./runner-workspace", "Répertoire de workspace pour les builds exécutés par l'agent")
+	addCacheFlags(runnerCmd)
+	addToolchainFlags(runnerCmd)
 }
 
-// registerAgent enregistre l'agent auprès du control plane
-func registerAgent(controlPlaneURL, name string, labels map[string]string) (int, error) {
-	url := fmt.Sprintf("%s/v1/api/agents", controlPlaneURL)
+// registerAgent enregistre l'agent auprès du control plane et retourne son ID et
+// son jeton, conservé dans le workspace. Si un agent du même nom existe déjà
+// (redémarrage du runner), il est réutilisé avec le jeton conservé.
+func registerAgent(controlPlaneURL, name string, labels map[string]string, workspace string) (int, string, error) {
+	url := fmt.Sprintf("%s/v1/api/agents/register", controlPlaneURL)
+	tokenPath := filepath.Join(workspace, agentTokenFile)
 
 	request := AgentRegistrationRequest{
 		Name:   name,
@@ -124,27 +176,93 @@ func registerAgent(controlPlaneURL, name string, labels map[string]string) (int,
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
-		return 0, fmt.Errorf("erreur lors de la sérialisation JSON: %w", err)
+		return 0, "", fmt.Errorf("erreur lors de la sérialisation JSON: %w", err)
 	}
 
 	log.Debug().Str("url", url).Str("body", string(jsonData)).Msg("Envoi de la requête d'enregistrement")
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return 0, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+		return 0, "", fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if registrationToken := os.Getenv(registrationTokenEnv); registrationToken != "" {
+		req.Header.Set("Authorization", "Bearer "+registrationToken)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("erreur lors de la requête HTTP: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusCreated {
-		return 0, fmt.Errorf("code de statut inattendu: %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+	case http.StatusConflict:
+		token, err := os.ReadFile(tokenPath)
+		if err != nil {
+			return 0, "", fmt.Errorf("l'agent %q est déjà enregistré mais son jeton est illisible (%w) : supprimez l'agent du control plane ou changez de nom", name, err)
+		}
+		id, err := findAgentByName(controlPlaneURL, name)
+		return id, strings.TrimSpace(string(token)), err
+	case http.StatusUnauthorized:
+		return 0, "", fmt.Errorf("jeton d'enregistrement refusé par le control plane : vérifiez %s", registrationTokenEnv)
+	default:
+		return 0, "", fmt.Errorf("code de statut inattendu: %d", resp.StatusCode)
 	}
 
 	var agentResp AgentResponse
 	if err := json.NewDecoder(resp.Body).Decode(&agentResp); err != nil {
+		return 0, "", fmt.Errorf("erreur lors de la désérialisation de la réponse: %w", err)
+	}
+
+	if err := os.WriteFile(tokenPath, []byte(agentResp.Token+"\n"), 0o600); err != nil {
+		return 0, "", fmt.Errorf("impossible de conserver le jeton de l'agent: %w", err)
+	}
+
+	return agentResp.ID, agentResp.Token, nil
+}
+
+// postAgent envoie une requête POST authentifiée par le jeton de l'agent
+func postAgent(url, token, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la création de la requête: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
+
+// findAgentByName retrouve l'ID d'un agent déjà enregistré
+func findAgentByName(controlPlaneURL, name string) (int, error) {
+	url := fmt.Sprintf("%s/v1/api/agents", controlPlaneURL)
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("code de statut inattendu: %d", resp.StatusCode)
+	}
+
+	var listResp struct {
+		Agents []AgentResponse `json:"agents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
 		return 0, fmt.Errorf("erreur lors de la désérialisation de la réponse: %w", err)
 	}
 
-	return agentResp.ID, nil
+	for _, agent := range listResp.Agents {
+		if agent.Name == name {
+			log.Info().Int("agent_id", agent.ID).Msg("Agent déjà enregistré, réutilisation")
+			return agent.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("agent %q introuvable", name)
 }
 
 // updateAgentStatus met à jour le statut de l'agent
@@ -177,17 +295,17 @@ func updateAgentStatus(controlPlaneURL string, agentID int, status string) error
 }
 
 // startHeartbeat envoie des heartbeats réguliers au control plane
-func startHeartbeat(controlPlaneURL string, agentID int, stopChan chan struct{}) {
+func startHeartbeat(controlPlaneURL string, agentID int, token string, stopChan chan struct{}) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
 	// Envoyer un premier heartbeat immédiatement
-	sendHeartbeat(controlPlaneURL, agentID)
+	sendHeartbeat(controlPlaneURL, agentID, token)
 
 	for {
 		select {
 		case <-ticker.C:
-			sendHeartbeat(controlPlaneURL, agentID)
+			sendHeartbeat(controlPlaneURL, agentID, token)
 		case <-stopChan:
 			log.Info().Msg("Arrêt de la goroutine de heartbeat")
 			return
@@ -196,12 +314,12 @@ func startHeartbeat(controlPlaneURL string, agentID int, stopChan chan struct{})
 }
 
 // sendHeartbeat envoie un heartbeat au control plane
-func sendHeartbeat(controlPlaneURL string, agentID int) {
+func sendHeartbeat(controlPlaneURL string, agentID int, token string) {
 	url := fmt.Sprintf("%s/v1/api/agents/%d/heartbeat", controlPlaneURL, agentID)
 
 	log.Debug().Str("url", url).Msg("Envoi du heartbeat")
 
-	resp, err := http.Post(url, "application/json", nil)
+	resp, err := postAgent(url, token, "application/json", nil)
 	if err != nil {
 		log.Error().Err(err).Msg("Erreur lors de l'envoi du heartbeat")
 		return
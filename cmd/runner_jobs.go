@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/gocache"
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// leasePollInterval est l'intervalle entre deux demandes de build quand la file est vide
+	leasePollInterval = 5 * time.Second
+
+	// logFlushInterval est l'intervalle d'envoi des logs, qui renouvelle aussi le bail du build
+	logFlushInterval = 2 * time.Second
+)
+
+// errBuildAbandoned indique que le control plane a retiré le build à l'agent
+var errBuildAbandoned = errors.New("build retiré à l'agent par le control plane")
+
+// runJobs demande des builds au control plane et les exécute un par un, avec le
+// cache Go partagé cache, jusqu'à l'annulation de ctx. Chaque requête présente le
+// jeton token de l'agent.
+func runJobs(ctx context.Context, controlPlaneURL string, agentID int, token, workspace string, cache *gocache.Cache) {
+	for {
+		job, err := leaseBuild(controlPlaneURL, agentID, token)
+		if err != nil {
+			log.Error().Err(err).Msg("Erreur lors de la demande de build")
+		}
+
+		if job != nil {
+			executeJob(ctx, controlPlaneURL, agentID, token, workspace, cache, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leasePollInterval):
+		}
+	}
+}
+
+// executeJob exécute le pipeline d'un build et en renvoie le résultat au control plane
+func executeJob(ctx context.Context, controlPlaneURL string, agentID int, token, workspace string, cache *gocache.Cache, job *builder.Job) {
+	log.Info().Int("build_id", job.BuildID).Str("project", job.ProjectName).Msg("Exécution du build")
+
+	buildCtx, cancel := context.WithTimeout(ctx, builder.BuildTimeout)
+	defer cancel()
+
+	logs := newLogStreamer(controlPlaneURL, agentID, token, job.BuildID, cancel)
+	result, runErr := builder.Run(buildCtx, workspace, cache, job, logs)
+	abandoned := logs.Close()
+
+	// Les artefacts ne sont conservés que par le control plane
+	defer func() {
+		if err := workspacemanager.RemoveArtifacts(workspace, job.BuildID); err != nil {
+			log.Warn().Err(err).Int("build_id", job.BuildID).Msg("Impossible de supprimer les artefacts envoyés")
+		}
+	}()
+
+	if ctx.Err() != nil {
+		// Arrêt du runner : le bail expirera et le build sera repris ailleurs
+		log.Warn().Int("build_id", job.BuildID).Msg("Build interrompu par l'arrêt du runner")
+		return
+	}
+	if abandoned {
+		log.Warn().Int("build_id", job.BuildID).Msg("Build abandonné à la demande du control plane (annulé ou réattribué)")
+		return
+	}
+
+	if runErr == nil {
+		for _, artifact := range result.Artifacts {
+			if err := uploadArtifact(controlPlaneURL, agentID, token, job.BuildID, artifact.Path); err != nil {
+				runErr = fmt.Errorf("Failed to upload artifact %s: %w", artifact.Name, err)
+				break
+			}
+		}
+	}
+
+	if err := completeBuild(controlPlaneURL, agentID, token, job.BuildID, result, runErr); err != nil {
+		log.Error().Err(err).Int("build_id", job.BuildID).Msg("Impossible d'envoyer le résultat du build")
+		return
+	}
+
+	log.Info().Int("build_id", job.BuildID).Bool("success", runErr == nil).Msg("Build terminé")
+}
+
+// leaseBuild demande un build au control plane. Retourne nil s'il n'y en a aucun.
+func leaseBuild(controlPlaneURL string, agentID int, token string) (*builder.Job, error) {
+	url := fmt.Sprintf("%s/v1/api/agents/%d/lease", controlPlaneURL, agentID)
+
+	resp, err := postAgent(url, token, "application/json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("code de statut inattendu: %d", resp.StatusCode)
+	}
+
+	var job builder.Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("erreur lors de la désérialisation de la réponse: %w", err)
+	}
+
+	return &job, nil
+}
+
+// uploadArtifact envoie un artefact produit au control plane
+func uploadArtifact(controlPlaneURL string, agentID int, token string, buildID int, artifactPath string) error {
+	url := fmt.Sprintf("%s/v1/api/agents/%d/builds/%d/artifact", controlPlaneURL, agentID, buildID)
+
+	file, err := os.Open(artifactPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// L'artefact est envoyé en streaming pour ne pas le charger en mémoire
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("file", filepath.Base(artifactPath))
+		if err == nil {
+			_, err = io.Copy(part, file)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	resp, err := postAgent(url, token, form.FormDataContentType(), body)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("code de statut inattendu: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// completeBuild envoie le résultat final du build au control plane
+func completeBuild(controlPlaneURL string, agentID int, token string, buildID int, result *builder.Result, runErr error) error {
+	url := fmt.Sprintf("%s/v1/api/agents/%d/builds/%d/complete", controlPlaneURL, agentID, buildID)
+
+	request := map[string]interface{}{"result": result}
+	if runErr != nil {
+		request["error"] = runErr.Error()
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("erreur lors de la sérialisation JSON: %w", err)
+	}
+
+	resp, err := postAgent(url, token, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("code de statut inattendu: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// logStreamer est un io.Writer qui envoie périodiquement la sortie du build au
+// control plane. Chaque envoi, même vide, renouvelle le bail du build.
+type logStreamer struct {
+	url     string
+	token   string
+	abandon context.CancelFunc
+
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	abandoned bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newLogStreamer(controlPlaneURL string, agentID int, token string, buildID int, abandon context.CancelFunc) *logStreamer {
+	s := &logStreamer{
+		url:     fmt.Sprintf("%s/v1/api/agents/%d/builds/%d/logs", controlPlaneURL, agentID, buildID),
+		token:   token,
+		abandon: abandon,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go s.loop()
+	return s
+}
+
+func (s *logStreamer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+// Close envoie les derniers logs et indique si le control plane a retiré le build à l'agent
+func (s *logStreamer) Close() bool {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.abandoned
+}
+
+func (s *logStreamer) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *logStreamer) flush() {
+	s.mu.Lock()
+	chunk := append([]byte(nil), s.buf.Bytes()...)
+	s.buf.Reset()
+	s.mu.Unlock()
+
+	err := s.send(chunk)
+	if err == nil {
+		return
+	}
+
+	if err == errBuildAbandoned {
+		s.mu.Lock()
+		s.abandoned = true
+		s.mu.Unlock()
+		s.abandon()
+		return
+	}
+
+	// Erreur temporaire : les logs seront renvoyés au prochain envoi
+	log.Warn().Err(err).Msg("Erreur lors de l'envoi des logs du build")
+	s.mu.Lock()
+	rest := append(chunk, s.buf.Bytes()...)
+	s.buf.Reset()
+	s.buf.Write(rest)
+	s.mu.Unlock()
+}
+
+func (s *logStreamer) send(chunk []byte) error {
+	resp, err := postAgent(s.url, s.token, "text/plain; charset=utf-8", bytes.NewReader(chunk))
+	if err != nil {
+		return fmt.Errorf("erreur lors de la requête HTTP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusConflict, http.StatusNotFound:
+		return errBuildAbandoned
+	default:
+		return fmt.Errorf("code de statut inattendu: %d", resp.StatusCode)
+	}
+}
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"os"
+
 	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/secrets"
 	"forgeronvirtuel/gip/internal/server"
 	"forgeronvirtuel/gip/internal/workspacemanager"
 
@@ -13,14 +16,43 @@ var (
 	port         string
 	dbPath       string
 	workspaceDir string
+	buildWorkers int
+	masterKey    string
 )
 
+// masterKeyEnv est la variable d'environnement contenant la clé maître encodée
+// en base64, prioritaire sur le fichier --master-key-file
+const masterKeyEnv = "GIP_MASTER_KEY"
+
+// loadMasterKey charge la clé maître qui chiffre les secrets des projets
+func loadMasterKey() (*secrets.Cipher, error) {
+	if encoded := os.Getenv(masterKeyEnv); encoded != "" {
+		key, err := secrets.ParseKey(encoded)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Str("env", masterKeyEnv).Msg("Clé maître chargée depuis l'environnement")
+		return secrets.NewCipher(key)
+	}
+
+	key, created, err := secrets.LoadOrCreateKey(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		log.Warn().Str("path", masterKey).Msg("Clé maître générée : sauvegardez ce fichier, les secrets des projets ne peuvent pas être déchiffrés sans lui")
+	} else {
+		log.Info().Str("path", masterKey).Msg("Clé maître chargée")
+	}
+	return secrets.NewCipher(key)
+}
+
 var serveCmd = &cobra.Command{
 	Use:   "serve",
 	Short: "Démarre le serveur HTTP",
 	Long:  `Démarre le serveur HTTP avec Gin et initialise la base de données SQLite.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Info().Str("port", port).Str("db", dbPath).Str("workspace", workspaceDir).Msg("Démarrage du serveur")
+		log.Info().Str("port", port).Str("db", dbPath).Str("workspace", workspaceDir).Int("workers", buildWorkers).Msg("Démarrage du serveur")
 
 		// Valider et vérifier le répertoire de workspace
 		if err := workspacemanager.ValidateWorkspaceDir(workspaceDir); err != nil {
@@ -38,8 +70,25 @@ var serveCmd = &cobra.Command{
 
 		log.Info().Msg("Base de données initialisée avec succès")
 
+		cipher, err := loadMasterKey()
+		if err != nil {
+			log.Fatal().Err(err).Msg("Impossible de charger la clé maître")
+		}
+
+		cache, err := openGoCache(workspaceDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Impossible d'ouvrir le cache Go partagé")
+		}
+
+		seedToolchains(workspaceDir)
+
+		registrationToken := os.Getenv(registrationTokenEnv)
+		if registrationToken == "" {
+			log.Warn().Str("env", registrationTokenEnv).Msg("Jeton d'enregistrement des agents non défini : tout client qui atteint le serveur peut enregistrer un agent et recevoir les secrets des projets")
+		}
+
 		// Démarrer le serveur
-		server.Start(port, db, workspaceDir)
+		server.Start(port, db, workspaceDir, cache, buildWorkers, cipher, registrationToken)
 	},
 }
 
@@ -49,4 +98,8 @@ func init() {
 	serveCmd.Flags().StringVarP(&port, "port", "p", "3000", "Port d'écoute du serveur")
 	serveCmd.Flags().StringVarP(&dbPath, "database", "d", "./data.db", "Chemin vers le fichier de base de données SQLite")
 	serveCmd.Flags().StringVarP(&workspaceDir, "workspace", "w", "./workspace", "Répertoire de workspace pour les projets")
+	serveCmd.Flags().IntVar(&buildWorkers, "workers", 2, "Nombre de workers exécutant les builds en parallèle")
+	addCacheFlags(serveCmd)
+	addToolchainFlags(serveCmd)
+	serveCmd.Flags().StringVar(&masterKey, "master-key-file", "./master.key", "Fichier de la clé maître chiffrant les secrets des projets, créé s'il n'existe pas (ignoré si "+masterKeyEnv+" est défini)")
 }
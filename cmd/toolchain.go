@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"forgeronvirtuel/gip/internal/toolchain"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var toolchainArchives string
+
+// addToolchainFlags ajoute à cmd l'option des archives de toolchains Go à installer
+func addToolchainFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&toolchainArchives, "toolchain-archives", "", "Répertoire d'archives de distributions Go (go1.22.3.linux-amd64.tar.gz...) installées au démarrage dans workspace/toolchains")
+}
+
+// seedToolchains installe dans workspace/toolchains les toolchains Go des
+// archives de --toolchain-archives qui n'y sont pas encore. Une archive invalide
+// est signalée sans empêcher le démarrage.
+func seedToolchains(workspace string) {
+	dir := toolchain.Dir(workspace)
+	if toolchainArchives != "" {
+		installed, err := toolchain.Seed(dir, toolchainArchives)
+		if err != nil {
+			log.Warn().Err(err).Str("archives", toolchainArchives).Msg("Certaines archives de toolchains Go n'ont pas pu être installées")
+		}
+		log.Info().Int("count", len(installed)).Str("archives", toolchainArchives).Msg("Archives de toolchains Go installées")
+	}
+
+	toolchains, err := toolchain.List(dir)
+	if err != nil {
+		log.Warn().Err(err).Str("dir", dir).Msg("Impossible de lire les toolchains Go installées")
+		return
+	}
+	versions := make([]string, len(toolchains))
+	for i, t := range toolchains {
+		versions[i] = t.Version
+	}
+	log.Info().Str("dir", dir).Strs("versions", versions).Msg("Toolchains Go disponibles")
+}
@@ -0,0 +1,185 @@
+// Package bindiff compare deux binaires produits par go build et résume leurs
+// différences par section : pour un binaire ELF, Mach-O ou PE, les sections dont
+// le contenu diffère (.text, .rodata, .go.buildinfo...), et les octets hors des
+// sections (en-têtes, tables) comme une pseudo-section HeadersSection. Un fichier
+// d'un autre format est comparé en entier, comme la pseudo-section FileSection.
+package bindiff
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Pseudo-sections des différences hors des sections
+const (
+	HeadersSection = "(headers)" // Octets hors des sections : en-têtes, tables
+	FileSection    = "(file)"    // Fichier entier, de format non reconnu
+)
+
+// Section est une section dont le contenu diffère entre deux binaires
+type Section struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`       // Taille dans le premier binaire, -1 si elle en est absente
+	OtherSize int64  `json:"other_size"` // Taille dans le second binaire, -1 si elle en est absente
+}
+
+// String décrit la section, avec ses tailles si elles diffèrent
+func (s Section) String() string {
+	switch {
+	case s.Size < 0:
+		return fmt.Sprintf("%s (added, %d bytes)", s.Name, s.OtherSize)
+	case s.OtherSize < 0:
+		return fmt.Sprintf("%s (removed, %d bytes)", s.Name, s.Size)
+	case s.Size != s.OtherSize:
+		return fmt.Sprintf("%s (%d -> %d bytes)", s.Name, s.Size, s.OtherSize)
+	}
+	return s.Name
+}
+
+// Summary décrit les sections, séparées par des virgules
+func Summary(sections []Section) string {
+	names := make([]string, len(sections))
+	for i, section := range sections {
+		names[i] = section.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// Files compare les binaires des fichiers a et b. Voir Diff.
+func Files(a, b string) ([]Section, error) {
+	dataA, err := os.ReadFile(a)
+	if err != nil {
+		return nil, err
+	}
+	dataB, err := os.ReadFile(b)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(dataA, dataB), nil
+}
+
+// Diff retourne les sections dont le contenu diffère entre les binaires a et b,
+// dans l'ordre du premier, puis celles du second seulement, puis HeadersSection.
+// Les binaires sont identiques si elle retourne une liste vide. Si l'un des deux
+// n'est pas un binaire reconnu, ou s'ils ne sont pas du même format, la seule
+// différence est FileSection.
+func Diff(a, b []byte) []Section {
+	if bytes.Equal(a, b) {
+		return []Section{}
+	}
+
+	regionsA, formatA := sections(a)
+	regionsB, formatB := sections(b)
+	if formatA == "" || formatA != formatB {
+		return []Section{{Name: FileSection, Size: int64(len(a)), OtherSize: int64(len(b))}}
+	}
+
+	byName := make(map[string]region, len(regionsB))
+	for _, r := range regionsB {
+		byName[r.name] = r
+	}
+
+	diffs := []Section{}
+	for _, r := range regionsA {
+		other, found := byName[r.name]
+		if !found {
+			diffs = append(diffs, Section{Name: r.name, Size: r.size, OtherSize: -1})
+			continue
+		}
+		delete(byName, r.name)
+		if !bytes.Equal(r.content(a), other.content(b)) {
+			diffs = append(diffs, Section{Name: r.name, Size: r.size, OtherSize: other.size})
+		}
+	}
+	for _, r := range regionsB {
+		if _, found := byName[r.name]; found {
+			diffs = append(diffs, Section{Name: r.name, Size: -1, OtherSize: r.size})
+		}
+	}
+
+	headersA, headersB := outside(a, regionsA), outside(b, regionsB)
+	if !bytes.Equal(headersA, headersB) {
+		diffs = append(diffs, Section{Name: HeadersSection, Size: int64(len(headersA)), OtherSize: int64(len(headersB))})
+	}
+	return diffs
+}
+
+// region est l'emplacement d'une section dans un binaire
+type region struct {
+	name         string
+	offset, size int64
+}
+
+// content retourne le contenu de la section, tronqué à la fin du fichier
+func (r region) content(data []byte) []byte {
+	start := min(r.offset, int64(len(data)))
+	end := min(r.offset+r.size, int64(len(data)))
+	return data[start:end]
+}
+
+// sections retourne les sections du binaire data qui occupent de la place dans le
+// fichier, avec leur format : "elf", "macho" ou "pe", vide si data n'est pas un
+// binaire reconnu. Une section dont le nom est déjà pris est suffixée par #2, #3...
+func sections(data []byte) ([]region, string) {
+	var regions []region
+	var format string
+
+	if f, err := elf.NewFile(bytes.NewReader(data)); err == nil {
+		format = "elf"
+		for _, s := range f.Sections {
+			if s.Type != elf.SHT_NOBITS && s.Size > 0 {
+				regions = append(regions, region{s.Name, int64(s.Offset), int64(s.Size)})
+			}
+		}
+	} else if f, err := macho.NewFile(bytes.NewReader(data)); err == nil {
+		format = "macho"
+		for _, s := range f.Sections {
+			// Les sections zerofill n'occupent pas de place dans le fichier
+			const zerofill = 0x1
+			if s.Flags&0xff != zerofill && s.Size > 0 {
+				regions = append(regions, region{s.Seg + "," + s.Name, int64(s.Offset), int64(s.Size)})
+			}
+		}
+	} else if f, err := pe.NewFile(bytes.NewReader(data)); err == nil {
+		format = "pe"
+		for _, s := range f.Sections {
+			if s.Size > 0 {
+				regions = append(regions, region{s.Name, int64(s.Offset), int64(s.Size)})
+			}
+		}
+	} else {
+		return nil, ""
+	}
+
+	seen := make(map[string]int, len(regions))
+	for i := range regions {
+		seen[regions[i].name]++
+		if n := seen[regions[i].name]; n > 1 {
+			regions[i].name = fmt.Sprintf("%s#%d", regions[i].name, n)
+		}
+	}
+	return regions, format
+}
+
+// outside retourne les octets de data qui ne sont dans aucune des régions
+func outside(data []byte, regions []region) []byte {
+	sorted := append([]region{}, regions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].offset < sorted[j].offset })
+
+	var rest []byte
+	var pos int64
+	for _, r := range sorted {
+		start := min(r.offset, int64(len(data)))
+		if start > pos {
+			rest = append(rest, data[pos:start]...)
+		}
+		pos = max(pos, min(r.offset+r.size, int64(len(data))))
+	}
+	return append(rest, data[pos:]...)
+}
@@ -0,0 +1,68 @@
+package bindiff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// build compile un programme qui affiche message pour goos/amd64 et retourne
+// le chemin du binaire
+func build(t *testing.T, goos, message string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/hello\n\ngo 1.21\n"), 0o644))
+	main := "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"" + message + "\") }\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(main), 0o644))
+
+	output := filepath.Join(dir, "hello")
+	cmd := exec.Command("go", "build", "-trimpath", "-o", output, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=amd64", "CGO_ENABLED=0")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return output
+}
+
+func TestFiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping go build in short mode")
+	}
+
+	for _, goos := range []string{"linux", "darwin", "windows"} {
+		hello := build(t, goos, "hello")
+
+		// Deux compilations des mêmes sources, dans des répertoires différents
+		sections, err := Files(hello, build(t, goos, "hello"))
+		require.NoError(t, err)
+		assert.Empty(t, sections, goos)
+
+		sections, err = Files(hello, build(t, goos, "hello, world"))
+		require.NoError(t, err)
+		names := make([]string, len(sections))
+		for i, section := range sections {
+			names[i] = section.Name
+		}
+		t.Log(goos, Summary(sections))
+		assert.NotEmpty(t, names, goos)
+		assert.NotContains(t, names, FileSection, goos)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	assert.Empty(t, Diff([]byte("same"), []byte("same")))
+	assert.Equal(t, []Section{{Name: FileSection, Size: 5, OtherSize: 6}}, Diff([]byte("text1"), []byte("text22")))
+}
+
+func TestSummary(t *testing.T) {
+	sections := []Section{
+		{Name: ".text", Size: 100, OtherSize: 116},
+		{Name: ".go.buildinfo", Size: 300, OtherSize: 300},
+		{Name: ".note.extra", Size: -1, OtherSize: 24},
+		{Name: ".debug", Size: 8, OtherSize: -1},
+	}
+	assert.Equal(t, ".text (100 -> 116 bytes), .go.buildinfo, .note.extra (added, 24 bytes), .debug (removed, 8 bytes)", Summary(sections))
+}
@@ -0,0 +1,70 @@
+// Package buildenv valide les variables d'environnement et les noms de secrets
+// d'un projet, ajoutés à l'environnement des commandes de build.
+//
+// Les noms suivent la convention des shells ([A-Za-z_][A-Za-z0-9_]*). Les
+// variables fixées par le pipeline lui-même (PATH, HOME, caches Go, plateforme
+// cible) ne peuvent pas être redéfinies.
+package buildenv
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reserved liste les variables fixées par le pipeline
+var reserved = map[string]bool{
+	"PATH":        true,
+	"HOME":        true,
+	"GOMODCACHE":  true,
+	"GOCACHE":     true,
+	"GOOS":        true,
+	"GOARCH":      true,
+	"GOARM":       true,
+	"GOAMD64":     true,
+	"GOTOOLCHAIN": true,
+}
+
+// ValidateName vérifie le nom d'une variable d'environnement ou d'un secret
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid variable name %q", name)
+	}
+	if reserved[strings.ToUpper(name)] {
+		return fmt.Errorf("variable %s is set by the build pipeline and cannot be overridden", name)
+	}
+	return nil
+}
+
+// Validate vérifie les variables d'environnement d'un projet
+func Validate(env map[string]string) (map[string]string, error) {
+	vars := make(map[string]string, len(env))
+	for name, value := range env {
+		if err := ValidateName(name); err != nil {
+			return nil, err
+		}
+		if strings.ContainsRune(value, 0) {
+			return nil, fmt.Errorf("invalid value for %s: NUL character", name)
+		}
+		vars[name] = value
+	}
+	return vars, nil
+}
+
+// List retourne les variables au format NAME=value, triées par nom
+func List(env map[string]string) []string {
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]string, 0, len(names))
+	for _, name := range names {
+		list = append(list, name+"="+env[name])
+	}
+	return list
+}
@@ -0,0 +1,39 @@
+package buildenv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	env, err := Validate(map[string]string{"GOFLAGS": "-mod=vendor", "CGO_ENABLED": "0", "_PRIVATE": ""})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"CGO_ENABLED=0", "GOFLAGS=-mod=vendor", "_PRIVATE="}, List(env))
+
+	env, err = Validate(nil)
+	require.NoError(t, err)
+	assert.Empty(t, List(env))
+}
+
+func TestValidateInvalid(t *testing.T) {
+	for _, env := range []map[string]string{
+		{"1ABC": "x"},
+		{"MY-VAR": "x"},
+		{"A=B": "x"},
+		{"": "x"},
+		{"PATH": "/tmp"},
+		{"goos": "windows"},
+		{"VALUE": "a\x00b"},
+	} {
+		_, err := Validate(env)
+		assert.Error(t, err, "%v devrait être refusé", env)
+	}
+
+	assert.EqualError(t, ValidateName("HOME"), "variable HOME is set by the build pipeline and cannot be overridden")
+	// La toolchain du projet ne peut pas être remplacée par une autre, téléchargée par go
+	assert.EqualError(t, ValidateName("GOTOOLCHAIN"), "variable GOTOOLCHAIN is set by the build pipeline and cannot be overridden")
+	_, err := Validate(map[string]string{"gotoolchain": "go1.22.0"})
+	assert.Error(t, err)
+}
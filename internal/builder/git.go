@@ -0,0 +1,157 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"forgeronvirtuel/gip/internal/database"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// checkoutRevision extrait dans le dépôt cloné le commit demandé, ou à défaut
+// la branche ou le tag ref, et retourne la description du commit extrait.
+// Sans ref ni commit, le HEAD du clone est conservé.
+func checkoutRevision(repo *git.Repository, ref, commit string) (*database.CommitInfo, error) {
+	var hash *plumbing.Hash
+	var err error
+
+	switch {
+	case commit != "":
+		hash, err = repo.ResolveRevision(plumbing.Revision(commit))
+		if err != nil {
+			return nil, fmt.Errorf("Commit %s not found in the repository", commit)
+		}
+	case ref != "":
+		hash, err = resolveRef(repo, ref)
+		if err != nil {
+			return nil, fmt.Errorf("Branch or tag %s not found in the repository", ref)
+		}
+	default:
+		head, err := repo.Head()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to resolve HEAD: %w", err)
+		}
+		h := head.Hash()
+		hash = &h
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: *hash, Force: true}); err != nil {
+		return nil, fmt.Errorf("Failed to checkout %s: %w", hash, err)
+	}
+
+	c, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &database.CommitInfo{
+		SHA:     c.Hash.String(),
+		Author:  fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email),
+		Date:    c.Committer.When.UTC(),
+		Message: strings.TrimSpace(c.Message),
+	}, nil
+}
+
+// resolveRef résout le nom court d'une branche distante ou d'un tag, ou un nom
+// de référence complet (refs/...). Les branches sont prioritaires sur les tags,
+// comme pour `git clone --branch`.
+func resolveRef(repo *git.Repository, ref string) (*plumbing.Hash, error) {
+	// Le clone ne contient que la branche par défaut en local : les autres
+	// branches sont sous refs/remotes/origin
+	candidates := []string{"refs/remotes/origin/" + ref, "refs/tags/" + ref}
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		candidates = []string{"refs/remotes/origin/" + strings.TrimPrefix(ref, "refs/heads/")}
+	case strings.HasPrefix(ref, "refs/"):
+		candidates = []string{ref}
+	}
+
+	var err error
+	for _, candidate := range candidates {
+		var hash *plumbing.Hash
+		hash, err = repo.ResolveRevision(plumbing.Revision(candidate))
+		if err == nil {
+			return hash, nil
+		}
+	}
+	return nil, err
+}
+
+// describeCommit retourne le tag pointant sur un commit (vide s'il n'y en a pas)
+// et la version du commit, à la manière de `git describe --tags` : le tag s'il
+// y en a un, sinon le tag le plus proche suivi du nombre de commits depuis ce tag
+// et du SHA abrégé (v1.2.0-3-gabc1234), ou le SHA abrégé seul sans tag.
+func describeCommit(repo *git.Repository, sha string) (tag, version string, err error) {
+	hash := plumbing.NewHash(sha)
+	short := sha
+	if len(short) > 7 {
+		short = short[:7]
+	}
+
+	// Tags par commit, les tags annotés étant résolus vers leur commit
+	tags := make(map[plumbing.Hash][]string)
+	refs, err := repo.Tags()
+	if err != nil {
+		return "", "", err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		target := ref.Hash()
+		if annotated, err := repo.TagObject(target); err == nil {
+			c, err := annotated.Commit()
+			if err != nil {
+				// Tag d'un objet qui n'est pas un commit
+				return nil
+			}
+			target = c.Hash
+		}
+		tags[target] = append(tags[target], ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	commits, err := repo.Log(&git.LogOptions{From: hash})
+	if err != nil {
+		return "", "", err
+	}
+	defer commits.Close()
+
+	distance := 0
+	nearest := ""
+	err = commits.ForEach(func(c *object.Commit) error {
+		if names, ok := tags[c.Hash]; ok {
+			// Plusieurs tags sur le même commit : le premier dans l'ordre
+			// alphabétique l'emporte (v1.1.0 plutôt que v1.1.0-rc1)
+			sort.Strings(names)
+			nearest = names[0]
+			return storer.ErrStop
+		}
+		distance++
+		return nil
+	})
+	// Un clone superficiel s'arrête sur des parents absents : l'historique
+	// disponible a été parcouru en entier
+	if err != nil && !errors.Is(err, storer.ErrStop) && !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return "", "", err
+	}
+
+	switch {
+	case nearest == "":
+		return "", short, nil
+	case distance == 0:
+		return nearest, nearest, nil
+	default:
+		return "", fmt.Sprintf("%s-%d-g%s", nearest, distance, short), nil
+	}
+}
@@ -0,0 +1,135 @@
+package builder
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitCmd exécute une commande git dans dir et retourne sa sortie
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Ada", "GIT_AUTHOR_EMAIL=ada@example.com",
+		"GIT_COMMITTER_NAME=Ada", "GIT_COMMITTER_EMAIL=ada@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, output)
+	return strings.TrimSpace(string(output))
+}
+
+// commitFile écrit un fichier et le commite, puis retourne le SHA du commit
+func commitFile(t *testing.T, dir, name, content, message string) string {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	gitCmd(t, dir, "add", name)
+	gitCmd(t, dir, "commit", "-m", message)
+	return gitCmd(t, dir, "rev-parse", "HEAD")
+}
+
+func TestCheckoutRevision(t *testing.T) {
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "-b", "master")
+	first := commitFile(t, origin, "version.txt", "1", "Release v1\n\nPremière version")
+	gitCmd(t, origin, "tag", "-a", "v1", "-m", "v1")
+	second := commitFile(t, origin, "version.txt", "2", "Version 2")
+	gitCmd(t, origin, "checkout", "-b", "develop")
+	develop := commitFile(t, origin, "version.txt", "3", "Work in progress")
+	gitCmd(t, origin, "checkout", "master")
+
+	for _, tc := range []struct {
+		name, ref, commit string
+		sha, content      string
+	}{
+		{name: "HEAD du clone", sha: second, content: "2"},
+		{name: "branche", ref: "develop", sha: develop, content: "3"},
+		{name: "branche complète", ref: "refs/heads/develop", sha: develop, content: "3"},
+		{name: "tag annoté", ref: "v1", sha: first, content: "1"},
+		{name: "commit abrégé", ref: "develop", commit: first[:8], sha: first, content: "1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clone := t.TempDir()
+			repo, err := git.PlainClone(clone, &git.CloneOptions{URL: origin})
+			require.NoError(t, err)
+
+			commit, err := checkoutRevision(repo, tc.ref, tc.commit)
+			require.NoError(t, err)
+			assert.Equal(t, tc.sha, commit.SHA)
+
+			content, err := os.ReadFile(filepath.Join(clone, "version.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.content, string(content))
+		})
+	}
+
+	clone := t.TempDir()
+	repo, err := git.PlainClone(clone, &git.CloneOptions{URL: origin})
+	require.NoError(t, err)
+
+	commit, err := checkoutRevision(repo, "v1", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Ada <ada@example.com>", commit.Author)
+	assert.Equal(t, "Release v1\n\nPremière version", commit.Message)
+	assert.False(t, commit.Date.IsZero())
+
+	_, err = checkoutRevision(repo, "feature/missing", "")
+	assert.EqualError(t, err, "Branch or tag feature/missing not found in the repository")
+
+	_, err = checkoutRevision(repo, "", "deadbeef")
+	assert.EqualError(t, err, "Commit deadbeef not found in the repository")
+}
+
+func TestDescribeCommit(t *testing.T) {
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "-b", "master")
+	untagged := commitFile(t, origin, "version.txt", "0", "Initial commit")
+	first := commitFile(t, origin, "version.txt", "1", "Release v1")
+	gitCmd(t, origin, "tag", "-a", "v1.0.0", "-m", "v1.0.0")
+	gitCmd(t, origin, "tag", "v1.0.0-rc1")
+	commitFile(t, origin, "version.txt", "2", "Fix")
+	head := commitFile(t, origin, "version.txt", "3", "Feature")
+
+	repo, err := git.PlainClone(t.TempDir(), &git.CloneOptions{URL: origin})
+	require.NoError(t, err)
+
+	tag, version, err := describeCommit(repo, first)
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", tag)
+	assert.Equal(t, "v1.0.0", version)
+
+	tag, version, err = describeCommit(repo, head)
+	require.NoError(t, err)
+	assert.Empty(t, tag)
+	assert.Equal(t, "v1.0.0-2-g"+head[:7], version)
+
+	tag, version, err = describeCommit(repo, untagged)
+	require.NoError(t, err)
+	assert.Empty(t, tag)
+	assert.Equal(t, untagged[:7], version)
+}
+
+func TestDescribeCommitShallow(t *testing.T) {
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "-b", "master")
+	commitFile(t, origin, "version.txt", "1", "Release v1")
+	gitCmd(t, origin, "tag", "v1.0.0")
+	commitFile(t, origin, "version.txt", "2", "Fix")
+	head := commitFile(t, origin, "version.txt", "3", "Feature")
+
+	// L'historique s'arrête avant le tag : la version est le SHA abrégé
+	clone := filepath.Join(t.TempDir(), "clone")
+	gitCmd(t, origin, "clone", "--depth", "2", "--no-tags", "file://"+origin, clone)
+	repo, err := git.PlainOpen(clone)
+	require.NoError(t, err)
+
+	tag, version, err := describeCommit(repo, head)
+	require.NoError(t, err)
+	assert.Empty(t, tag)
+	assert.Equal(t, head[:7], version)
+}
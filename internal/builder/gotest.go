@@ -0,0 +1,123 @@
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"forgeronvirtuel/gip/internal/database"
+)
+
+// maxTestOutput est la taille maximale de la sortie conservée pour un test : au-delà,
+// seule la fin, qui contient en général l'échec, est conservée
+const maxTestOutput = 64 << 10
+
+// testEvent est un événement du flux de go test -json (voir go doc test2json)
+type testEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"`
+	Output  string  `json:"Output"`
+}
+
+// testParser est un io.Writer qui lit la sortie de go test -json. Il écrit la
+// sortie lisible des tests dans logw et regroupe les événements en un résultat par
+// test et par package.
+type testParser struct {
+	logw    io.Writer
+	line    []byte
+	results []*database.TestResult
+	byKey   map[[2]string]*database.TestResult
+}
+
+func newTestParser(logw io.Writer) *testParser {
+	return &testParser{logw: logw, byKey: make(map[[2]string]*database.TestResult)}
+}
+
+func (p *testParser) Write(b []byte) (int, error) {
+	p.line = append(p.line, b...)
+	for {
+		i := bytes.IndexByte(p.line, '\n')
+		if i < 0 {
+			break
+		}
+		p.handle(p.line[:i+1])
+		p.line = p.line[i+1:]
+	}
+	return len(b), nil
+}
+
+// Close traite une dernière ligne sans retour à la ligne
+func (p *testParser) Close() {
+	if len(p.line) > 0 {
+		p.handle(append(p.line, '\n'))
+		p.line = nil
+	}
+}
+
+func (p *testParser) handle(line []byte) {
+	var event testEvent
+	if len(line) == 0 || line[0] != '{' || json.Unmarshal(line, &event) != nil || event.Action == "" {
+		// Sortie hors du flux JSON (erreurs de go lui-même)
+		p.logw.Write(line)
+		return
+	}
+
+	switch event.Action {
+	case "build-output":
+		io.WriteString(p.logw, event.Output)
+	case "output":
+		io.WriteString(p.logw, event.Output)
+		result := p.result(event.Package, event.Test)
+		result.Output += event.Output
+		if len(result.Output) > maxTestOutput {
+			result.Output = result.Output[len(result.Output)-maxTestOutput:]
+		}
+	case "run", "start":
+		p.result(event.Package, event.Test)
+	case "pass", "fail", "skip":
+		result := p.result(event.Package, event.Test)
+		result.Status = event.Action
+		result.Duration = event.Elapsed
+	}
+}
+
+// result retourne le résultat d'un test, ou d'un package si test est vide, en le
+// créant au premier événement
+func (p *testParser) result(pkg, test string) *database.TestResult {
+	key := [2]string{pkg, test}
+	if result, ok := p.byKey[key]; ok {
+		return result
+	}
+	result := &database.TestResult{Package: pkg, Test: test}
+	p.byKey[key] = result
+	p.results = append(p.results, result)
+	return result
+}
+
+// Results retourne les résultats dans l'ordre de leur premier événement. Un test
+// sans résultat final (panic, timeout) est en échec.
+func (p *testParser) Results() []database.TestResult {
+	results := make([]database.TestResult, 0, len(p.results))
+	for _, result := range p.results {
+		if result.Status == "" {
+			result.Status = "fail"
+		}
+		results = append(results, *result)
+	}
+	return results
+}
+
+// failedPackages retourne les packages dont les tests ont échoué
+func failedPackages(results []database.TestResult) []string {
+	var packages []string
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if result.Status == "fail" && !seen[result.Package] {
+			seen[result.Package] = true
+			packages = append(packages, result.Package)
+		}
+	}
+	return packages
+}
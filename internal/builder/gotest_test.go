@@ -0,0 +1,78 @@
+package builder
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/database"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goTestOutput est une sortie de go test -json : un package avec un test réussi,
+// un test en échec et un test ignoré, un package sans tests
+const goTestOutput = `==> Running: go [test -json ./...] (in /tmp/src)
+{"Action":"start","Package":"example.com/app"}
+{"Action":"run","Package":"example.com/app","Test":"TestOK"}
+{"Action":"output","Package":"example.com/app","Test":"TestOK","Output":"=== RUN   TestOK\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestOK","Output":"--- PASS: TestOK (0.00s)\n"}
+{"Action":"pass","Package":"example.com/app","Test":"TestOK","Elapsed":0.001}
+{"Action":"run","Package":"example.com/app","Test":"TestBroken"}
+{"Action":"output","Package":"example.com/app","Test":"TestBroken","Output":"=== RUN   TestBroken\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestBroken","Output":"    app_test.go:9: want 2, got 3\n"}
+{"Action":"output","Package":"example.com/app","Test":"TestBroken","Output":"--- FAIL: TestBroken (0.02s)\n"}
+{"Action":"fail","Package":"example.com/app","Test":"TestBroken","Elapsed":0.02}
+{"Action":"run","Package":"example.com/app","Test":"TestLater"}
+{"Action":"skip","Package":"example.com/app","Test":"TestLater","Elapsed":0}
+{"Action":"output","Package":"example.com/app","Output":"FAIL\n"}
+{"Action":"fail","Package":"example.com/app","Elapsed":0.031}
+{"Action":"start","Package":"example.com/app/util"}
+{"Action":"output","Package":"example.com/app/util","Output":"?   \texample.com/app/util\t[no test files]\n"}
+{"Action":"skip","Package":"example.com/app/util","Elapsed":0}
+`
+
+func TestTestParser(t *testing.T) {
+	var logs bytes.Buffer
+	parser := newTestParser(&logs)
+
+	// Le flux arrive par morceaux qui coupent les lignes
+	for i := 0; i < len(goTestOutput); i += 50 {
+		end := min(i+50, len(goTestOutput))
+		n, err := fmt.Fprint(parser, goTestOutput[i:end])
+		require.NoError(t, err)
+		assert.Equal(t, end-i, n)
+	}
+	parser.Close()
+
+	assert.Equal(t, "==> Running: go [test -json ./...] (in /tmp/src)\n"+
+		"=== RUN   TestOK\n--- PASS: TestOK (0.00s)\n"+
+		"=== RUN   TestBroken\n    app_test.go:9: want 2, got 3\n--- FAIL: TestBroken (0.02s)\n"+
+		"FAIL\n?   \texample.com/app/util\t[no test files]\n", logs.String(), "Les logs contiennent la sortie lisible des tests")
+
+	results := parser.Results()
+	require.Len(t, results, 5)
+	assert.Equal(t, database.TestResult{Package: "example.com/app", Status: "fail", Duration: 0.031, Output: "FAIL\n"}, results[0])
+	assert.Equal(t, database.TestResult{
+		Package:  "example.com/app",
+		Test:     "TestBroken",
+		Status:   "fail",
+		Duration: 0.02,
+		Output:   "=== RUN   TestBroken\n    app_test.go:9: want 2, got 3\n--- FAIL: TestBroken (0.02s)\n",
+	}, results[2])
+	assert.Equal(t, "skip", results[3].Status)
+	assert.Equal(t, []string{"example.com/app"}, failedPackages(results))
+}
+
+func TestTestParserUnfinishedTest(t *testing.T) {
+	parser := newTestParser(&bytes.Buffer{})
+	fmt.Fprint(parser, `{"Action":"run","Package":"example.com/app","Test":"TestHangs"}`+"\n"+
+		`{"Action":"output","Package":"example.com/app","Test":"TestHangs","Output":"panic: test timed out after 1s\n"}`)
+	parser.Close()
+
+	results := parser.Results()
+	require.Len(t, results, 1)
+	assert.Equal(t, "fail", results[0].Status, "Un test interrompu est en échec")
+	assert.Equal(t, "panic: test timed out after 1s\n", results[0].Output)
+}
@@ -0,0 +1,87 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// fetchModules est la première phase d'un build hermétique : go mod download
+// télécharge les modules de go.mod, avec accès au réseau, puis go mod verify
+// vérifie les modules du cache. go.sum doit déjà contenir la somme de chaque
+// module : si go mod download le complète, le build échoue.
+// La seconde phase exécute les étapes du build hors ligne, avec hermeticEnv.
+func (p *pipeline) fetchModules(ctx context.Context) error {
+	p.startStep("fetch modules", false)
+	fmt.Fprintf(p.logw, "==> Step fetch modules\n")
+
+	goSum := filepath.Join(p.sourceDir, "go.sum")
+	before, err := os.ReadFile(goSum)
+	if err != nil && !os.IsNotExist(err) {
+		return p.endStep(err)
+	}
+
+	env := p.env(nil)
+	if err := p.runCmd(ctx, env, p.logw, "go", "mod", "download"); err != nil {
+		return p.endStep(fmt.Errorf("Failed to download modules: %v", err))
+	}
+
+	after, err := os.ReadFile(goSum)
+	if err != nil && !os.IsNotExist(err) {
+		return p.endStep(err)
+	}
+	if !bytes.Equal(before, after) {
+		return p.endStep(errors.New("go.sum is missing module checksums: run go mod tidy and commit go.sum"))
+	}
+
+	if err := p.runCmd(ctx, env, p.logw, "go", "mod", "verify"); err != nil {
+		return p.endStep(fmt.Errorf("Failed to verify modules: %v", err))
+	}
+	return p.endStep(nil)
+}
+
+// hermeticEnv ajoute à env les variables de la seconde phase d'un build
+// hermétique : aucun module n'est téléchargé, go.mod et go.sum ne peuvent pas être
+// modifiés, et les chemins du serveur n'apparaissent pas dans les binaires. Les
+// options de GOFLAGS déjà définies sont conservées, sauf -mod.
+func hermeticEnv(env []string) []string {
+	var flags []string
+	for _, variable := range env {
+		if value, found := strings.CutPrefix(variable, "GOFLAGS="); found {
+			flags = strings.Fields(value)
+		}
+	}
+	flags = slices.DeleteFunc(flags, func(flag string) bool {
+		return strings.HasPrefix(flag, "-mod=") || flag == "-trimpath"
+	})
+	flags = append(flags, "-mod=readonly", "-trimpath")
+	return append(slices.Clone(env), "GOPROXY=off", "GOFLAGS="+strings.Join(flags, " "))
+}
+
+// fetchPatterns sont les messages, en minuscules, d'une commande qui a tenté
+// d'accéder au réseau : go avec GOPROXY=off, ou tout programme sans réseau
+var fetchPatterns = []string{
+	"disabled by goproxy=off",
+	"network is unreachable",
+	"temporary failure in name resolution",
+	"could not resolve host",
+}
+
+// fetchAttempt retourne la première ligne de output qui montre une tentative
+// d'accès au réseau, vide s'il n'y en a pas
+func fetchAttempt(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		lower := strings.ToLower(line)
+		for _, pattern := range fetchPatterns {
+			if strings.Contains(lower, pattern) {
+				return strings.TrimSpace(line)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,34 @@
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHermeticEnv(t *testing.T) {
+	env := []string{"GOMODCACHE=/cache/mod", "GOFLAGS=-mod=vendor -tags=netgo", "CGO_ENABLED=0"}
+	assert.Equal(t, []string{
+		"GOMODCACHE=/cache/mod", "GOFLAGS=-mod=vendor -tags=netgo", "CGO_ENABLED=0",
+		"GOPROXY=off", "GOFLAGS=-tags=netgo -mod=readonly -trimpath",
+	}, hermeticEnv(env))
+	// env n'est pas modifié
+	assert.Len(t, env, 3)
+
+	assert.Equal(t, []string{"GOPROXY=off", "GOFLAGS=-mod=readonly -trimpath"}, hermeticEnv(nil))
+}
+
+func TestFetchAttempt(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{"==> Running: go [build]\nmain.go:3:8: module lookup disabled by GOPROXY=off\n", "main.go:3:8: module lookup disabled by GOPROXY=off"},
+		{"dial tcp: lookup proxy.golang.org on 10.0.0.53:53: dial udp 10.0.0.53:53: connect: network is unreachable", "dial tcp: lookup proxy.golang.org on 10.0.0.53:53: dial udp 10.0.0.53:53: connect: network is unreachable"},
+		{"curl: (6) Could not resolve host: example.com\n", "curl: (6) Could not resolve host: example.com"},
+		{"./main.go:3:1: syntax error\n", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, fetchAttempt(tt.output), tt.output)
+	}
+}
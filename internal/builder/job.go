@@ -0,0 +1,238 @@
+package builder
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"forgeronvirtuel/gip/internal/coverage"
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/gitauth"
+	"forgeronvirtuel/gip/internal/mainpkg"
+	"forgeronvirtuel/gip/internal/sandbox"
+	"forgeronvirtuel/gip/internal/secrets"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Job décrit tout ce qu'il faut pour exécuter le pipeline d'un build.
+// Il est sérialisable pour pouvoir être transmis à un agent distant.
+type Job struct {
+	BuildID          int               `json:"build_id"`
+	ProjectID        int               `json:"project_id"`
+	ProjectName      string            `json:"project_name"`
+	RepoURL          string            `json:"repo_url"`
+	Branch           string            `json:"branch"` // Branche ou tag à compiler
+	Commit           string            `json:"commit"` // Commit à compiler, prioritaire sur Branch
+	Subdir           string            `json:"subdir"`
+	Platforms        []string          `json:"platforms"`
+	Binaries         []mainpkg.Binary  `json:"binaries"`
+	DiscoverBinaries bool              `json:"discover_binaries"`
+	Ldflags          string            `json:"ldflags"` // Modèle -ldflags, voir le package ldflags
+	Env              map[string]string `json:"env"`     // Variables d'environnement du projet
+	Secrets          map[string]string `json:"secrets"` // Secrets déchiffrés, ajoutés à l'environnement
+	RunTests         bool              `json:"run_tests"`
+	Coverage         bool              `json:"coverage"`    // Mesure la couverture des tests
+	CloneDepth       int               `json:"clone_depth"` // Nombre de commits récupérés, 0 = tout l'historique
+	Submodules       bool              `json:"submodules"`  // Extrait récursivement les sous-modules
+	LFS              bool              `json:"lfs"`         // Remplace les pointeurs Git LFS par leurs fichiers
+	// Identifiants déchiffrés du dépôt et des modules privés, nil pour un dépôt public
+	Credentials *gitauth.Credentials `json:"credentials,omitempty"`
+	// Limites du sandbox des commandes du build, nil pour les exécuter sans sandbox
+	Sandbox *sandbox.Policy `json:"sandbox,omitempty"`
+	// Télécharge et vérifie les modules, puis exécute les étapes hors ligne
+	Hermetic bool `json:"hermetic"`
+	// Compile avec -trimpath et -buildvcs=true, datés du commit, pour que les
+	// artefacts puissent être recompilés à l'identique
+	Reproducible bool `json:"reproducible"`
+	// Build dont celui-ci vérifie la reproductibilité, 0 pour un build ordinaire
+	RebuildOf int `json:"rebuild_of,omitempty"`
+	// Version de Go épinglée, toolchain.GoMod pour suivre le go.mod, vide pour la
+	// commande go du PATH de l'exécutant
+	GoToolchain string `json:"go_toolchain,omitempty"`
+}
+
+// sensitiveValues retourne les valeurs à masquer dans les logs et les erreurs du build
+func (j *Job) sensitiveValues() []string {
+	values := make([]string, 0, len(j.Secrets))
+	for _, value := range j.Secrets {
+		values = append(values, value)
+	}
+	return append(values, j.Credentials.Sensitive()...)
+}
+
+// Result contient le résultat d'un pipeline : les étapes exécutées et si l'une
+// d'elles compile des binaires, auquel cas le build doit produire des artefacts,
+// le commit compilé, sa version et ses sous-modules, la version de Go, les
+// fichiers produits, le résultat de chaque plateforme compilée, celui des tests
+// et leur couverture, et si les étapes ont été exécutées hors ligne. Il peut
+// accompagner une erreur quand le build échoue, dès le clone.
+type Result struct {
+	Steps        []database.BuildStep      `json:"steps"`
+	HasBuildStep bool                      `json:"has_build_step"`
+	Commit       *database.CommitInfo      `json:"commit"`
+	Version      string                    `json:"version"`
+	Artifacts    []database.Artifact       `json:"artifacts"`
+	Targets      []database.BuildTarget    `json:"targets"`
+	Submodules   []database.BuildSubmodule `json:"submodules"`
+	GoVersion    string                    `json:"go_version"`
+	Tests        []database.TestResult     `json:"tests"`
+	Coverage     *coverage.Report          `json:"coverage"`
+	Hermetic     bool                      `json:"hermetic"`
+}
+
+// NewJob construit le Job d'un build à partir de son projet, avec ses secrets
+// déchiffrés par cipher. Les identifiants du dépôt ne sont transmis que si le
+// dépôt est toujours sur l'hôte pour lequel ils ont été enregistrés. L'erreur
+// retournée est destinée à être affichée à l'utilisateur.
+func NewJob(db *sql.DB, cipher *secrets.Cipher, build *database.Build) (*Job, error) {
+	project, err := database.GetProjectByID(db, build.ProjectID)
+	if err != nil {
+		log.Error().Err(err).Int("build_id", build.ID).Msg("Projet du build introuvable")
+		return nil, errors.New("Project not found")
+	}
+
+	sealed, err := database.GetProjectSecretValues(db, project.ID)
+	if err != nil {
+		log.Error().Err(err).Int("build_id", build.ID).Msg("Impossible de lire les secrets du projet")
+		return nil, errors.New("Failed to read project secrets")
+	}
+	projectSecrets := make(map[string]string, len(sealed))
+	for name, value := range sealed {
+		projectSecrets[name], err = cipher.Decrypt(project.ID, name, value)
+		if err != nil {
+			log.Error().Err(err).Int("build_id", build.ID).Str("secret", name).Msg("Impossible de déchiffrer un secret du projet")
+			return nil, errors.New("Failed to decrypt project secrets")
+		}
+	}
+
+	var credentials *gitauth.Credentials
+	stored, sealedSecret, err := database.GetProjectCredentialsSecret(db, project.ID)
+	switch {
+	case err == nil:
+		// L'URL du dépôt a pu changer depuis l'enregistrement des identifiants
+		if host := gitauth.Host(project.RepoURL); stored.Host != "" && stored.Host != host {
+			log.Warn().Int("build_id", build.ID).Str("credentials_host", stored.Host).Str("repo_host", host).Msg("Identifiants du dépôt enregistrés pour un autre hôte")
+			return nil, fmt.Errorf("Repository credentials were saved for %s, not %s: set them again", stored.Host, host)
+		}
+		credentials = &gitauth.Credentials{
+			Kind:       gitauth.Kind(stored.Kind),
+			Username:   stored.Username,
+			KnownHosts: stored.KnownHosts,
+			GoPrivate:  stored.GoPrivate,
+		}
+		credentials.Secret, err = cipher.Decrypt(project.ID, gitauth.CipherName, sealedSecret)
+		if err != nil {
+			log.Error().Err(err).Int("build_id", build.ID).Msg("Impossible de déchiffrer les identifiants du dépôt")
+			return nil, errors.New("Failed to decrypt repository credentials")
+		}
+	case err != sql.ErrNoRows:
+		log.Error().Err(err).Int("build_id", build.ID).Msg("Impossible de lire les identifiants du dépôt")
+		return nil, errors.New("Failed to read repository credentials")
+	}
+
+	return &Job{
+		BuildID:          build.ID,
+		ProjectID:        project.ID,
+		ProjectName:      project.Name,
+		RepoURL:          project.RepoURL,
+		Branch:           build.Branch,
+		Commit:           build.RequestedCommit,
+		Subdir:           project.Subdir,
+		Platforms:        project.Platforms,
+		Binaries:         project.Binaries,
+		DiscoverBinaries: project.DiscoverBinaries,
+		Ldflags:          project.Ldflags,
+		Env:              project.Env,
+		Secrets:          projectSecrets,
+		RunTests:         project.RunTests,
+		Coverage:         project.Coverage,
+		CloneDepth:       project.CloneDepth,
+		Submodules:       project.Submodules,
+		LFS:              project.LFS,
+		Credentials:      credentials,
+		Sandbox:          project.Sandbox,
+		Hermetic:         project.Hermetic,
+		Reproducible:     project.Reproducible || build.RebuildOf.Valid,
+		RebuildOf:        int(build.RebuildOf.Int64),
+		GoToolchain:      project.GoToolchain,
+	}, nil
+}
+
+// ErrBuildCancelled est la cause d'annulation du contexte d'un build annulé par l'utilisateur
+var ErrBuildCancelled = errors.New("Build cancelled")
+
+// Finish enregistre le statut final d'un build, qu'il ait été exécuté par un
+// worker local ou par un agent, puis termine son flux de logs en direct.
+// runErr est nil si le pipeline a réussi.
+func Finish(db *sql.DB, logs *LogHub, buildID int, logOutput string, result *Result, runErr error) error {
+	if result != nil && len(result.Steps) > 0 {
+		if err := database.SaveBuildSteps(db, buildID, result.Steps); err != nil {
+			return err
+		}
+	}
+	if result != nil && result.Commit != nil {
+		if err := database.SaveBuildCommit(db, buildID, result.Commit, result.Version); err != nil {
+			return err
+		}
+	}
+	if result != nil && result.GoVersion != "" {
+		if err := database.SaveBuildGoVersion(db, buildID, result.GoVersion); err != nil {
+			return err
+		}
+	}
+	if result != nil && result.Hermetic {
+		if err := database.MarkBuildHermetic(db, buildID); err != nil {
+			return err
+		}
+	}
+	if result != nil && len(result.Submodules) > 0 {
+		if err := database.SaveBuildSubmodules(db, buildID, result.Submodules); err != nil {
+			return err
+		}
+	}
+	if result != nil && len(result.Targets) > 0 {
+		if err := database.SaveBuildTargets(db, buildID, result.Targets); err != nil {
+			return err
+		}
+	}
+	if result != nil && len(result.Tests) > 0 {
+		if err := database.SaveTestResults(db, buildID, result.Tests); err != nil {
+			return err
+		}
+	}
+	if result != nil && result.Coverage != nil {
+		if err := database.SaveBuildCoverage(db, buildID, result.Coverage); err != nil {
+			return err
+		}
+	}
+
+	// Un pipeline sans étape go-build (lint, tests...) réussit sans artefact
+	if runErr == nil && (result == nil || (result.HasBuildStep && len(result.Artifacts) == 0)) {
+		runErr = errors.New("Build finished without artifacts")
+	}
+
+	var err error
+	if runErr != nil {
+		err = database.FinishBuild(db, buildID, "failed", logOutput, runErr.Error())
+	} else {
+		err = database.FinishSuccessfulBuild(db, buildID, logOutput, result.Artifacts)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Le build a pu être annulé entre temps : le flux se termine avec le statut enregistré
+	build, err := database.GetBuildByID(db, strconv.Itoa(buildID))
+	if err != nil {
+		return err
+	}
+	logs.Close(buildID, build.Status, build.Error)
+
+	// La vérification de la reproductibilité ne change pas le statut du build
+	if err := CheckReproducibility(db, build); err != nil {
+		log.Error().Err(err).Int("build_id", buildID).Msg("Erreur lors de la vérification de la reproductibilité du build")
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package builder
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// subscriberBuffer est le nombre d'événements qu'un abonné peut avoir en retard
+// avant d'être déconnecté
+const subscriberBuffer = 256
+
+// LogEvent est un événement du flux de logs d'un build : une ligne de sortie,
+// ou l'événement final portant le statut du build (Done).
+type LogEvent struct {
+	Line   string
+	Done   bool
+	Status string
+	Error  string
+}
+
+// LogHub diffuse en direct la sortie des builds en cours à leurs abonnés.
+// Il conserve l'historique des lignes des builds en cours pour que les abonnés
+// arrivés en retard reçoivent la sortie depuis le début. Un LogHub nil ne fait rien.
+type LogHub struct {
+	mu      sync.Mutex
+	streams map[int]*logStream
+}
+
+type logStream struct {
+	lines       []string
+	partial     []byte
+	subscribers map[chan LogEvent]struct{}
+}
+
+// NewLogHub crée un hub de logs vide
+func NewLogHub() *LogHub {
+	return &LogHub{streams: make(map[int]*logStream)}
+}
+
+// stream retourne le flux d'un build en le créant si besoin. h.mu doit être verrouillé.
+func (h *LogHub) stream(buildID int) *logStream {
+	s, ok := h.streams[buildID]
+	if !ok {
+		s = &logStream{subscribers: make(map[chan LogEvent]struct{})}
+		h.streams[buildID] = s
+	}
+	return s
+}
+
+// Writer retourne un io.Writer qui publie tout ce qui y est écrit dans le flux du build
+func (h *LogHub) Writer(buildID int) io.Writer {
+	return logHubWriter{hub: h, buildID: buildID}
+}
+
+type logHubWriter struct {
+	hub     *LogHub
+	buildID int
+}
+
+func (w logHubWriter) Write(p []byte) (int, error) {
+	w.hub.Publish(w.buildID, p)
+	return len(p), nil
+}
+
+// Publish ajoute de la sortie au flux d'un build. Les lignes complètes sont
+// diffusées immédiatement, une ligne incomplète attend la suite ou Close.
+func (h *LogHub) Publish(buildID int, p []byte) {
+	if h == nil || len(p) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.stream(buildID)
+	s.partial = append(s.partial, p...)
+
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(bytes.TrimSuffix(s.partial[:i], []byte("\r")))
+		s.partial = s.partial[i+1:]
+		h.send(s, LogEvent{Line: line})
+	}
+}
+
+// Close termine le flux d'un build : la ligne incomplète éventuelle est envoyée,
+// puis l'événement final avec le statut, et les abonnés sont déconnectés.
+func (h *LogHub) Close(buildID int, status, errMsg string) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.streams[buildID]
+	if !ok {
+		return
+	}
+
+	if len(s.partial) > 0 {
+		h.send(s, LogEvent{Line: string(s.partial)})
+		s.partial = nil
+	}
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- LogEvent{Done: true, Status: status, Error: errMsg}:
+		default:
+		}
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+	delete(h.streams, buildID)
+}
+
+// Reset oublie le flux d'un build remis en file d'attente : ses abonnés sont
+// déconnectés sans événement final et se réabonneront au flux de sa reprise.
+func (h *LogHub) Reset(buildID int) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.streams[buildID]
+	if !ok {
+		return
+	}
+
+	for ch := range s.subscribers {
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+	delete(h.streams, buildID)
+}
+
+// send enregistre une ligne et la diffuse aux abonnés. h.mu doit être verrouillé.
+func (h *LogHub) send(s *logStream, event LogEvent) {
+	s.lines = append(s.lines, event.Line)
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Abonné trop lent : il est déconnecté et pourra se réabonner
+			close(ch)
+			delete(s.subscribers, ch)
+		}
+	}
+}
+
+// Subscribe s'abonne au flux d'un build. Il retourne les lignes déjà produites,
+// le canal des événements suivants et une fonction de désabonnement.
+// Le canal est fermé après l'événement final, si l'abonné prend trop de retard
+// ou si le build est remis en file d'attente.
+func (h *LogHub) Subscribe(buildID int) ([]string, <-chan LogEvent, func()) {
+	ch := make(chan LogEvent, subscriberBuffer)
+	if h == nil {
+		close(ch)
+		return nil, ch, func() {}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.stream(buildID)
+	s.subscribers[ch] = struct{}{}
+	history := append([]string(nil), s.lines...)
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		if _, ok := s.subscribers[ch]; ok {
+			delete(s.subscribers, ch)
+			close(ch)
+		}
+		// Flux créé par l'abonnement d'un build qui n'a encore rien produit
+		if len(s.subscribers) == 0 && len(s.lines) == 0 && len(s.partial) == 0 && h.streams[buildID] == s {
+			delete(h.streams, buildID)
+		}
+	}
+
+	return history, ch, unsubscribe
+}
@@ -0,0 +1,100 @@
+package builder
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// collect lit les événements d'un canal jusqu'à sa fermeture
+func collect(events <-chan LogEvent) []LogEvent {
+	var collected []LogEvent
+	for event := range events {
+		collected = append(collected, event)
+	}
+	return collected
+}
+
+func TestLogHubPublishAndClose(t *testing.T) {
+	hub := NewLogHub()
+
+	fmt.Fprint(hub.Writer(1), "==> Cloning\r\n==> Running: go ")
+	history, events, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+	assert.Equal(t, []string{"==> Cloning"}, history, "Seules les lignes complètes font partie de l'historique")
+
+	fmt.Fprint(hub.Writer(1), "build\nok")
+	hub.Close(1, "success", "")
+
+	assert.Equal(t, []LogEvent{
+		{Line: "==> Running: go build"},
+		{Line: "ok"},
+		{Done: true, Status: "success"},
+	}, collect(events))
+
+	// Le flux d'un build terminé est oublié
+	history, _, unsubscribe2 := hub.Subscribe(1)
+	defer unsubscribe2()
+	assert.Empty(t, history)
+}
+
+func TestLogHubSlowSubscriberIsDisconnected(t *testing.T) {
+	hub := NewLogHub()
+	_, events, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.Publish(1, []byte("line\n"))
+	}
+
+	assert.Len(t, collect(events), subscriberBuffer, "L'abonné en retard devrait être déconnecté")
+
+	// Un nouvel abonné reçoit tout l'historique
+	history, _, unsubscribe2 := hub.Subscribe(1)
+	defer unsubscribe2()
+	assert.Len(t, history, subscriberBuffer+1)
+}
+
+func TestLogHubReset(t *testing.T) {
+	hub := NewLogHub()
+
+	fmt.Fprint(hub.Writer(1), "==> Cloning\n")
+	_, events, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+
+	hub.Reset(1)
+	assert.Empty(t, collect(events), "L'abonné devrait être déconnecté sans événement final")
+
+	// La reprise du build commence un nouveau flux
+	fmt.Fprint(hub.Writer(1), "==> Updating mirror\n")
+	history, _, unsubscribe2 := hub.Subscribe(1)
+	defer unsubscribe2()
+	assert.Equal(t, []string{"==> Updating mirror"}, history)
+}
+
+func TestLogHubUnsubscribeForgetsEmptyStream(t *testing.T) {
+	hub := NewLogHub()
+
+	_, events, unsubscribe := hub.Subscribe(7)
+	unsubscribe()
+	_, ok := <-events
+	assert.False(t, ok, "Le canal devrait être fermé au désabonnement")
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	require.Empty(t, hub.streams)
+}
+
+func TestNilLogHub(t *testing.T) {
+	var hub *LogHub
+
+	fmt.Fprint(hub.Writer(1), "ignored\n")
+	hub.Close(1, "failed", "boom")
+
+	history, events, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+	assert.Empty(t, history)
+	assert.Empty(t, collect(events))
+}
@@ -0,0 +1,762 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"forgeronvirtuel/gip/internal/buildenv"
+	"forgeronvirtuel/gip/internal/coverage"
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/gipfile"
+	"forgeronvirtuel/gip/internal/gocache"
+	"forgeronvirtuel/gip/internal/ldflags"
+	"forgeronvirtuel/gip/internal/logmask"
+	"forgeronvirtuel/gip/internal/mainpkg"
+	"forgeronvirtuel/gip/internal/platform"
+	"forgeronvirtuel/gip/internal/sandbox"
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+const (
+	// BuildTimeout est la durée maximale d'exécution d'un pipeline de build
+	BuildTimeout = 5 * time.Minute
+
+	// cmdWaitDelay est le délai laissé aux sorties d'une commande tuée pour se fermer
+	cmdWaitDelay = 5 * time.Second
+)
+
+// Run exécute le pipeline complet (clone, go mod download, go test si le projet
+// l'active, go build) dans le répertoire workspace/project-<id>/build-<bid>/src,
+// cloné depuis le miroir du dépôt dans workspace/mirrors, avec le cache Go partagé
+// cache (nil : un cache dans le dépôt, supprimé à chaque build). Les identifiants
+// du job authentifient le clone et, par le HOME des commandes, les modules privés.
+// Si le dépôt contient un fichier .gip.yml, ses étapes remplacent celles qui
+// suivent le clone. Pour un job hermétique, les modules sont d'abord téléchargés
+// et vérifiés, puis les étapes sont exécutées hors ligne (voir fetchModules).
+// Le répertoire du build est supprimé à la fin ; les artefacts d'un build réussi
+// sont déplacés dans workspace/artifacts/build-<bid>.
+// La sortie des commandes est écrite dans logw. Une étape en échec arrête le build,
+// sauf si elle est marquée continue_on_error.
+// Chaque binaire du job est compilé pour chaque plateforme ; une plateforme en
+// échec n'empêche pas de compiler les suivantes, et le Result est alors retourné
+// avec l'erreur.
+// Les valeurs sensibles du job sont remplacées par *** dans la sortie écrite dans
+// logw, dans les étapes, dans les logs des plateformes et des tests et dans
+// l'erreur retournée.
+// L'erreur retournée est destinée à être affichée à l'utilisateur.
+func Run(ctx context.Context, workspace string, cache *gocache.Cache, job *Job, logw io.Writer) (*Result, error) {
+	masker := logmask.New(job.sensitiveValues()...)
+	maskedLog := logmask.NewWriter(masker, logw)
+
+	release := cache.Acquire()
+	result, err := run(ctx, workspace, cache, job, maskedLog)
+	release()
+	maskedLog.Flush()
+
+	if result != nil {
+		for i := range result.Steps {
+			result.Steps[i].Command = masker.Mask(result.Steps[i].Command)
+			result.Steps[i].LogOutput = masker.Mask(result.Steps[i].LogOutput)
+			result.Steps[i].Error = masker.Mask(result.Steps[i].Error)
+		}
+		for i := range result.Targets {
+			result.Targets[i].LogOutput = masker.Mask(result.Targets[i].LogOutput)
+			result.Targets[i].Error = masker.Mask(result.Targets[i].Error)
+		}
+		for i := range result.Tests {
+			result.Tests[i].Output = masker.Mask(result.Tests[i].Output)
+		}
+	}
+	if err != nil {
+		err = errors.New(masker.Mask(err.Error()))
+	}
+	return result, err
+}
+
+// run exécute le pipeline de Run, sans masquer les valeurs sensibles
+func run(ctx context.Context, workspace string, cache *gocache.Cache, job *Job, logw io.Writer) (*Result, error) {
+	buildDate := time.Now().UTC()
+
+	// Always use absolute path
+	absWorkspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, errors.New("Failed to get absolute workspace path")
+	}
+
+	// Chaque build a son propre répertoire, supprimé à la fin du build : les
+	// artefacts sont déplacés dans workspace/artifacts avant
+	buildDir, err := workspacemanager.AllocateBuildDir(absWorkspace, job.ProjectID, job.BuildID)
+	if err != nil {
+		fmt.Fprintf(logw, "%v\n", err)
+		return nil, errors.New("Failed to allocate the build directory")
+	}
+	defer func() {
+		if err := buildDir.Release(); err != nil {
+			fmt.Fprintf(logw, "==> Failed to clean the build directory: %v\n", err)
+		}
+	}()
+	repoPath := buildDir.SourceDir()
+
+	result := &Result{}
+	p := &pipeline{job: job, cacheEnv: cache.Env(), logw: logw, result: result}
+	p.authEnv = []string{"HOME=" + buildDir.HomeDir()}
+
+	// Step 1: update the bare mirror of the repository, clone it into the
+	// workspace directory, then check out the requested revision: commit, else
+	// branch or tag
+	p.startStep("clone", false)
+	p.step.Command = "git clone " + job.RepoURL
+	if job.CloneDepth > 0 {
+		p.step.Command += fmt.Sprintf(" --depth %d", job.CloneDepth)
+	}
+	if job.Submodules {
+		p.step.Command += " --recurse-submodules"
+	}
+	// Les identifiants du dépôt servent au clone, puis aux commandes go et git du
+	// build pour les modules privés
+	auth, authEnv, err := job.Credentials.Setup(buildDir.HomeDir(), job.RepoURL)
+	if err != nil {
+		return result, p.endStep(fmt.Errorf("Invalid repository credentials: %v", err))
+	}
+	p.auth = auth
+	p.authEnv = append(p.authEnv, authEnv...)
+	fmt.Fprintf(p.logw, "==> Updating mirror of %s\n", job.RepoURL)
+	mirrorDir, err := workspacemanager.UpdateMirror(ctx, absWorkspace, job.RepoURL, job.CloneDepth, auth)
+	if err != nil {
+		fmt.Fprintf(p.logw, "%v\n", err)
+		return result, p.endStep(errors.New("Failed to clone repository"))
+	}
+	fmt.Fprintf(p.logw, "==> Cloning %s\n", job.RepoURL)
+	repo, err := workspacemanager.CloneFromMirror(ctx, mirrorDir, repoPath)
+	if err != nil {
+		fmt.Fprintf(p.logw, "%v\n", err)
+		return result, p.endStep(errors.New("Failed to clone repository"))
+	}
+
+	commit, err := checkoutRevision(repo, job.Branch, job.Commit)
+	if err != nil {
+		return result, p.endStep(err)
+	}
+	fmt.Fprintf(p.logw, "==> Checked out %s (%s)\n", commit.SHA, firstLine(commit.Message))
+
+	// Les fichiers Git LFS et les sous-modules font partie des sources compilées
+	if job.LFS {
+		if err := p.pullLFS(ctx, repo, repoPath, job.RepoURL, ""); err != nil {
+			return result, p.endStep(err)
+		}
+	}
+	if job.Submodules {
+		if err := p.checkoutSubmodules(ctx, absWorkspace, repo, repoPath, job.RepoURL, "", 0); err != nil {
+			return result, p.endStep(err)
+		}
+	}
+	p.endStep(nil)
+
+	// From here on, the result records the commit even if the build fails
+	result.Commit = commit
+
+	// Step 2: prepare the build (version, ldflags, pipeline, Go toolchain and
+	// sandbox) before running its first command
+	p.startStep("prepare", false)
+	tag, version, err := describeCommit(repo, commit.SHA)
+	if err != nil {
+		fmt.Fprintf(p.logw, "%v\n", err)
+		return result, p.endStep(errors.New("Failed to describe the commit version"))
+	}
+	result.Version = version
+	fmt.Fprintf(p.logw, "==> Version %s\n", version)
+
+	// Options communes à tous les go build. Un build reproductible ne dépend ni du
+	// répertoire du build, ni de sa date, ni de son ID : ceux de son build de
+	// vérification sont différents.
+	ldflagsBuildID := job.BuildID
+	if job.Reproducible {
+		p.buildFlags = append(p.buildFlags, "-trimpath", "-buildvcs=true")
+		buildDate = commit.Date.UTC()
+		if job.RebuildOf != 0 {
+			ldflagsBuildID = job.RebuildOf
+			fmt.Fprintf(p.logw, "==> Rebuild of build #%d, to verify its reproducibility\n", job.RebuildOf)
+		}
+	}
+	if job.Ldflags != "" {
+		flags, err := ldflags.Render(job.Ldflags, ldflags.Vars{
+			Commit:      commit.SHA,
+			ShortCommit: commit.SHA[:7],
+			Tag:         tag,
+			Version:     version,
+			Branch:      job.Branch,
+			BuildID:     ldflagsBuildID,
+			Date:        buildDate.Format(time.RFC3339),
+			Project:     job.ProjectName,
+		})
+		if err != nil {
+			return result, p.endStep(err)
+		}
+		p.buildFlags = append(p.buildFlags, "-ldflags="+flags)
+	}
+
+	p.sourceDir = repoPath
+	if job.Subdir != "" {
+		p.sourceDir = filepath.Join(repoPath, job.Subdir)
+	}
+
+	// Étapes du .gip.yml du projet, sinon pipeline par défaut
+	dirs := []string{p.sourceDir}
+	if p.sourceDir != repoPath {
+		dirs = append(dirs, repoPath)
+	}
+	steps, stepsFile, err := gipfile.Load(dirs...)
+	if stepsFile == "" {
+		stepsFile = gipfile.FileName
+	} else if rel, err := filepath.Rel(repoPath, stepsFile); err == nil {
+		stepsFile = rel
+	}
+	if err != nil {
+		return result, p.endStep(fmt.Errorf("Invalid %s: %v", stepsFile, err))
+	}
+	if steps == nil {
+		steps = gipfile.Default(job.RunTests)
+	} else {
+		fmt.Fprintf(p.logw, "==> Pipeline from %s (%d steps)\n", stepsFile, len(steps))
+	}
+	result.HasBuildStep = slices.ContainsFunc(steps, func(step gipfile.Step) bool {
+		return step.Uses == gipfile.UsesBuild
+	})
+
+	p.outDir = filepath.Join(repoPath, "out")
+	if err := os.MkdirAll(p.outDir, 0o755); err != nil {
+		return result, p.endStep(err)
+	}
+
+	// Toolchain Go du projet, sinon la commande go du PATH
+	toolchainDirs, err := p.selectToolchain(ctx, absWorkspace)
+	if err != nil {
+		return result, p.endStep(err)
+	}
+
+	// Dans le sandbox, les commandes ne voient que le répertoire du build, les
+	// caches et la toolchain Go
+	writable := []string{buildDir.Path}
+	if cache != nil {
+		writable = append(writable, cache.Dir())
+	}
+	if job.Sandbox != nil {
+		box := &sandbox.Sandbox{Policy: *job.Sandbox, ReadOnly: toolchainDirs, Writable: writable}
+		if err := checkSandbox(ctx, box); err != nil {
+			return result, p.endStep(err)
+		}
+		p.sandbox = box
+		fmt.Fprintf(p.logw, "==> Sandbox: %s\n", job.Sandbox.WithDefaults())
+	}
+
+	// Build hermétique : les modules sont téléchargés et vérifiés, puis toutes les
+	// étapes s'exécutent hors ligne, dans le sandbox. go mod verify ne vérifie pas
+	// le cache de compilation partagé, que tout build peut modifier : le build a le
+	// sien, et ne voit les modules vérifiés qu'en lecture seule.
+	var offline *sandbox.Sandbox
+	if job.Hermetic {
+		p.cacheEnv = []string{"GOCACHE=" + buildDir.GoCacheDir()}
+		readOnly := slices.Clone(toolchainDirs)
+		if cache != nil {
+			p.cacheEnv = append(p.cacheEnv, "GOMODCACHE="+cache.ModDir())
+			readOnly = append(readOnly, cache.ModDir())
+		}
+		offline = &sandbox.Sandbox{ReadOnly: readOnly, Writable: []string{buildDir.Path}, Offline: true}
+		if job.Sandbox != nil {
+			offline.Policy = *job.Sandbox
+		}
+		if err := checkSandbox(ctx, offline); err != nil {
+			return result, p.endStep(err)
+		}
+	}
+	p.endStep(nil)
+
+	// Les modules du projet restent dans le cache partagé, même si le build échoue
+	defer func() {
+		if err := cache.MarkUsed(filepath.Join(p.sourceDir, "go.sum")); err != nil {
+			fmt.Fprintf(logw, "==> Failed to update the Go cache: %v\n", err)
+		}
+	}()
+
+	if offline != nil {
+		if err := p.fetchModules(ctx); err != nil {
+			return result, err
+		}
+		p.sandbox = offline
+		p.hermetic = true
+		result.Hermetic = true
+		fmt.Fprintf(logw, "==> Hermetic build: offline sandbox (%s)\n", offline.Policy.WithDefaults())
+	}
+
+	for _, step := range steps {
+		if err := p.runStep(ctx, step); err != nil {
+			if !step.ContinueOnError || ctx.Err() != nil {
+				return result, err
+			}
+			fmt.Fprintf(logw, "==> Step %s failed, continuing: %v\n", step.Name, err)
+		}
+	}
+
+	for i := range result.Artifacts {
+		path, err := workspacemanager.StoreArtifact(absWorkspace, job.BuildID, result.Artifacts[i].Path)
+		if err != nil {
+			fmt.Fprintf(logw, "%v\n", err)
+			return result, fmt.Errorf("Failed to store artifact %s", result.Artifacts[i].Name)
+		}
+		result.Artifacts[i].Path = path
+	}
+
+	return result, nil
+}
+
+// maxStepOutput est la taille maximale des logs conservés pour une étape : au-delà,
+// seule la fin, qui contient en général l'échec, est conservée
+const maxStepOutput = 64 << 10
+
+// pipeline regroupe ce que partagent les étapes d'un build
+type pipeline struct {
+	job        *Job
+	goEnv      []string             // GOTOOLCHAIN=local et PATH de la toolchain Go du projet
+	cacheEnv   []string             // GOMODCACHE et GOCACHE du cache partagé
+	auth       transport.AuthMethod // Authentification go-git du dépôt, nil pour un dépôt public
+	authEnv    []string             // HOME du build et variables des identifiants du dépôt
+	sourceDir  string
+	outDir     string
+	buildFlags []string  // Options communes à tous les go build
+	logw       io.Writer // Logs du build, et de l'étape en cours
+	result     *Result
+	sandbox    *sandbox.Sandbox // Sandbox des commandes du build, nil sans sandbox
+	hermetic   bool             // Seconde phase d'un build hermétique : étapes hors ligne
+
+	// Étape en cours, ajoutée à result.Steps par endStep
+	step      *database.BuildStep
+	stepLog   bytes.Buffer
+	buildLogw io.Writer
+	limitErr  error // Première limite du sandbox atteinte par une commande de l'étape
+}
+
+// startStep commence l'enregistrement d'une étape : jusqu'à endStep, les logs
+// écrits dans p.logw et les commandes exécutées par p.runCmd lui sont attribués
+func (p *pipeline) startStep(name string, continueOnError bool) {
+	p.step = &database.BuildStep{Name: name, ContinueOnError: continueOnError, StartedAt: time.Now()}
+	p.stepLog.Reset()
+	p.limitErr = nil
+	p.buildLogw = p.logw
+	p.logw = io.MultiWriter(p.buildLogw, &p.stepLog)
+}
+
+// endStep termine l'étape en cours avec son erreur et l'ajoute au résultat.
+// Retourne err.
+func (p *pipeline) endStep(err error) error {
+	step := p.step
+	step.EndedAt = time.Now()
+	step.LogOutput = p.stepLog.String()
+	if len(step.LogOutput) > maxStepOutput {
+		step.LogOutput = step.LogOutput[len(step.LogOutput)-maxStepOutput:]
+	}
+	if err != nil {
+		step.Status = "failed"
+		step.Error = err.Error()
+		if step.ExitCode == 0 {
+			// Échec sans code de sortie : commande introuvable ou interrompue, ou erreur du pipeline
+			step.ExitCode = -1
+		}
+	} else {
+		step.Status = "success"
+		step.ExitCode = 0
+	}
+
+	p.result.Steps = append(p.result.Steps, *step)
+	p.logw = p.buildLogw
+	p.step = nil
+	return err
+}
+
+// checkSandbox vérifie que le sandbox box peut être mis en place, avant d'y
+// exécuter les commandes du build
+func checkSandbox(ctx context.Context, box *sandbox.Sandbox) error {
+	if err := box.Check(ctx); err != nil {
+		reason := err.Error()
+		var setupErr *sandbox.SetupError
+		if errors.As(err, &setupErr) {
+			reason = setupErr.Reason
+		}
+		return fmt.Errorf("Sandbox unavailable: %s", reason)
+	}
+	return nil
+}
+
+// env retourne l'environnement des commandes d'une étape : toolchain Go, cache
+// partagé, identifiants du dépôt, variables du projet, puis de l'étape, puis
+// secrets. La dernière définition l'emporte.
+func (p *pipeline) env(stepEnv map[string]string) []string {
+	env := slices.Concat(p.goEnv, p.cacheEnv, p.authEnv, buildenv.List(p.job.Env), buildenv.List(stepEnv), buildenv.List(p.job.Secrets))
+	if p.hermetic {
+		env = hermeticEnv(env)
+	}
+	return env
+}
+
+// runCmd exécute une commande avec runCmdEnv, dans le sandbox du build s'il en a
+// un, et l'ajoute à l'étape en cours, avec le code de sortie de la première
+// commande en échec
+func (p *pipeline) runCmd(ctx context.Context, env []string, w io.Writer, name string, args ...string) error {
+	err := runCmdIn(ctx, p.sandbox, p.sourceDir, env, w, name, args...)
+	var limitErr *sandbox.LimitError
+	if errors.As(err, &limitErr) && p.limitErr == nil {
+		p.limitErr = limitErr
+	}
+	if p.step != nil {
+		p.step.Command = strings.TrimPrefix(p.step.Command+"\n"+strings.Join(append([]string{name}, args...), " "), "\n")
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && p.step.ExitCode == 0 {
+			p.step.ExitCode = exitErr.ExitCode()
+		}
+	}
+	return err
+}
+
+// runStep exécute une étape dans sourceDir, dans la limite de son timeout
+func (p *pipeline) runStep(ctx context.Context, step gipfile.Step) error {
+	p.startStep(step.Name, step.ContinueOnError)
+	fmt.Fprintf(p.logw, "==> Step %s\n", step.Name)
+
+	if step.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, step.Timeout)
+		defer cancel()
+	}
+
+	env := p.env(step.Env)
+
+	var err error
+	switch step.Uses {
+	case gipfile.UsesModDownload:
+		err = p.runCmd(ctx, env, p.logw, "go", "mod", "download")
+	case gipfile.UsesTest:
+		err = p.runTests(ctx, env)
+	case gipfile.UsesBuild:
+		err = p.build(ctx, env)
+	default:
+		if err = p.runCmd(ctx, env, p.logw, step.Run[0], step.Run[1:]...); err != nil {
+			err = fmt.Errorf("Step %s failed: %v", step.Name, err)
+		}
+	}
+
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && step.Timeout > 0 {
+		err = fmt.Errorf("Step %s timed out after %s", step.Name, step.Timeout)
+	}
+	// Hors ligne, une tentative de téléchargement est la cause de l'échec
+	if err != nil && p.hermetic {
+		if line := fetchAttempt(p.stepLog.String()); line != "" {
+			err = fmt.Errorf("Step %s failed: hermetic build tried to fetch from the network: %s", step.Name, line)
+		}
+	}
+	// Une limite du sandbox atteinte est la cause de l'échec, que go build ou go
+	// test résument autrement
+	if err != nil && p.limitErr != nil {
+		err = fmt.Errorf("Step %s failed: %v", step.Name, p.limitErr)
+	}
+	return p.endStep(err)
+}
+
+// build compile chaque binaire du job pour chaque plateforme
+func (p *pipeline) build(ctx context.Context, env []string) error {
+	job, result := p.job, p.result
+
+	binaries, err := resolveBinaries(job, p.sourceDir)
+	if err != nil {
+		return err
+	}
+	for _, b := range binaries {
+		fmt.Fprintf(p.logw, "==> Binary %s (%s)\n", b.Name, b.Package)
+	}
+
+	targets, err := platform.ParseList(job.Platforms)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+
+	if len(targets) == 0 {
+		// Sans matrice, le binaire cible la plateforme de l'exécutant et garde le nom historique
+		host := platform.Platform{OS: runtime.GOOS, Arch: runtime.GOARCH}
+		if !p.buildTarget(ctx, host, binaryOutputs(binaries, p.outDir, job.BuildID, ""), env) {
+			failed = append(failed, host.String())
+		}
+	}
+
+	for _, target := range targets {
+		if ctx.Err() != nil {
+			break
+		}
+		outputs := binaryOutputs(binaries, p.outDir, job.BuildID, target.BinarySuffix())
+		if !p.buildTarget(ctx, target, outputs, append(slices.Clone(env), target.Env()...)) {
+			failed = append(failed, target.String())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("Build failed for %d of %d targets: %s", len(failed), len(result.Targets), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// resolveBinaries retourne les binaires à compiler pour un job : ceux configurés
+// pour le projet, puis les packages main découverts sous sourceDir si la découverte
+// est activée. Sans configuration, seul cmd/main.go est compilé, sous le nom du projet.
+func resolveBinaries(job *Job, sourceDir string) ([]mainpkg.Binary, error) {
+	binaries := append([]mainpkg.Binary{}, job.Binaries...)
+
+	if job.DiscoverBinaries {
+		discovered, err := mainpkg.Discover(sourceDir, job.ProjectName)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to discover main packages: %w", err)
+		}
+		for _, d := range discovered {
+			configured := false
+			for _, b := range job.Binaries {
+				if b.Package == d.Package {
+					configured = true
+					break
+				}
+			}
+			if !configured {
+				binaries = append(binaries, d)
+			}
+		}
+		if len(binaries) == 0 {
+			return nil, errors.New("No main package found in the repository")
+		}
+	}
+
+	if len(binaries) == 0 {
+		// Check if a file in "cmd/main.go" exists
+		if _, err := os.Stat(filepath.Join(sourceDir, "cmd", "main.go")); os.IsNotExist(err) {
+			return nil, errors.New("cmd/main.go not found in the repository")
+		}
+		return []mainpkg.Binary{{Package: "./cmd/main.go", Name: job.ProjectName}}, nil
+	}
+
+	// Les binaires découverts peuvent porter le nom d'un binaire configuré
+	return mainpkg.ParseList(binaries)
+}
+
+// runTests exécute go test -json ./... dans sourceDir et ajoute ses résultats au
+// résultat du build, avec la couverture si le projet la mesure. Retourne une erreur
+// si un test ou un package échoue.
+func (p *pipeline) runTests(ctx context.Context, env []string) error {
+	logw, result := p.logw, p.result
+	withCoverage := p.job.Coverage
+
+	args := []string{"test", "-json"}
+	profile := filepath.Join(p.outDir, "coverage.out")
+	if withCoverage {
+		args = append(args, "-coverprofile="+profile)
+	}
+	args = append(args, "./...")
+
+	tests := newTestParser(logw)
+	err := p.runCmd(ctx, env, tests, "go", args...)
+	tests.Close()
+	result.Tests = tests.Results()
+
+	if failed := failedPackages(result.Tests); len(failed) > 0 {
+		return fmt.Errorf("Tests failed in %s", strings.Join(failed, ", "))
+	}
+	if err != nil {
+		return errors.New("Failed to run tests")
+	}
+
+	if withCoverage {
+		report, err := readCoverage(profile)
+		if err != nil {
+			fmt.Fprintf(logw, "%v\n", err)
+			return errors.New("Failed to read the coverage profile")
+		}
+		result.Coverage = report
+		fmt.Fprintf(logw, "==> Coverage %.1f%% of statements\n", report.Total.Percent())
+	}
+	return nil
+}
+
+// readCoverage lit un profil de couverture
+func readCoverage(profile string) (*coverage.Report, error) {
+	f, err := os.Open(profile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return coverage.Parse(f)
+}
+
+// binaryOutput est un binaire à compiler et le chemin du fichier produit
+type binaryOutput struct {
+	binary mainpkg.Binary
+	path   string
+}
+
+// binaryOutputs retourne les fichiers à produire pour une plateforme, nommés
+// <binaire>-<build id><suffixe de la plateforme>
+func binaryOutputs(binaries []mainpkg.Binary, outDir string, buildID int, suffix string) []binaryOutput {
+	outputs := make([]binaryOutput, 0, len(binaries))
+	for _, b := range binaries {
+		outputs = append(outputs, binaryOutput{
+			binary: b,
+			path:   filepath.Join(outDir, fmt.Sprintf("%s-%d%s", b.Name, buildID, suffix)),
+		})
+	}
+	return outputs
+}
+
+// buildTarget compile les binaires d'une plateforme et ajoute son résultat, et ses
+// artefacts en cas de succès, au résultat du build. Un binaire en échec n'empêche
+// pas de compiler les suivants. La sortie de la compilation est écrite dans les
+// logs du build et conservée dans les logs de la plateforme.
+func (p *pipeline) buildTarget(ctx context.Context, target platform.Platform, outputs []binaryOutput, env []string) bool {
+	logw, result := p.logw, p.result
+	fmt.Fprintf(logw, "==> Target %s\n", target)
+
+	var targetLog bytes.Buffer
+	w := io.MultiWriter(logw, &targetLog)
+	outcome := database.BuildTarget{Platform: target.String(), StartedAt: time.Now()}
+
+	var artifacts []database.Artifact
+	var failed []string
+	for _, output := range outputs {
+		if ctx.Err() != nil {
+			failed = append(failed, output.binary.Name)
+			continue
+		}
+
+		args := append([]string{"build"}, p.buildFlags...)
+		args = append(args, "-o", output.path, output.binary.Package)
+		err := p.runCmd(ctx, env, w, "go", args...)
+		if err != nil {
+			failed = append(failed, output.binary.Name)
+			continue
+		}
+
+		artifact, err := database.NewArtifactFromFile(output.path, output.binary.Name, target)
+		if err != nil {
+			fmt.Fprintf(w, "%v\n", err)
+			failed = append(failed, output.binary.Name)
+			continue
+		}
+		artifacts = append(artifacts, *artifact)
+	}
+
+	outcome.EndedAt = time.Now()
+	outcome.LogOutput = targetLog.String()
+	if len(failed) > 0 {
+		outcome.Status = "failed"
+		outcome.Error = "Failed to build " + strings.Join(failed, ", ")
+	} else {
+		outcome.Status = "success"
+		result.Artifacts = append(result.Artifacts, artifacts...)
+	}
+	result.Targets = append(result.Targets, outcome)
+
+	return len(failed) == 0
+}
+
+// firstLine retourne la première ligne d'un message de commit
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return line
+}
+
+// runCmd runs a command with a controlled environment and logs its output.
+// It avoids using any shell ("bash -c") to prevent injection issues.
+func runCmd(ctx context.Context, workDir string, log io.Writer, name string, args ...string) error {
+	return runCmdEnv(ctx, workDir, nil, log, name, args...)
+}
+
+// runCmdEnv is runCmd with extra environment variables, such as GOOS/GOARCH or
+// the shared GOMODCACHE/GOCACHE, which override the defaults below.
+func runCmdEnv(ctx context.Context, workDir string, extraEnv []string, log io.Writer, name string, args ...string) error {
+	return runCmdIn(ctx, nil, workDir, extraEnv, log, name, args...)
+}
+
+// runCmdIn est runCmdEnv dans le sandbox box, nil pour exécuter la commande sans
+// sandbox. L'échec d'une commande du sandbox est remplacé par sa cause quand le
+// sandbox l'explique : voir sandbox.Sandbox.Cause.
+func runCmdIn(ctx context.Context, box *sandbox.Sandbox, workDir string, extraEnv []string, log io.Writer, name string, args ...string) error {
+	fmt.Fprintf(log, "==> Running: %s %v (in %s)\n", name, args, workDir)
+
+	// Convert to absolute path to ensure GOCACHE and GOMODCACHE are absolute
+	absWorkDir, err := filepath.Abs(workDir)
+	if err != nil {
+		return err
+	}
+
+	// Minimal, controlled environment:
+	// - PATH is kept from parent (to find git/go)
+	// - HOME is set to workDir (avoid polluting real home)
+	// - No proxy, no extra env from the parent process.
+	// When a variable is set twice, exec uses the last value.
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + absWorkDir,
+		"GOMODCACHE=" + filepath.Join(absWorkDir, ".gomodcache"),
+		"GOCACHE=" + filepath.Join(absWorkDir, ".gocache"),
+	}
+	env = append(env, extraEnv...)
+
+	// Dans le sandbox, la commande est cherchée dans le PATH de env
+	var cmd *exec.Cmd
+	if box == nil {
+		cmd = exec.CommandContext(ctx, lookPath(name, env), args...)
+	} else if cmd, err = box.Command(ctx, absWorkDir, name, args...); err != nil {
+		return err
+	}
+	cmd.Dir = absWorkDir
+	cmd.Env = env
+	killTreeOnCancel(cmd)
+	cmd.WaitDelay = cmdWaitDelay
+
+	// La fin de la sortie explique l'échec d'une commande du sandbox
+	output := &tailWriter{max: maxCauseOutput}
+	if box != nil {
+		log = io.MultiWriter(log, output)
+	}
+	cmd.Stdout = log
+	cmd.Stderr = log
+
+	err = cmd.Run()
+	if box != nil && ctx.Err() == nil {
+		return box.Cause(err, string(output.buf))
+	}
+	return err
+}
+
+// maxCauseOutput est la taille de la fin de la sortie d'une commande du sandbox
+// conservée pour expliquer son échec
+const maxCauseOutput = 8 << 10
+
+// tailWriter conserve les max derniers octets écrits
+type tailWriter struct {
+	buf []byte
+	max int
+}
+
+func (w *tailWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(b), nil
+}
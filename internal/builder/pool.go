@@ -0,0 +1,250 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"io"
+	"sync"
+	"time"
+
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/gocache"
+	"forgeronvirtuel/gip/internal/secrets"
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// pollInterval est l'intervalle auquel les workers consultent la file d'attente
+	// lorsqu'ils n'ont pas été réveillés explicitement
+	pollInterval = 2 * time.Second
+
+	// LeaseDuration est la durée d'un bail de build attribué à un agent.
+	// L'agent le renouvelle à chaque envoi de logs.
+	LeaseDuration = 2 * time.Minute
+
+	// leaseCheckInterval est l'intervalle de vérification des baux expirés
+	leaseCheckInterval = 30 * time.Second
+)
+
+// Pool est un ensemble de workers qui exécutent les builds en attente.
+// La file d'attente est la table builds elle-même : un build "pending" y reste
+// jusqu'à ce qu'un worker le réserve, ce qui lui permet de survivre à un redémarrage.
+type Pool struct {
+	db        *sql.DB
+	workspace string
+	cache     *gocache.Cache
+	workers   int
+	logs      *LogHub
+	cipher    *secrets.Cipher
+
+	// running associe aux builds en cours la fonction qui annule leur contexte
+	mu      sync.Mutex
+	running map[int]context.CancelCauseFunc
+
+	wake   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewPool crée un pool de workers. Il doit être démarré avec Start.
+// Avec 0 worker, les builds ne sont exécutés que par les agents distants.
+// Les builds partagent le cache Go cache (nil : un cache par dépôt). La sortie
+// des builds est diffusée en direct dans logs ; cipher déchiffre les secrets des
+// projets.
+func NewPool(db *sql.DB, workspace string, cache *gocache.Cache, workers int, logs *LogHub, cipher *secrets.Cipher) *Pool {
+	if workers < 0 {
+		workers = 0
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Pool{
+		db:        db,
+		workspace: workspace,
+		cache:     cache,
+		workers:   workers,
+		logs:      logs,
+		cipher:    cipher,
+		running:   make(map[int]context.CancelCauseFunc),
+		wake:      make(chan struct{}, 1),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// Start remet en file les builds interrompus par un arrêt précédent, supprime
+// leurs répertoires de travail, puis démarre les workers et la surveillance des
+// baux des agents
+func (p *Pool) Start() error {
+	requeued, err := database.RequeueInterruptedBuilds(p.db)
+	if err != nil {
+		return err
+	}
+	if requeued > 0 {
+		log.Info().Int("count", requeued).Msg("Builds interrompus remis en file d'attente")
+	}
+
+	removed, err := workspacemanager.Cleanup(p.workspace)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		log.Info().Int("count", removed).Msg("Répertoires de builds interrompus supprimés")
+	}
+
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work(i + 1)
+	}
+
+	p.wg.Add(1)
+	go p.watchLeases()
+
+	log.Info().Int("workers", p.workers).Msg("Pool de workers de build démarré")
+	return nil
+}
+
+// Stop arrête les workers et attend la fin de leur exécution.
+// Les builds en cours sont remis en attente pour être repris au prochain démarrage.
+func (p *Pool) Stop() {
+	p.cancel()
+	p.wg.Wait()
+	log.Info().Msg("Pool de workers de build arrêté")
+}
+
+// Notify réveille un worker pour qu'il consulte immédiatement la file d'attente
+func (p *Pool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Cancel annule un build exécuté par un worker du pool : son contexte est annulé,
+// ce qui tue ses processus. Retourne false si le build n'est pas en cours sur ce pool.
+func (p *Pool) Cancel(buildID int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cancel, ok := p.running[buildID]
+	if ok {
+		cancel(ErrBuildCancelled)
+	}
+	return ok
+}
+
+func (p *Pool) work(workerID int) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Vider la file tant qu'il y a des builds à exécuter
+		for p.ctx.Err() == nil {
+			build, err := database.ClaimNextBuild(p.db)
+			if err != nil {
+				log.Error().Err(err).Int("worker", workerID).Msg("Erreur lors de la lecture de la file d'attente")
+				break
+			}
+			if build == nil {
+				break
+			}
+			p.execute(workerID, build)
+		}
+
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchLeases remet régulièrement en file d'attente les builds dont l'agent a disparu
+func (p *Pool) watchLeases() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(leaseCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-ticker.C:
+			requeued, err := database.RequeueExpiredLeases(p.db)
+			if err != nil {
+				log.Error().Err(err).Msg("Erreur lors de la vérification des baux des agents")
+				continue
+			}
+			// Le build reprend depuis le début : la sortie de l'agent disparu est oubliée
+			for _, buildID := range requeued {
+				p.logs.Reset(buildID)
+			}
+			if len(requeued) > 0 {
+				log.Warn().Ints("build_ids", requeued).Msg("Builds d'agents sans nouvelles remis en file d'attente")
+				p.Notify()
+			}
+		}
+	}
+}
+
+// execute exécute le pipeline d'un build réservé et enregistre son résultat
+func (p *Pool) execute(workerID int, build *database.Build) {
+	log.Info().Int("worker", workerID).Int("build_id", build.ID).Msg("Démarrage du build")
+
+	job, err := NewJob(p.db, p.cipher, build)
+	if err != nil {
+		database.FinishBuild(p.db, build.ID, "failed", "", err.Error())
+		p.logs.Close(build.ID, "failed", err.Error())
+		return
+	}
+
+	buildCtx, cancelBuild := context.WithCancelCause(p.ctx)
+	p.mu.Lock()
+	p.running[build.ID] = cancelBuild
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.running, build.ID)
+		p.mu.Unlock()
+		cancelBuild(nil)
+	}()
+
+	// Global timeout for the whole pipeline
+	ctx, cancel := context.WithTimeout(buildCtx, BuildTimeout)
+	defer cancel()
+
+	logBuf := &bytes.Buffer{}
+	result, err := Run(ctx, p.workspace, p.cache, job, io.MultiWriter(logBuf, p.logs.Writer(build.ID)))
+
+	if context.Cause(buildCtx) == ErrBuildCancelled {
+		// Le statut "cancelled" a déjà été enregistré par l'annulation
+		log.Info().Int("build_id", build.ID).Msg("Build annulé, processus arrêtés")
+		if err := database.SaveBuildLogs(p.db, build.ID, logBuf.String()); err != nil {
+			log.Error().Err(err).Int("build_id", build.ID).Msg("Impossible d'enregistrer les logs du build annulé")
+		}
+		p.logs.Close(build.ID, "cancelled", ErrBuildCancelled.Error())
+		return
+	}
+
+	if err != nil && p.ctx.Err() != nil {
+		// Arrêt du serveur : le build sera repris au prochain démarrage
+		log.Warn().Int("build_id", build.ID).Msg("Build interrompu par l'arrêt du pool, remis en file d'attente")
+		if err := database.UpdateBuildStatus(p.db, build.ID, "pending", logBuf.String()); err != nil {
+			log.Error().Err(err).Int("build_id", build.ID).Msg("Impossible de remettre le build en file d'attente")
+		}
+		return
+	}
+
+	if err != nil {
+		log.Warn().Err(err).Int("build_id", build.ID).Msg("Build en échec")
+	}
+	if err := Finish(p.db, p.logs, build.ID, logBuf.String(), result, err); err != nil {
+		log.Error().Err(err).Int("build_id", build.ID).Msg("Impossible d'enregistrer le résultat du build")
+	}
+}
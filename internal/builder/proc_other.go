@@ -0,0 +1,9 @@
+//go:build !unix
+
+package builder
+
+import "os/exec"
+
+// killTreeOnCancel ne fait rien sur les systèmes sans groupes de processus :
+// l'annulation du contexte tue seulement la commande elle-même.
+func killTreeOnCancel(cmd *exec.Cmd) {}
@@ -0,0 +1,21 @@
+//go:build unix
+
+package builder
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killTreeOnCancel place la commande dans son propre groupe de processus pour que
+// l'annulation de son contexte tue aussi les processus qu'elle a lancés
+// (compilateur, linker, git...) et pas seulement la commande elle-même.
+func killTreeOnCancel(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
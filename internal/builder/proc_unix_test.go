@@ -0,0 +1,75 @@
+//go:build unix
+
+package builder
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processAlive indique si un processus existe encore et n'est pas un zombie
+func processAlive(pid int) bool {
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat))
+	return len(fields) > 2 && fields[2] != "Z"
+}
+
+func TestRunCmdCancelKillsProcessTree(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("/proc non disponible")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reader, writer := io.Pipe()
+	lines := make(chan string, 10)
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		// Le shell lance un processus enfant et attend sa fin
+		done <- runCmd(ctx, t.TempDir(), writer, "sh", "-c", "sleep 60 & echo $!; wait")
+		writer.Close()
+	}()
+
+	<-lines // ==> Running: ...
+	var childPID int
+	select {
+	case line := <-lines:
+		pid, err := strconv.Atoi(line)
+		require.NoError(t, err)
+		childPID = pid
+	case <-time.After(10 * time.Second):
+		t.Fatal("Le processus enfant n'a pas démarré")
+	}
+	require.True(t, processAlive(childPID))
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("runCmd ne s'est pas terminé après l'annulation")
+	}
+
+	assert.Eventually(t, func() bool { return !processAlive(childPID) }, 5*time.Second, 50*time.Millisecond,
+		"Le processus enfant devrait être tué avec la commande")
+}
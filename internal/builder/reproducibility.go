@@ -0,0 +1,132 @@
+package builder
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"forgeronvirtuel/gip/internal/bindiff"
+	"forgeronvirtuel/gip/internal/database"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CheckReproducibility poursuit la vérification de la reproductibilité d'un build
+// terminé :
+//   - un build réussi d'un projet reproductible met en file son build de
+//     vérification, qui recompile le même commit dans un autre répertoire, sur le
+//     serveur ou sur un agent ;
+//   - un build de vérification terminé compare ses artefacts à ceux du build qu'il
+//     vérifie, par leur somme SHA-256, et enregistre le résultat sur ce dernier,
+//     avec les sections des artefacts qui diffèrent.
+func CheckReproducibility(db *sql.DB, build *database.Build) error {
+	if build.RebuildOf.Valid {
+		return compareRebuild(db, build)
+	}
+	if build.Status != "success" {
+		return nil
+	}
+
+	project, err := database.GetProjectByID(db, build.ProjectID)
+	if err != nil {
+		return err
+	}
+	if !project.Reproducible {
+		return nil
+	}
+	rebuild, err := database.CreateRebuild(db, build)
+	if err != nil {
+		return err
+	}
+	log.Info().Int("build_id", build.ID).Int("rebuild_id", rebuild.ID).Msg("Build de vérification de la reproductibilité mis en file")
+	return nil
+}
+
+// compareRebuild enregistre le résultat d'un build de vérification terminé sur
+// le build qu'il vérifie
+func compareRebuild(db *sql.DB, rebuild *database.Build) error {
+	if !database.IsTerminalBuildStatus(rebuild.Status) {
+		return nil
+	}
+	buildID := int(rebuild.RebuildOf.Int64)
+
+	if rebuild.Status != "success" {
+		reason := fmt.Sprintf("Rebuild #%d %s", rebuild.ID, rebuild.Status)
+		if rebuild.Error != "" {
+			reason += ": " + rebuild.Error
+		}
+		return database.SaveReproducibility(db, buildID, database.ReproducibilityFailed, reason, nil)
+	}
+
+	artifacts, err := database.GetArtifactsByBuildID(db, buildID)
+	if err != nil {
+		return err
+	}
+	rebuilt, err := database.GetArtifactsByBuildID(db, rebuild.ID)
+	if err != nil {
+		return err
+	}
+
+	comparisons := compareArtifacts(artifacts, rebuilt)
+	var differences []string
+	for _, c := range comparisons {
+		switch {
+		case c.Identical:
+		case c.RebuildSHA256 == "":
+			differences = append(differences, c.Name+": not rebuilt")
+		default:
+			differences = append(differences, c.Name+": "+bindiff.Summary(c.Sections))
+		}
+	}
+
+	if len(differences) > 0 {
+		reason := fmt.Sprintf("%d of %d artifacts differ: %s", len(differences), len(comparisons), strings.Join(differences, "; "))
+		// Une toolchain Go différente, choisie par l'exécutant, explique les différences
+		build, err := database.GetBuildByID(db, strconv.Itoa(buildID))
+		if err != nil {
+			return err
+		}
+		if build.GoVersion != rebuild.GoVersion {
+			reason += fmt.Sprintf(" (built with %s, rebuilt with %s)", build.GoVersion, rebuild.GoVersion)
+		}
+		log.Warn().Int("build_id", buildID).Int("rebuild_id", rebuild.ID).Str("differences", reason).Msg("Build non reproductible")
+		return database.SaveReproducibility(db, buildID, database.ReproducibilityFailed, reason, comparisons)
+	}
+	log.Info().Int("build_id", buildID).Int("rebuild_id", rebuild.ID).Msg("Build reproductible")
+	return database.SaveReproducibility(db, buildID, database.ReproducibilityPassed, "", comparisons)
+}
+
+// compareArtifacts compare chaque artefact d'un build à celui du même binaire et
+// de la même plateforme produit par son build de vérification
+func compareArtifacts(artifacts, rebuilt []database.Artifact) []database.ReproducibilityArtifact {
+	comparisons := make([]database.ReproducibilityArtifact, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		c := database.ReproducibilityArtifact{
+			Name:     artifact.Name,
+			Binary:   artifact.Binary,
+			Platform: artifact.Platform().String(),
+			SHA256:   artifact.SHA256,
+			Sections: []bindiff.Section{},
+		}
+		for _, other := range rebuilt {
+			if other.Binary != artifact.Binary || other.Platform() != artifact.Platform() {
+				continue
+			}
+			c.RebuildSHA256 = other.SHA256
+			c.Identical = other.SHA256 == artifact.SHA256
+			if !c.Identical {
+				sections, err := bindiff.Files(artifact.Path, other.Path)
+				if err != nil {
+					// Un fichier supprimé entre temps : seules les sommes sont comparées
+					log.Error().Err(err).Int("build_id", artifact.BuildID).Str("artifact", artifact.Name).Msg("Impossible de comparer les sections de l'artefact")
+					sections = []bindiff.Section{{Name: bindiff.FileSection, Size: artifact.Size, OtherSize: other.Size}}
+				}
+				c.Sections = sections
+			}
+			break
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
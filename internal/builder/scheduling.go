@@ -0,0 +1,97 @@
+package builder
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/selector"
+)
+
+// maxReportedAgents limite le nombre d'agents détaillés dans une raison d'attente
+const maxReportedAgents = 5
+
+// WaitingReasons calcule les raisons d'attente des builds d'une même requête. Les
+// agents et les projets ne sont chargés qu'au premier build en attente qui en a
+// besoin, puis réutilisés pour les suivants.
+type WaitingReasons struct {
+	db       *sql.DB
+	projects map[int]*database.Project
+	agents   []database.Agent
+	loaded   bool
+}
+
+// NewWaitingReasons retourne un calcul de raisons d'attente sur db
+func NewWaitingReasons(db *sql.DB) *WaitingReasons {
+	return &WaitingReasons{db: db, projects: make(map[int]*database.Project)}
+}
+
+// Reason explique pourquoi un build en attente ne peut être attribué à aucun
+// exécutant. Retourne une chaîne vide si le build n'est pas en attente ou si un
+// exécutant disponible peut le prendre.
+func (w *WaitingReasons) Reason(build *database.Build) (string, error) {
+	if build.Status != "pending" {
+		return "", nil
+	}
+
+	project, ok := w.projects[build.ProjectID]
+	if !ok {
+		var err error
+		project, err = database.GetProjectByID(w.db, build.ProjectID)
+		if err != nil {
+			return "", err
+		}
+		w.projects[build.ProjectID] = project
+	}
+
+	sel, err := selector.Parse(project.AgentSelector)
+	if err != nil {
+		return fmt.Sprintf("Invalid agent selector %q: %v", project.AgentSelector, err), nil
+	}
+	if len(sel) == 0 {
+		return "", nil
+	}
+
+	if !w.loaded {
+		w.agents, err = database.GetAllAgents(w.db)
+		if err != nil {
+			return "", err
+		}
+		w.loaded = true
+	}
+
+	var unavailable, mismatches []string
+	for _, agent := range w.agents {
+		unsatisfied := sel.Unsatisfied(agent.Labels)
+		switch {
+		case len(unsatisfied) == 0 && agent.Status == "ONLINE":
+			// Un agent peut prendre le build : il attend simplement son tour
+			return "", nil
+		case len(unsatisfied) == 0:
+			unavailable = append(unavailable, fmt.Sprintf("%s is %s", agent.Name, agent.Status))
+		case agent.Status == "ONLINE":
+			mismatches = append(mismatches, fmt.Sprintf("%s does not satisfy %s", agent.Name, selector.Selector(unsatisfied)))
+		}
+	}
+
+	reason := fmt.Sprintf("No ONLINE agent matches selector %q", sel.String())
+	switch {
+	case len(unavailable) > 0:
+		reason += ": matching agents are unavailable (" + joinReported(unavailable) + ")"
+	case len(mismatches) > 0:
+		reason += " (" + joinReported(mismatches) + ")"
+	default:
+		reason += ": no agent is ONLINE"
+	}
+
+	return reason, nil
+}
+
+// joinReported concatène les détails en se limitant à maxReportedAgents entrées
+func joinReported(details []string) string {
+	if len(details) <= maxReportedAgents {
+		return strings.Join(details, "; ")
+	}
+	return fmt.Sprintf("%s; and %d more", strings.Join(details[:maxReportedAgents], "; "), len(details)-maxReportedAgents)
+}
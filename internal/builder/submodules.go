@@ -0,0 +1,181 @@
+package builder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/gitlfs"
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// maxSubmoduleDepth limite l'imbrication des sous-modules, qui pourraient former un cycle
+const maxSubmoduleDepth = 8
+
+// checkoutSubmodules extrait récursivement les sous-modules du dépôt repo, cloné
+// depuis repoURL dans dir, aux commits enregistrés par le dépôt, et les ajoute à
+// p.result.Submodules. prefix est le chemin de dir dans le dépôt du build.
+// Chaque sous-module est cloné depuis son propre miroir, comme le dépôt du projet,
+// puis ses pointeurs Git LFS sont remplacés si le projet l'active.
+func (p *pipeline) checkoutSubmodules(ctx context.Context, workspace string, repo *git.Repository, dir, repoURL, prefix string, level int) error {
+	modules, err := readGitmodules(dir)
+	if err != nil || len(modules) == 0 {
+		return err
+	}
+	if level >= maxSubmoduleDepth {
+		return fmt.Errorf("Submodules nested more than %d levels deep in %s", maxSubmoduleDepth, prefix)
+	}
+
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	for _, module := range modules {
+		name := path.Join(prefix, module.Path)
+		entry, err := idx.Entry(module.Path)
+		if err != nil || entry.Mode != filemode.Submodule {
+			// Déclaré dans .gitmodules mais absent du commit, comme git submodule update
+			fmt.Fprintf(p.logw, "==> Skipping submodule %s: not recorded in the commit\n", name)
+			continue
+		}
+
+		url, err := resolveSubmoduleURL(repoURL, module.URL)
+		if err != nil {
+			return fmt.Errorf("Invalid URL for submodule %s: %v", name, err)
+		}
+		if isLocalURL(url) && !isLocalURL(p.job.RepoURL) {
+			// Comme git (protocol.file.allow=user), un dépôt distant ne peut pas
+			// désigner un dépôt local de l'exécutant
+			return fmt.Errorf("Submodule %s: local repository %s is not allowed", name, url)
+		}
+		fmt.Fprintf(p.logw, "==> Submodule %s (%s) at %s\n", name, url, entry.Hash)
+
+		auth := p.authFor(url)
+		mirrorDir, err := workspacemanager.UpdateMirror(ctx, workspace, url, 0, auth)
+		if err != nil {
+			fmt.Fprintf(p.logw, "%v\n", err)
+			return fmt.Errorf("Failed to clone submodule %s", name)
+		}
+		subDir := filepath.Join(dir, filepath.FromSlash(module.Path))
+		if err := os.RemoveAll(subDir); err != nil {
+			return err
+		}
+		subRepo, err := workspacemanager.CloneFromMirror(ctx, mirrorDir, subDir)
+		if err != nil {
+			fmt.Fprintf(p.logw, "%v\n", err)
+			return fmt.Errorf("Failed to clone submodule %s", name)
+		}
+		if _, err := checkoutRevision(subRepo, "", entry.Hash.String()); err != nil {
+			return fmt.Errorf("Submodule %s: %v", name, err)
+		}
+		p.result.Submodules = append(p.result.Submodules, database.BuildSubmodule{Path: name, URL: url, SHA: entry.Hash.String()})
+
+		if p.job.LFS {
+			if err := p.pullLFS(ctx, subRepo, subDir, url, name); err != nil {
+				return err
+			}
+		}
+		if err := p.checkoutSubmodules(ctx, workspace, subRepo, subDir, url, name, level+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pullLFS remplace les pointeurs Git LFS du dépôt repo, cloné depuis url dans dir
+func (p *pipeline) pullLFS(ctx context.Context, repo *git.Repository, dir, url, name string) error {
+	n, err := gitlfs.Pull(ctx, repo, dir, url, p.authFor(url))
+	if err != nil {
+		fmt.Fprintf(p.logw, "%v\n", err)
+		if name == "" {
+			return errors.New("Failed to fetch LFS objects")
+		}
+		return fmt.Errorf("Failed to fetch LFS objects of submodule %s", name)
+	}
+	if n > 0 {
+		fmt.Fprintf(p.logw, "==> %d LFS files fetched\n", n)
+	}
+	return nil
+}
+
+// authFor retourne l'authentification du dépôt du projet pour le dépôt url, nil
+// s'il est sur un autre hôte : les identifiants du projet ne sont envoyés qu'à
+// son hébergeur
+func (p *pipeline) authFor(url string) transport.AuthMethod {
+	if p.auth == nil {
+		return nil
+	}
+	project, err := transport.NewEndpoint(p.job.RepoURL)
+	if err != nil {
+		return nil
+	}
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil || endpoint.Scheme != project.Scheme || endpoint.Host != project.Host {
+		return nil
+	}
+	return p.auth
+}
+
+// readGitmodules lit les sous-modules déclarés dans le fichier .gitmodules de dir,
+// triés par chemin
+func readGitmodules(dir string) ([]*config.Submodule, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitmodules"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	modules := config.NewModules()
+	if err := modules.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("Invalid .gitmodules: %v", err)
+	}
+	list := make([]*config.Submodule, 0, len(modules.Submodules))
+	for _, module := range modules.Submodules {
+		if err := module.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid .gitmodules: submodule %s: %v", module.Name, err)
+		}
+		if !filepath.IsLocal(filepath.FromSlash(module.Path)) {
+			return nil, fmt.Errorf("Invalid .gitmodules: submodule %s: path %s is outside the repository", module.Name, module.Path)
+		}
+		list = append(list, module)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+// resolveSubmoduleURL résout l'URL d'un sous-module : une URL relative (./ ou ../)
+// l'est par rapport à l'URL du dépôt parent, comme le fait git
+func resolveSubmoduleURL(parentURL, url string) (string, error) {
+	if !strings.HasPrefix(url, "./") && !strings.HasPrefix(url, "../") {
+		return url, nil
+	}
+	endpoint, err := transport.NewEndpoint(parentURL)
+	if err != nil {
+		return "", err
+	}
+	if endpoint.Scheme == "file" && !strings.HasPrefix(parentURL, "file://") {
+		return filepath.Join(parentURL, url), nil
+	}
+	endpoint.Path = path.Join(endpoint.Path, url)
+	return endpoint.String(), nil
+}
+
+// isLocalURL indique si url désigne un dépôt local (chemin ou file://)
+func isLocalURL(url string) bool {
+	endpoint, err := transport.NewEndpoint(url)
+	return err == nil && endpoint.Scheme == "file"
+}
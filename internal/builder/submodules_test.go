@@ -0,0 +1,131 @@
+package builder
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/database"
+
+	"github.com/go-git/go-git/v6"
+	githttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSubmoduleURL(t *testing.T) {
+	tests := []struct {
+		parent, url, expected string
+	}{
+		{"https://github.com/acme/app.git", "https://github.com/acme/proto.git", "https://github.com/acme/proto.git"},
+		{"https://github.com/acme/app.git", "../proto.git", "https://github.com/acme/proto.git"},
+		{"https://github.com/acme/app.git", "./vendor/lib.git", "https://github.com/acme/app.git/vendor/lib.git"},
+		{"ssh://git@github.com/acme/app.git", "../../other/proto.git", "ssh://git@github.com/other/proto.git"},
+		{"/srv/git/app", "../lib", "/srv/git/lib"},
+	}
+	for _, tt := range tests {
+		url, err := resolveSubmoduleURL(tt.parent, tt.url)
+		require.NoError(t, err, tt.url)
+		assert.Equal(t, tt.expected, url, tt.url)
+	}
+}
+
+func TestReadGitmodules(t *testing.T) {
+	dir := t.TempDir()
+	modules, err := readGitmodules(dir)
+	require.NoError(t, err)
+	assert.Empty(t, modules)
+
+	gitmodules := `[submodule "proto"]
+	path = api/proto
+	url = ../proto.git
+[submodule "lib"]
+	path = lib
+	url = https://github.com/acme/lib.git
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(gitmodules), 0o644))
+	modules, err = readGitmodules(dir)
+	require.NoError(t, err)
+	require.Len(t, modules, 2)
+	assert.Equal(t, "api/proto", modules[0].Path)
+	assert.Equal(t, "lib", modules[1].Path)
+
+	// Les chemins hors du dépôt sont ignorés par go-git (..) ou refusés
+	gitmodules = "[submodule \"evil\"]\n\tpath = lib/../../outside\n\turl = https://github.com/acme/evil.git\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(gitmodules), 0o644))
+	modules, err = readGitmodules(dir)
+	require.NoError(t, err)
+	assert.Empty(t, modules)
+
+	gitmodules = "[submodule \"evil\"]\n\tpath = /etc\n\turl = https://github.com/acme/evil.git\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitmodules"), []byte(gitmodules), 0o644))
+	_, err = readGitmodules(dir)
+	assert.ErrorContains(t, err, "outside the repository")
+}
+
+func TestAuthFor(t *testing.T) {
+	auth := &githttp.BasicAuth{Username: "ci", Password: "token"}
+	p := &pipeline{job: &Job{RepoURL: "https://git.example.com/acme/app.git"}, auth: auth}
+
+	assert.Equal(t, auth, p.authFor("https://git.example.com/acme/proto.git"))
+	// Les identifiants ne sont pas envoyés à un autre hôte
+	assert.Nil(t, p.authFor("https://github.com/acme/proto.git"))
+	assert.Nil(t, p.authFor("http://git.example.com/acme/proto.git"))
+
+	p.auth = nil
+	assert.Nil(t, p.authFor("https://git.example.com/acme/proto.git"))
+}
+
+func TestCheckoutSubmodules(t *testing.T) {
+	root := t.TempDir()
+
+	// lib contient lui-même le sous-module proto
+	proto := filepath.Join(root, "proto")
+	require.NoError(t, os.Mkdir(proto, 0o755))
+	gitCmd(t, proto, "init", "-b", "master")
+	protoSHA := commitFile(t, proto, "api.proto", "v1", "Initial proto")
+
+	lib := filepath.Join(root, "lib")
+	require.NoError(t, os.Mkdir(lib, 0o755))
+	gitCmd(t, lib, "init", "-b", "master")
+	commitFile(t, lib, "lib.go", "package lib", "Initial lib")
+	gitCmd(t, lib, "-c", "protocol.file.allow=always", "submodule", "add", "../proto", "proto")
+	gitCmd(t, lib, "commit", "-m", "Add proto")
+	libSHA := gitCmd(t, lib, "rev-parse", "HEAD")
+
+	app := filepath.Join(root, "app")
+	require.NoError(t, os.Mkdir(app, 0o755))
+	gitCmd(t, app, "init", "-b", "master")
+	commitFile(t, app, "main.go", "package main", "Initial app")
+	gitCmd(t, app, "-c", "protocol.file.allow=always", "submodule", "add", "../lib", "third_party/lib")
+	gitCmd(t, app, "commit", "-m", "Add lib")
+
+	// Un commit ultérieur de lib n'est pas celui enregistré par app
+	commitFile(t, lib, "lib.go", "package lib // v2", "Update lib")
+
+	dir := filepath.Join(root, "checkout")
+	repo, err := git.PlainClone(dir, &git.CloneOptions{URL: app})
+	require.NoError(t, err)
+
+	p := &pipeline{job: &Job{RepoURL: app}, logw: io.Discard, result: &Result{}}
+	require.NoError(t, p.checkoutSubmodules(context.Background(), filepath.Join(root, "workspace"), repo, dir, app, "", 0))
+
+	assert.Equal(t, []database.BuildSubmodule{
+		{Path: "third_party/lib", URL: lib, SHA: libSHA},
+		{Path: "third_party/lib/proto", URL: proto, SHA: protoSHA},
+	}, p.result.Submodules)
+
+	content, err := os.ReadFile(filepath.Join(dir, "third_party", "lib", "lib.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package lib", string(content))
+	content, err = os.ReadFile(filepath.Join(dir, "third_party", "lib", "proto", "api.proto"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1", string(content))
+
+	// Un dépôt distant ne peut pas désigner un dépôt local comme sous-module
+	p = &pipeline{job: &Job{RepoURL: "https://git.example.com/acme/app.git"}, logw: io.Discard, result: &Result{}}
+	err = p.checkoutSubmodules(context.Background(), filepath.Join(root, "workspace"), repo, dir, app, "", 0)
+	assert.ErrorContains(t, err, "local repository")
+}
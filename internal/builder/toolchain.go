@@ -0,0 +1,79 @@
+package builder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"forgeronvirtuel/gip/internal/toolchain"
+)
+
+// selectToolchain choisit la toolchain Go du build parmi celles du workspace,
+// selon le réglage du projet et le go.mod de sourceDir, puis enregistre dans le
+// résultat la version de Go que ses commandes exécutent. Sans réglage, la commande
+// go du PATH est utilisée. Dans les deux cas, GOTOOLCHAIN=local. Retourne les
+// répertoires de la toolchain, que le sandbox doit rendre visibles.
+func (p *pipeline) selectToolchain(ctx context.Context, workspace string) ([]string, error) {
+	request, err := toolchain.Resolve(p.job.GoToolchain, filepath.Join(p.sourceDir, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid Go toolchain: %v", err)
+	}
+
+	// Aucune commande go du build ne télécharge une autre toolchain pour suivre le
+	// go.mod, même sans toolchain épinglée
+	p.goEnv = []string{"GOTOOLCHAIN=local"}
+	var readOnly []string
+	location := "the PATH"
+	if request != nil {
+		t, err := toolchain.Select(toolchain.Dir(workspace), *request)
+		if err != nil {
+			return nil, err
+		}
+		// La commande go de la toolchain passe devant celle du PATH
+		p.goEnv = append(p.goEnv, "PATH="+t.Bin()+string(os.PathListSeparator)+os.Getenv("PATH"))
+		readOnly = append(readOnly, t.Root)
+		location = t.Root
+		fmt.Fprintf(p.logw, "==> Go toolchain %s required by %s\n", request, request.Source)
+	}
+
+	var output bytes.Buffer
+	if err := runCmdEnv(ctx, p.sourceDir, p.env(nil), io.MultiWriter(p.logw, &output), "go", "env", "GOVERSION"); err != nil {
+		fmt.Fprintf(p.logw, "%v\n", err)
+		return nil, errors.New("Failed to run the Go toolchain")
+	}
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	p.result.GoVersion = strings.TrimSpace(lines[len(lines)-1])
+	fmt.Fprintf(p.logw, "==> Go %s from %s\n", p.result.GoVersion, location)
+	return readOnly, nil
+}
+
+// lookPath cherche la commande name dans le PATH de l'environnement env de la
+// commande, plutôt que dans celui du serveur : celui d'un build commence par la
+// toolchain Go de son projet. Retourne name si elle n'y est pas, ou si name est
+// un chemin.
+func lookPath(name string, env []string) string {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		return name
+	}
+	var path string
+	for _, variable := range env {
+		if value, ok := strings.CutPrefix(variable, "PATH="); ok {
+			path = value
+		}
+	}
+	for _, dir := range filepath.SplitList(path) {
+		if dir == "" {
+			continue
+		}
+		if found, err := exec.LookPath(filepath.Join(dir, name)); err == nil {
+			return found
+		}
+	}
+	return name
+}
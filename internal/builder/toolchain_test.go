@@ -0,0 +1,32 @@
+package builder
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Les commandes Windows sont cherchées avec leur extension")
+	}
+	toolchainBin := t.TempDir()
+	systemBin := t.TempDir()
+	for _, dir := range []string{toolchainBin, systemBin} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "go"), []byte("#!/bin/sh\n"), 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(systemBin, "git"), []byte("#!/bin/sh\n"), 0o755))
+	// Un fichier non exécutable n'est pas une commande
+	require.NoError(t, os.WriteFile(filepath.Join(toolchainBin, "git"), []byte(""), 0o644))
+
+	// La dernière définition de PATH l'emporte, comme pour exec
+	env := []string{"PATH=" + systemBin, "HOME=/tmp", "PATH=" + toolchainBin + string(os.PathListSeparator) + systemBin}
+	assert.Equal(t, filepath.Join(toolchainBin, "go"), lookPath("go", env))
+	assert.Equal(t, filepath.Join(systemBin, "git"), lookPath("git", env))
+	assert.Equal(t, "gip-missing-command", lookPath("gip-missing-command", env))
+	assert.Equal(t, "./scripts/build.sh", lookPath("./scripts/build.sh", env))
+}
@@ -0,0 +1,133 @@
+// Package coverage lit les profils de couverture produits par
+// go test -coverprofile et les résume par fichier, par package et au total.
+//
+// Un profil contient une ligne par bloc de code :
+//
+//	mode: set
+//	example.com/app/calc/calc.go:3.24,5.2 1 1
+//
+// soit le fichier, la position du bloc, son nombre d'instructions et le nombre
+// de fois où il a été exécuté. Un bloc présent plusieurs fois (packages testés
+// par plusieurs binaires de test) n'est compté qu'une fois.
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry est la couverture d'un fichier, d'un package (File vide) ou du build
+// entier (Package et File vides)
+type Entry struct {
+	Package    string `json:"package"`
+	File       string `json:"file"`
+	Statements int    `json:"statements"` // Nombre d'instructions
+	Covered    int    `json:"covered"`    // Nombre d'instructions exécutées au moins une fois
+}
+
+// Percent retourne le pourcentage d'instructions couvertes, 0 sans instruction
+func (e Entry) Percent() float64 {
+	if e.Statements == 0 {
+		return 0
+	}
+	return float64(e.Covered) * 100 / float64(e.Statements)
+}
+
+// Report est la couverture d'un build
+type Report struct {
+	Total    Entry   `json:"total"`
+	Packages []Entry `json:"packages"` // Triés par package
+	Files    []Entry `json:"files"`    // Triés par fichier
+}
+
+// block est un bloc de code d'un profil
+type block struct {
+	statements int
+	covered    bool
+}
+
+// Parse lit un profil de couverture
+func Parse(r io.Reader) (*Report, error) {
+	blocks := make(map[string]map[string]*block) // fichier -> position -> bloc
+
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if lineNumber == 1 {
+			if !strings.HasPrefix(line, "mode: ") {
+				return nil, fmt.Errorf("invalid coverage profile: missing mode line")
+			}
+			continue
+		}
+
+		// fichier:début,fin instructions exécutions
+		colon := strings.LastIndex(line, ":")
+		fields := strings.Fields(line[colon+1:])
+		if colon <= 0 || len(fields) != 3 {
+			return nil, fmt.Errorf("invalid coverage profile line %d: %q", lineNumber, line)
+		}
+		statements, err1 := strconv.Atoi(fields[1])
+		count, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || statements < 0 || count < 0 {
+			return nil, fmt.Errorf("invalid coverage profile line %d: %q", lineNumber, line)
+		}
+
+		file := line[:colon]
+		if blocks[file] == nil {
+			blocks[file] = make(map[string]*block)
+		}
+		b, ok := blocks[file][fields[0]]
+		if !ok {
+			b = &block{statements: statements}
+			blocks[file][fields[0]] = b
+		}
+		b.covered = b.covered || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lineNumber == 0 {
+		return nil, fmt.Errorf("invalid coverage profile: empty file")
+	}
+
+	report := &Report{Packages: []Entry{}, Files: []Entry{}}
+	packages := make(map[string]*Entry)
+	for file, fileBlocks := range blocks {
+		entry := Entry{Package: path.Dir(file), File: file}
+		for _, b := range fileBlocks {
+			entry.Statements += b.statements
+			if b.covered {
+				entry.Covered += b.statements
+			}
+		}
+		report.Files = append(report.Files, entry)
+
+		pkg, ok := packages[entry.Package]
+		if !ok {
+			pkg = &Entry{Package: entry.Package}
+			packages[entry.Package] = pkg
+		}
+		pkg.Statements += entry.Statements
+		pkg.Covered += entry.Covered
+
+		report.Total.Statements += entry.Statements
+		report.Total.Covered += entry.Covered
+	}
+	for _, pkg := range packages {
+		report.Packages = append(report.Packages, *pkg)
+	}
+
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].File < report.Files[j].File })
+	sort.Slice(report.Packages, func(i, j int) bool { return report.Packages[i].Package < report.Packages[j].Package })
+	return report, nil
+}
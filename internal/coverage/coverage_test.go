@@ -0,0 +1,49 @@
+package coverage
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profile = `mode: set
+example.com/app/calc/calc.go:3.24,5.2 1 1
+example.com/app/calc/calc.go:7.24,9.16 2 0
+example.com/app/calc/calc.go:9.16,11.3 1 1
+example.com/app/calc/div.go:3.24,5.2 4 0
+example.com/app/main.go:5.13,7.2 2 0
+example.com/app/calc/calc.go:7.24,9.16 2 3
+`
+
+func TestParse(t *testing.T) {
+	report, err := Parse(strings.NewReader(profile))
+	require.NoError(t, err)
+
+	// Le bloc présent deux fois est couvert par la seconde exécution
+	assert.Equal(t, []Entry{
+		{Package: "example.com/app/calc", File: "example.com/app/calc/calc.go", Statements: 4, Covered: 4},
+		{Package: "example.com/app/calc", File: "example.com/app/calc/div.go", Statements: 4, Covered: 0},
+		{Package: "example.com/app", File: "example.com/app/main.go", Statements: 2, Covered: 0},
+	}, report.Files)
+	assert.Equal(t, []Entry{
+		{Package: "example.com/app", Statements: 2, Covered: 0},
+		{Package: "example.com/app/calc", Statements: 8, Covered: 4},
+	}, report.Packages)
+	assert.Equal(t, Entry{Statements: 10, Covered: 4}, report.Total)
+	assert.Equal(t, 40.0, report.Total.Percent())
+	assert.Equal(t, 0.0, Entry{}.Percent())
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		"",
+		"example.com/app/main.go:5.13,7.2 2 0\n",
+		"mode: set\nexample.com/app/main.go 2 0\n",
+		"mode: set\nexample.com/app/main.go:5.13,7.2 two 0\n",
+	} {
+		_, err := Parse(strings.NewReader(invalid))
+		assert.Error(t, err, "%q devrait être refusé", invalid)
+	}
+}
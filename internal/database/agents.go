@@ -1,7 +1,11 @@
 package database
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"time"
 
@@ -27,6 +31,7 @@ func CreateAgentsTable(db *sql.DB) error {
 		labels TEXT NOT NULL DEFAULT '{}',
 		status TEXT NOT NULL DEFAULT 'OFFLINE' CHECK(status IN ('ONLINE', 'OFFLINE', 'DRAINING')),
 		last_seen_at DATETIME,
+		token_hash TEXT NOT NULL DEFAULT '',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
 	CREATE INDEX IF NOT EXISTS idx_agents_status ON agents(status);
@@ -38,6 +43,12 @@ func CreateAgentsTable(db *sql.DB) error {
 		return err
 	}
 
+	// Migration des bases créées avant l'authentification des agents
+	if err := addColumnIfMissing(db, "agents", "token_hash", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la migration de la table agents")
+		return err
+	}
+
 	log.Info().Msg("Table 'agents' créée ou déjà existante")
 	return nil
 }
@@ -275,6 +286,50 @@ func UpdateAgentHeartbeat(db *sql.DB, id int) error {
 	return err
 }
 
+// IssueAgentToken génère un nouveau jeton d'authentification pour l'agent, qui
+// remplace le précédent. Seule son empreinte est conservée : le jeton retourné ne
+// peut plus être relu.
+func IssueAgentToken(db *sql.DB, id int) (string, error) {
+	token := rand.Text()
+	if _, err := db.Exec("UPDATE agents SET token_hash = ? WHERE id = ?", hashAgentToken(token), id); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// AgentHasToken indique si un jeton a déjà été délivré à l'agent. Les agents
+// enregistrés avant l'authentification des agents n'en ont pas.
+func AgentHasToken(db *sql.DB, id int) (bool, error) {
+	var hash string
+	if err := db.QueryRow("SELECT token_hash FROM agents WHERE id = ?", id).Scan(&hash); err != nil {
+		return false, err
+	}
+	return hash != "", nil
+}
+
+// AuthenticateAgent vérifie le jeton présenté par un agent. Un agent sans jeton
+// ne peut pas être authentifié.
+func AuthenticateAgent(db *sql.DB, id int, token string) (bool, error) {
+	var hash string
+	err := db.QueryRow("SELECT token_hash FROM agents WHERE id = ?", id).Scan(&hash)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if hash == "" || token == "" {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(hashAgentToken(token))) == 1, nil
+}
+
+// hashAgentToken retourne l'empreinte SHA-256 d'un jeton d'agent, en hexadécimal
+func hashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // DeleteAgent supprime un agent
 func DeleteAgent(db *sql.DB, id int) error {
 	query := `DELETE FROM agents WHERE id = ?`
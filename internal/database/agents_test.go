@@ -207,3 +207,47 @@ func TestAgentUniqueNameConstraint(t *testing.T) {
 	_, err := CreateAgent(db, "unique-agent", map[string]string{})
 	assert.Error(t, err)
 }
+
+func TestAgentToken(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	agent, err := CreateAgent(db, "token-agent", nil)
+	require.NoError(t, err)
+
+	// Un agent sans jeton ne peut pas être authentifié
+	hasToken, err := AgentHasToken(db, agent.ID)
+	require.NoError(t, err)
+	assert.False(t, hasToken)
+	ok, err := AuthenticateAgent(db, agent.ID, "")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	token, err := IssueAgentToken(db, agent.ID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	hasToken, err = AgentHasToken(db, agent.ID)
+	require.NoError(t, err)
+	assert.True(t, hasToken)
+
+	ok, err = AuthenticateAgent(db, agent.ID, token)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, _ = AuthenticateAgent(db, agent.ID, token+"x")
+	assert.False(t, ok)
+	ok, _ = AuthenticateAgent(db, agent.ID+1, token)
+	assert.False(t, ok)
+
+	// Seule l'empreinte du jeton est enregistrée
+	var stored string
+	require.NoError(t, db.QueryRow("SELECT token_hash FROM agents WHERE id = ?", agent.ID).Scan(&stored))
+	assert.NotContains(t, stored, token)
+
+	// Un nouveau jeton remplace le précédent
+	renewed, err := IssueAgentToken(db, agent.ID)
+	require.NoError(t, err)
+	ok, _ = AuthenticateAgent(db, agent.ID, token)
+	assert.False(t, ok)
+	ok, _ = AuthenticateAgent(db, agent.ID, renewed)
+	assert.True(t, ok)
+}
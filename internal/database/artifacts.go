@@ -0,0 +1,297 @@
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"forgeronvirtuel/gip/internal/platform"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Artifact représente un fichier produit par un build
+type Artifact struct {
+	ID          int       `json:"id"`
+	BuildID     int       `json:"build_id"`
+	Name        string    `json:"name"`
+	Binary      string    `json:"binary"` // Nom du binaire configuré pour le projet, vide pour les anciens builds
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+	Variant     string    `json:"variant"` // GOARM (v7) ou GOAMD64 (v3), vide par défaut
+	ContentType string    `json:"content_type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// artifactColumns liste les colonnes lues par scanArtifact, dans le même ordre
+const artifactColumns = `id, build_id, name, binary, path, size, sha256, os, arch, variant, content_type, created_at`
+
+// scanArtifact lit une ligne de la table artifacts sélectionnée avec artifactColumns
+func scanArtifact(row rowScanner) (*Artifact, error) {
+	artifact := &Artifact{}
+	err := row.Scan(&artifact.ID, &artifact.BuildID, &artifact.Name, &artifact.Binary, &artifact.Path, &artifact.Size, &artifact.SHA256, &artifact.OS, &artifact.Arch, &artifact.Variant, &artifact.ContentType, &artifact.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// CreateArtifactsTable crée la table artifacts si elle n'existe pas, puis y migre
+// les binaires des builds enregistrés avant son ajout
+func CreateArtifactsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS artifacts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		build_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		binary TEXT NOT NULL DEFAULT '',
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		sha256 TEXT NOT NULL DEFAULT '',
+		os TEXT NOT NULL DEFAULT '',
+		arch TEXT NOT NULL DEFAULT '',
+		variant TEXT NOT NULL DEFAULT '',
+		content_type TEXT NOT NULL DEFAULT 'application/octet-stream',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (build_id) REFERENCES builds(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_artifacts_build_id ON artifacts(build_id);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// Migration des bases créées avant l'ajout des colonnes
+	if err := addColumnIfMissing(db, "artifacts", "variant", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "artifacts", "binary", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	if err := migrateBinaryArtifacts(db); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'artifacts' créée ou déjà existante")
+	return nil
+}
+
+// migrateBinaryArtifacts crée les artefacts des builds dont le chemin du binaire
+// était stocké en tête de log_output ("Binary: <path>"), et retire cette ligne des logs.
+// La taille et le SHA-256 sont calculés si le binaire est encore sur le disque ;
+// l'OS et l'architecture de ces anciens builds sont inconnus.
+func migrateBinaryArtifacts(db *sql.DB) error {
+	rows, err := db.Query(`
+	SELECT id, log_output FROM builds
+	WHERE log_output LIKE 'Binary: %'
+	AND id NOT IN (SELECT build_id FROM artifacts)`)
+	if err != nil {
+		return err
+	}
+
+	type legacyBuild struct {
+		id        int
+		logOutput string
+	}
+	var builds []legacyBuild
+	for rows.Next() {
+		var b legacyBuild
+		if err := rows.Scan(&b.id, &b.logOutput); err != nil {
+			rows.Close()
+			return err
+		}
+		builds = append(builds, b)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, b := range builds {
+		header, logOutput, _ := strings.Cut(b.logOutput, "\n")
+		binaryPath := strings.TrimPrefix(header, "Binary: ")
+
+		artifact := &Artifact{
+			BuildID:     b.id,
+			Name:        filepath.Base(binaryPath),
+			Path:        binaryPath,
+			ContentType: artifactContentType(binaryPath),
+		}
+		if size, sum, err := fileDigest(binaryPath); err == nil {
+			artifact.Size, artifact.SHA256 = size, sum
+		} else {
+			log.Warn().Err(err).Int("build_id", b.id).Str("path", binaryPath).Msg("Binaire d'un ancien build introuvable, artefact migré sans empreinte")
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := insertArtifact(tx, artifact); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// La ligne "Binary: " était suivie d'une ligne vide
+		if _, err := tx.Exec("UPDATE builds SET log_output = ? WHERE id = ?", strings.TrimPrefix(logOutput, "\n"), b.id); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	if len(builds) > 0 {
+		log.Info().Int("count", len(builds)).Msg("Binaires des anciens builds migrés vers la table artifacts")
+	}
+	return nil
+}
+
+// NewArtifactFromFile décrit le fichier du binaire nommé binary, produit par un
+// build pour la plateforme target : nom, taille, SHA-256 et type de contenu
+func NewArtifactFromFile(path, binary string, target platform.Platform) (*Artifact, error) {
+	size, sum, err := fileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Artifact{
+		Name:        filepath.Base(path),
+		Binary:      binary,
+		Path:        path,
+		Size:        size,
+		SHA256:      sum,
+		OS:          target.OS,
+		Arch:        target.Arch,
+		Variant:     target.Variant,
+		ContentType: artifactContentType(path),
+	}, nil
+}
+
+// Platform retourne la plateforme ciblée par l'artefact
+func (a *Artifact) Platform() platform.Platform {
+	return platform.Platform{OS: a.OS, Arch: a.Arch, Variant: a.Variant}
+}
+
+// fileDigest retourne la taille et le SHA-256 (hexadécimal) d'un fichier
+func fileDigest(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// artifactContentType déduit le type de contenu de l'extension du fichier.
+// Les binaires sans extension sont des application/octet-stream.
+func artifactContentType(path string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// execer est implémenté par *sql.DB et *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// insertArtifact enregistre un artefact et renseigne son ID
+func insertArtifact(db execer, artifact *Artifact) error {
+	result, err := db.Exec(
+		`INSERT INTO artifacts (build_id, name, binary, path, size, sha256, os, arch, variant, content_type)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		artifact.BuildID, artifact.Name, artifact.Binary, artifact.Path, artifact.Size, artifact.SHA256, artifact.OS, artifact.Arch, artifact.Variant, artifact.ContentType,
+	)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	artifact.ID = int(id)
+	return nil
+}
+
+// FinishSuccessfulBuild passe un build en "success" et enregistre ses artefacts
+// dans la même transaction. Comme FinishBuild, un build déjà terminé (annulé entre
+// temps par exemple) n'est pas modifié et ses artefacts sont ignorés.
+func FinishSuccessfulBuild(db *sql.DB, id int, logOutput string, artifacts []Artifact) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE builds SET status = 'success', log_output = ?, error = '', ended_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN ('pending', 'building')`,
+		logOutput, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		log.Warn().Int("id", id).Str("status", "success").Msg("Build déjà terminé, résultat ignoré")
+		return nil
+	}
+
+	for i := range artifacts {
+		artifacts[i].BuildID = id
+		if err := insertArtifact(tx, &artifacts[i]); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Info().Int("id", id).Str("status", "success").Int("artifacts", len(artifacts)).Msg("Build terminé")
+	return nil
+}
+
+// GetArtifactsByBuildID récupère les artefacts d'un build
+func GetArtifactsByBuildID(db *sql.DB, buildID int) ([]Artifact, error) {
+	rows, err := db.Query("SELECT "+artifactColumns+" FROM artifacts WHERE build_id = ? ORDER BY id", buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artifacts := []Artifact{}
+	for rows.Next() {
+		artifact, err := scanArtifact(rows)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, *artifact)
+	}
+
+	return artifacts, rows.Err()
+}
+
+// GetArtifactByID récupère un artefact d'un build par son ID
+func GetArtifactByID(db *sql.DB, buildID, id int) (*Artifact, error) {
+	return scanArtifact(db.QueryRow("SELECT "+artifactColumns+" FROM artifacts WHERE build_id = ? AND id = ?", buildID, id))
+}
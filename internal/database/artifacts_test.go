@@ -0,0 +1,111 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/platform"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinishSuccessfulBuildStoresArtifacts(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	require.NoError(t, CreateArtifactsTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+
+	path := filepath.Join(t.TempDir(), "api-1")
+	require.NoError(t, os.WriteFile(path, []byte("binary"), 0o755))
+	artifact, err := NewArtifactFromFile(path, "api", platform.Platform{OS: "linux", Arch: "amd64"})
+	require.NoError(t, err)
+	assert.Equal(t, "application/octet-stream", artifact.ContentType)
+
+	require.NoError(t, FinishSuccessfulBuild(db, build.ID, "logs\n", []Artifact{*artifact}))
+
+	stored, err := GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "success", stored.Status)
+	assert.Equal(t, "logs\n", stored.LogOutput)
+
+	artifacts, err := GetArtifactsByBuildID(db, build.ID)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	sum := sha256.Sum256([]byte("binary"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), artifacts[0].SHA256)
+	assert.Equal(t, int64(6), artifacts[0].Size)
+	assert.Equal(t, "linux", artifacts[0].OS)
+
+	fetched, err := GetArtifactByID(db, build.ID, artifacts[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, path, fetched.Path)
+}
+
+func TestFinishSuccessfulBuildIgnoredWhenCancelled(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	require.NoError(t, CreateArtifactsTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+	_, err := CancelBuild(db, build.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, FinishSuccessfulBuild(db, build.ID, "logs\n", []Artifact{{Name: "api-1", Path: "/out/api-1"}}))
+
+	stored, _ := GetBuildByID(db, strconv.Itoa(build.ID))
+	assert.Equal(t, "cancelled", stored.Status)
+	artifacts, err := GetArtifactsByBuildID(db, build.ID)
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
+
+func TestMigrateBinaryArtifacts(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	onDisk, _ := CreateBuild(db, project.ID, "main")
+	deleted, _ := CreateBuild(db, project.ID, "main")
+	failed, _ := CreateBuild(db, project.ID, "main")
+
+	binaryPath := filepath.Join(t.TempDir(), "api-1")
+	require.NoError(t, os.WriteFile(binaryPath, []byte("binary"), 0o755))
+
+	// Builds enregistrés par une version précédente de GIP
+	require.NoError(t, FinishBuild(db, onDisk.ID, "success", "Binary: "+binaryPath+"\n\n==> Running: go build\n", ""))
+	require.NoError(t, FinishBuild(db, deleted.ID, "success", "Binary: /gone/api-2\n\n==> Running: go build\n", ""))
+	require.NoError(t, FinishBuild(db, failed.ID, "failed", "==> Running: go build\n", "exit status 1"))
+
+	require.NoError(t, CreateArtifactsTable(db))
+	// La migration est idempotente
+	require.NoError(t, CreateArtifactsTable(db))
+
+	artifacts, err := GetArtifactsByBuildID(db, onDisk.ID)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, "api-1", artifacts[0].Name)
+	assert.Equal(t, binaryPath, artifacts[0].Path)
+	assert.Equal(t, int64(6), artifacts[0].Size)
+	assert.Len(t, artifacts[0].SHA256, 64)
+
+	stored, _ := GetBuildByID(db, strconv.Itoa(onDisk.ID))
+	assert.Equal(t, "==> Running: go build\n", stored.LogOutput, "La ligne Binary: devrait être retirée des logs")
+
+	artifacts, err = GetArtifactsByBuildID(db, deleted.ID)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1, "Un binaire disparu du disque reste référencé")
+	assert.Equal(t, "/gone/api-2", artifacts[0].Path)
+	assert.Empty(t, artifacts[0].SHA256)
+
+	artifacts, err = GetArtifactsByBuildID(db, failed.ID)
+	require.NoError(t, err)
+	assert.Empty(t, artifacts)
+}
@@ -4,18 +4,65 @@ import (
 	"database/sql"
 	"time"
 
+	"forgeronvirtuel/gip/internal/selector"
+
 	"github.com/rs/zerolog/log"
 )
 
 type Build struct {
-	ID        int
-	ProjectID int
-	Branch    string
-	Status    string
-	LogOutput string
-	StartedAt time.Time
-	EndedAt   sql.NullTime
-	CreatedAt time.Time
+	ID                   int
+	ProjectID            int
+	Branch               string // Branche ou tag demandé
+	RequestedCommit      string // Commit demandé, prioritaire sur Branch
+	Status               string
+	LogOutput            string
+	Error                string
+	AgentID              sql.NullInt64
+	CommitSHA            string // Commit effectivement compilé
+	CommitAuthor         string
+	CommitDate           sql.NullTime
+	CommitMessage        string
+	Version              string        // Version du commit compilé (tag ou description à la git describe)
+	Hermetic             bool          // Compilé et testé hors ligne, à partir des seuls modules vérifiés par go.sum
+	RebuildOf            sql.NullInt64 // Build dont celui-ci vérifie la reproductibilité
+	Reproducibility      string        // Vérification de la reproductibilité : vide sans vérification, pending, passed ou failed
+	ReproducibilityError string        // Cause de l'échec de la vérification
+	GoVersion            string        // Version de Go du build (go1.22.3), vide s'il s'est arrêté avant de la déterminer
+	StartedAt            time.Time
+	EndedAt              sql.NullTime
+	CreatedAt            time.Time
+}
+
+// CommitInfo décrit le commit à partir duquel un build a été compilé
+type CommitInfo struct {
+	SHA     string    `json:"sha"`
+	Author  string    `json:"author"`
+	Date    time.Time `json:"date"` // Date du committer
+	Message string    `json:"message"`
+}
+
+// buildColumns liste les colonnes lues par scanBuild, dans le même ordre
+const buildColumns = `id, project_id, branch, COALESCE(requested_commit, ''), status, COALESCE(log_output, ''), COALESCE(error, ''), agent_id,
+	COALESCE(commit_sha, ''), COALESCE(commit_author, ''), commit_date, COALESCE(commit_message, ''), COALESCE(version, ''), hermetic, rebuild_of,
+	COALESCE(reproducibility, ''), COALESCE(reproducibility_error, ''), COALESCE(go_version, ''), started_at, ended_at, created_at`
+
+// rowScanner est implémenté par *sql.Row et *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanBuild lit une ligne de la table builds sélectionnée avec buildColumns
+func scanBuild(row rowScanner) (*Build, error) {
+	build := &Build{}
+	err := row.Scan(
+		&build.ID, &build.ProjectID, &build.Branch, &build.RequestedCommit, &build.Status, &build.LogOutput, &build.Error, &build.AgentID,
+		&build.CommitSHA, &build.CommitAuthor, &build.CommitDate, &build.CommitMessage, &build.Version, &build.Hermetic, &build.RebuildOf,
+		&build.Reproducibility, &build.ReproducibilityError, &build.GoVersion, &build.StartedAt, &build.EndedAt, &build.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return build, nil
 }
 
 // CreateBuildsTable crée la table builds si elle n'existe pas
@@ -25,8 +72,22 @@ func CreateBuildsTable(db *sql.DB) error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		project_id INTEGER NOT NULL,
 		branch TEXT NOT NULL,
+		requested_commit TEXT,
 		status TEXT DEFAULT 'pending',
 		log_output TEXT,
+		error TEXT,
+		agent_id INTEGER REFERENCES agents(id) ON DELETE SET NULL,
+		lease_expires_at DATETIME,
+		commit_sha TEXT,
+		commit_author TEXT,
+		commit_date DATETIME,
+		commit_message TEXT,
+		version TEXT,
+		hermetic BOOLEAN NOT NULL DEFAULT 0,
+		rebuild_of INTEGER REFERENCES builds(id) ON DELETE CASCADE,
+		reproducibility TEXT,
+		reproducibility_error TEXT,
+		go_version TEXT,
 		started_at DATETIME,
 		ended_at DATETIME,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
@@ -39,16 +100,54 @@ func CreateBuildsTable(db *sql.DB) error {
 		return err
 	}
 
+	// Migration des bases créées avant l'ajout des colonnes
+	if err := addColumnIfMissing(db, "builds", "error", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "builds", "agent_id", "INTEGER REFERENCES agents(id) ON DELETE SET NULL"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "builds", "lease_expires_at", "DATETIME"); err != nil {
+		return err
+	}
+	for _, column := range []struct{ name, definition string }{
+		{"requested_commit", "TEXT"},
+		{"commit_sha", "TEXT"},
+		{"commit_author", "TEXT"},
+		{"commit_date", "DATETIME"},
+		{"commit_message", "TEXT"},
+		{"version", "TEXT"},
+		{"hermetic", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"rebuild_of", "INTEGER REFERENCES builds(id) ON DELETE CASCADE"},
+		{"reproducibility", "TEXT"},
+		{"reproducibility_error", "TEXT"},
+		{"go_version", "TEXT"},
+	} {
+		if err := addColumnIfMissing(db, "builds", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_builds_status ON builds(status)"); err != nil {
+		return err
+	}
+
 	log.Info().Msg("Table 'builds' créée ou déjà existante")
 	return nil
 }
 
-// CreateBuild crée un nouveau build
+// CreateBuild crée un nouveau build de la branche donnée
 func CreateBuild(db *sql.DB, projectID int, branch string) (*Build, error) {
+	return CreateBuildAt(db, projectID, branch, "")
+}
+
+// CreateBuildAt crée un nouveau build d'une branche ou d'un tag (ref), ou d'un
+// commit précis si commit n'est pas vide
+func CreateBuildAt(db *sql.DB, projectID int, ref, commit string) (*Build, error) {
 	startedAt := time.Now()
 	result, err := db.Exec(
-		"INSERT INTO builds (project_id, branch, status, started_at) VALUES (?, ?, ?, ?)",
-		projectID, branch, "pending", startedAt,
+		"INSERT INTO builds (project_id, branch, requested_commit, status, started_at) VALUES (?, ?, ?, ?, ?)",
+		projectID, ref, commit, "pending", startedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -60,34 +159,47 @@ func CreateBuild(db *sql.DB, projectID int, branch string) (*Build, error) {
 	}
 
 	return &Build{
-		ID:        int(id),
-		ProjectID: projectID,
-		Branch:    branch,
-		Status:    "pending",
-		StartedAt: startedAt,
+		ID:              int(id),
+		ProjectID:       projectID,
+		Branch:          ref,
+		RequestedCommit: commit,
+		Status:          "pending",
+		StartedAt:       startedAt,
+		CreatedAt:       startedAt,
 	}, nil
 }
 
-// GetBuildByID récupère un build par son ID
-func GetBuildByID(db *sql.DB, id string) (*Build, error) {
-	build := &Build{}
+// SaveBuildCommit enregistre le commit à partir duquel un build a été compilé
+// et la version qui en a été déduite
+func SaveBuildCommit(db *sql.DB, id int, commit *CommitInfo, version string) error {
+	_, err := db.Exec(
+		"UPDATE builds SET commit_sha = ?, commit_author = ?, commit_date = ?, commit_message = ?, version = ? WHERE id = ?",
+		commit.SHA, commit.Author, commit.Date, commit.Message, version, id,
+	)
+	return err
+}
 
-	err := db.QueryRow(
-		"SELECT id, project_id, branch, status, log_output, started_at, ended_at, created_at FROM builds WHERE id = ?",
-		id,
-	).Scan(&build.ID, &build.ProjectID, &build.Branch, &build.Status, &build.LogOutput, &build.StartedAt, &build.EndedAt, &build.CreatedAt)
+// MarkBuildHermetic indique qu'un build a été compilé et testé hors ligne
+func MarkBuildHermetic(db *sql.DB, id int) error {
+	_, err := db.Exec("UPDATE builds SET hermetic = 1 WHERE id = ?", id)
+	return err
+}
 
-	if err != nil {
-		return nil, err
-	}
+// SaveBuildGoVersion enregistre la version de Go avec laquelle un build a été exécuté
+func SaveBuildGoVersion(db *sql.DB, id int, goVersion string) error {
+	_, err := db.Exec("UPDATE builds SET go_version = ? WHERE id = ?", goVersion, id)
+	return err
+}
 
-	return build, nil
+// GetBuildByID récupère un build par son ID
+func GetBuildByID(db *sql.DB, id string) (*Build, error) {
+	return scanBuild(db.QueryRow("SELECT "+buildColumns+" FROM builds WHERE id = ?", id))
 }
 
 // UpdateBuildStatus met à jour le statut d'un build
 func UpdateBuildStatus(db *sql.DB, id int, status string, logOutput string) error {
 	var err error
-	if status == "success" || status == "failed" {
+	if IsTerminalBuildStatus(status) {
 		// Si le build est terminé, on met à jour ended_at
 		_, err = db.Exec(
 			"UPDATE builds SET status = ?, log_output = ?, ended_at = CURRENT_TIMESTAMP WHERE id = ?",
@@ -109,24 +221,261 @@ func UpdateBuildStatus(db *sql.DB, id int, status string, logOutput string) erro
 	return nil
 }
 
-// GetAllBuilds récupère tous les builds
-func GetAllBuilds(db *sql.DB) ([]Build, error) {
+// FinishBuild enregistre le statut final d'un build avec ses logs et son message d'erreur.
+// Un build déjà terminé (annulé entre temps par exemple) n'est pas modifié.
+func FinishBuild(db *sql.DB, id int, status, logOutput, errMsg string) error {
+	result, err := db.Exec(
+		`UPDATE builds SET status = ?, log_output = ?, error = ?, ended_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN ('pending', 'building')`,
+		status, logOutput, errMsg, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		log.Warn().Int("id", id).Str("status", status).Msg("Build déjà terminé, résultat ignoré")
+		return nil
+	}
+
+	log.Info().Int("id", id).Str("status", status).Msg("Build terminé")
+	return nil
+}
+
+// CancelBuild passe un build en attente ou en cours au statut "cancelled".
+// Retourne false si le build est déjà terminé.
+func CancelBuild(db *sql.DB, id int) (bool, error) {
+	result, err := db.Exec(
+		`UPDATE builds SET status = 'cancelled', error = 'Build cancelled', lease_expires_at = NULL, ended_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN ('pending', 'building')`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	log.Info().Int("id", id).Msg("Build annulé")
+	return true, nil
+}
+
+// SaveBuildLogs remplace les logs d'un build, par exemple ceux d'un build annulé
+// une fois son processus arrêté
+func SaveBuildLogs(db *sql.DB, id int, logOutput string) error {
+	_, err := db.Exec("UPDATE builds SET log_output = ? WHERE id = ?", logOutput, id)
+	return err
+}
+
+// IsTerminalBuildStatus indique si un statut correspond à un build terminé
+func IsTerminalBuildStatus(status string) bool {
+	return status == "success" || status == "failed" || status == "cancelled"
+}
+
+// ClaimNextBuild réserve le plus ancien build en attente pour un worker local et le
+// passe en "building". Les projets restreints à certains agents par un sélecteur
+// sont ignorés. Retourne nil, nil si aucun build n'est disponible.
+func ClaimNextBuild(db *sql.DB) (*Build, error) {
+	accept := func(agentSelector string) bool {
+		return agentSelector == ""
+	}
+
+	build, err := claimNextBuild(db, sql.NullInt64{}, sql.NullTime{}, accept)
+	if err != nil || build == nil {
+		return build, err
+	}
+
+	log.Info().Int("id", build.ID).Msg("Build réservé par un worker")
+	return build, nil
+}
+
+// LeaseNextBuild réserve le plus ancien build en attente dont le projet accepte
+// les labels de l'agent. Le bail doit être renouvelé avant son expiration avec
+// RenewBuildLease, sinon le build est remis en file d'attente par RequeueExpiredLeases.
+func LeaseNextBuild(db *sql.DB, agent *Agent, leaseDuration time.Duration) (*Build, error) {
+	accept := func(agentSelector string) bool {
+		sel, err := selector.Parse(agentSelector)
+		if err != nil {
+			log.Error().Err(err).Str("selector", agentSelector).Msg("Sélecteur d'agents invalide")
+			return false
+		}
+		return sel.Matches(agent.Labels)
+	}
+
+	build, err := claimNextBuild(
+		db,
+		sql.NullInt64{Int64: int64(agent.ID), Valid: true},
+		sql.NullTime{Time: time.Now().UTC().Add(leaseDuration), Valid: true},
+		accept,
+	)
+	if err != nil || build == nil {
+		return build, err
+	}
+
+	log.Info().Int("id", build.ID).Int("agent_id", agent.ID).Msg("Build attribué à un agent")
+	return build, nil
+}
+
+// claimNextBuild passe en "building" le plus ancien build en attente dont le
+// sélecteur d'agents du projet est accepté, en lui attribuant l'agent et le bail
+// donnés (NULL pour un worker local).
+func claimNextBuild(db *sql.DB, agentID sql.NullInt64, leaseExpiresAt sql.NullTime, accept func(agentSelector string) bool) (*Build, error) {
+	type candidate struct {
+		id            int
+		agentSelector string
+	}
+
+	// Les sélecteurs ne peuvent pas être évalués en SQL : on lit d'abord les candidats
+	rows, err := db.Query(`
+	SELECT b.id, COALESCE(p.agent_selector, '')
+	FROM builds b
+	JOIN projects p ON p.id = b.project_id
+	WHERE b.status = 'pending'
+	ORDER BY b.id`)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.agentSelector); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query := `
+	UPDATE builds
+	SET status = 'building', agent_id = ?, lease_expires_at = ?, started_at = CURRENT_TIMESTAMP
+	WHERE id = ?
+	AND status = 'pending'
+	RETURNING ` + buildColumns
+
+	for _, c := range candidates {
+		if !accept(c.agentSelector) {
+			continue
+		}
+
+		// Le build a pu être réservé par un autre exécutant entre temps
+		build, err := scanBuild(db.QueryRow(query, agentID, leaseExpiresAt, c.id))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return build, nil
+	}
+
+	return nil, nil
+}
+
+// RenewBuildLease prolonge le bail d'un build attribué à un agent et ajoute un
+// morceau de logs à log_output. Retourne false si le build n'est plus en cours
+// sur cet agent (bail expiré, build terminé...), auquel cas l'agent doit l'abandonner.
+func RenewBuildLease(db *sql.DB, buildID, agentID int, logChunk string, leaseDuration time.Duration) (bool, error) {
+	result, err := db.Exec(
+		`UPDATE builds
+		SET log_output = COALESCE(log_output, '') || ?, lease_expires_at = ?
+		WHERE id = ? AND agent_id = ? AND status = 'building'`,
+		logChunk, time.Now().UTC().Add(leaseDuration), buildID, agentID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+// ReleaseBuildLease remet en attente un build qui vient d'être attribué à un
+// agent, sans qu'il ait commencé à l'exécuter
+func ReleaseBuildLease(db *sql.DB, buildID, agentID int) error {
+	_, err := db.Exec(
+		`UPDATE builds
+		SET status = 'pending', agent_id = NULL, lease_expires_at = NULL
+		WHERE id = ? AND agent_id = ? AND status = 'building'`,
+		buildID, agentID,
+	)
+	return err
+}
+
+// RequeueExpiredLeases remet en attente les builds dont l'agent n'a pas renouvelé
+// le bail à temps, par exemple parce qu'il a été arrêté en plein build, et
+// retourne leurs IDs
+func RequeueExpiredLeases(db *sql.DB) ([]int, error) {
 	rows, err := db.Query(
-		"SELECT id, project_id, branch, status, log_output, started_at, ended_at, created_at FROM builds ORDER BY id DESC",
+		`UPDATE builds
+		SET status = 'pending', agent_id = NULL, lease_expires_at = NULL, log_output = NULL
+		WHERE status = 'building' AND agent_id IS NOT NULL AND lease_expires_at < ?
+		RETURNING id`,
+		time.Now().UTC(),
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// RequeueInterruptedBuilds remet en attente les builds locaux restés en "building",
+// par exemple après un arrêt brutal du serveur. Les builds exécutés par des agents
+// continuent tant que leur bail est renouvelé.
+func RequeueInterruptedBuilds(db *sql.DB) (int, error) {
+	result, err := db.Exec("UPDATE builds SET status = 'pending' WHERE status = 'building' AND agent_id IS NULL")
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rowsAffected), nil
+}
+
+// GetAllBuilds récupère tous les builds
+func GetAllBuilds(db *sql.DB) ([]Build, error) {
+	rows, err := db.Query("SELECT " + buildColumns + " FROM builds ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
 	var builds []Build
 	for rows.Next() {
-		var build Build
-		err := rows.Scan(&build.ID, &build.ProjectID, &build.Branch, &build.Status, &build.LogOutput, &build.StartedAt, &build.EndedAt, &build.CreatedAt)
+		build, err := scanBuild(rows)
 		if err != nil {
 			return nil, err
 		}
-		builds = append(builds, build)
+		builds = append(builds, *build)
 	}
 
 	return builds, nil
@@ -135,7 +484,7 @@ func GetAllBuilds(db *sql.DB) ([]Build, error) {
 // GetBuildsByProjectID récupère tous les builds d'un projet
 func GetBuildsByProjectID(db *sql.DB, projectID int) ([]Build, error) {
 	rows, err := db.Query(
-		"SELECT id, project_id, branch, status, log_output, started_at, ended_at, created_at FROM builds WHERE project_id = ? ORDER BY id DESC",
+		"SELECT "+buildColumns+" FROM builds WHERE project_id = ? ORDER BY id DESC",
 		projectID,
 	)
 	if err != nil {
@@ -145,12 +494,11 @@ func GetBuildsByProjectID(db *sql.DB, projectID int) ([]Build, error) {
 
 	var builds []Build
 	for rows.Next() {
-		var build Build
-		err := rows.Scan(&build.ID, &build.ProjectID, &build.Branch, &build.Status, &build.LogOutput, &build.StartedAt, &build.EndedAt, &build.CreatedAt)
+		build, err := scanBuild(rows)
 		if err != nil {
 			return nil, err
 		}
-		builds = append(builds, build)
+		builds = append(builds, *build)
 	}
 
 	return builds, nil
@@ -0,0 +1,266 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupBuildsTestDB crée une base en mémoire avec les tables projects, agents et builds
+func setupBuildsTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	// Une seule connexion : chaque connexion ":memory:" ouvre une base distincte
+	db.SetMaxOpenConns(1)
+
+	require.NoError(t, CreateProjectsTable(db))
+	require.NoError(t, CreateAgentsTable(db))
+	require.NoError(t, CreateBuildsTable(db))
+
+	return db
+}
+
+func TestCreateBuildIsPending(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, err := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	require.NoError(t, err)
+
+	build, err := CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+	assert.Equal(t, "pending", build.Status)
+
+	stored, err := GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err, "Un build en attente sans logs devrait être lisible")
+	assert.Equal(t, "pending", stored.Status)
+	assert.Equal(t, "", stored.LogOutput)
+	assert.False(t, stored.EndedAt.Valid)
+}
+
+func TestClaimNextBuild(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	// File vide
+	build, err := ClaimNextBuild(db)
+	require.NoError(t, err)
+	assert.Nil(t, build)
+
+	p1, _ := CreateProject(db, "p1", "https://github.com/user/p1.git", "main", "")
+	p2, _ := CreateProject(db, "p2", "https://github.com/user/p2.git", "main", "")
+
+	first, _ := CreateBuild(db, p1.ID, "main")
+	second, _ := CreateBuild(db, p1.ID, "main")
+	third, _ := CreateBuild(db, p2.ID, "main")
+
+	// Le plus ancien build est réservé en premier
+	claimed, err := ClaimNextBuild(db)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, first.ID, claimed.ID)
+	assert.Equal(t, "building", claimed.Status)
+
+	// Chaque build a son propre répertoire : le second build de p1 n'attend pas
+	// la fin du premier
+	claimed, err = ClaimNextBuild(db)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, second.ID, claimed.ID)
+
+	claimed, err = ClaimNextBuild(db)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, third.ID, claimed.ID)
+
+	claimed, err = ClaimNextBuild(db)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+}
+
+func TestLeaseNextBuildAgentSelector(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	arm, _ := CreateProject(db, "arm", "https://github.com/user/arm.git", "main", "")
+	require.NoError(t, UpdateProjectAgentSelector(db, arm.ID, "os=linux,arch=arm64"))
+	generic, _ := CreateProject(db, "generic", "https://github.com/user/generic.git", "main", "")
+
+	armBuild, _ := CreateBuild(db, arm.ID, "main")
+	genericBuild, _ := CreateBuild(db, generic.ID, "main")
+
+	amd64Agent, _ := CreateAgent(db, "amd64", map[string]string{"os": "linux", "arch": "amd64"})
+	arm64Agent, _ := CreateAgent(db, "arm64", map[string]string{"os": "linux", "arch": "arm64"})
+
+	// Le build restreint est ignoré par un agent qui ne satisfait pas le sélecteur
+	leased, err := LeaseNextBuild(db, amd64Agent, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, leased)
+	assert.Equal(t, genericBuild.ID, leased.ID)
+
+	// Les workers locaux n'ont pas de labels : ils ne prennent pas les builds restreints
+	claimed, err := ClaimNextBuild(db)
+	require.NoError(t, err)
+	assert.Nil(t, claimed)
+
+	leased, err = LeaseNextBuild(db, arm64Agent, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, leased)
+	assert.Equal(t, armBuild.ID, leased.ID)
+	assert.Equal(t, int64(arm64Agent.ID), leased.AgentID.Int64)
+}
+
+func TestReleaseBuildLease(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+	agent, _ := CreateAgent(db, "runner", nil)
+	other, _ := CreateAgent(db, "other", nil)
+
+	leased, err := LeaseNextBuild(db, agent, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, leased)
+
+	// Seul l'agent qui a le bail peut le rendre
+	require.NoError(t, ReleaseBuildLease(db, build.ID, other.ID))
+	stored, _ := GetBuildByID(db, strconv.Itoa(build.ID))
+	assert.Equal(t, "building", stored.Status)
+
+	require.NoError(t, ReleaseBuildLease(db, build.ID, agent.ID))
+	stored, _ = GetBuildByID(db, strconv.Itoa(build.ID))
+	assert.Equal(t, "pending", stored.Status)
+	assert.False(t, stored.AgentID.Valid)
+}
+
+func TestRequeueExpiredLeases(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	expired, _ := CreateBuild(db, project.ID, "main")
+	renewed, _ := CreateBuild(db, project.ID, "main")
+	agent, _ := CreateAgent(db, "runner", nil)
+
+	_, err := LeaseNextBuild(db, agent, -time.Minute)
+	require.NoError(t, err)
+	_, err = LeaseNextBuild(db, agent, time.Minute)
+	require.NoError(t, err)
+
+	// Seul le build dont le bail a expiré est remis en attente
+	requeued, err := RequeueExpiredLeases(db)
+	require.NoError(t, err)
+	assert.Equal(t, []int{expired.ID}, requeued)
+
+	stored, _ := GetBuildByID(db, strconv.Itoa(expired.ID))
+	assert.Equal(t, "pending", stored.Status)
+	assert.False(t, stored.AgentID.Valid)
+	stored, _ = GetBuildByID(db, strconv.Itoa(renewed.ID))
+	assert.Equal(t, "building", stored.Status)
+
+	requeued, err = RequeueExpiredLeases(db)
+	require.NoError(t, err)
+	assert.Empty(t, requeued)
+}
+
+func TestFinishBuild(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+
+	err := FinishBuild(db, build.ID, "failed", "==> Running: go build", "exit status 1")
+	require.NoError(t, err)
+
+	stored, err := GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "failed", stored.Status)
+	assert.Equal(t, "==> Running: go build", stored.LogOutput)
+	assert.Equal(t, "exit status 1", stored.Error)
+	assert.True(t, stored.EndedAt.Valid)
+}
+
+func TestMarkBuildHermetic(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+	assert.False(t, build.Hermetic)
+
+	require.NoError(t, MarkBuildHermetic(db, build.ID))
+	stored, err := GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.True(t, stored.Hermetic)
+}
+
+func TestSaveBuildGoVersion(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+	assert.Empty(t, build.GoVersion)
+
+	require.NoError(t, SaveBuildGoVersion(db, build.ID, "go1.22.3"))
+	stored, err := GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "go1.22.3", stored.GoVersion)
+}
+
+func TestCancelBuild(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+	_, err := ClaimNextBuild(db)
+	require.NoError(t, err)
+
+	cancelled, err := CancelBuild(db, build.ID)
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+
+	// Le worker termine après l'annulation : son résultat est ignoré
+	require.NoError(t, FinishBuild(db, build.ID, "success", "logs", ""))
+
+	stored, err := GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", stored.Status)
+	assert.Equal(t, "Build cancelled", stored.Error)
+	assert.True(t, stored.EndedAt.Valid)
+	assert.True(t, IsTerminalBuildStatus(stored.Status))
+
+	// Un build terminé ne peut plus être annulé
+	cancelled, err = CancelBuild(db, build.ID)
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+}
+
+func TestRequeueInterruptedBuilds(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, _ := CreateBuild(db, project.ID, "main")
+
+	claimed, err := ClaimNextBuild(db)
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+
+	count, err := RequeueInterruptedBuilds(db)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	stored, err := GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "pending", stored.Status)
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"forgeronvirtuel/gip/internal/coverage"
+
+	"github.com/rs/zerolog/log"
+)
+
+// CoveragePoint est la couverture totale d'un build réussi, pour suivre
+// l'évolution de la couverture d'un projet
+type CoveragePoint struct {
+	BuildID    int       `json:"build_id"`
+	Branch     string    `json:"branch"`
+	CommitSHA  string    `json:"commit_sha"`
+	Version    string    `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	Statements int       `json:"statements"`
+	Covered    int       `json:"covered"`
+	Percent    float64   `json:"percent"`
+}
+
+// CreateBuildCoverageTable crée la table build_coverage si elle n'existe pas.
+// Chaque build y a une ligne pour le total (package et fichier vides), une par
+// package (fichier vide) et une par fichier.
+func CreateBuildCoverageTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS build_coverage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		build_id INTEGER NOT NULL,
+		package TEXT NOT NULL DEFAULT '',
+		file TEXT NOT NULL DEFAULT '',
+		statements INTEGER NOT NULL,
+		covered INTEGER NOT NULL,
+		FOREIGN KEY (build_id) REFERENCES builds(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_build_coverage_build_id ON build_coverage(build_id);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'build_coverage' créée ou déjà existante")
+	return nil
+}
+
+// SaveBuildCoverage enregistre la couverture d'un build, en remplaçant celle
+// d'une exécution précédente (build repris après un arrêt)
+func SaveBuildCoverage(db *sql.DB, buildID int, report *coverage.Report) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM build_coverage WHERE build_id = ?", buildID); err != nil {
+		return err
+	}
+
+	entries := append([]coverage.Entry{{Statements: report.Total.Statements, Covered: report.Total.Covered}}, report.Packages...)
+	for _, entry := range append(entries, report.Files...) {
+		_, err := tx.Exec(
+			"INSERT INTO build_coverage (build_id, package, file, statements, covered) VALUES (?, ?, ?, ?, ?)",
+			buildID, entry.Package, entry.File, entry.Statements, entry.Covered,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBuildCoverage récupère la couverture d'un build.
+// Retourne sql.ErrNoRows si la couverture du build n'a pas été mesurée.
+func GetBuildCoverage(db *sql.DB, buildID int) (*coverage.Report, error) {
+	rows, err := db.Query(
+		"SELECT package, file, statements, covered FROM build_coverage WHERE build_id = ? ORDER BY package, file",
+		buildID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &coverage.Report{Packages: []coverage.Entry{}, Files: []coverage.Entry{}}
+	found := false
+	for rows.Next() {
+		var entry coverage.Entry
+		if err := rows.Scan(&entry.Package, &entry.File, &entry.Statements, &entry.Covered); err != nil {
+			return nil, err
+		}
+		found = true
+		switch {
+		case entry.Package == "" && entry.File == "":
+			report.Total = entry
+		case entry.File == "":
+			report.Packages = append(report.Packages, entry)
+		default:
+			report.Files = append(report.Files, entry)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, sql.ErrNoRows
+	}
+	return report, nil
+}
+
+// GetProjectCoverageTrend récupère la couverture totale des derniers builds réussis
+// d'un projet, du plus ancien au plus récent. branch filtre les builds si elle
+// n'est pas vide ; limit borne le nombre de builds retournés.
+func GetProjectCoverageTrend(db *sql.DB, projectID int, branch string, limit int) ([]CoveragePoint, error) {
+	query := `
+	SELECT b.id, b.branch, COALESCE(b.commit_sha, ''), COALESCE(b.version, ''), b.created_at, c.statements, c.covered
+	FROM builds b
+	JOIN build_coverage c ON c.build_id = b.id AND c.package = '' AND c.file = ''
+	WHERE b.project_id = ? AND b.status = 'success' AND (? = '' OR b.branch = ?)
+	ORDER BY b.id DESC
+	LIMIT ?
+	`
+	rows, err := db.Query(query, projectID, branch, branch, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []CoveragePoint{}
+	for rows.Next() {
+		var point CoveragePoint
+		err := rows.Scan(&point.BuildID, &point.Branch, &point.CommitSHA, &point.Version, &point.CreatedAt, &point.Statements, &point.Covered)
+		if err != nil {
+			return nil, err
+		}
+		point.Percent = coverage.Entry{Statements: point.Statements, Covered: point.Covered}.Percent()
+		points = append(points, point)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Du plus ancien au plus récent
+	for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+		points[i], points[j] = points[j], points[i]
+	}
+	return points, nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/coverage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCoverageTrend(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	require.NoError(t, CreateBuildCoverageTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+
+	// saveBuild enregistre un build terminé avec la couverture donnée
+	saveBuild := func(branch, status string, covered int) int {
+		build, err := CreateBuild(db, project.ID, branch)
+		require.NoError(t, err)
+		require.NoError(t, SaveBuildCoverage(db, build.ID, &coverage.Report{
+			Total:    coverage.Entry{Statements: 10, Covered: covered},
+			Packages: []coverage.Entry{{Package: "example.com/api", Statements: 10, Covered: covered}},
+			Files:    []coverage.Entry{{Package: "example.com/api", File: "example.com/api/api.go", Statements: 10, Covered: covered}},
+		}))
+		require.NoError(t, FinishBuild(db, build.ID, status, "", ""))
+		return build.ID
+	}
+
+	first := saveBuild("main", "success", 8)
+	saveBuild("main", "failed", 2)
+	saveBuild("feature", "success", 5)
+	last := saveBuild("main", "success", 6)
+
+	report, err := GetBuildCoverage(db, first)
+	require.NoError(t, err)
+	assert.Equal(t, coverage.Entry{Statements: 10, Covered: 8}, report.Total)
+	assert.Equal(t, []coverage.Entry{{Package: "example.com/api", Statements: 10, Covered: 8}}, report.Packages)
+	assert.Equal(t, "example.com/api/api.go", report.Files[0].File)
+
+	build, err := CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+	_, err = GetBuildCoverage(db, build.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	// Seuls les builds réussis, du plus ancien au plus récent
+	points, err := GetProjectCoverageTrend(db, project.ID, "main", 50)
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, first, points[0].BuildID)
+	assert.Equal(t, 80.0, points[0].Percent)
+	assert.Equal(t, last, points[1].BuildID)
+	assert.Equal(t, 60.0, points[1].Percent)
+
+	points, err = GetProjectCoverageTrend(db, project.ID, "", 2)
+	require.NoError(t, err)
+	require.Len(t, points, 2, "La limite garde les builds les plus récents")
+	assert.Equal(t, "feature", points[0].Branch)
+	assert.Equal(t, last, points[1].BuildID)
+}
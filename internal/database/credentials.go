@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ProjectCredentials décrit les identifiants d'accès au dépôt d'un projet (voir
+// gitauth.Credentials). Leur secret, chiffré avec la clé maître du serveur, n'est
+// lu que par GetProjectCredentialsSecret pour préparer un build, et seulement
+// transmis pour un dépôt hébergé sur Host.
+type ProjectCredentials struct {
+	ProjectID  int       `json:"project_id"`
+	Kind       string    `json:"kind"`
+	Host       string    `json:"host"` // Hôte du dépôt pour lequel ils ont été enregistrés, vide pour les plus anciens
+	Username   string    `json:"username"`
+	KnownHosts string    `json:"known_hosts"`
+	GoPrivate  string    `json:"goprivate"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// projectCredentialsColumns liste les colonnes lues par scanProjectCredentials, dans le même ordre
+const projectCredentialsColumns = `project_id, kind, host, username, known_hosts, goprivate, created_at, updated_at`
+
+// scanProjectCredentials lit une ligne de la table project_credentials sélectionnée avec projectCredentialsColumns
+func scanProjectCredentials(row rowScanner) (*ProjectCredentials, error) {
+	creds := &ProjectCredentials{}
+	err := row.Scan(&creds.ProjectID, &creds.Kind, &creds.Host, &creds.Username, &creds.KnownHosts, &creds.GoPrivate, &creds.CreatedAt, &creds.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return creds, nil
+}
+
+// CreateProjectCredentialsTable crée la table project_credentials si elle n'existe pas
+func CreateProjectCredentialsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS project_credentials (
+		project_id INTEGER PRIMARY KEY,
+		kind TEXT NOT NULL,
+		host TEXT NOT NULL DEFAULT '',
+		username TEXT NOT NULL DEFAULT '',
+		secret BLOB NOT NULL,
+		known_hosts TEXT NOT NULL DEFAULT '',
+		goprivate TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	// Migration des bases créées avant la restriction des identifiants à l'hôte du dépôt
+	if err := addColumnIfMissing(db, "project_credentials", "host", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'project_credentials' créée ou déjà existante")
+	return nil
+}
+
+// SetProjectCredentials crée ou remplace les identifiants d'un projet. encrypted
+// est le secret chiffré avec secrets.Cipher.Encrypt.
+func SetProjectCredentials(db *sql.DB, creds ProjectCredentials, encrypted []byte) (*ProjectCredentials, error) {
+	_, err := db.Exec(
+		`INSERT INTO project_credentials (project_id, kind, host, username, secret, known_hosts, goprivate) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (project_id) DO UPDATE SET kind = excluded.kind, host = excluded.host, username = excluded.username, secret = excluded.secret,
+			known_hosts = excluded.known_hosts, goprivate = excluded.goprivate, updated_at = CURRENT_TIMESTAMP`,
+		creds.ProjectID, creds.Kind, creds.Host, creds.Username, encrypted, creds.KnownHosts, creds.GoPrivate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetProjectCredentials(db, creds.ProjectID)
+}
+
+// GetProjectCredentials retourne les identifiants d'un projet, sans leur secret.
+// Retourne sql.ErrNoRows si le projet n'en a pas.
+func GetProjectCredentials(db *sql.DB, projectID int) (*ProjectCredentials, error) {
+	return scanProjectCredentials(db.QueryRow("SELECT "+projectCredentialsColumns+" FROM project_credentials WHERE project_id = ?", projectID))
+}
+
+// GetProjectCredentialsSecret retourne les identifiants d'un projet et leur secret chiffré.
+// Retourne sql.ErrNoRows si le projet n'en a pas.
+func GetProjectCredentialsSecret(db *sql.DB, projectID int) (*ProjectCredentials, []byte, error) {
+	creds, err := GetProjectCredentials(db, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var secret []byte
+	if err := db.QueryRow("SELECT secret FROM project_credentials WHERE project_id = ?", projectID).Scan(&secret); err != nil {
+		return nil, nil, err
+	}
+	return creds, secret, nil
+}
+
+// DeleteProjectCredentials supprime les identifiants d'un projet.
+// Retourne sql.ErrNoRows si le projet n'en a pas.
+func DeleteProjectCredentials(db *sql.DB, projectID int) error {
+	result, err := db.Exec("DELETE FROM project_credentials WHERE project_id = ?", projectID)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
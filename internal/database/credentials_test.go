@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectCredentials(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	_, err := db.Exec("PRAGMA foreign_keys = ON")
+	require.NoError(t, err)
+	require.NoError(t, CreateProjectCredentialsTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+
+	_, err = GetProjectCredentials(db, project.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	creds, err := SetProjectCredentials(db, ProjectCredentials{ProjectID: project.ID, Kind: "token", Host: "github.com", GoPrivate: "github.com/user"}, []byte("sealed-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "token", creds.Kind)
+	assert.Equal(t, "github.com", creds.Host)
+	assert.Equal(t, "github.com/user", creds.GoPrivate)
+
+	// Remplacer les identifiants remplace tous leurs champs
+	creds, err = SetProjectCredentials(db, ProjectCredentials{ProjectID: project.ID, Kind: "password", Username: "bot"}, []byte("sealed-2"))
+	require.NoError(t, err)
+	assert.Equal(t, "password", creds.Kind)
+	assert.Equal(t, "bot", creds.Username)
+	assert.Empty(t, creds.GoPrivate)
+
+	stored, secret, err := GetProjectCredentialsSecret(db, project.ID)
+	require.NoError(t, err)
+	assert.Equal(t, creds, stored)
+	assert.Equal(t, []byte("sealed-2"), secret)
+
+	require.NoError(t, DeleteProjectCredentials(db, project.ID))
+	assert.Equal(t, sql.ErrNoRows, DeleteProjectCredentials(db, project.ID))
+
+	// Les identifiants sont supprimés avec leur projet
+	_, err = SetProjectCredentials(db, ProjectCredentials{ProjectID: project.ID, Kind: "token"}, []byte("sealed-3"))
+	require.NoError(t, err)
+	require.NoError(t, DeleteProject(db, project.ID))
+	_, _, err = GetProjectCredentialsSecret(db, project.ID)
+	assert.Equal(t, sql.ErrNoRows, err)
+}
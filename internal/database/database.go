@@ -2,20 +2,26 @@ package database
 
 import (
 	"database/sql"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/rs/zerolog/log"
 )
 
+// connectionOptions sont les options de chaque connexion du pool : les clés
+// étrangères (ON DELETE CASCADE) sont appliquées, et une écriture concurrente
+// attend la fin de la précédente au lieu d'échouer avec SQLITE_BUSY
+const connectionOptions = "_foreign_keys=on&_busy_timeout=5000"
+
 // InitDB initialise la connexion à la base de données SQLite et crée les tables
 func InitDB(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, err
+	// Un PRAGMA ne s'appliquerait qu'à une connexion du pool : les options sont
+	// passées dans le DSN, pour toutes les connexions
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
 	}
-
-	// Activer les foreign keys pour SQLite
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	db, err := sql.Open("sqlite3", dbPath+separator+connectionOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -42,17 +48,105 @@ func createTables(db *sql.DB) error {
 		return err
 	}
 
+	// Table project_secrets
+	if err := CreateProjectSecretsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table project_secrets")
+		return err
+	}
+
+	// Table project_credentials
+	if err := CreateProjectCredentialsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table project_credentials")
+		return err
+	}
+
+	// Table agents (référencée par builds)
+	if err := CreateAgentsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table agents")
+		return err
+	}
+
 	// Table builds
 	if err := CreateBuildsTable(db); err != nil {
 		log.Error().Err(err).Msg("Erreur lors de la création de la table builds")
 		return err
 	}
 
-	// Table agents
-	if err := CreateAgentsTable(db); err != nil {
-		log.Error().Err(err).Msg("Erreur lors de la création de la table agents")
+	// Table artifacts
+	if err := CreateArtifactsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table artifacts")
+		return err
+	}
+
+	// Table build_targets
+	if err := CreateBuildTargetsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table build_targets")
+		return err
+	}
+
+	// Table build_submodules
+	if err := CreateBuildSubmodulesTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table build_submodules")
+		return err
+	}
+
+	// Table build_steps
+	if err := CreateBuildStepsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table build_steps")
+		return err
+	}
+
+	// Table test_results
+	if err := CreateTestResultsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table test_results")
+		return err
+	}
+
+	// Table build_coverage
+	if err := CreateBuildCoverageTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table build_coverage")
+		return err
+	}
+
+	// Table reproducibility_artifacts
+	if err := CreateReproducibilityArtifactsTable(db); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la création de la table reproducibility_artifacts")
+		return err
+	}
+
+	return nil
+}
+
+// addColumnIfMissing ajoute une colonne à une table existante si elle n'existe pas encore.
+// Permet de migrer les bases créées par une version précédente de GIP.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, ctype string
+		var dfltValue sql.NullString
+
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if _, err := db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition); err != nil {
 		return err
 	}
 
+	log.Info().Str("table", table).Str("column", column).Msg("Colonne ajoutée à la table")
 	return nil
 }
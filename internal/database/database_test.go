@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"os"
 	"testing"
@@ -23,6 +24,21 @@ func TestInitDB(t *testing.T) {
 	// Vérifier que la connexion fonctionne
 	err = db.Ping()
 	assert.NoError(t, err, "La base de données devrait être accessible")
+
+	// Chaque connexion du pool applique les clés étrangères et attend les écritures concurrentes
+	conns := make([]*sql.Conn, 4)
+	for i := range conns {
+		conns[i], err = db.Conn(context.Background())
+		require.NoError(t, err)
+		defer conns[i].Close()
+	}
+	for _, conn := range conns {
+		var foreignKeys, busyTimeout int
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA foreign_keys").Scan(&foreignKeys))
+		require.NoError(t, conn.QueryRowContext(context.Background(), "PRAGMA busy_timeout").Scan(&busyTimeout))
+		assert.Equal(t, 1, foreignKeys)
+		assert.Equal(t, 5000, busyTimeout)
+	}
 }
 
 func TestCreateTables(t *testing.T) {
@@ -2,18 +2,98 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
+
+	"forgeronvirtuel/gip/internal/mainpkg"
+	"forgeronvirtuel/gip/internal/sandbox"
 )
 
 // Project représente un projet Go déployable
 type Project struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	RepoURL   string    `json:"repo_url"`
-	Branch    string    `json:"branch"`
-	Subdir    string    `json:"subdir"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID               int               `json:"id"`
+	Name             string            `json:"name"`
+	RepoURL          string            `json:"repo_url"`
+	Branch           string            `json:"branch"`
+	Subdir           string            `json:"subdir"`
+	AgentSelector    string            `json:"agent_selector"`    // Labels requis des agents (ex: os=linux,gpu!=true), vide = tous
+	Platforms        []string          `json:"platforms"`         // Cibles de compilation (ex: linux/arm/v7), vide = plateforme de l'exécutant
+	Binaries         []mainpkg.Binary  `json:"binaries"`          // Binaires à compiler, vide = cmd/main.go
+	DiscoverBinaries bool              `json:"discover_binaries"` // Compile aussi chaque package main trouvé sous Subdir
+	Ldflags          string            `json:"ldflags"`           // Modèle -ldflags (ex: -X main.version={{.Tag}}), vide = aucun
+	Env              map[string]string `json:"env"`               // Variables d'environnement des commandes de build
+	RunTests         bool              `json:"run_tests"`         // Exécute go test avant la compilation
+	Coverage         bool              `json:"coverage"`          // Mesure la couverture des tests, avec RunTests
+	CloneDepth       int               `json:"clone_depth"`       // Nombre de commits récupérés par le clone, 0 = tout l'historique
+	Submodules       bool              `json:"submodules"`        // Initialise récursivement les sous-modules au checkout
+	LFS              bool              `json:"lfs"`               // Remplace les pointeurs Git LFS par leurs fichiers au checkout
+	Sandbox          *sandbox.Policy   `json:"sandbox"`           // Exécute les commandes du build dans un sandbox, nil = sans sandbox
+	Hermetic         bool              `json:"hermetic"`          // Télécharge les modules, puis compile et teste hors ligne
+	Reproducible     bool              `json:"reproducible"`      // Recompile chaque build réussi pour vérifier que ses artefacts sont identiques
+	GoToolchain      string            `json:"go_toolchain"`      // Version de Go épinglée, "go.mod" pour suivre le go.mod, vide = go du PATH
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+// projectColumns liste les colonnes lues par scanProject, dans le même ordre
+const projectColumns = `id, name, repo_url, branch, subdir, COALESCE(agent_selector, ''), COALESCE(platforms, '[]'), COALESCE(binaries, '[]'), discover_binaries, COALESCE(ldflags, ''), COALESCE(env, '{}'), run_tests, coverage, clone_depth, submodules, lfs, sandbox, hermetic, reproducible, COALESCE(go_toolchain, ''), created_at, updated_at`
+
+// scanProject lit une ligne de la table projects sélectionnée avec projectColumns
+func scanProject(row rowScanner) (*Project, error) {
+	project := &Project{}
+	var platformsJSON, binariesJSON, envJSON string
+	var sandboxJSON sql.NullString
+	err := row.Scan(
+		&project.ID,
+		&project.Name,
+		&project.RepoURL,
+		&project.Branch,
+		&project.Subdir,
+		&project.AgentSelector,
+		&platformsJSON,
+		&binariesJSON,
+		&project.DiscoverBinaries,
+		&project.Ldflags,
+		&envJSON,
+		&project.RunTests,
+		&project.Coverage,
+		&project.CloneDepth,
+		&project.Submodules,
+		&project.LFS,
+		&sandboxJSON,
+		&project.Hermetic,
+		&project.Reproducible,
+		&project.GoToolchain,
+		&project.CreatedAt,
+		&project.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(platformsJSON), &project.Platforms); err != nil {
+		return nil, err
+	}
+	if project.Platforms == nil {
+		project.Platforms = []string{}
+	}
+	if err := json.Unmarshal([]byte(binariesJSON), &project.Binaries); err != nil {
+		return nil, err
+	}
+	if project.Binaries == nil {
+		project.Binaries = []mainpkg.Binary{}
+	}
+	if err := json.Unmarshal([]byte(envJSON), &project.Env); err != nil {
+		return nil, err
+	}
+	if project.Env == nil {
+		project.Env = map[string]string{}
+	}
+	if sandboxJSON.Valid {
+		if err := json.Unmarshal([]byte(sandboxJSON.String), &project.Sandbox); err != nil {
+			return nil, err
+		}
+	}
+	return project, nil
 }
 
 // CreateProjectsTable crée la table projects dans la base de données
@@ -25,6 +105,21 @@ func CreateProjectsTable(db *sql.DB) error {
 		repo_url TEXT NOT NULL,
 		branch TEXT NOT NULL DEFAULT 'main',
 		subdir TEXT,
+		agent_selector TEXT,
+		platforms TEXT,
+		binaries TEXT,
+		discover_binaries BOOLEAN NOT NULL DEFAULT 0,
+		ldflags TEXT,
+		env TEXT,
+		run_tests BOOLEAN NOT NULL DEFAULT 0,
+		coverage BOOLEAN NOT NULL DEFAULT 0,
+		clone_depth INTEGER NOT NULL DEFAULT 0,
+		submodules BOOLEAN NOT NULL DEFAULT 0,
+		lfs BOOLEAN NOT NULL DEFAULT 0,
+		sandbox TEXT,
+		hermetic BOOLEAN NOT NULL DEFAULT 0,
+		reproducible BOOLEAN NOT NULL DEFAULT 0,
+		go_toolchain TEXT,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);
@@ -34,7 +129,50 @@ func CreateProjectsTable(db *sql.DB) error {
 		return err
 	}
 
-	return nil
+	// Migration des bases créées avant l'ajout des colonnes
+	if err := addColumnIfMissing(db, "projects", "agent_selector", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "platforms", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "binaries", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "discover_binaries", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "ldflags", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "env", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "run_tests", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "coverage", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "clone_depth", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "submodules", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "lfs", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "sandbox", "TEXT"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "hermetic", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(db, "projects", "reproducible", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return addColumnIfMissing(db, "projects", "go_toolchain", "TEXT")
 }
 
 // CreateProject insère un nouveau projet dans la base de données
@@ -63,65 +201,17 @@ func CreateProject(db *sql.DB, name, repoURL, branch, subdir string) (*Project,
 
 // GetProjectByID récupère un projet par son ID
 func GetProjectByID(db *sql.DB, id int) (*Project, error) {
-	query := `
-	SELECT id, name, repo_url, branch, subdir, created_at, updated_at
-	FROM projects
-	WHERE id = ?
-	`
-
-	project := &Project{}
-	err := db.QueryRow(query, id).Scan(
-		&project.ID,
-		&project.Name,
-		&project.RepoURL,
-		&project.Branch,
-		&project.Subdir,
-		&project.CreatedAt,
-		&project.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return project, nil
+	return scanProject(db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE id = ?", id))
 }
 
 // GetProjectByName récupère un projet par son nom
 func GetProjectByName(db *sql.DB, name string) (*Project, error) {
-	query := `
-	SELECT id, name, repo_url, branch, subdir, created_at, updated_at
-	FROM projects
-	WHERE name = ?
-	`
-
-	project := &Project{}
-	err := db.QueryRow(query, name).Scan(
-		&project.ID,
-		&project.Name,
-		&project.RepoURL,
-		&project.Branch,
-		&project.Subdir,
-		&project.CreatedAt,
-		&project.UpdatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return project, nil
+	return scanProject(db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE name = ?", name))
 }
 
 // GetAllProjects récupère tous les projets
 func GetAllProjects(db *sql.DB) ([]*Project, error) {
-	query := `
-	SELECT id, name, repo_url, branch, subdir, created_at, updated_at
-	FROM projects
-	ORDER BY created_at DESC
-	`
-
-	rows, err := db.Query(query)
+	rows, err := db.Query("SELECT " + projectColumns + " FROM projects ORDER BY created_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -129,16 +219,7 @@ func GetAllProjects(db *sql.DB) ([]*Project, error) {
 
 	projects := []*Project{}
 	for rows.Next() {
-		project := &Project{}
-		err := rows.Scan(
-			&project.ID,
-			&project.Name,
-			&project.RepoURL,
-			&project.Branch,
-			&project.Subdir,
-			&project.CreatedAt,
-			&project.UpdatedAt,
-		)
+		project, err := scanProject(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -164,6 +245,160 @@ func UpdateProject(db *sql.DB, id int, name, repoURL, branch, subdir string) (*P
 	return GetProjectByID(db, id)
 }
 
+// UpdateProjectAgentSelector met à jour le sélecteur d'agents d'un projet.
+// Le sélecteur doit avoir été validé avec selector.Parse.
+func UpdateProjectAgentSelector(db *sql.DB, id int, agentSelector string) error {
+	_, err := db.Exec(
+		"UPDATE projects SET agent_selector = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		agentSelector, id,
+	)
+	return err
+}
+
+// UpdateProjectPlatforms met à jour les plateformes cibles d'un projet.
+// Les plateformes doivent avoir été validées avec platform.ParseList.
+func UpdateProjectPlatforms(db *sql.DB, id int, platforms []string) error {
+	if platforms == nil {
+		platforms = []string{}
+	}
+	platformsJSON, err := json.Marshal(platforms)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"UPDATE projects SET platforms = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(platformsJSON), id,
+	)
+	return err
+}
+
+// UpdateProjectBinaries met à jour les binaires compilés pour un projet et
+// l'activation de la découverte des packages main.
+// Les binaires doivent avoir été validés avec mainpkg.ParseList.
+func UpdateProjectBinaries(db *sql.DB, id int, binaries []mainpkg.Binary, discover bool) error {
+	if binaries == nil {
+		binaries = []mainpkg.Binary{}
+	}
+	binariesJSON, err := json.Marshal(binaries)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"UPDATE projects SET binaries = ?, discover_binaries = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(binariesJSON), discover, id,
+	)
+	return err
+}
+
+// UpdateProjectLdflags met à jour le modèle -ldflags d'un projet.
+// Le modèle doit avoir été validé avec ldflags.Validate.
+func UpdateProjectLdflags(db *sql.DB, id int, ldflags string) error {
+	_, err := db.Exec(
+		"UPDATE projects SET ldflags = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		ldflags, id,
+	)
+	return err
+}
+
+// UpdateProjectEnv met à jour les variables d'environnement d'un projet.
+// Les variables doivent avoir été validées avec buildenv.Validate.
+func UpdateProjectEnv(db *sql.DB, id int, env map[string]string) error {
+	if env == nil {
+		env = map[string]string{}
+	}
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"UPDATE projects SET env = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		string(envJSON), id,
+	)
+	return err
+}
+
+// UpdateProjectTests active ou désactive l'exécution des tests avant la
+// compilation et la mesure de leur couverture
+func UpdateProjectTests(db *sql.DB, id int, runTests, coverage bool) error {
+	_, err := db.Exec(
+		"UPDATE projects SET run_tests = ?, coverage = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		runTests, coverage, id,
+	)
+	return err
+}
+
+// UpdateProjectCloneDepth met à jour la profondeur du clone d'un projet :
+// le nombre de commits récupérés depuis chaque référence, 0 pour tout l'historique
+func UpdateProjectCloneDepth(db *sql.DB, id int, depth int) error {
+	_, err := db.Exec(
+		"UPDATE projects SET clone_depth = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		depth, id,
+	)
+	return err
+}
+
+// UpdateProjectCheckout active ou désactive l'initialisation des sous-modules et
+// le téléchargement des fichiers Git LFS au checkout d'un projet
+func UpdateProjectCheckout(db *sql.DB, id int, submodules, lfs bool) error {
+	_, err := db.Exec(
+		"UPDATE projects SET submodules = ?, lfs = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		submodules, lfs, id,
+	)
+	return err
+}
+
+// UpdateProjectSandbox met à jour la politique de sandbox d'un projet, nil pour
+// exécuter ses builds sans sandbox.
+// La politique doit avoir été validée avec Policy.Validate.
+func UpdateProjectSandbox(db *sql.DB, id int, policy *sandbox.Policy) error {
+	var policyJSON sql.NullString
+	if policy != nil {
+		data, err := json.Marshal(policy)
+		if err != nil {
+			return err
+		}
+		policyJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
+	_, err := db.Exec(
+		"UPDATE projects SET sandbox = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		policyJSON, id,
+	)
+	return err
+}
+
+// UpdateProjectHermetic active ou désactive les builds hermétiques d'un projet
+func UpdateProjectHermetic(db *sql.DB, id int, hermetic bool) error {
+	_, err := db.Exec(
+		"UPDATE projects SET hermetic = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		hermetic, id,
+	)
+	return err
+}
+
+// UpdateProjectReproducible active ou désactive la vérification de la
+// reproductibilité des builds d'un projet
+func UpdateProjectReproducible(db *sql.DB, id int, reproducible bool) error {
+	_, err := db.Exec(
+		"UPDATE projects SET reproducible = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		reproducible, id,
+	)
+	return err
+}
+
+// UpdateProjectGoToolchain met à jour la toolchain Go des builds d'un projet :
+// une version, toolchain.GoMod, ou vide pour la commande go du PATH
+func UpdateProjectGoToolchain(db *sql.DB, id int, goToolchain string) error {
+	_, err := db.Exec(
+		"UPDATE projects SET go_toolchain = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?",
+		goToolchain, id,
+	)
+	return err
+}
+
 // DeleteProject supprime un projet
 func DeleteProject(db *sql.DB, id int) error {
 	query := `DELETE FROM projects WHERE id = ?`
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"testing"
 
+	"forgeronvirtuel/gip/internal/sandbox"
+
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -167,6 +169,29 @@ func TestUpdateProject(t *testing.T) {
 	assert.True(t, updated.UpdatedAt.After(created.UpdatedAt) || updated.UpdatedAt.Equal(created.UpdatedAt))
 }
 
+func TestUpdateProjectSandbox(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, CreateProjectsTable(db))
+	created, err := CreateProject(db, "api-users", "https://github.com/user/api-users.git", "main", "")
+	require.NoError(t, err)
+	assert.Nil(t, created.Sandbox)
+
+	policy := &sandbox.Policy{CPUSeconds: 120, MemoryMB: 1024}
+	require.NoError(t, UpdateProjectSandbox(db, created.ID, policy))
+	project, err := GetProjectByID(db, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, policy, project.Sandbox)
+
+	// nil désactive le sandbox
+	require.NoError(t, UpdateProjectSandbox(db, created.ID, nil))
+	project, err = GetProjectByID(db, created.ID)
+	require.NoError(t, err)
+	assert.Nil(t, project.Sandbox)
+}
+
 func TestDeleteProject(t *testing.T) {
 	db, err := sql.Open("sqlite3", ":memory:")
 	require.NoError(t, err)
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"forgeronvirtuel/gip/internal/bindiff"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Résultats de la vérification de la reproductibilité d'un build
+const (
+	ReproducibilityPending = "pending" // Le build de vérification n'est pas terminé
+	ReproducibilityPassed  = "passed"  // Les artefacts recompilés sont identiques
+	ReproducibilityFailed  = "failed"  // Un artefact diffère, ou le build de vérification a échoué
+)
+
+// ReproducibilityArtifact compare un artefact d'un build à celui du build de
+// vérification, pour le même binaire et la même plateforme
+type ReproducibilityArtifact struct {
+	ID            int               `json:"id"`
+	BuildID       int               `json:"build_id"`
+	Name          string            `json:"name"`
+	Binary        string            `json:"binary"`
+	Platform      string            `json:"platform"`
+	SHA256        string            `json:"sha256"`
+	RebuildSHA256 string            `json:"rebuild_sha256"` // Vide si le build de vérification ne l'a pas produit
+	Identical     bool              `json:"identical"`
+	Sections      []bindiff.Section `json:"sections"` // Sections qui diffèrent
+}
+
+// reproducibilityArtifactColumns liste les colonnes lues par GetReproducibilityArtifacts, dans le même ordre
+const reproducibilityArtifactColumns = `id, build_id, name, binary, platform, sha256, rebuild_sha256, identical, sections`
+
+// CreateReproducibilityArtifactsTable crée la table reproducibility_artifacts si elle n'existe pas
+func CreateReproducibilityArtifactsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS reproducibility_artifacts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		build_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		binary TEXT NOT NULL,
+		platform TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		rebuild_sha256 TEXT NOT NULL,
+		identical BOOLEAN NOT NULL,
+		sections TEXT NOT NULL,
+		FOREIGN KEY (build_id) REFERENCES builds(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_reproducibility_artifacts_build_id ON reproducibility_artifacts(build_id);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'reproducibility_artifacts' créée ou déjà existante")
+	return nil
+}
+
+// CreateRebuild crée le build de vérification de la reproductibilité d'un build
+// réussi : un nouveau build en attente du même commit, et marque la vérification
+// du build en attente
+func CreateRebuild(db *sql.DB, build *Build) (*Build, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	startedAt := time.Now()
+	result, err := tx.Exec(
+		"INSERT INTO builds (project_id, branch, requested_commit, rebuild_of, status, started_at) VALUES (?, ?, ?, ?, ?, ?)",
+		build.ProjectID, build.Branch, build.CommitSHA, build.ID, "pending", startedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE builds SET reproducibility = ?, reproducibility_error = NULL WHERE id = ?",
+		ReproducibilityPending, build.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &Build{
+		ID:              int(id),
+		ProjectID:       build.ProjectID,
+		Branch:          build.Branch,
+		RequestedCommit: build.CommitSHA,
+		RebuildOf:       sql.NullInt64{Int64: int64(build.ID), Valid: true},
+		Status:          "pending",
+		StartedAt:       startedAt,
+		CreatedAt:       startedAt,
+	}, nil
+}
+
+// GetRebuildID retourne l'ID du dernier build de vérification d'un build, 0 s'il
+// n'en a pas
+func GetRebuildID(db *sql.DB, buildID int) (int, error) {
+	var id int
+	err := db.QueryRow("SELECT id FROM builds WHERE rebuild_of = ? ORDER BY id DESC LIMIT 1", buildID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// SaveReproducibility enregistre le résultat de la vérification de la
+// reproductibilité d'un build, avec la comparaison de chacun de ses artefacts, en
+// remplaçant celle d'une vérification précédente
+func SaveReproducibility(db *sql.DB, buildID int, status, errMsg string, artifacts []ReproducibilityArtifact) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"UPDATE builds SET reproducibility = ?, reproducibility_error = ? WHERE id = ?",
+		status, errMsg, buildID,
+	)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM reproducibility_artifacts WHERE build_id = ?", buildID); err != nil {
+		return err
+	}
+
+	for _, artifact := range artifacts {
+		sections, err := json.Marshal(artifact.Sections)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(
+			`INSERT INTO reproducibility_artifacts (build_id, name, binary, platform, sha256, rebuild_sha256, identical, sections)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			buildID, artifact.Name, artifact.Binary, artifact.Platform, artifact.SHA256, artifact.RebuildSHA256, artifact.Identical, string(sections),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetReproducibilityArtifacts récupère la comparaison de chaque artefact d'un
+// build avec celui de son build de vérification
+func GetReproducibilityArtifacts(db *sql.DB, buildID int) ([]ReproducibilityArtifact, error) {
+	rows, err := db.Query("SELECT "+reproducibilityArtifactColumns+" FROM reproducibility_artifacts WHERE build_id = ? ORDER BY id", buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	artifacts := []ReproducibilityArtifact{}
+	for rows.Next() {
+		var artifact ReproducibilityArtifact
+		var sections string
+		err := rows.Scan(&artifact.ID, &artifact.BuildID, &artifact.Name, &artifact.Binary, &artifact.Platform,
+			&artifact.SHA256, &artifact.RebuildSHA256, &artifact.Identical, &sections)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(sections), &artifact.Sections); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	return artifacts, rows.Err()
+}
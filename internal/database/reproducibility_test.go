@@ -0,0 +1,65 @@
+package database
+
+import (
+	"strconv"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/bindiff"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReproducibility(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	require.NoError(t, CreateReproducibilityArtifactsTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, err := CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+	require.NoError(t, SaveBuildCommit(db, build.ID, &CommitInfo{SHA: "4f2a9c0e8d7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f"}, "v1.0.0"))
+	build, err = GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+
+	id, err := GetRebuildID(db, build.ID)
+	require.NoError(t, err)
+	assert.Zero(t, id)
+
+	// Le build de vérification compile le même commit
+	rebuild, err := CreateRebuild(db, build)
+	require.NoError(t, err)
+	stored, err := GetBuildByID(db, strconv.Itoa(rebuild.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "pending", stored.Status)
+	assert.Equal(t, "main", stored.Branch)
+	assert.Equal(t, build.CommitSHA, stored.RequestedCommit)
+	assert.Equal(t, int64(build.ID), stored.RebuildOf.Int64)
+
+	id, err = GetRebuildID(db, build.ID)
+	require.NoError(t, err)
+	assert.Equal(t, rebuild.ID, id)
+	stored, err = GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, ReproducibilityPending, stored.Reproducibility)
+
+	artifacts := []ReproducibilityArtifact{
+		{Name: "api-1", Binary: "api", Platform: "linux/amd64", SHA256: "aaaa", RebuildSHA256: "aaaa", Identical: true, Sections: []bindiff.Section{}},
+		{Name: "api-1.exe", Binary: "api", Platform: "windows/amd64", SHA256: "bbbb", RebuildSHA256: "cccc",
+			Sections: []bindiff.Section{{Name: ".rdata", Size: 100, OtherSize: 104}}},
+	}
+	require.NoError(t, SaveReproducibility(db, build.ID, ReproducibilityFailed, "1 of 2 artifacts differ", artifacts))
+
+	stored, err = GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, ReproducibilityFailed, stored.Reproducibility)
+	assert.Equal(t, "1 of 2 artifacts differ", stored.ReproducibilityError)
+
+	saved, err := GetReproducibilityArtifacts(db, build.ID)
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	assert.True(t, saved[0].Identical)
+	assert.Empty(t, saved[0].Sections)
+	assert.False(t, saved[1].Identical)
+	assert.Equal(t, artifacts[1].Sections, saved[1].Sections)
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ProjectSecret décrit un secret d'un projet. Sa valeur, chiffrée avec la clé
+// maître du serveur, n'est lue que par GetProjectSecretValues pour préparer un build.
+type ProjectSecret struct {
+	ID        int       `json:"id"`
+	ProjectID int       `json:"project_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// projectSecretColumns liste les colonnes lues par scanProjectSecret, dans le même ordre
+const projectSecretColumns = `id, project_id, name, created_at, updated_at`
+
+// scanProjectSecret lit une ligne de la table project_secrets sélectionnée avec projectSecretColumns
+func scanProjectSecret(row rowScanner) (*ProjectSecret, error) {
+	secret := &ProjectSecret{}
+	err := row.Scan(&secret.ID, &secret.ProjectID, &secret.Name, &secret.CreatedAt, &secret.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// CreateProjectSecretsTable crée la table project_secrets si elle n'existe pas
+func CreateProjectSecretsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS project_secrets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		project_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		value BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (project_id, name),
+		FOREIGN KEY (project_id) REFERENCES projects(id) ON DELETE CASCADE
+	);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'project_secrets' créée ou déjà existante")
+	return nil
+}
+
+// SetProjectSecret crée ou remplace un secret d'un projet. encrypted est la
+// valeur chiffrée avec secrets.Cipher.Encrypt.
+func SetProjectSecret(db *sql.DB, projectID int, name string, encrypted []byte) (*ProjectSecret, error) {
+	_, err := db.Exec(
+		`INSERT INTO project_secrets (project_id, name, value) VALUES (?, ?, ?)
+		ON CONFLICT (project_id, name) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+		projectID, name, encrypted,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanProjectSecret(db.QueryRow("SELECT "+projectSecretColumns+" FROM project_secrets WHERE project_id = ? AND name = ?", projectID, name))
+}
+
+// GetProjectSecrets liste les secrets d'un projet, sans leurs valeurs
+func GetProjectSecrets(db *sql.DB, projectID int) ([]ProjectSecret, error) {
+	rows, err := db.Query("SELECT "+projectSecretColumns+" FROM project_secrets WHERE project_id = ? ORDER BY name", projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets := []ProjectSecret{}
+	for rows.Next() {
+		secret, err := scanProjectSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, *secret)
+	}
+
+	return secrets, rows.Err()
+}
+
+// GetProjectSecretValues retourne les valeurs chiffrées des secrets d'un projet, par nom
+func GetProjectSecretValues(db *sql.DB, projectID int) (map[string][]byte, error) {
+	rows, err := db.Query("SELECT name, value FROM project_secrets WHERE project_id = ?", projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := make(map[string][]byte)
+	for rows.Next() {
+		var name string
+		var value []byte
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+
+	return values, rows.Err()
+}
+
+// DeleteProjectSecret supprime un secret d'un projet.
+// Retourne sql.ErrNoRows si le secret n'existe pas.
+func DeleteProjectSecret(db *sql.DB, projectID int, name string) error {
+	result, err := db.Exec("DELETE FROM project_secrets WHERE project_id = ? AND name = ?", projectID, name)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectSecrets(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	_, err := db.Exec("PRAGMA foreign_keys = ON")
+	require.NoError(t, err)
+	require.NoError(t, CreateProjectSecretsTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	other, _ := CreateProject(db, "web", "https://github.com/user/web.git", "main", "")
+
+	secret, err := SetProjectSecret(db, project.ID, "NPM_TOKEN", []byte("sealed-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "NPM_TOKEN", secret.Name)
+	assert.Equal(t, project.ID, secret.ProjectID)
+
+	// Remplacer la valeur garde le même secret
+	replaced, err := SetProjectSecret(db, project.ID, "NPM_TOKEN", []byte("sealed-2"))
+	require.NoError(t, err)
+	assert.Equal(t, secret.ID, replaced.ID)
+
+	_, err = SetProjectSecret(db, project.ID, "AWS_KEY", []byte("sealed-3"))
+	require.NoError(t, err)
+	_, err = SetProjectSecret(db, other.ID, "NPM_TOKEN", []byte("sealed-4"))
+	require.NoError(t, err)
+
+	secrets, err := GetProjectSecrets(db, project.ID)
+	require.NoError(t, err)
+	require.Len(t, secrets, 2)
+	assert.Equal(t, "AWS_KEY", secrets[0].Name)
+	assert.Equal(t, "NPM_TOKEN", secrets[1].Name)
+
+	values, err := GetProjectSecretValues(db, project.ID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"AWS_KEY": []byte("sealed-3"), "NPM_TOKEN": []byte("sealed-2")}, values)
+
+	require.NoError(t, DeleteProjectSecret(db, project.ID, "AWS_KEY"))
+	assert.Equal(t, sql.ErrNoRows, DeleteProjectSecret(db, project.ID, "AWS_KEY"))
+
+	// Les secrets sont supprimés avec leur projet
+	require.NoError(t, DeleteProject(db, project.ID))
+	secrets, err = GetProjectSecrets(db, project.ID)
+	require.NoError(t, err)
+	assert.Empty(t, secrets)
+}
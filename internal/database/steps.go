@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BuildStep est une étape exécutée par le pipeline d'un build : le clone, puis
+// chaque étape du pipeline (par défaut go mod download, go test et go build)
+type BuildStep struct {
+	ID              int       `json:"id"`
+	BuildID         int       `json:"build_id"`
+	Name            string    `json:"name"`
+	Command         string    `json:"command"` // Commandes exécutées, une par ligne
+	Status          string    `json:"status"`  // success, failed
+	ExitCode        int       `json:"exit_code"`
+	ContinueOnError bool      `json:"continue_on_error"` // Un échec n'a pas arrêté le build
+	LogOutput       string    `json:"log_output"`
+	Error           string    `json:"error"`
+	StartedAt       time.Time `json:"started_at"`
+	EndedAt         time.Time `json:"ended_at"`
+}
+
+// buildStepColumns liste les colonnes lues par GetBuildSteps, dans le même ordre
+const buildStepColumns = `id, build_id, name, COALESCE(command, ''), status, exit_code, continue_on_error, COALESCE(log_output, ''), COALESCE(error, ''), started_at, ended_at`
+
+// CreateBuildStepsTable crée la table build_steps si elle n'existe pas
+func CreateBuildStepsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS build_steps (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		build_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		command TEXT,
+		status TEXT NOT NULL,
+		exit_code INTEGER NOT NULL DEFAULT 0,
+		continue_on_error BOOLEAN NOT NULL DEFAULT 0,
+		log_output TEXT,
+		error TEXT,
+		started_at DATETIME,
+		ended_at DATETIME,
+		FOREIGN KEY (build_id) REFERENCES builds(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_build_steps_build_id ON build_steps(build_id);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'build_steps' créée ou déjà existante")
+	return nil
+}
+
+// SaveBuildSteps enregistre les étapes d'un build, dans l'ordre d'exécution, en
+// remplaçant celles d'une exécution précédente (build repris après un arrêt)
+func SaveBuildSteps(db *sql.DB, buildID int, steps []BuildStep) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM build_steps WHERE build_id = ?", buildID); err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		_, err := tx.Exec(
+			`INSERT INTO build_steps (build_id, name, command, status, exit_code, continue_on_error, log_output, error, started_at, ended_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			buildID, step.Name, step.Command, step.Status, step.ExitCode, step.ContinueOnError, step.LogOutput, step.Error, step.StartedAt, step.EndedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBuildSteps récupère les étapes d'un build, dans l'ordre d'exécution
+func GetBuildSteps(db *sql.DB, buildID int) ([]BuildStep, error) {
+	rows, err := db.Query("SELECT "+buildStepColumns+" FROM build_steps WHERE build_id = ? ORDER BY id", buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []BuildStep{}
+	for rows.Next() {
+		var step BuildStep
+		err := rows.Scan(&step.ID, &step.BuildID, &step.Name, &step.Command, &step.Status, &step.ExitCode, &step.ContinueOnError,
+			&step.LogOutput, &step.Error, &step.StartedAt, &step.EndedAt)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, rows.Err()
+}
@@ -0,0 +1,46 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveBuildSteps(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	require.NoError(t, CreateBuildStepsTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, err := CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+
+	// Une exécution précédente, interrompue, est remplacée
+	require.NoError(t, SaveBuildSteps(db, build.ID, []BuildStep{{Name: "clone", Status: "failed", ExitCode: -1}}))
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	steps := []BuildStep{
+		{Name: "clone", Command: "git clone https://github.com/user/api.git", Status: "success", StartedAt: start, EndedAt: start.Add(time.Second)},
+		{Name: "lint", Command: "go vet ./...", Status: "failed", ExitCode: 1, ContinueOnError: true, LogOutput: "vet: boom\n", Error: "Step lint failed: exit status 1",
+			StartedAt: start.Add(time.Second), EndedAt: start.Add(3 * time.Second)},
+		{Name: "go build", Command: "go build -o out/api-1 ./cmd/main.go", Status: "success", StartedAt: start.Add(3 * time.Second), EndedAt: start.Add(9 * time.Second)},
+	}
+	require.NoError(t, SaveBuildSteps(db, build.ID, steps))
+
+	stored, err := GetBuildSteps(db, build.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	assert.Equal(t, []string{"clone", "lint", "go build"}, []string{stored[0].Name, stored[1].Name, stored[2].Name})
+	assert.Equal(t, build.ID, stored[1].BuildID)
+	assert.Equal(t, 1, stored[1].ExitCode)
+	assert.True(t, stored[1].ContinueOnError)
+	assert.Equal(t, "vet: boom\n", stored[1].LogOutput)
+	assert.Equal(t, "Step lint failed: exit status 1", stored[1].Error)
+	assert.Equal(t, 2*time.Second, stored[1].EndedAt.Sub(stored[1].StartedAt))
+
+	empty, err := GetBuildSteps(db, build.ID+1)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
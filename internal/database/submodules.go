@@ -0,0 +1,87 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BuildSubmodule est un sous-module extrait par un build, au commit enregistré
+// dans le dépôt parent
+type BuildSubmodule struct {
+	ID      int    `json:"id"`
+	BuildID int    `json:"build_id"`
+	Path    string `json:"path"` // Chemin dans le dépôt du build, y compris pour un sous-module imbriqué
+	URL     string `json:"url"`  // URL résolue, les URL relatives le sont par rapport au dépôt parent
+	SHA     string `json:"sha"`
+}
+
+// buildSubmoduleColumns liste les colonnes lues par GetBuildSubmodules, dans le même ordre
+const buildSubmoduleColumns = `id, build_id, path, url, sha`
+
+// CreateBuildSubmodulesTable crée la table build_submodules si elle n'existe pas
+func CreateBuildSubmodulesTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS build_submodules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		build_id INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		url TEXT NOT NULL,
+		sha TEXT NOT NULL,
+		FOREIGN KEY (build_id) REFERENCES builds(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_build_submodules_build_id ON build_submodules(build_id);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'build_submodules' créée ou déjà existante")
+	return nil
+}
+
+// SaveBuildSubmodules enregistre les sous-modules extraits par un build, en
+// remplaçant ceux d'une exécution précédente (build repris après un arrêt)
+func SaveBuildSubmodules(db *sql.DB, buildID int, submodules []BuildSubmodule) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM build_submodules WHERE build_id = ?", buildID); err != nil {
+		return err
+	}
+
+	for _, submodule := range submodules {
+		_, err := tx.Exec(
+			"INSERT INTO build_submodules (build_id, path, url, sha) VALUES (?, ?, ?, ?)",
+			buildID, submodule.Path, submodule.URL, submodule.SHA,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBuildSubmodules récupère les sous-modules extraits par un build, dans l'ordre du checkout
+func GetBuildSubmodules(db *sql.DB, buildID int) ([]BuildSubmodule, error) {
+	rows, err := db.Query("SELECT "+buildSubmoduleColumns+" FROM build_submodules WHERE build_id = ? ORDER BY id", buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	submodules := []BuildSubmodule{}
+	for rows.Next() {
+		var submodule BuildSubmodule
+		if err := rows.Scan(&submodule.ID, &submodule.BuildID, &submodule.Path, &submodule.URL, &submodule.SHA); err != nil {
+			return nil, err
+		}
+		submodules = append(submodules, submodule)
+	}
+
+	return submodules, rows.Err()
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveBuildSubmodules(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	require.NoError(t, CreateBuildSubmodulesTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, err := CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+
+	// Une exécution précédente, interrompue, est remplacée
+	require.NoError(t, SaveBuildSubmodules(db, build.ID, []BuildSubmodule{{Path: "old", URL: "https://github.com/user/old.git", SHA: "0000"}}))
+
+	submodules := []BuildSubmodule{
+		{Path: "proto", URL: "https://github.com/user/proto.git", SHA: "1111111111111111111111111111111111111111"},
+		{Path: "proto/third_party/googleapis", URL: "https://github.com/googleapis/googleapis.git", SHA: "2222222222222222222222222222222222222222"},
+	}
+	require.NoError(t, SaveBuildSubmodules(db, build.ID, submodules))
+
+	stored, err := GetBuildSubmodules(db, build.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, "proto", stored[0].Path)
+	assert.Equal(t, build.ID, stored[1].BuildID)
+	assert.Equal(t, submodules[1].URL, stored[1].URL)
+	assert.Equal(t, submodules[1].SHA, stored[1].SHA)
+
+	empty, err := GetBuildSubmodules(db, build.ID+1)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
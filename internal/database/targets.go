@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// BuildTarget est le résultat de la compilation d'un build pour une plateforme
+type BuildTarget struct {
+	ID        int       `json:"id"`
+	BuildID   int       `json:"build_id"`
+	Platform  string    `json:"platform"` // os/arch[/variante]
+	Status    string    `json:"status"`   // success, failed
+	LogOutput string    `json:"log_output"`
+	Error     string    `json:"error"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+// buildTargetColumns liste les colonnes lues par GetBuildTargets, dans le même ordre
+const buildTargetColumns = `id, build_id, platform, status, COALESCE(log_output, ''), COALESCE(error, ''), started_at, ended_at`
+
+// CreateBuildTargetsTable crée la table build_targets si elle n'existe pas
+func CreateBuildTargetsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS build_targets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		build_id INTEGER NOT NULL,
+		platform TEXT NOT NULL,
+		status TEXT NOT NULL,
+		log_output TEXT,
+		error TEXT,
+		started_at DATETIME,
+		ended_at DATETIME,
+		FOREIGN KEY (build_id) REFERENCES builds(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_build_targets_build_id ON build_targets(build_id);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'build_targets' créée ou déjà existante")
+	return nil
+}
+
+// SaveBuildTargets enregistre le résultat de chaque plateforme d'un build, en
+// remplaçant ceux d'une exécution précédente (build repris après un arrêt)
+func SaveBuildTargets(db *sql.DB, buildID int, targets []BuildTarget) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM build_targets WHERE build_id = ?", buildID); err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		_, err := tx.Exec(
+			`INSERT INTO build_targets (build_id, platform, status, log_output, error, started_at, ended_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			buildID, target.Platform, target.Status, target.LogOutput, target.Error, target.StartedAt, target.EndedAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetBuildTargets récupère le résultat de chaque plateforme d'un build
+func GetBuildTargets(db *sql.DB, buildID int) ([]BuildTarget, error) {
+	rows, err := db.Query("SELECT "+buildTargetColumns+" FROM build_targets WHERE build_id = ? ORDER BY id", buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []BuildTarget{}
+	for rows.Next() {
+		var target BuildTarget
+		err := rows.Scan(&target.ID, &target.BuildID, &target.Platform, &target.Status, &target.LogOutput, &target.Error, &target.StartedAt, &target.EndedAt)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, rows.Err()
+}
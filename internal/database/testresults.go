@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TestResult est le résultat d'un test, ou d'un package de tests si Test est
+// vide, exécuté par go test pendant un build
+type TestResult struct {
+	ID       int     `json:"id"`
+	BuildID  int     `json:"build_id"`
+	Package  string  `json:"package"`
+	Test     string  `json:"test"`     // Vide pour le résultat du package
+	Status   string  `json:"status"`   // pass, fail, skip
+	Duration float64 `json:"duration"` // En secondes
+	Output   string  `json:"output"`
+}
+
+// TestSummary compte les résultats des tests d'un build
+type TestSummary struct {
+	Packages       int     `json:"packages"`
+	FailedPackages int     `json:"failed_packages"`
+	Total          int     `json:"total"`
+	Passed         int     `json:"passed"`
+	Failed         int     `json:"failed"`
+	Skipped        int     `json:"skipped"`
+	Duration       float64 `json:"duration"` // Somme des durées des packages, en secondes
+}
+
+// SummarizeTests compte les résultats des tests et des packages
+func SummarizeTests(results []TestResult) TestSummary {
+	var summary TestSummary
+	for _, result := range results {
+		if result.Test == "" {
+			summary.Packages++
+			summary.Duration += result.Duration
+			if result.Status == "fail" {
+				summary.FailedPackages++
+			}
+			continue
+		}
+
+		summary.Total++
+		switch result.Status {
+		case "pass":
+			summary.Passed++
+		case "fail":
+			summary.Failed++
+		case "skip":
+			summary.Skipped++
+		}
+	}
+	return summary
+}
+
+// testResultColumns liste les colonnes lues par GetTestResults, dans le même ordre
+const testResultColumns = `id, build_id, package, test, status, duration, COALESCE(output, '')`
+
+// CreateTestResultsTable crée la table test_results si elle n'existe pas
+func CreateTestResultsTable(db *sql.DB) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS test_results (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		build_id INTEGER NOT NULL,
+		package TEXT NOT NULL,
+		test TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		duration REAL NOT NULL DEFAULT 0,
+		output TEXT,
+		FOREIGN KEY (build_id) REFERENCES builds(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_test_results_build_id ON test_results(build_id);
+	`
+	if _, err := db.Exec(query); err != nil {
+		return err
+	}
+
+	log.Info().Msg("Table 'test_results' créée ou déjà existante")
+	return nil
+}
+
+// SaveTestResults enregistre les résultats des tests d'un build, en remplaçant
+// ceux d'une exécution précédente (build repris après un arrêt)
+func SaveTestResults(db *sql.DB, buildID int, results []TestResult) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM test_results WHERE build_id = ?", buildID); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		_, err := tx.Exec(
+			`INSERT INTO test_results (build_id, package, test, status, duration, output)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			buildID, result.Package, result.Test, result.Status, result.Duration, result.Output,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTestResults récupère les résultats des tests d'un build, dans l'ordre de leur exécution
+func GetTestResults(db *sql.DB, buildID int) ([]TestResult, error) {
+	rows, err := db.Query("SELECT "+testResultColumns+" FROM test_results WHERE build_id = ? ORDER BY id", buildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []TestResult{}
+	for rows.Next() {
+		var result TestResult
+		err := rows.Scan(&result.ID, &result.BuildID, &result.Package, &result.Test, &result.Status, &result.Duration, &result.Output)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
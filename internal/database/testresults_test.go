@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveTestResults(t *testing.T) {
+	db := setupBuildsTestDB(t)
+	defer db.Close()
+	require.NoError(t, CreateTestResultsTable(db))
+
+	project, _ := CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	build, err := CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+
+	// Une exécution précédente, interrompue, est remplacée
+	require.NoError(t, SaveTestResults(db, build.ID, []TestResult{{Package: "example.com/api", Status: "fail"}}))
+
+	results := []TestResult{
+		{Package: "example.com/api", Test: "TestOK", Status: "pass", Duration: 0.01, Output: "=== RUN   TestOK\n"},
+		{Package: "example.com/api", Test: "TestBroken", Status: "fail", Duration: 0.02, Output: "api_test.go:12: boom\n"},
+		{Package: "example.com/api", Test: "TestLater", Status: "skip"},
+		{Package: "example.com/api", Status: "fail", Duration: 0.5},
+		{Package: "example.com/api/util", Status: "skip"},
+	}
+	require.NoError(t, SaveTestResults(db, build.ID, results))
+
+	stored, err := GetTestResults(db, build.ID)
+	require.NoError(t, err)
+	require.Len(t, stored, 5)
+	assert.Equal(t, "TestBroken", stored[1].Test)
+	assert.Equal(t, "api_test.go:12: boom\n", stored[1].Output)
+	assert.Equal(t, 0.02, stored[1].Duration)
+	assert.Equal(t, build.ID, stored[1].BuildID)
+
+	assert.Equal(t, TestSummary{
+		Packages: 2, FailedPackages: 1, Total: 3, Passed: 1, Failed: 1, Skipped: 1, Duration: 0.5,
+	}, SummarizeTests(stored))
+}
@@ -0,0 +1,250 @@
+// Package gipfile lit le fichier .gip.yml qui décrit le pipeline d'un projet.
+//
+// Le fichier déclare des étapes exécutées dans l'ordre :
+//
+//	steps:
+//	  - name: generate
+//	    run: go generate ./...
+//	    env:
+//	      CGO_ENABLED: "0"
+//	    timeout: 2m
+//	  - name: lint
+//	    run: go vet ./...
+//	    continue_on_error: true
+//	  - uses: go-mod-download
+//	  - uses: go-test
+//	  - uses: go-build
+//
+// Une étape exécute soit une commande (run), découpée en arguments comme dans un
+// shell mais sans expansion ni redirection, soit une étape intégrée du pipeline
+// (uses). Sans étape go-build, le build ne produit pas d'artefact.
+package gipfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"forgeronvirtuel/gip/internal/buildenv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName est le nom du fichier de pipeline, cherché dans le sous-répertoire du
+// projet puis à la racine du dépôt
+const FileName = ".gip.yml"
+
+// Étapes intégrées du pipeline
+const (
+	UsesModDownload = "go-mod-download" // go mod download
+	UsesTest        = "go-test"         // go test -json ./..., avec la couverture si le projet la mesure
+	UsesBuild       = "go-build"        // Compilation des binaires pour chaque plateforme
+)
+
+// Step est une étape du pipeline
+type Step struct {
+	Name            string            `json:"name"`
+	Run             []string          `json:"run,omitempty"`  // Commande et ses arguments
+	Uses            string            `json:"uses,omitempty"` // Étape intégrée
+	Env             map[string]string `json:"env,omitempty"`
+	Timeout         time.Duration     `json:"timeout,omitempty"` // 0 = limité par la durée du build
+	ContinueOnError bool              `json:"continue_on_error,omitempty"`
+}
+
+// Default retourne les étapes du pipeline d'un projet sans fichier .gip.yml
+func Default(runTests bool) []Step {
+	steps := []Step{{Name: "go mod download", Uses: UsesModDownload}}
+	if runTests {
+		steps = append(steps, Step{Name: "go test", Uses: UsesTest})
+	}
+	return append(steps, Step{Name: "go build", Uses: UsesBuild})
+}
+
+// rawFile est la forme YAML du fichier
+type rawFile struct {
+	Steps []rawStep `yaml:"steps"`
+}
+
+type rawStep struct {
+	Name            string            `yaml:"name"`
+	Run             string            `yaml:"run"`
+	Uses            string            `yaml:"uses"`
+	Env             map[string]string `yaml:"env"`
+	Timeout         string            `yaml:"timeout"`
+	ContinueOnError bool              `yaml:"continue_on_error"`
+}
+
+// Parse valide le contenu d'un fichier .gip.yml et retourne ses étapes
+func Parse(data []byte) ([]Step, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var raw rawFile
+	if err := decoder.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("empty file")
+		}
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return nil, errors.New(describeTypeError(typeErr))
+		}
+		return nil, err
+	}
+	if len(raw.Steps) == 0 {
+		return nil, errors.New("no steps")
+	}
+
+	steps := make([]Step, 0, len(raw.Steps))
+	for i, r := range raw.Steps {
+		step, err := parseStep(r)
+		if err != nil {
+			label := fmt.Sprintf("step %d", i+1)
+			if r.Name != "" {
+				label += fmt.Sprintf(" (%s)", r.Name)
+			}
+			return nil, fmt.Errorf("%s: %w", label, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// Erreurs de yaml.v3 sur un champ inconnu et sur une valeur du mauvais type
+var (
+	unknownField = regexp.MustCompile(`^(line \d+): field (\S+) not found in type \S+$`)
+	invalidValue = regexp.MustCompile("^(line \\d+): cannot unmarshal \\S+ `(.*)` into \\S+$")
+)
+
+// describeTypeError reformule les erreurs de décodage sans les types Go internes
+func describeTypeError(err *yaml.TypeError) string {
+	messages := make([]string, 0, len(err.Errors))
+	for _, message := range err.Errors {
+		if m := unknownField.FindStringSubmatch(message); m != nil {
+			message = fmt.Sprintf("%s: unknown field %s", m[1], m[2])
+		} else if m := invalidValue.FindStringSubmatch(message); m != nil {
+			message = fmt.Sprintf("%s: invalid value %q", m[1], m[2])
+		}
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func parseStep(r rawStep) (Step, error) {
+	step := Step{Name: strings.TrimSpace(r.Name), Uses: r.Uses, ContinueOnError: r.ContinueOnError}
+
+	run := strings.TrimSpace(r.Run)
+	switch {
+	case run != "" && r.Uses != "":
+		return Step{}, errors.New("run and uses are mutually exclusive")
+	case run != "":
+		args, err := SplitCommand(run)
+		if err != nil {
+			return Step{}, err
+		}
+		step.Run = args
+		if step.Name == "" {
+			step.Name = run
+		}
+	case r.Uses != "":
+		switch r.Uses {
+		case UsesModDownload, UsesTest, UsesBuild:
+		default:
+			return Step{}, fmt.Errorf("unknown step %q, expected %s, %s or %s", r.Uses, UsesModDownload, UsesTest, UsesBuild)
+		}
+		if step.Name == "" {
+			step.Name = r.Uses
+		}
+	default:
+		return Step{}, errors.New("run or uses is required")
+	}
+
+	env, err := buildenv.Validate(r.Env)
+	if err != nil {
+		return Step{}, err
+	}
+	if len(env) > 0 {
+		step.Env = env
+	}
+
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil || timeout <= 0 {
+			return Step{}, fmt.Errorf("invalid timeout %q, expected a positive duration such as 90s or 5m", r.Timeout)
+		}
+		step.Timeout = timeout
+	}
+
+	return step, nil
+}
+
+// SplitCommand découpe une commande en arguments. Les guillemets simples et doubles
+// regroupent des mots et la barre oblique inverse échappe le caractère suivant ;
+// les variables, jokers et redirections ne sont pas interprétés.
+func SplitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != '\'' && r == '\\' && i+1 < len(runes):
+			i++
+			current.WriteRune(runes[i])
+			inArg = true
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command %q", quote, command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
+
+// Load lit le premier fichier .gip.yml trouvé dans dirs et retourne son chemin,
+// y compris s'il est invalide. Retourne des étapes nil et un chemin vide si aucun
+// répertoire n'en contient.
+func Load(dirs ...string) ([]Step, string, error) {
+	for _, dir := range dirs {
+		path := filepath.Join(dir, FileName)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, path, err
+		}
+		steps, err := Parse(data)
+		return steps, path, err
+	}
+	return nil, "", nil
+}
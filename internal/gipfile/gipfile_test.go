@@ -0,0 +1,106 @@
+package gipfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	steps, err := Parse([]byte(`
+steps:
+  - name: generate
+    run: go generate ./...
+    env:
+      CGO_ENABLED: "0"
+    timeout: 90s
+  - run: go vet -tags 'ci tools' ./...
+    continue_on_error: true
+  - uses: go-mod-download
+  - name: build
+    uses: go-build
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []Step{
+		{Name: "generate", Run: []string{"go", "generate", "./..."}, Env: map[string]string{"CGO_ENABLED": "0"}, Timeout: 90 * time.Second},
+		{Name: "go vet -tags 'ci tools' ./...", Run: []string{"go", "vet", "-tags", "ci tools", "./..."}, ContinueOnError: true},
+		{Name: "go-mod-download", Uses: UsesModDownload},
+		{Name: "build", Uses: UsesBuild},
+	}, steps)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		err     string
+	}{
+		{"", "empty file"},
+		{"steps: []", "no steps"},
+		{"stages: []", "line 1: unknown field stages"},
+		{"steps:\n  - run: go vet\n    timeout: 5m\n    retries: 2", "line 4: unknown field retries"},
+		{"steps:\n  - run: go vet\n    continue_on_error: maybe", `line 3: invalid value "maybe"`},
+		{"steps: build", `line 1: invalid value "build"`},
+		{"steps:\n  - name: lint", "step 1 (lint): run or uses is required"},
+		{"steps:\n  - uses: go-build\n  - uses: deploy", `step 2: unknown step "deploy", expected go-mod-download, go-test or go-build`},
+		{"steps:\n  - run: go vet\n    uses: go-build", "step 1: run and uses are mutually exclusive"},
+		{"steps:\n  - run: go vet\n    timeout: soon", `step 1: invalid timeout "soon", expected a positive duration such as 90s or 5m`},
+		{"steps:\n  - run: go vet\n    timeout: -1m", `step 1: invalid timeout "-1m", expected a positive duration such as 90s or 5m`},
+		{"steps:\n  - run: go vet\n    env:\n      GOOS: windows", "step 1: variable GOOS is set by the build pipeline and cannot be overridden"},
+		{"steps:\n  - run: echo 'oops", `step 1: unterminated ' quote in command "echo 'oops"`},
+	} {
+		_, err := Parse([]byte(tc.content))
+		assert.EqualError(t, err, tc.err, "contenu : %q", tc.content)
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	args, err := SplitCommand(`go build -ldflags "-s -w" ./cmd/a\ b '$HOME'`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go", "build", "-ldflags", "-s -w", "./cmd/a b", "$HOME"}, args)
+
+	args, err = SplitCommand(`echo ""`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"echo", ""}, args)
+
+	_, err = SplitCommand("   ")
+	assert.EqualError(t, err, "empty command")
+}
+
+func TestDefault(t *testing.T) {
+	assert.Equal(t, []Step{
+		{Name: "go mod download", Uses: UsesModDownload},
+		{Name: "go build", Uses: UsesBuild},
+	}, Default(false))
+	assert.Len(t, Default(true), 3)
+	assert.Equal(t, UsesTest, Default(true)[1].Uses)
+}
+
+func TestLoad(t *testing.T) {
+	root := t.TempDir()
+	subdir := filepath.Join(root, "service")
+	require.NoError(t, os.Mkdir(subdir, 0o755))
+
+	// Aucun fichier : pipeline par défaut
+	steps, path, err := Load(subdir, root)
+	require.NoError(t, err)
+	assert.Nil(t, steps)
+	assert.Empty(t, path)
+
+	// Fichier à la racine du dépôt
+	require.NoError(t, os.WriteFile(filepath.Join(root, FileName), []byte("steps:\n  - uses: go-build\n"), 0o644))
+	steps, path, err = Load(subdir, root)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(root, FileName), path)
+	assert.Len(t, steps, 1)
+
+	// Le fichier du sous-répertoire est prioritaire
+	require.NoError(t, os.WriteFile(filepath.Join(subdir, FileName), []byte("steps:\n  - run: make\n"), 0o644))
+	steps, path, err = Load(subdir, root)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(subdir, FileName), path)
+	assert.Equal(t, []string{"make"}, steps[0].Run)
+}
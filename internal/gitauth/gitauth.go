@@ -0,0 +1,278 @@
+// Package gitauth décrit les identifiants d'accès au dépôt privé d'un projet :
+// jeton HTTPS, nom d'utilisateur et mot de passe, ou clé privée SSH avec les clés
+// des serveurs (known_hosts).
+//
+// Setup les prépare pour le clone par go-git, et pour les commandes go et git du
+// build : un .netrc ou une clé SSH et un .gitconfig sont écrits dans le HOME des
+// commandes, et GOPRIVATE désigne les modules à télécharger directement depuis
+// leurs dépôts, avec ces identifiants.
+package gitauth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	githttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v6/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh/knownhosts"
+	"golang.org/x/crypto/ssh"
+)
+
+// Kind est le type d'identifiants d'un dépôt
+type Kind string
+
+const (
+	KindToken    Kind = "token"    // Jeton d'accès HTTPS
+	KindPassword Kind = "password" // Nom d'utilisateur et mot de passe HTTPS
+	KindSSH      Kind = "ssh"      // Clé privée SSH
+)
+
+const (
+	// CipherName est le nom sous lequel le secret des identifiants est chiffré
+	// avec secrets.Cipher. Il n'est pas un nom de secret valide, et ne peut donc
+	// pas être confondu avec un secret du projet.
+	CipherName = "git-credentials"
+
+	// DefaultTokenUser est le nom d'utilisateur envoyé avec un jeton sans nom
+	DefaultTokenUser = "x-access-token"
+
+	// DefaultSSHUser est l'utilisateur SSH par défaut
+	DefaultSSHUser = "git"
+)
+
+// Credentials sont les identifiants d'accès au dépôt d'un projet
+type Credentials struct {
+	Kind       Kind   `json:"kind"`
+	Username   string `json:"username"`
+	Secret     string `json:"secret"`      // Jeton, mot de passe ou clé privée SSH
+	KnownHosts string `json:"known_hosts"` // Clés des serveurs SSH, au format known_hosts
+	GoPrivate  string `json:"goprivate"`   // Modules privés (GOPRIVATE), vide = hôte et propriétaire du dépôt
+}
+
+// Validate vérifie les identifiants et leur cohérence avec l'URL du dépôt
+func (c *Credentials) Validate(repoURL string) error {
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL: %v", err)
+	}
+	if c.Secret == "" {
+		return errors.New("secret is required")
+	}
+	if strings.ContainsAny(c.Username, " \t\r\n") {
+		return errors.New("username must not contain whitespace")
+	}
+	if strings.ContainsAny(c.GoPrivate, " \t\r\n") {
+		return errors.New("goprivate must be a comma-separated list of module path patterns")
+	}
+
+	switch c.Kind {
+	case KindToken, KindPassword:
+		if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+			return fmt.Errorf("%s credentials require an https repository URL", c.Kind)
+		}
+		if c.Kind == KindPassword && c.Username == "" {
+			return errors.New("username is required for password credentials")
+		}
+		// Le .netrc sépare ses champs par des espaces
+		if strings.ContainsAny(c.Secret, " \t\r\n") {
+			return fmt.Errorf("%s must not contain whitespace", c.Kind)
+		}
+	case KindSSH:
+		if endpoint.Scheme != "ssh" {
+			return errors.New("ssh credentials require an ssh repository URL (ssh://host/path or user@host:path)")
+		}
+		if _, err := ssh.ParsePrivateKey([]byte(c.Secret)); err != nil {
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				return errors.New("passphrase-protected private keys are not supported")
+			}
+			return fmt.Errorf("invalid private key: %v", err)
+		}
+		if err := validateKnownHosts(c.KnownHosts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid kind %q (expected token, password or ssh)", c.Kind)
+	}
+	return nil
+}
+
+// validateKnownHosts vérifie qu'il y a au moins une clé de serveur et qu'elles sont lisibles
+func validateKnownHosts(knownHosts string) error {
+	rest := []byte(knownHosts)
+	count := 0
+	for {
+		_, _, _, _, next, err := ssh.ParseKnownHosts(rest)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid known_hosts: %v", err)
+		}
+		count++
+		rest = next
+	}
+	if count == 0 {
+		return errors.New("known_hosts is required for ssh credentials")
+	}
+	return nil
+}
+
+// Host retourne l'hôte (et le port) du dépôt repoURL, auquel les identifiants
+// enregistrés pour ce dépôt sont réservés. Retourne une chaîne vide pour une URL invalide.
+func Host(repoURL string) string {
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return ""
+	}
+	return endpoint.Host
+}
+
+// User retourne le nom d'utilisateur, ou celui par défaut du type d'identifiants
+func (c *Credentials) User() string {
+	switch {
+	case c.Username != "":
+		return c.Username
+	case c.Kind == KindSSH:
+		return DefaultSSHUser
+	default:
+		return DefaultTokenUser
+	}
+}
+
+// Sensitive retourne les valeurs à masquer dans les logs : le secret et, pour une
+// clé privée, chacune de ses lignes
+func (c *Credentials) Sensitive() []string {
+	if c == nil || c.Secret == "" {
+		return nil
+	}
+	values := []string{c.Secret}
+	if c.Kind == KindSSH {
+		for _, line := range strings.Split(c.Secret, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "-----") {
+				values = append(values, line)
+			}
+		}
+	}
+	return values
+}
+
+// goPrivate retourne les modules privés : ceux configurés, sinon ceux de l'hôte
+// et du propriétaire du dépôt (github.com/acme pour github.com/acme/api.git)
+func (c *Credentials) goPrivate(endpoint *transport.Endpoint) string {
+	if c.GoPrivate != "" {
+		return c.GoPrivate
+	}
+	owner, _, found := strings.Cut(strings.Trim(endpoint.Path, "/"), "/")
+	if !found || owner == "" {
+		return endpoint.Hostname()
+	}
+	return endpoint.Hostname() + "/" + owner
+}
+
+// Setup prépare les identifiants du dépôt repoURL : il écrit dans home, le HOME
+// des commandes du build, le .netrc (jeton, mot de passe) ou la clé privée, les
+// known_hosts et le .gitconfig (SSH) lus par go et git. Il retourne
+// l'authentification de go-git et les variables d'environnement des commandes.
+// Sans identifiants (c nil), il ne fait rien.
+func (c *Credentials) Setup(home, repoURL string) (transport.AuthMethod, []string, error) {
+	if c == nil {
+		return nil, nil, nil
+	}
+	if err := c.Validate(repoURL); err != nil {
+		return nil, nil, err
+	}
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	env := []string{
+		"GOPRIVATE=" + c.goPrivate(endpoint),
+		// Pas de demande d'identifiants interactive si ceux-ci sont refusés
+		"GIT_TERMINAL_PROMPT=0",
+	}
+
+	if c.Kind != KindSSH {
+		netrc := fmt.Sprintf("machine %s login %s password %s\n", endpoint.Hostname(), c.User(), c.Secret)
+		if err := os.WriteFile(filepath.Join(home, ".netrc"), []byte(netrc), 0o600); err != nil {
+			return nil, nil, err
+		}
+		return &githttp.BasicAuth{Username: c.User(), Password: c.Secret}, env, nil
+	}
+
+	sshDir := filepath.Join(home, ".ssh")
+	keyFile := filepath.Join(sshDir, "id_gip")
+	knownHostsFile := filepath.Join(sshDir, "known_hosts")
+	if err := os.MkdirAll(sshDir, 0o700); err != nil {
+		return nil, nil, err
+	}
+	key := c.Secret
+	if !strings.HasSuffix(key, "\n") {
+		key += "\n"
+	}
+	if err := os.WriteFile(keyFile, []byte(key), 0o600); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(knownHostsFile, []byte(c.KnownHosts), 0o600); err != nil {
+		return nil, nil, err
+	}
+
+	// git passe par ssh avec la clé et les known_hosts du projet, y compris pour
+	// les modules que go télécharge depuis https://hôte/...
+	host := endpoint.Hostname()
+	if port := endpoint.Port(); port != "" {
+		host += ":" + port
+	}
+	gitconfig := fmt.Sprintf(`[core]
+	sshCommand = ssh -i '%s' -o IdentitiesOnly=yes -o UserKnownHostsFile='%s' -o StrictHostKeyChecking=yes -o BatchMode=yes
+[url "ssh://%s@%s/"]
+	insteadOf = https://%s/
+`, keyFile, knownHostsFile, c.User(), host, endpoint.Hostname())
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitconfig), 0o600); err != nil {
+		return nil, nil, err
+	}
+
+	auth, err := gitssh.NewPublicKeys(c.User(), []byte(c.Secret), "")
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := knownhosts.NewDB(knownHostsFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	auth.HostKeyCallback = db.HostKeyCallback()
+	port := endpoint.Port()
+	if port == "" {
+		port = "22"
+	}
+	return &publicKeys{
+		PublicKeys:        auth,
+		hostKeyAlgorithms: db.HostKeyAlgorithms(net.JoinHostPort(endpoint.Hostname(), port)),
+	}, env, nil
+}
+
+// publicKeys est l'authentification SSH de go-git avec les algorithmes des clés
+// d'hôte connues du projet. Sans eux, go-git les cherche dans les known_hosts de
+// l'utilisateur du serveur, et échoue s'il n'en a pas.
+type publicKeys struct {
+	*gitssh.PublicKeys
+	hostKeyAlgorithms []string
+}
+
+// ClientConfig retourne la configuration du client SSH
+func (a *publicKeys) ClientConfig() (*ssh.ClientConfig, error) {
+	config, err := a.PublicKeys.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.HostKeyAlgorithms = a.hostKeyAlgorithms
+	return config, nil
+}
@@ -0,0 +1,436 @@
+// Package gitlfs remplace les pointeurs Git LFS d'un dépôt extrait par les
+// fichiers qu'ils désignent, comme `git lfs pull`, sans dépendre du binaire git-lfs.
+//
+// Les objets sont téléchargés avec l'API batch de Git LFS (transfert basic) depuis
+// le serveur LFS du dépôt : lfs.url du fichier .lfsconfig, sinon <url>.git/info/lfs.
+// Pour un dépôt local, ils sont copiés depuis son répertoire lfs/objects. Les
+// identifiants du dépôt ne sont envoyés qu'à un serveur LFS sur son hôte.
+package gitlfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	gitconfig "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	githttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+)
+
+const (
+	// pointerVersion est la première ligne d'un fichier pointeur
+	pointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+	// maxPointerSize est la taille maximale d'un fichier pointeur
+	maxPointerSize = 1024
+
+	// batchSize est le nombre maximal d'objets demandés par requête batch
+	batchSize = 100
+
+	// mediaType est le type des requêtes et réponses de l'API batch
+	mediaType = "application/vnd.git-lfs+json"
+)
+
+// Pointer désigne un objet Git LFS : le SHA-256 et la taille de son contenu
+type Pointer struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// ParsePointer lit un fichier pointeur Git LFS. Retourne false si data n'en est pas un.
+func ParsePointer(data []byte) (Pointer, bool) {
+	if len(data) > maxPointerSize || !bytes.HasPrefix(data, []byte(pointerVersion+"\n")) {
+		return Pointer{}, false
+	}
+
+	var p Pointer
+	size := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			p.OID, _ = strings.CutPrefix(value, "sha256:")
+		case "size":
+			size = value
+		}
+	}
+
+	var err error
+	p.Size, err = strconv.ParseInt(size, 10, 64)
+	if err != nil || p.Size < 0 {
+		return Pointer{}, false
+	}
+	if oid, err := hex.DecodeString(p.OID); err != nil || len(oid) != sha256.Size {
+		return Pointer{}, false
+	}
+	return p, true
+}
+
+// Endpoint retourne l'URL du serveur LFS du dépôt repoURL extrait dans dir :
+// lfs.url du fichier .lfsconfig, sinon <url>.git/info/lfs (en https pour un
+// dépôt SSH), ou file://<chemin> pour un dépôt local. Un .lfsconfig ne peut
+// désigner un stockage local (file://) que pour un dépôt local.
+func Endpoint(dir, repoURL string) (string, error) {
+	lfsURL, err := configURL(dir)
+	if err != nil {
+		return "", err
+	}
+	endpoint, err := transport.NewEndpoint(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository URL: %v", err)
+	}
+	if lfsURL != "" {
+		// Le contenu du dépôt ne doit pas faire lire des fichiers de l'exécutant
+		if u, err := url.Parse(lfsURL); err == nil && u.Scheme == "file" && endpoint.Scheme != "file" {
+			return "", fmt.Errorf("invalid .lfsconfig: lfs.url %q is a local path, only allowed for a local repository", lfsURL)
+		}
+		return lfsURL, nil
+	}
+
+	switch endpoint.Scheme {
+	case "http", "https":
+		base := strings.TrimSuffix(repoURL, "/")
+		if !strings.HasSuffix(base, ".git") {
+			base += ".git"
+		}
+		return base + "/info/lfs", nil
+	case "ssh", "git":
+		path := strings.TrimSuffix(strings.Trim(endpoint.Path, "/"), "/")
+		if !strings.HasSuffix(path, ".git") {
+			path += ".git"
+		}
+		return fmt.Sprintf("https://%s/%s/info/lfs", endpoint.Hostname(), path), nil
+	case "file":
+		return "file://" + endpoint.Path, nil
+	default:
+		return "", fmt.Errorf("unsupported repository URL scheme %q", endpoint.Scheme)
+	}
+}
+
+// configURL retourne lfs.url du fichier .lfsconfig de dir, vide s'il n'y en a pas
+func configURL(dir string) (string, error) {
+	f, err := os.Open(filepath.Join(dir, ".lfsconfig"))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	cfg := gitconfig.New()
+	if err := gitconfig.NewDecoder(f).Decode(cfg); err != nil {
+		return "", fmt.Errorf("invalid .lfsconfig: %v", err)
+	}
+	return cfg.Section("lfs").Option("url"), nil
+}
+
+// Pull remplace les pointeurs Git LFS extraits dans dir, le répertoire de travail
+// de repo cloné depuis repoURL, par les fichiers qu'ils désignent, et retourne
+// le nombre de fichiers remplacés. auth, s'il s'agit d'identifiants HTTP,
+// authentifie les requêtes adressées au serveur LFS quand celui-ci est sur
+// l'hôte du dépôt : ils ne sont pas envoyés à un serveur désigné par le
+// .lfsconfig sur un autre hôte.
+// Les fichiers remplacés sont marqués inchangés dans l'index (git update-index
+// --assume-unchanged), pour que go build ne stampe pas le dépôt comme modifié.
+func Pull(ctx context.Context, repo *git.Repository, dir, repoURL string, auth transport.AuthMethod) (int, error) {
+	files, err := pointerFiles(repo, dir)
+	if err != nil || len(files) == 0 {
+		return 0, err
+	}
+
+	endpoint, err := Endpoint(dir, repoURL)
+	if err != nil {
+		return 0, err
+	}
+
+	// Un objet peut être désigné par plusieurs fichiers : il n'est récupéré qu'une fois
+	paths := make(map[Pointer][]string)
+	var pointers []Pointer
+	for _, f := range files {
+		if _, ok := paths[f.pointer]; !ok {
+			pointers = append(pointers, f.pointer)
+		}
+		paths[f.pointer] = append(paths[f.pointer], f.path)
+	}
+
+	var fetch func(ctx context.Context, pointers []Pointer, paths map[Pointer][]string) error
+	if localDir, ok := strings.CutPrefix(endpoint, "file://"); ok {
+		fetch = func(_ context.Context, pointers []Pointer, paths map[Pointer][]string) error {
+			return copyLocal(localDir, pointers, paths)
+		}
+	} else {
+		c, err := newClient(endpoint, repoURL, auth)
+		if err != nil {
+			return 0, err
+		}
+		fetch = c.download
+	}
+	for start := 0; start < len(pointers); start += batchSize {
+		batch := pointers[start:min(start+batchSize, len(pointers))]
+		if err := fetch(ctx, batch, paths); err != nil {
+			return 0, err
+		}
+	}
+
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.name
+	}
+	markUnchanged(ctx, dir, names)
+	return len(files), nil
+}
+
+// pointerFile est un fichier pointeur extrait dans le répertoire de travail
+type pointerFile struct {
+	name    string // Chemin dans le dépôt
+	path    string // Chemin sur le disque
+	pointer Pointer
+}
+
+// pointerFiles liste les fichiers de l'index de repo extraits comme pointeurs LFS dans dir
+func pointerFiles(repo *git.Repository, dir string) ([]pointerFile, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []pointerFile
+	for _, entry := range idx.Entries {
+		if (entry.Mode != filemode.Regular && entry.Mode != filemode.Executable) || entry.Size > maxPointerSize {
+			continue
+		}
+		path := filepath.Join(dir, filepath.FromSlash(entry.Name))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		if pointer, ok := ParsePointer(data); ok {
+			files = append(files, pointerFile{name: entry.Name, path: path, pointer: pointer})
+		}
+	}
+	return files, nil
+}
+
+// copyLocal copie les objets depuis le stockage LFS d'un dépôt local, de travail
+// (.git/lfs/objects) ou nu (lfs/objects)
+func copyLocal(repoDir string, pointers []Pointer, paths map[Pointer][]string) error {
+	for _, p := range pointers {
+		var src *os.File
+		var err error
+		for _, objects := range []string{filepath.Join(repoDir, ".git", "lfs", "objects"), filepath.Join(repoDir, "lfs", "objects")} {
+			src, err = os.Open(filepath.Join(objects, p.OID[0:2], p.OID[2:4], p.OID))
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("LFS object %s not found in %s", p.OID, repoDir)
+		}
+		err = store(src, p, paths[p])
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// store écrit le contenu d'un objet à la place de ses fichiers pointeurs, après
+// avoir vérifié sa taille et son SHA-256
+func store(r io.Reader, p Pointer, paths []string) error {
+	first := paths[0]
+	info, err := os.Stat(first)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(first), ".lfs-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, p.Size+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("LFS object %s: %v", p.OID, err)
+	}
+	if n != p.Size || hex.EncodeToString(hash.Sum(nil)) != p.OID {
+		return fmt.Errorf("LFS object %s: content does not match its pointer", p.OID)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	// Les autres fichiers du même objet en sont des copies
+	for _, path := range paths[1:] {
+		if err := copyFile(tmp.Name(), path); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), first)
+}
+
+// copyFile remplace le contenu de dst par celui de src, en gardant le mode de dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// markUnchanged marque les fichiers remplacés comme inchangés dans l'index, pour
+// git status et le stamping VCS de go build. Sans git, ils restent modifiés.
+func markUnchanged(ctx context.Context, dir string, names []string) {
+	cmd := exec.CommandContext(ctx, "git", "update-index", "--assume-unchanged", "-z", "--stdin")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(strings.Join(names, "\x00") + "\x00")
+	cmd.Run()
+}
+
+// client télécharge des objets avec l'API batch d'un serveur LFS
+type client struct {
+	endpoint string
+	scheme   string
+	host     string
+	auth     *githttp.BasicAuth // Identifiants envoyés à scheme://host, nil sans identifiants
+}
+
+// newClient crée le client du serveur LFS endpoint du dépôt repoURL. Les
+// identifiants auth ne sont retenus que si le serveur est sur l'hôte du dépôt,
+// comme ceux des sous-modules.
+func newClient(endpoint, repoURL string, auth transport.AuthMethod) (*client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid LFS endpoint %q", endpoint)
+	}
+	c := &client{endpoint: strings.TrimSuffix(endpoint, "/"), scheme: u.Scheme, host: u.Host}
+	if repo, err := transport.NewEndpoint(repoURL); err == nil && repo.Scheme == u.Scheme && repo.Host == u.Host {
+		c.auth, _ = auth.(*githttp.BasicAuth)
+	}
+	return c, nil
+}
+
+// action est une requête à effectuer pour transférer un objet
+type action struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header"`
+}
+
+// batchResponse est la réponse d'une requête batch
+type batchResponse struct {
+	Objects []struct {
+		Pointer
+		Actions struct {
+			Download *action `json:"download"`
+		} `json:"actions"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"objects"`
+}
+
+// download télécharge les objets pointers et les écrit à la place de leurs fichiers
+func (c *client) download(ctx context.Context, pointers []Pointer, paths map[Pointer][]string) error {
+	body, err := json.Marshal(map[string]any{
+		"operation": "download",
+		"transfers": []string{"basic"},
+		"objects":   pointers,
+		"hash_algo": "sha256",
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", mediaType)
+	req.Header.Set("Content-Type", mediaType)
+
+	var batch batchResponse
+	if err := c.do(req, func(r io.Reader) error { return json.NewDecoder(r).Decode(&batch) }); err != nil {
+		return fmt.Errorf("LFS batch request: %v", err)
+	}
+
+	for _, object := range batch.Objects {
+		if object.Error != nil {
+			return fmt.Errorf("LFS object %s: %s (%d)", object.OID, object.Error.Message, object.Error.Code)
+		}
+		p := Pointer{OID: object.OID, Size: object.Size}
+		if _, ok := paths[p]; !ok || object.Actions.Download == nil {
+			return fmt.Errorf("LFS object %s: no download action", object.OID)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, object.Actions.Download.Href, nil)
+		if err != nil {
+			return err
+		}
+		for name, value := range object.Actions.Download.Header {
+			req.Header.Set(name, value)
+		}
+		err = c.do(req, func(r io.Reader) error { return store(r, p, paths[p]) })
+		if err != nil {
+			return fmt.Errorf("LFS object %s: %v", object.OID, err)
+		}
+	}
+	return nil
+}
+
+// do envoie une requête, avec les identifiants si elle est adressée au schéma et
+// à l'hôte du serveur LFS et n'est pas déjà authentifiée, et lit le corps d'une réponse réussie
+func (c *client) do(req *http.Request, read func(io.Reader) error) error {
+	if c.auth != nil && req.URL.Scheme == c.scheme && req.URL.Host == c.host && req.Header.Get("Authorization") == "" {
+		req.SetBasicAuth(c.auth.Username, c.auth.Password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&failure)
+		if failure.Message != "" {
+			return fmt.Errorf("%s: %s", resp.Status, failure.Message)
+		}
+		return errors.New(resp.Status)
+	}
+	return read(resp.Body)
+}
@@ -0,0 +1,231 @@
+package gitlfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	githttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pointer retourne le fichier pointeur de content et son objet
+func pointer(content string) (string, Pointer) {
+	sum := sha256.Sum256([]byte(content))
+	p := Pointer{OID: hex.EncodeToString(sum[:]), Size: int64(len(content))}
+	return fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", pointerVersion, p.OID, p.Size), p
+}
+
+// gitCmd exécute une commande git dans dir et retourne sa sortie
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Ada", "GIT_AUTHOR_EMAIL=ada@example.com",
+		"GIT_COMMITTER_NAME=Ada", "GIT_COMMITTER_EMAIL=ada@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, output)
+	return strings.TrimSpace(string(output))
+}
+
+// lfsRepo crée un dépôt dont les fichiers sont des pointeurs vers les contenus
+// files, et le clone avec go-git
+func lfsRepo(t *testing.T, files map[string]string) (string, string, *git.Repository) {
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "-b", "master")
+	for name, content := range files {
+		text, _ := pointer(content)
+		path := filepath.Join(origin, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(text), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(origin, "main.go"), []byte("package main\n"), 0o644))
+	gitCmd(t, origin, "add", ".")
+	gitCmd(t, origin, "commit", "-m", "Assets")
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	repo, err := git.PlainClone(dir, &git.CloneOptions{URL: origin})
+	require.NoError(t, err)
+	return origin, dir, repo
+}
+
+// lfsServer simule un serveur LFS qui sert objects, par SHA-256, aux requêtes
+// authentifiées avec le mot de passe token
+func lfsServer(t *testing.T, token string, objects map[string]string) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, ok := r.BasicAuth(); !ok || password != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"message": "credentials needed"})
+			return
+		}
+		if oid, ok := strings.CutPrefix(r.URL.Path, "/objects/"); ok && r.Method == http.MethodGet {
+			w.Write([]byte(objects[oid]))
+			return
+		}
+		if r.URL.Path != "/repo.git/info/lfs/objects/batch" || r.Header.Get("Accept") != mediaType {
+			http.NotFound(w, r)
+			return
+		}
+
+		var req struct {
+			Operation string    `json:"operation"`
+			Objects   []Pointer `json:"objects"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "download", req.Operation)
+		response := []map[string]any{}
+		for _, p := range req.Objects {
+			object := map[string]any{"oid": p.OID, "size": p.Size}
+			if _, ok := objects[p.OID]; ok {
+				object["actions"] = map[string]any{"download": map[string]any{"href": server.URL + "/objects/" + p.OID}}
+			} else {
+				object["error"] = map[string]any{"code": 404, "message": "Object does not exist"}
+			}
+			response = append(response, object)
+		}
+		w.Header().Set("Content-Type", mediaType)
+		json.NewEncoder(w).Encode(map[string]any{"transfer": "basic", "objects": response})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestParsePointer(t *testing.T) {
+	text, want := pointer("hello")
+	p, ok := ParsePointer([]byte(text))
+	require.True(t, ok)
+	assert.Equal(t, want, p)
+
+	for _, invalid := range []string{
+		"hello",
+		strings.Replace(text, "spec/v1", "spec/v2", 1),
+		strings.Replace(text, "sha256:", "sha256:zz", 1),
+		strings.Replace(text, "size 5", "size -1", 1),
+		text + strings.Repeat("x", maxPointerSize),
+	} {
+		_, ok := ParsePointer([]byte(invalid))
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	dir := t.TempDir()
+	for repoURL, want := range map[string]string{
+		"https://github.com/acme/api.git":    "https://github.com/acme/api.git/info/lfs",
+		"https://github.com/acme/api":        "https://github.com/acme/api.git/info/lfs",
+		"git@github.com:acme/api.git":        "https://github.com/acme/api.git/info/lfs",
+		"ssh://git@github.com:2222/acme/api": "https://github.com/acme/api.git/info/lfs",
+		"/srv/git/api":                       "file:///srv/git/api",
+	} {
+		endpoint, err := Endpoint(dir, repoURL)
+		require.NoError(t, err, repoURL)
+		assert.Equal(t, want, endpoint, repoURL)
+	}
+
+	// Le serveur LFS configuré par le dépôt est prioritaire
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".lfsconfig"), []byte("[lfs]\n\turl = https://lfs.example.com/api\n"), 0o644))
+	endpoint, err := Endpoint(dir, "https://github.com/acme/api.git")
+	require.NoError(t, err)
+	assert.Equal(t, "https://lfs.example.com/api", endpoint)
+
+	// Un dépôt distant ne peut pas faire copier des fichiers de l'exécutant
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".lfsconfig"), []byte("[lfs]\n\turl = file:///etc\n"), 0o644))
+	_, err = Endpoint(dir, "https://github.com/acme/api.git")
+	assert.ErrorContains(t, err, "only allowed for a local repository")
+	endpoint, err = Endpoint(dir, "/srv/git/api")
+	require.NoError(t, err)
+	assert.Equal(t, "file:///etc", endpoint)
+}
+
+func TestPull(t *testing.T) {
+	files := map[string]string{"assets/logo.png": "PNG logo", "assets/copy.png": "PNG logo", "data/schema.json": `{"type":"object"}`}
+	_, dir, repo := lfsRepo(t, files)
+
+	objects := map[string]string{}
+	for _, content := range files {
+		_, p := pointer(content)
+		objects[p.OID] = content
+	}
+	server := lfsServer(t, "s3cret", objects)
+
+	// Sans identifiants, le serveur refuse le téléchargement
+	_, err := Pull(context.Background(), repo, dir, server.URL+"/repo.git", nil)
+	assert.EqualError(t, err, "LFS batch request: 401 Unauthorized: credentials needed")
+
+	n, err := Pull(context.Background(), repo, dir, server.URL+"/repo.git", &githttp.BasicAuth{Username: "x-access-token", Password: "s3cret"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	for name, content := range files {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(data), name)
+	}
+	assert.Empty(t, gitCmd(t, dir, "status", "--porcelain"), "Les fichiers remplacés ne sont pas vus comme modifiés")
+
+	// Les fichiers déjà remplacés ne sont plus des pointeurs
+	n, err = Pull(context.Background(), repo, dir, server.URL+"/repo.git", nil)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestPullOtherHost(t *testing.T) {
+	_, dir, repo := lfsRepo(t, map[string]string{"logo.png": "PNG logo"})
+	_, p := pointer("PNG logo")
+	server := lfsServer(t, "s3cret", map[string]string{p.OID: "PNG logo"})
+
+	// Les identifiants du dépôt ne sont pas envoyés au serveur LFS d'un autre hôte
+	lfsconfig := fmt.Sprintf("[lfs]\n\turl = %s/repo.git/info/lfs\n", server.URL)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".lfsconfig"), []byte(lfsconfig), 0o644))
+	_, err := Pull(context.Background(), repo, dir, "https://git.example.com/acme/api.git", &githttp.BasicAuth{Username: "bot", Password: "s3cret"})
+	assert.EqualError(t, err, "LFS batch request: 401 Unauthorized: credentials needed")
+}
+
+func TestPullMissingObject(t *testing.T) {
+	_, dir, repo := lfsRepo(t, map[string]string{"logo.png": "PNG logo"})
+	server := lfsServer(t, "s3cret", map[string]string{})
+
+	_, p := pointer("PNG logo")
+	_, err := Pull(context.Background(), repo, dir, server.URL+"/repo.git", &githttp.BasicAuth{Username: "bot", Password: "s3cret"})
+	assert.EqualError(t, err, fmt.Sprintf("LFS object %s: Object does not exist (404)", p.OID))
+}
+
+func TestPullCorruptObject(t *testing.T) {
+	_, dir, repo := lfsRepo(t, map[string]string{"logo.png": "PNG logo"})
+	_, p := pointer("PNG logo")
+	server := lfsServer(t, "s3cret", map[string]string{p.OID: "PNG l0go"})
+
+	_, err := Pull(context.Background(), repo, dir, server.URL+"/repo.git", &githttp.BasicAuth{Username: "bot", Password: "s3cret"})
+	assert.EqualError(t, err, fmt.Sprintf("LFS object %s: LFS object %s: content does not match its pointer", p.OID, p.OID))
+	text, _ := pointer("PNG logo")
+	data, err := os.ReadFile(filepath.Join(dir, "logo.png"))
+	require.NoError(t, err)
+	assert.Equal(t, text, string(data), "Le pointeur reste en place")
+}
+
+func TestPullLocal(t *testing.T) {
+	origin, dir, repo := lfsRepo(t, map[string]string{"logo.png": "PNG logo"})
+	_, p := pointer("PNG logo")
+	objects := filepath.Join(origin, ".git", "lfs", "objects", p.OID[0:2], p.OID[2:4])
+	require.NoError(t, os.MkdirAll(objects, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(objects, p.OID), []byte("PNG logo"), 0o644))
+
+	n, err := Pull(context.Background(), repo, dir, origin, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	data, err := os.ReadFile(filepath.Join(dir, "logo.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "PNG logo", string(data))
+}
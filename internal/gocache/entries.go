@@ -0,0 +1,243 @@
+package gocache
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// entry est une entrée d'un cache supprimée d'un bloc : une version de module
+// (fichiers téléchargés et sources extraites) ou un fichier du cache de compilation
+type entry struct {
+	paths    []string
+	size     int64
+	lastUsed time.Time
+}
+
+func (e *entry) remove() error {
+	for _, path := range e.paths {
+		if err := removeAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortLeastRecentlyUsed trie les entrées de la moins récemment utilisée à la plus récente
+func sortLeastRecentlyUsed(entries []*entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].lastUsed.Before(entries[j].lastUsed)
+	})
+}
+
+func (c *Cache) entries(kind Kind) ([]*entry, error) {
+	if kind == Mod {
+		return moduleEntries(c.kindDir(Mod))
+	}
+	return buildEntries(c.kindDir(Build))
+}
+
+// buildEntries liste les fichiers du cache de compilation, rangés dans des
+// sous-répertoires nommés par les deux premiers caractères de leur empreinte
+func buildEntries(dir string) ([]*entry, error) {
+	subdirs, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*entry
+	for _, sub := range subdirs {
+		if !sub.IsDir() || len(sub.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, sub.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			info, err := f.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue // Supprimé par une commande go entre temps
+				}
+				return nil, err
+			}
+			entries = append(entries, &entry{
+				paths:    []string{filepath.Join(dir, sub.Name(), f.Name())},
+				size:     info.Size(),
+				lastUsed: info.ModTime(),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// downloadExtensions liste les fichiers d'une version de module dans cache/download/<module>/@v
+var downloadExtensions = []string{".info", ".mod", ".zip", ".ziphash", ".lock", ".partial"}
+
+// moduleEntries liste les versions de modules du cache des modules. Une version
+// regroupe ses fichiers cache/download/<module>/@v/<version>.* et ses sources
+// extraites dans <module>@<version> ; sa date d'utilisation est la plus récente
+// date de modification de ses fichiers téléchargés.
+func moduleEntries(dir string) ([]*entry, error) {
+	downloadDir := filepath.Join(dir, "cache", "download")
+
+	var entries []*entry
+	err := filepath.WalkDir(downloadDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() || d.Name() != "@v" {
+			return nil
+		}
+
+		module, err := filepath.Rel(downloadDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		files, err := os.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		versions := make(map[string]*entry)
+		var order []string
+		for _, f := range files {
+			version, ok := versionOf(f.Name())
+			if !ok {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			e := versions[version]
+			if e == nil {
+				e = &entry{}
+				versions[version] = e
+				order = append(order, version)
+			}
+			e.paths = append(e.paths, filepath.Join(path, f.Name()))
+			e.size += info.Size()
+			if info.ModTime().After(e.lastUsed) {
+				e.lastUsed = info.ModTime()
+			}
+		}
+
+		for _, version := range order {
+			e := versions[version]
+			extracted := filepath.Join(dir, module+"@"+version)
+			if size, err := dirSize(extracted); err == nil && size > 0 {
+				e.paths = append(e.paths, extracted)
+				e.size += size
+			}
+			entries = append(entries, e)
+		}
+		return filepath.SkipDir
+	})
+	return entries, err
+}
+
+// versionOf retourne la version d'un fichier de cache/download/<module>/@v
+func versionOf(name string) (string, bool) {
+	for _, ext := range downloadExtensions {
+		if version, ok := strings.CutSuffix(name, ext); ok && version != "" {
+			return version, true
+		}
+	}
+	return "", false
+}
+
+// MarkUsed marque comme utilisées les versions de modules listées par le fichier
+// go.sum d'un projet. Sans effet si le fichier n'existe pas ou pour un cache nil.
+func (c *Cache) MarkUsed(goSum string) error {
+	if c == nil {
+		return nil
+	}
+	f, err := os.Open(goSum)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := time.Now()
+	downloadDir := filepath.Join(c.kindDir(Mod), "cache", "download")
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		version := strings.TrimSuffix(fields[1], "/go.mod")
+		prefix := filepath.Join(downloadDir, escape(fields[0]), "@v", escape(version))
+		for _, ext := range downloadExtensions {
+			if err := os.Chtimes(prefix+ext, now, now); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// escape encode un chemin ou une version de module comme dans le cache des
+// modules : chaque majuscule est remplacée par ! suivi de la minuscule
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			b.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// dirSize retourne la taille des fichiers d'un répertoire. Les fichiers supprimés
+// pendant le parcours sont ignorés.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size, err
+}
+
+// removeAll supprime path, y compris les répertoires en lecture seule du cache
+// des modules
+func removeAll(path string) error {
+	err := os.RemoveAll(path)
+	if err == nil || !errors.Is(err, fs.ErrPermission) {
+		return err
+	}
+	filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			os.Chmod(p, 0o755)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
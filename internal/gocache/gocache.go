@@ -0,0 +1,276 @@
+// Package gocache gère le cache des modules Go (GOMODCACHE) et le cache de
+// compilation (GOCACHE) partagés par les builds d'un workspace.
+//
+// Les commandes go des builds peuvent utiliser les deux caches en parallèle. Le
+// nettoyage, qui supprime des entrées, n'est fait que lorsqu'aucun build ne les
+// utilise : les builds prennent un verrou partagé (Acquire), l'éviction et la purge
+// un verrou exclusif, sans attendre les builds en cours.
+//
+// Au-delà de leur taille maximale, les entrées les moins récemment utilisées sont
+// supprimées : les fichiers du cache de compilation, dont go met à jour la date de
+// modification quand il les utilise, et les versions de modules, marquées comme
+// utilisées par MarkUsed à partir du go.sum des projets compilés.
+package gocache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrInUse est retourné par Trim et Purge quand des builds utilisent le cache
+var ErrInUse = errors.New("cache is in use by running builds")
+
+// Kind désigne l'un des deux caches
+type Kind string
+
+const (
+	Mod   Kind = "mod"   // GOMODCACHE
+	Build Kind = "build" // GOCACHE
+)
+
+// Limits fixe la taille maximale de chaque cache, en octets. 0 = illimitée.
+type Limits struct {
+	ModBytes   int64
+	BuildBytes int64
+}
+
+// Cache est le cache Go partagé d'un workspace
+type Cache struct {
+	dir    string
+	limits Limits
+
+	mu    sync.RWMutex // Partagé par les builds, exclusif pour supprimer des entrées
+	inUse atomic.Int32
+}
+
+// Usage décrit l'occupation d'un cache
+type Usage struct {
+	Dir     string `json:"dir"`
+	Size    int64  `json:"size"`
+	Entries int    `json:"entries"` // Versions de modules ou fichiers du cache de compilation
+	Limit   int64  `json:"limit"`   // 0 = illimitée
+}
+
+// Stats décrit l'occupation des deux caches
+type Stats struct {
+	Dir   string `json:"dir"`
+	InUse int    `json:"in_use"` // Builds en cours utilisant le cache
+	Mod   Usage  `json:"mod"`
+	Build Usage  `json:"build"`
+}
+
+// New ouvre le cache du répertoire dir, créé s'il n'existe pas
+func New(dir string, limits Limits) (*Cache, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &Cache{dir: absDir, limits: limits}
+	for _, kind := range []Kind{Mod, Build} {
+		if err := os.MkdirAll(c.kindDir(kind), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Dir retourne le répertoire du cache
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// ModDir retourne le répertoire du cache des modules (GOMODCACHE)
+func (c *Cache) ModDir() string {
+	return c.kindDir(Mod)
+}
+
+func (c *Cache) kindDir(kind Kind) string {
+	return filepath.Join(c.dir, string(kind))
+}
+
+func (c *Cache) limit(kind Kind) int64 {
+	if kind == Mod {
+		return c.limits.ModBytes
+	}
+	return c.limits.BuildBytes
+}
+
+// Env retourne les variables GOMODCACHE et GOCACHE des commandes de build.
+// Retourne nil pour un cache nil.
+func (c *Cache) Env() []string {
+	if c == nil {
+		return nil
+	}
+	return []string{
+		"GOMODCACHE=" + c.kindDir(Mod),
+		"GOCACHE=" + c.kindDir(Build),
+	}
+}
+
+// Acquire réserve le cache pour un build : il n'est ni nettoyé ni purgé avant
+// l'appel de la fonction retournée. Celle-ci libère le cache puis supprime les
+// entrées les plus anciennes des caches qui dépassent leur taille maximale, si
+// aucun autre build ne les utilise. Sans effet pour un cache nil.
+func (c *Cache) Acquire() (release func()) {
+	if c == nil {
+		return func() {}
+	}
+	c.mu.RLock()
+	c.inUse.Add(1)
+	return func() {
+		c.inUse.Add(-1)
+		c.mu.RUnlock()
+		if c.limits.ModBytes > 0 || c.limits.BuildBytes > 0 {
+			if _, err := c.Trim(); err != nil && !errors.Is(err, ErrInUse) {
+				log.Error().Err(err).Str("cache", c.dir).Msg("Erreur lors du nettoyage du cache Go")
+			}
+		}
+	}
+}
+
+// Stats retourne l'occupation des caches
+func (c *Cache) Stats() (*Stats, error) {
+	stats := &Stats{Dir: c.dir, InUse: int(c.inUse.Load())}
+	for _, kind := range []Kind{Mod, Build} {
+		size, err := dirSize(c.kindDir(kind))
+		if err != nil {
+			return nil, err
+		}
+		entries, err := c.entries(kind)
+		if err != nil {
+			return nil, err
+		}
+		usage := Usage{Dir: c.kindDir(kind), Size: size, Entries: len(entries), Limit: c.limit(kind)}
+		if kind == Mod {
+			stats.Mod = usage
+		} else {
+			stats.Build = usage
+		}
+	}
+	return stats, nil
+}
+
+// TrimReport décrit les entrées supprimées par Trim
+type TrimReport struct {
+	Evicted int   `json:"evicted"`
+	Freed   int64 `json:"freed"`
+}
+
+// Trim supprime les entrées les moins récemment utilisées des caches qui
+// dépassent leur taille maximale. Retourne ErrInUse si des builds utilisent le cache.
+func (c *Cache) Trim() (TrimReport, error) {
+	var report TrimReport
+	if !c.mu.TryLock() {
+		return report, ErrInUse
+	}
+	defer c.mu.Unlock()
+
+	for _, kind := range []Kind{Mod, Build} {
+		limit := c.limit(kind)
+		if limit <= 0 {
+			continue
+		}
+		size, err := dirSize(c.kindDir(kind))
+		if err != nil {
+			return report, err
+		}
+		if size <= limit {
+			continue
+		}
+
+		entries, err := c.entries(kind)
+		if err != nil {
+			return report, err
+		}
+		sortLeastRecentlyUsed(entries)
+		for _, e := range entries {
+			if size <= limit {
+				break
+			}
+			if err := e.remove(); err != nil {
+				return report, err
+			}
+			size -= e.size
+			report.Evicted++
+			report.Freed += e.size
+		}
+		log.Info().Str("cache", c.kindDir(kind)).Int64("size", size).Int64("limit", limit).Msg("Cache Go nettoyé")
+	}
+	return report, nil
+}
+
+// Purge vide les caches kinds, ou les deux si kinds est vide. Retourne la taille
+// libérée, ou ErrInUse si des builds utilisent le cache.
+func (c *Cache) Purge(kinds ...Kind) (int64, error) {
+	if !c.mu.TryLock() {
+		return 0, ErrInUse
+	}
+	defer c.mu.Unlock()
+
+	if len(kinds) == 0 {
+		kinds = []Kind{Mod, Build}
+	}
+	var freed int64
+	for _, kind := range kinds {
+		dir := c.kindDir(kind)
+		size, err := dirSize(dir)
+		if err != nil {
+			return freed, err
+		}
+		if err := removeAll(dir); err != nil {
+			return freed, err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return freed, err
+		}
+		freed += size
+		log.Info().Str("cache", dir).Int64("freed", size).Msg("Cache Go purgé")
+	}
+	return freed, nil
+}
+
+// ParseKind valide le nom d'un cache
+func ParseKind(s string) (Kind, error) {
+	switch Kind(s) {
+	case Mod, Build:
+		return Kind(s), nil
+	}
+	return "", fmt.Errorf("unknown cache %q, expected %s or %s", s, Mod, Build)
+}
+
+// sizeUnits liste les suffixes acceptés par ParseSize, du plus long au plus court
+var sizeUnits = []struct {
+	suffix string
+	factor int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// ParseSize lit une taille comme 500MB, 10GiB ou 1073741824. 0 = illimitée.
+func ParseSize(s string) (int64, error) {
+	value := strings.TrimSpace(s)
+	factor := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			factor = unit.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected a value such as 500MB or 10GiB", s)
+	}
+	return int64(n * float64(factor)), nil
+}
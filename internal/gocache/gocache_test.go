@@ -0,0 +1,166 @@
+package gocache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile crée un fichier de size octets modifié à la date modTime
+func writeFile(t *testing.T, path string, size int, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, make([]byte, size), 0o444))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+// addModule ajoute une version de module au cache, téléchargée et extraite
+func addModule(t *testing.T, c *Cache, module, version string, size int, modTime time.Time) {
+	t.Helper()
+	download := filepath.Join(c.kindDir(Mod), "cache", "download", escape(module), "@v", escape(version))
+	writeFile(t, download+".info", 10, modTime)
+	writeFile(t, download+".mod", 10, modTime)
+	writeFile(t, download+".zip", size, modTime)
+	writeFile(t, filepath.Join(c.kindDir(Mod), escape(module)+"@"+escape(version), "lib.go"), size, modTime)
+}
+
+func TestEnvAndNilCache(t *testing.T) {
+	c, err := New(t.TempDir(), Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"GOMODCACHE=" + filepath.Join(c.Dir(), "mod"),
+		"GOCACHE=" + filepath.Join(c.Dir(), "build"),
+	}, c.Env())
+
+	var disabled *Cache
+	assert.Nil(t, disabled.Env())
+	disabled.Acquire()()
+	assert.NoError(t, disabled.MarkUsed("go.sum"))
+}
+
+func TestTrimBuildCache(t *testing.T) {
+	c, err := New(t.TempDir(), Limits{BuildBytes: 250})
+	require.NoError(t, err)
+
+	now := time.Now()
+	writeFile(t, filepath.Join(c.kindDir(Build), "ab", "ab01-a"), 100, now.Add(-3*time.Hour))
+	writeFile(t, filepath.Join(c.kindDir(Build), "ab", "ab02-d"), 100, now.Add(-2*time.Hour))
+	writeFile(t, filepath.Join(c.kindDir(Build), "cd", "cd03-a"), 100, now)
+	writeFile(t, filepath.Join(c.kindDir(Build), "trim.txt"), 10, now.Add(-10*time.Hour))
+
+	stats, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, Usage{Dir: c.kindDir(Build), Size: 310, Entries: 3, Limit: 250}, stats.Build)
+
+	report, err := c.Trim()
+	require.NoError(t, err)
+	assert.Equal(t, TrimReport{Evicted: 1, Freed: 100}, report)
+	assert.NoFileExists(t, filepath.Join(c.kindDir(Build), "ab", "ab01-a"))
+	assert.FileExists(t, filepath.Join(c.kindDir(Build), "ab", "ab02-d"))
+	assert.FileExists(t, filepath.Join(c.kindDir(Build), "trim.txt"), "Les fichiers de go lui-même sont conservés")
+}
+
+func TestTrimModuleCache(t *testing.T) {
+	c, err := New(t.TempDir(), Limits{ModBytes: 500})
+	require.NoError(t, err)
+
+	old := time.Now().Add(-48 * time.Hour)
+	addModule(t, c, "github.com/BurntSushi/toml", "v1.3.2", 100, old)
+	addModule(t, c, "golang.org/x/text", "v0.14.0", 100, old.Add(time.Hour))
+	addModule(t, c, "golang.org/x/text", "v0.15.0", 100, old.Add(2*time.Hour))
+
+	stats, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Mod.Entries)
+	assert.Equal(t, int64(660), stats.Mod.Size)
+
+	// Un build utilise la plus ancienne version, qui devient la plus récente
+	goSum := filepath.Join(t.TempDir(), "go.sum")
+	require.NoError(t, os.WriteFile(goSum, []byte(
+		"github.com/BurntSushi/toml v1.3.2 h1:abc=\ngithub.com/BurntSushi/toml v1.3.2/go.mod h1:def=\n",
+	), 0o644))
+	require.NoError(t, c.MarkUsed(goSum))
+
+	report, err := c.Trim()
+	require.NoError(t, err)
+	assert.Equal(t, TrimReport{Evicted: 1, Freed: 220}, report)
+	assert.NoDirExists(t, filepath.Join(c.kindDir(Mod), "golang.org/x/text@v0.14.0"))
+	assert.NoFileExists(t, filepath.Join(c.kindDir(Mod), "cache/download/golang.org/x/text/@v/v0.14.0.zip"))
+	assert.DirExists(t, filepath.Join(c.kindDir(Mod), "github.com/!burnt!sushi/toml@v1.3.2"))
+	assert.DirExists(t, filepath.Join(c.kindDir(Mod), "golang.org/x/text@v0.15.0"))
+}
+
+func TestTrimAndPurgeWaitForBuilds(t *testing.T) {
+	c, err := New(t.TempDir(), Limits{BuildBytes: 1})
+	require.NoError(t, err)
+	writeFile(t, filepath.Join(c.kindDir(Build), "ab", "ab01-a"), 100, time.Now())
+
+	release := c.Acquire()
+	stats, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.InUse)
+
+	_, err = c.Trim()
+	assert.ErrorIs(t, err, ErrInUse)
+	_, err = c.Purge()
+	assert.ErrorIs(t, err, ErrInUse)
+	assert.FileExists(t, filepath.Join(c.kindDir(Build), "ab", "ab01-a"))
+
+	// La libération du dernier build nettoie le cache qui dépasse sa limite
+	release()
+	assert.NoFileExists(t, filepath.Join(c.kindDir(Build), "ab", "ab01-a"))
+}
+
+func TestPurge(t *testing.T) {
+	c, err := New(t.TempDir(), Limits{})
+	require.NoError(t, err)
+	addModule(t, c, "example.com/lib", "v1.0.0", 100, time.Now())
+	writeFile(t, filepath.Join(c.kindDir(Build), "ab", "ab01-a"), 50, time.Now())
+
+	freed, err := c.Purge(Build)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), freed)
+	assert.DirExists(t, c.kindDir(Build))
+
+	freed, err = c.Purge()
+	require.NoError(t, err)
+	assert.Equal(t, int64(220), freed)
+
+	stats, err := c.Stats()
+	require.NoError(t, err)
+	assert.Zero(t, stats.Mod.Size)
+	assert.Zero(t, stats.Mod.Entries)
+}
+
+func TestParseSize(t *testing.T) {
+	for input, expected := range map[string]int64{
+		"0":       0,
+		"1048576": 1 << 20,
+		"500MB":   500e6,
+		"10GiB":   10 << 30,
+		"1.5G":    3 << 29,
+		"2 KiB":   2048,
+		"100B":    100,
+	} {
+		size, err := ParseSize(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, size, input)
+	}
+
+	for _, input := range []string{"", "ten", "-1GB", "10PB"} {
+		_, err := ParseSize(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseKind(t *testing.T) {
+	kind, err := ParseKind("mod")
+	require.NoError(t, err)
+	assert.Equal(t, Mod, kind)
+	_, err = ParseKind("all")
+	assert.EqualError(t, err, `unknown cache "all", expected mod or build`)
+}
@@ -0,0 +1,80 @@
+// Package ldflags rend les modèles -ldflags des projets, qui permettent
+// d'inscrire la version d'un build dans ses binaires :
+//
+//	-s -w -X main.version={{.Version}} -X main.commit={{.Commit}} -X main.date={{.Date}}
+//
+// Les modèles utilisent la syntaxe de text/template. Les variables disponibles
+// sont les champs de Vars ; une variable inconnue est refusée.
+package ldflags
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Vars sont les variables disponibles dans un modèle -ldflags
+type Vars struct {
+	Commit      string // SHA complet du commit compilé
+	ShortCommit string // 7 premiers caractères du SHA
+	Tag         string // Tag pointant sur le commit, vide sinon
+	Version     string // Tag, ou description à la git describe (v1.2.0-3-gabc1234), ou SHA court
+	Branch      string // Branche ou tag demandé pour le build
+	BuildID     int
+	Date        string // Date du build au format RFC 3339 (UTC)
+	Project     string // Nom du projet
+}
+
+// example sert à vérifier qu'un modèle peut être rendu au moment de sa saisie
+var example = Vars{
+	Commit:      "0123456789abcdef0123456789abcdef01234567",
+	ShortCommit: "0123456",
+	Tag:         "v1.0.0",
+	Version:     "v1.0.0",
+	Branch:      "main",
+	BuildID:     1,
+	Date:        "2006-01-02T15:04:05Z",
+	Project:     "project",
+}
+
+// Parse analyse un modèle -ldflags. Un modèle vide n'ajoute aucune option.
+func Parse(text string) (*template.Template, error) {
+	tmpl, err := template.New("ldflags").Option("missingkey=error").Parse(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ldflags template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Validate vérifie qu'un modèle est valide et ne référence que des variables
+// connues, et retourne sa forme normalisée
+func Validate(text string) (string, error) {
+	if _, err := Render(text, example); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
+
+// Render rend un modèle -ldflags avec les variables d'un build
+func Render(text string, vars Vars) (string, error) {
+	tmpl, err := Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("invalid ldflags template: %w", err)
+	}
+	// Les options sont passées à go build en un seul argument : les retours à
+	// la ligne d'un modèle sur plusieurs lignes, et l'indentation qui les
+	// entoure, sont des séparateurs. Les espaces d'une ligne sont conservés,
+	// comme ceux d'une valeur entre guillemets.
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " "), nil
+}
@@ -0,0 +1,45 @@
+package ldflags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	vars := Vars{
+		Commit:      "3f9c2ab51e0d7c4b8a4f0e6c2d1b9a8e7f6d5c4b",
+		ShortCommit: "3f9c2ab",
+		Tag:         "v1.2.0",
+		Version:     "v1.2.0",
+		BuildID:     42,
+		Date:        "2025-12-12T09:30:00Z",
+		Project:     "api",
+	}
+
+	flags, err := Render("-s -w\n  -X main.version={{.Tag}} -X main.commit={{.Commit}}\n  -X main.build={{.BuildID}} -X main.date={{.Date}} -X main.name={{.Project}}", vars)
+	require.NoError(t, err)
+	assert.Equal(t, "-s -w -X main.version=v1.2.0 -X main.commit=3f9c2ab51e0d7c4b8a4f0e6c2d1b9a8e7f6d5c4b -X main.build=42 -X main.date=2025-12-12T09:30:00Z -X main.name=api", flags)
+
+	// Les espaces d'une valeur entre guillemets sont conservés
+	flags, err = Render("  -X 'main.title=Mon  API {{.Tag}}'\r\n\t-s\n", vars)
+	require.NoError(t, err)
+	assert.Equal(t, "-X 'main.title=Mon  API v1.2.0' -s", flags)
+
+	flags, err = Render("", vars)
+	require.NoError(t, err)
+	assert.Empty(t, flags)
+}
+
+func TestValidate(t *testing.T) {
+	text, err := Validate("  -X main.version={{.Version}}\n")
+	require.NoError(t, err)
+	assert.Equal(t, "-X main.version={{.Version}}", text)
+
+	_, err = Validate("-X main.version={{.Release}}")
+	assert.ErrorContains(t, err, "invalid ldflags template")
+
+	_, err = Validate("-X main.version={{.Tag")
+	assert.ErrorContains(t, err, "invalid ldflags template")
+}
@@ -0,0 +1,178 @@
+// Package logmask remplace les valeurs sensibles d'un build (secrets, jetons)
+// par *** dans ses logs et ses messages d'erreur.
+//
+// Chaque valeur est aussi masquée sous ses formes encodées en base64 (alphabets
+// standard et URL, avec ou sans padding, y compris au milieu d'un texte plus long
+// comme un en-tête Basic) et en URL (query et path). Les valeurs de moins de
+// MinLength octets ne sont pas masquées : elles apparaissent trop souvent dans des
+// logs ordinaires.
+package logmask
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// Mask remplace chaque valeur sensible
+	Mask = "***"
+
+	// MinLength est la longueur minimale d'une valeur masquée
+	MinLength = 4
+
+	// minEncodedLength est la longueur minimale d'un fragment base64 masqué
+	minEncodedLength = 8
+)
+
+// Masker masque un ensemble de valeurs sensibles. Un Masker nil ne masque rien.
+type Masker struct {
+	variants []string // Formes à masquer, des plus longues aux plus courtes
+	replacer *strings.Replacer
+}
+
+// New crée un Masker pour les valeurs données. Retourne nil si aucune valeur
+// n'est assez longue pour être masquée.
+func New(values ...string) *Masker {
+	seen := make(map[string]bool)
+	var variants []string
+	add := func(v string) {
+		if len(v) >= MinLength && !seen[v] {
+			seen[v] = true
+			variants = append(variants, v)
+		}
+	}
+
+	for _, value := range values {
+		if len(value) < MinLength {
+			continue
+		}
+		add(value)
+		add(url.QueryEscape(value))
+		add(url.PathEscape(value))
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+			raw := enc.WithPadding(base64.NoPadding)
+			add(enc.EncodeToString([]byte(value)))
+			add(raw.EncodeToString([]byte(value)))
+			for _, fragment := range base64Fragments(raw, value) {
+				if len(fragment) >= minEncodedLength {
+					add(fragment)
+				}
+			}
+		}
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+
+	// Les formes les plus longues d'abord, pour qu'une valeur ne soit pas
+	// masquée partiellement par une forme plus courte
+	sort.SliceStable(variants, func(i, j int) bool { return len(variants[i]) > len(variants[j]) })
+
+	pairs := make([]string, 0, 2*len(variants))
+	for _, v := range variants {
+		pairs = append(pairs, v, Mask)
+	}
+	return &Masker{variants: variants, replacer: strings.NewReplacer(pairs...)}
+}
+
+// base64Fragments retourne l'encodage de value tel qu'il apparaît quelle que soit
+// sa position dans un texte encodé : pour chacun des trois décalages possibles,
+// seuls les groupes de 4 caractères qui ne dépendent que de value sont conservés.
+func base64Fragments(enc *base64.Encoding, value string) []string {
+	fragments := make([]string, 0, 3)
+	for shift := 0; shift < 3; shift++ {
+		buf := append(make([]byte, shift), value...)
+		encoded := enc.EncodeToString(buf)
+
+		start := 0
+		if shift > 0 {
+			start = 4 // Le premier groupe contient des octets qui précèdent value
+		}
+		end := len(buf) / 3 * 4 // Le dernier groupe incomplet dépend des octets qui suivent
+		if end > start {
+			fragments = append(fragments, encoded[start:end])
+		}
+	}
+	return fragments
+}
+
+// Mask retourne s avec chaque valeur sensible remplacée par ***
+func (m *Masker) Mask(s string) string {
+	if m == nil {
+		return s
+	}
+	return m.replacer.Replace(s)
+}
+
+// heldLength retourne la longueur du plus long suffixe de b qui commence une forme
+// à masquer : il faut attendre la suite pour savoir s'il doit être masqué.
+func (m *Masker) heldLength(b []byte) int {
+	longest := len(m.variants[0]) - 1
+	if longest > len(b) {
+		longest = len(b)
+	}
+	for n := longest; n > 0; n-- {
+		suffix := b[len(b)-n:]
+		for _, v := range m.variants {
+			if len(v) > n && strings.HasPrefix(v, string(suffix)) {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// Writer masque les valeurs sensibles de ce qui est écrit avant de le transmettre
+// à un autre io.Writer. Une valeur coupée entre deux écritures est masquée : seule
+// la fin d'une écriture qui pourrait commencer une valeur est retenue jusqu'à
+// l'écriture suivante ou jusqu'à Flush.
+type Writer struct {
+	masker *Masker
+	w      io.Writer
+
+	mu      sync.Mutex
+	pending []byte
+}
+
+// NewWriter crée un Writer qui masque les valeurs de m avant d'écrire dans w
+func NewWriter(m *Masker, w io.Writer) *Writer {
+	return &Writer{masker: m, w: w}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.masker == nil {
+		return w.w.Write(p)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	masked := []byte(w.masker.Mask(string(append(w.pending, p...))))
+	held := w.masker.heldLength(masked)
+	w.pending = bytes.Clone(masked[len(masked)-held:])
+
+	if len(masked) > held {
+		if _, err := w.w.Write(masked[:len(masked)-held]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush écrit la sortie retenue dans l'attente d'une écriture suivante
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(w.pending)
+	w.pending = nil
+	return err
+}
@@ -0,0 +1,63 @@
+package logmask
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMask(t *testing.T) {
+	const token = "ghp_s3cr3t/T0ken+42"
+	m := New(token, "abc", "")
+	require.NotNil(t, m)
+
+	for _, form := range []string{
+		token,
+		url.QueryEscape(token),
+		url.PathEscape(token),
+		base64.StdEncoding.EncodeToString([]byte(token)),
+		base64.RawURLEncoding.EncodeToString([]byte(token)),
+	} {
+		assert.Equal(t, "token=*** end", m.Mask("token="+form+" end"), form)
+	}
+
+	// Valeur encodée au milieu d'un texte plus long, comme un en-tête Basic
+	basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + token + "\n"))
+	masked := m.Mask("Authorization: Basic " + basic)
+	assert.Contains(t, masked, Mask)
+	assert.NotContains(t, masked, basic)
+
+	// Les valeurs trop courtes ne sont pas masquées
+	assert.Equal(t, "abc", m.Mask("abc"))
+	assert.Nil(t, New("abc", ""))
+	assert.Equal(t, "abc", (*Masker)(nil).Mask("abc"))
+}
+
+func TestWriterSplitValue(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(New("hunter2-password"), &out)
+
+	// La valeur est coupée entre plusieurs écritures
+	for _, chunk := range []string{"login with hun", "ter2-pass", "word ok\nnext hunter"} {
+		n, err := fmt.Fprint(w, chunk)
+		require.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+	}
+	assert.Equal(t, "login with *** ok\nnext ", out.String(), "Le début possible d'une valeur est retenu")
+
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "login with *** ok\nnext hunter", out.String())
+}
+
+func TestWriterWithoutValues(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(nil, &out)
+	fmt.Fprint(w, "no secret")
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "no secret", out.String())
+}
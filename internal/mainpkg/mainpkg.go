@@ -0,0 +1,167 @@
+// Package mainpkg décrit les binaires compilés pour un projet : chacun est un
+// package main du dépôt et le nom du binaire produit.
+//
+// Le chemin du package est relatif au sous-répertoire du projet :
+//
+//	{"package": "./cmd/server", "name": "server"}
+//	{"package": "tools/migrate"}   (nom par défaut : migrate)
+//
+// Les packages main peuvent aussi être découverts dans le dépôt avec Discover.
+package mainpkg
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Binary est un binaire à compiler : le package main et le nom du fichier produit
+type Binary struct {
+	Package string `json:"package"` // Chemin relatif au sous-répertoire du projet, ex: ./cmd/server
+	Name    string `json:"name"`    // Nom du binaire, dernier élément du package par défaut
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Parse valide un binaire et retourne sa forme normalisée : le package est un
+// chemin relatif commençant par "./", le nom est déduit du package s'il est vide
+func Parse(b Binary) (Binary, error) {
+	pkg := strings.TrimSpace(b.Package)
+	if pkg == "" {
+		return Binary{}, fmt.Errorf("invalid binary: package is required")
+	}
+	if path.IsAbs(pkg) || filepath.IsAbs(pkg) || strings.Contains(pkg, "\\") {
+		return Binary{}, fmt.Errorf("invalid binary package %q: expected a relative path", b.Package)
+	}
+	if strings.Contains(pkg, "...") {
+		return Binary{}, fmt.Errorf("invalid binary package %q: patterns are not allowed, use discovery instead", b.Package)
+	}
+
+	pkg = path.Clean(pkg)
+	if pkg == ".." || strings.HasPrefix(pkg, "../") {
+		return Binary{}, fmt.Errorf("invalid binary package %q: path escapes the project directory", b.Package)
+	}
+
+	name := strings.TrimSpace(b.Name)
+	if name == "" {
+		if pkg == "." {
+			return Binary{}, fmt.Errorf("invalid binary package %q: name is required for the root package", b.Package)
+		}
+		name = path.Base(pkg)
+	}
+	if !namePattern.MatchString(name) {
+		return Binary{}, fmt.Errorf("invalid binary name %q", name)
+	}
+
+	if pkg != "." {
+		pkg = "./" + pkg
+	}
+	return Binary{Package: pkg, Name: name}, nil
+}
+
+// ParseList valide une liste de binaires et retourne leurs formes normalisées.
+// Deux binaires ne peuvent pas avoir le même nom ni le même package.
+func ParseList(list []Binary) ([]Binary, error) {
+	binaries := []Binary{}
+	names := make(map[string]bool)
+	packages := make(map[string]bool)
+
+	for _, b := range list {
+		b, err := Parse(b)
+		if err != nil {
+			return nil, err
+		}
+		if names[b.Name] {
+			return nil, fmt.Errorf("duplicate binary name %q", b.Name)
+		}
+		if packages[b.Package] {
+			return nil, fmt.Errorf("duplicate binary package %q", b.Package)
+		}
+		names[b.Name] = true
+		packages[b.Package] = true
+		binaries = append(binaries, b)
+	}
+
+	return binaries, nil
+}
+
+// Discover cherche les packages main sous dir et retourne un binaire par package,
+// nommé d'après son répertoire. Le package à la racine de dir est nommé rootName.
+// Les répertoires testdata, vendor, cachés ou préfixés par "_" sont ignorés,
+// comme par `go build ./...`, ainsi que les modules imbriqués.
+func Discover(dir, rootName string) ([]Binary, error) {
+	binaries := []Binary{}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if rel != "." {
+			name := d.Name()
+			if name == "testdata" || name == "vendor" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		isMain, err := isMainPackage(p)
+		if err != nil || !isMain {
+			return err
+		}
+
+		b := Binary{Package: ".", Name: rootName}
+		if rel != "." {
+			rel = filepath.ToSlash(rel)
+			b = Binary{Package: "./" + rel, Name: path.Base(rel)}
+		}
+		binaries = append(binaries, b)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return binaries, nil
+}
+
+// isMainPackage indique si les fichiers Go d'un répertoire, hors tests,
+// déclarent le package main
+func isMainPackage(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err != nil {
+			// Un fichier invalide sera signalé par go build
+			continue
+		}
+		if file.Name.Name == "main" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,84 @@
+package mainpkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	b, err := Parse(Binary{Package: " cmd/server/ "})
+	require.NoError(t, err)
+	assert.Equal(t, Binary{Package: "./cmd/server", Name: "server"}, b)
+
+	b, err = Parse(Binary{Package: "./tools/../cmd/migrate", Name: "db-migrate"})
+	require.NoError(t, err)
+	assert.Equal(t, Binary{Package: "./cmd/migrate", Name: "db-migrate"}, b)
+
+	b, err = Parse(Binary{Package: ".", Name: "tool"})
+	require.NoError(t, err)
+	assert.Equal(t, Binary{Package: ".", Name: "tool"}, b)
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, b := range []Binary{
+		{},
+		{Package: "/usr/src/tool"},
+		{Package: "../other/cmd/tool"},
+		{Package: "./cmd/..."},
+		{Package: "."},
+		{Package: "./cmd/tool", Name: "../tool"},
+		{Package: "./cmd/tool", Name: "my tool"},
+	} {
+		_, err := Parse(b)
+		assert.Error(t, err, "Le binaire %+v devrait être refusé", b)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	binaries, err := ParseList([]Binary{{Package: "cmd/server"}, {Package: "cmd/worker", Name: "worker"}})
+	require.NoError(t, err)
+	assert.Equal(t, []Binary{{Package: "./cmd/server", Name: "server"}, {Package: "./cmd/worker", Name: "worker"}}, binaries)
+
+	_, err = ParseList([]Binary{{Package: "cmd/tool"}, {Package: "tools/tool"}})
+	assert.EqualError(t, err, `duplicate binary name "tool"`)
+
+	_, err = ParseList([]Binary{{Package: "cmd/tool"}, {Package: "./cmd/tool", Name: "other"}})
+	assert.EqualError(t, err, `duplicate binary package "./cmd/tool"`)
+
+	binaries, err = ParseList(nil)
+	require.NoError(t, err)
+	assert.Empty(t, binaries)
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":                      "package main\n",
+		"cmd/server/main.go":           "package main\n",
+		"cmd/server/main_test.go":      "package main_test\n",
+		"cmd/worker/worker.go":         "// Worker\npackage main\n",
+		"internal/lib/lib.go":          "package lib\n",
+		"internal/lib/testdata/x.go":   "package main\n",
+		"vendor/example.com/x/main.go": "package main\n",
+		".hidden/main.go":              "package main\n",
+		"_old/main.go":                 "package main\n",
+		"nested/go.mod":                "module example.com/nested\n",
+		"nested/main.go":               "package main\n",
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	binaries, err := Discover(dir, "tool")
+	require.NoError(t, err)
+	assert.Equal(t, []Binary{
+		{Package: ".", Name: "tool"},
+		{Package: "./cmd/server", Name: "server"},
+		{Package: "./cmd/worker", Name: "worker"},
+	}, binaries)
+}
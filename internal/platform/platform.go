@@ -0,0 +1,135 @@
+// Package platform décrit les plateformes cibles de la compilation croisée
+// d'un projet.
+//
+// Une plateforme s'écrit os/arch, éventuellement suivie d'une variante :
+//
+//	linux/amd64
+//	linux/arm/v7     (GOARM=7)
+//	linux/amd64/v3   (GOAMD64=v3)
+//
+// Les variantes ne sont acceptées que pour arm (v5, v6, v7) et amd64 (v1 à v4).
+// La validité du couple os/arch est vérifiée par la chaîne de compilation Go au
+// moment du build.
+package platform
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Platform est une cible de compilation : GOOS, GOARCH et la variante éventuelle
+// (GOARM pour arm, GOAMD64 pour amd64)
+type Platform struct {
+	OS      string
+	Arch    string
+	Variant string
+}
+
+var namePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// variants liste les variantes acceptées par architecture
+var variants = map[string][]string{
+	"arm":   {"v5", "v6", "v7"},
+	"amd64": {"v1", "v2", "v3", "v4"},
+}
+
+// Parse analyse une plateforme au format os/arch[/variante]
+func Parse(s string) (Platform, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Platform{}, fmt.Errorf("invalid platform %q: expected os/arch or os/arch/variant", s)
+	}
+
+	p := Platform{OS: parts[0], Arch: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	if !namePattern.MatchString(p.OS) {
+		return Platform{}, fmt.Errorf("invalid platform %q: invalid os %q", s, p.OS)
+	}
+	if !namePattern.MatchString(p.Arch) {
+		return Platform{}, fmt.Errorf("invalid platform %q: invalid arch %q", s, p.Arch)
+	}
+	if p.Variant != "" && !contains(variants[p.Arch], p.Variant) {
+		if allowed, ok := variants[p.Arch]; ok {
+			return Platform{}, fmt.Errorf("invalid platform %q: variant of %s must be one of %s", s, p.Arch, strings.Join(allowed, ", "))
+		}
+		return Platform{}, fmt.Errorf("invalid platform %q: %s has no variant", s, p.Arch)
+	}
+
+	return p, nil
+}
+
+// ParseList analyse une liste de plateformes et retourne leurs formes normalisées.
+// Les doublons sont refusés.
+func ParseList(list []string) ([]Platform, error) {
+	platforms := []Platform{}
+	seen := make(map[Platform]bool)
+
+	for _, s := range list {
+		p, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("duplicate platform %q", p)
+		}
+		seen[p] = true
+		platforms = append(platforms, p)
+	}
+
+	return platforms, nil
+}
+
+// String retourne la forme normalisée os/arch[/variante]
+func (p Platform) String() string {
+	if p.Variant == "" {
+		return p.OS + "/" + p.Arch
+	}
+	return p.OS + "/" + p.Arch + "/" + p.Variant
+}
+
+// Env retourne les variables d'environnement sélectionnant la plateforme pour `go build`
+func (p Platform) Env() []string {
+	env := []string{"GOOS=" + p.OS, "GOARCH=" + p.Arch}
+
+	switch {
+	case p.Variant == "":
+	case p.Arch == "arm":
+		env = append(env, "GOARM="+strings.TrimPrefix(p.Variant, "v"))
+	case p.Arch == "amd64":
+		env = append(env, "GOAMD64="+p.Variant)
+	}
+
+	return env
+}
+
+// BinarySuffix retourne le suffixe d'un nom de binaire pour cette plateforme,
+// par exemple "-linux-arm-v7" ou "-windows-amd64.exe"
+func (p Platform) BinarySuffix() string {
+	suffix := "-" + strings.ReplaceAll(p.String(), "/", "-")
+	if p.OS == "windows" {
+		suffix += ".exe"
+	}
+	return suffix
+}
+
+// Strings retourne les formes normalisées d'une liste de plateformes
+func Strings(platforms []Platform) []string {
+	list := make([]string, 0, len(platforms))
+	for _, p := range platforms {
+		list = append(list, p.String())
+	}
+	return list
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	p, err := Parse(" linux/arm/v7 ")
+	require.NoError(t, err)
+	assert.Equal(t, Platform{OS: "linux", Arch: "arm", Variant: "v7"}, p)
+	assert.Equal(t, "linux/arm/v7", p.String())
+	assert.Equal(t, []string{"GOOS=linux", "GOARCH=arm", "GOARM=7"}, p.Env())
+	assert.Equal(t, "-linux-arm-v7", p.BinarySuffix())
+
+	p, err = Parse("windows/amd64/v3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GOOS=windows", "GOARCH=amd64", "GOAMD64=v3"}, p.Env())
+	assert.Equal(t, "-windows-amd64-v3.exe", p.BinarySuffix())
+
+	p, err = Parse("darwin/arm64")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"GOOS=darwin", "GOARCH=arm64"}, p.Env())
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"linux", "linux/", "/amd64", "linux/amd64/v3/x", "Linux/amd64", "linux/arm/v8", "linux/arm64/v8", "linux/amd64/v5"} {
+		_, err := Parse(s)
+		assert.Error(t, err, "La plateforme %q devrait être refusée", s)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	platforms, err := ParseList([]string{"linux/amd64", "linux/arm64", "windows/amd64"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm64", "windows/amd64"}, Strings(platforms))
+
+	_, err = ParseList([]string{"linux/amd64", " linux/amd64"})
+	assert.EqualError(t, err, `duplicate platform "linux/amd64"`)
+
+	platforms, err = ParseList(nil)
+	require.NoError(t, err)
+	assert.Empty(t, platforms)
+}
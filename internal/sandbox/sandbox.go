@@ -0,0 +1,194 @@
+// Package sandbox exécute les commandes d'un build isolées du reste du serveur,
+// sous Linux : dans leurs propres espaces de noms utilisateur, de montage et de
+// processus, elles ne voient du système de fichiers que les répertoires système,
+// en lecture seule, et les répertoires du build et des caches. Chaque processus
+// est limité en temps CPU, en mémoire et en fichiers ouverts, et l'utilisateur du
+// serveur en nombre de processus (RLIMIT_NPROC, compté par UID). Un
+// sandbox hors ligne a en plus son propre espace de noms réseau, sans accès au
+// réseau.
+//
+// Le sandbox est mis en place par le binaire lui-même, relancé sous le nom
+// HelperName : le processus relancé prépare les montages et les limites, puis
+// exécute la commande. Le relancement est détecté à l'initialisation du package,
+// avant main.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// HelperName est le nom (argv[0]) sous lequel le binaire est relancé pour mettre
+// en place le sandbox. Ses erreurs sont écrites sur la sortie d'erreur, préfixées
+// par ce nom.
+const HelperName = "gip-sandbox"
+
+// setupFailed est le code de sortie du processus relancé quand le sandbox n'a pas
+// pu être mis en place
+const setupFailed = 125
+
+// Limites par défaut, pour les champs nuls d'une Policy
+const (
+	DefaultCPUSeconds = 600
+	DefaultMemoryMB   = 4096
+	DefaultOpenFiles  = 4096
+	DefaultProcesses  = 4096
+)
+
+// Policy est la politique de sandbox d'un projet : les limites de chaque
+// processus du build. Un champ nul prend la valeur par défaut.
+//
+// La limite de processus (RLIMIT_NPROC) n'est pas propre au sandbox : le noyau
+// compte tous les processus et threads de l'UID réel, celui du serveur, y compris
+// ceux des autres builds en cours et du serveur lui-même, et ne l'applique pas à root.
+type Policy struct {
+	CPUSeconds int `json:"cpu_seconds"` // Temps CPU, en secondes
+	MemoryMB   int `json:"memory_mb"`   // Mémoire allouée (RLIMIT_DATA), en Mio
+	OpenFiles  int `json:"open_files"`  // Fichiers ouverts
+	Processes  int `json:"processes"`   // Processus et threads de l'UID du serveur (RLIMIT_NPROC)
+}
+
+// maxLimit borne les limites, pour qu'elles ne débordent pas une fois converties
+const maxLimit = 1 << 30
+
+// Validate vérifie les limites de la politique
+func (p *Policy) Validate() error {
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"cpu_seconds", p.CPUSeconds},
+		{"memory_mb", p.MemoryMB},
+		{"open_files", p.OpenFiles},
+		{"processes", p.Processes},
+	} {
+		if limit.value < 0 || limit.value > maxLimit {
+			return fmt.Errorf("%s must be between 0 (default) and %d", limit.name, maxLimit)
+		}
+	}
+	return nil
+}
+
+// WithDefaults retourne la politique dont les champs nuls ont la valeur par défaut
+func (p Policy) WithDefaults() Policy {
+	if p.CPUSeconds == 0 {
+		p.CPUSeconds = DefaultCPUSeconds
+	}
+	if p.MemoryMB == 0 {
+		p.MemoryMB = DefaultMemoryMB
+	}
+	if p.OpenFiles == 0 {
+		p.OpenFiles = DefaultOpenFiles
+	}
+	if p.Processes == 0 {
+		p.Processes = DefaultProcesses
+	}
+	return p
+}
+
+// String décrit les limites de la politique, pour les logs du build
+func (p Policy) String() string {
+	return fmt.Sprintf("CPU %ds, memory %d MB, %d open files, %d processes", p.CPUSeconds, p.MemoryMB, p.OpenFiles, p.Processes)
+}
+
+// Sandbox décrit l'environnement isolé des commandes d'un build
+type Sandbox struct {
+	Policy   Policy
+	Writable []string // Répertoires visibles en écriture : celui du build, les caches
+	ReadOnly []string // Répertoires visibles en lecture seule, en plus de ceux du système
+	Offline  bool     // Sans accès au réseau : seule l'interface lo est disponible
+}
+
+// LimitError indique qu'une commande a échoué parce qu'elle a atteint une limite du sandbox
+type LimitError struct {
+	Limit string // Limite atteinte : "CPU time", "memory", "open files" ou "processes"
+	Value string // Valeur de la limite, avec son unité
+	Err   error  // Erreur de la commande
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("sandbox %s limit exceeded (%s)", e.Limit, e.Value)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// SetupError indique que le sandbox d'une commande n'a pas pu être mis en place
+type SetupError struct {
+	Reason string
+	Err    error // Erreur de la commande
+}
+
+func (e *SetupError) Error() string {
+	return "sandbox setup failed: " + e.Reason
+}
+
+func (e *SetupError) Unwrap() error {
+	return e.Err
+}
+
+// Cause retourne la cause d'échec d'une commande exécutée dans le sandbox, à
+// partir de son erreur err et de la fin de sa sortie : *SetupError si le sandbox
+// n'a pas pu être mis en place, *LimitError si une limite a été atteinte. Sinon,
+// la cause est inconnue et elle retourne err.
+//
+// Les limites sont reconnues aux messages d'erreur des processus qui les
+// atteignent : allocation refusée, création de processus ou de thread refusée
+// (EAGAIN de fork, clone ou pthread_create), trop de fichiers ouverts. Au-delà de
+// son temps CPU, un processus reçoit SIGXCPU, puis SIGKILL une seconde plus tard
+// s'il l'ignore, comme les programmes Go. SIGKILL peut aussi venir du noyau à
+// court de mémoire ou d'un autre processus : il n'est attribué à la limite que si
+// la commande a consommé son temps CPU, et un sous-processus tué (« signal:
+// killed ») a une cause inconnue.
+func (s *Sandbox) Cause(err error, output string) error {
+	if err == nil {
+		return nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		// Le processus n'a pas démarré : les espaces de noms n'ont pas pu être créés
+		return &SetupError{Reason: err.Error(), Err: err}
+	}
+	if exitErr.ExitCode() == setupFailed {
+		for _, line := range strings.Split(output, "\n") {
+			if reason, found := strings.CutPrefix(line, HelperName+": "); found {
+				return &SetupError{Reason: reason, Err: err}
+			}
+		}
+	}
+
+	policy := s.Policy.WithDefaults()
+	output = strings.ToLower(output)
+	switch {
+	case strings.Contains(output, "too many open files"):
+		return &LimitError{Limit: "open files", Value: fmt.Sprint(policy.OpenFiles), Err: err}
+	case processRefused(output):
+		return &LimitError{Limit: "processes", Value: fmt.Sprint(policy.Processes), Err: err}
+	case strings.Contains(output, "out of memory"),
+		strings.Contains(output, "cannot allocate memory"):
+		return &LimitError{Limit: "memory", Value: fmt.Sprintf("%d MB", policy.MemoryMB), Err: err}
+	case cpuLimited(exitErr, policy.CPUSeconds),
+		strings.Contains(output, "cpu time limit exceeded"):
+		return &LimitError{Limit: "CPU time", Value: fmt.Sprintf("%ds", policy.CPUSeconds), Err: err}
+	}
+	return err
+}
+
+// processRefused indique si la sortie output, en minuscules, signale une création
+// de processus ou de thread refusée. Un EAGAIN d'une autre opération (lecture non
+// bloquante...) n'est pas lié à la limite de processus.
+func processRefused(output string) bool {
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.Contains(line, "failed to create new os thread"),
+			strings.Contains(line, "can't fork"),
+			strings.Contains(line, "resource temporarily unavailable") &&
+				(strings.Contains(line, "fork") || strings.Contains(line, "clone") || strings.Contains(line, "pthread_create")):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,490 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// systemPaths sont les répertoires et fichiers du système visibles en lecture
+// seule dans le sandbox : les programmes et bibliothèques, et la configuration
+// réseau et TLS. Le reste de /etc n'est pas visible.
+var systemPaths = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc/alternatives", "/etc/ca-certificates", "/etc/ca-certificates.conf", "/etc/gai.conf",
+	"/etc/group", "/etc/host.conf", "/etc/hosts", "/etc/ld.so.cache", "/etc/ld.so.conf",
+	"/etc/ld.so.conf.d", "/etc/localtime", "/etc/mime.types", "/etc/nsswitch.conf",
+	"/etc/passwd", "/etc/pki", "/etc/protocols", "/etc/resolv.conf", "/etc/services", "/etc/ssl",
+}
+
+// devices sont les périphériques visibles dans /dev
+var devices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+// helperConfig est la configuration transmise au processus relancé
+type helperConfig struct {
+	Name     string   `json:"name"` // Commande à exécuter, vide pour seulement vérifier le sandbox
+	Args     []string `json:"args"`
+	Dir      string   `json:"dir"`
+	ReadOnly []string `json:"read_only"`
+	Writable []string `json:"writable"`
+	Policy   Policy   `json:"policy"`
+	Offline  bool     `json:"offline"` // Espace de noms réseau sans autre interface que lo
+}
+
+func init() {
+	if len(os.Args) != 2 || os.Args[0] != HelperName {
+		return
+	}
+	// Les capacités et no_new_privs sont propres à chaque thread : celui qui les
+	// abandonne doit être celui qui exécute la commande
+	runtime.LockOSThread()
+
+	var config helperConfig
+	if err := json.Unmarshal([]byte(os.Args[1]), &config); err != nil {
+		fail(fmt.Errorf("invalid configuration: %v", err))
+	}
+	if config.Offline {
+		if err := setupLoopback(); err != nil {
+			fail(err)
+		}
+	}
+	if err := setupRoot(&config); err != nil {
+		fail(err)
+	}
+	if err := setLimits(config.Policy); err != nil {
+		fail(err)
+	}
+	if err := os.Chdir(config.Dir); err != nil {
+		fail(err)
+	}
+	if config.Name == "" {
+		os.Exit(0)
+	}
+
+	path, err := exec.LookPath(config.Name)
+	if err != nil {
+		// Comme un shell : commande introuvable
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(127)
+	}
+	if err := dropPrivileges(); err != nil {
+		fail(err)
+	}
+	err = syscall.Exec(path, append([]string{config.Name}, config.Args...), os.Environ())
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(126)
+}
+
+// fail termine le processus relancé après l'échec de la mise en place du sandbox
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "%s: %v\n", HelperName, err)
+	os.Exit(setupFailed)
+}
+
+// Command prépare l'exécution de la commande name dans le sandbox, depuis le
+// répertoire dir. Comme pour exec.CommandContext, l'annulation de ctx tue le
+// processus ; tous les processus du sandbox disparaissent avec lui.
+func (s *Sandbox) Command(ctx context.Context, dir, name string, args ...string) (*exec.Cmd, error) {
+	config, err := s.config(dir)
+	if err != nil {
+		return nil, err
+	}
+	config.Name = name
+	config.Args = args
+	return helperCommand(ctx, config)
+}
+
+// Check vérifie que le sandbox peut être mis en place sur ce système, avec les
+// répertoires du build
+func (s *Sandbox) Check(ctx context.Context) error {
+	config, err := s.config("/")
+	if err != nil {
+		return err
+	}
+	cmd, err := helperCommand(ctx, config)
+	if err != nil {
+		return err
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return s.Cause(err, string(output))
+	}
+	return nil
+}
+
+// config retourne la configuration du processus relancé : les chemins existants
+// du système, le GOROOT de la commande go hors de ces chemins, et les répertoires
+// du sandbox
+func (s *Sandbox) config(dir string) (*helperConfig, error) {
+	config := &helperConfig{Dir: dir, Policy: s.Policy.WithDefaults(), Offline: s.Offline}
+	for _, path := range systemPaths {
+		if _, err := os.Lstat(path); err == nil {
+			config.ReadOnly = append(config.ReadOnly, path)
+		}
+	}
+	if goroot := goRoot(); goroot != "" && !covered(config.ReadOnly, goroot) {
+		config.ReadOnly = append(config.ReadOnly, goroot)
+	}
+	for _, path := range s.ReadOnly {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		config.ReadOnly = append(config.ReadOnly, abs)
+	}
+	for _, path := range s.Writable {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		config.Writable = append(config.Writable, abs)
+	}
+	return config, nil
+}
+
+// goRoot retourne le répertoire d'installation de la commande go du PATH, vide si
+// elle est introuvable
+func goRoot() string {
+	path, err := exec.LookPath("go")
+	if err != nil {
+		return ""
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return ""
+	}
+	// <GOROOT>/bin/go
+	return filepath.Dir(filepath.Dir(path))
+}
+
+// covered indique si path est l'un des répertoires dirs ou se trouve dans l'un d'eux
+func covered(dirs []string, path string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// helperCommand prépare le relancement du binaire avec la configuration config,
+// dans de nouveaux espaces de noms, et un nouvel espace de noms réseau pour un
+// sandbox hors ligne. L'utilisateur du serveur y est root, pour préparer les
+// montages, mais la commande est exécutée sans ses capacités.
+func helperCommand(ctx context.Context, config *helperConfig) (*exec.Cmd, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var cloneflags uintptr = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if config.Offline {
+		cloneflags |= syscall.CLONE_NEWNET
+	}
+	cmd := exec.CommandContext(ctx, "/proc/self/exe")
+	cmd.Args = []string{HelperName, string(data)}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 cloneflags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+	}
+	return cmd, nil
+}
+
+// setupLoopback active l'interface lo du nouvel espace de noms réseau, pour que
+// les tests puissent écouter et se connecter sur 127.0.0.1
+func setupLoopback() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("loopback: %v", err)
+	}
+	defer unix.Close(fd)
+	ifreq, err := unix.NewIfreq("lo")
+	if err != nil {
+		return fmt.Errorf("loopback: %v", err)
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifreq); err != nil {
+		return fmt.Errorf("loopback: %v", err)
+	}
+	ifreq.SetUint16(ifreq.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifreq); err != nil {
+		return fmt.Errorf("loopback: %v", err)
+	}
+	return nil
+}
+
+// setupRoot remplace la racine du processus par un tmpfs qui ne contient que les
+// chemins de config, /dev, /proc et /tmp. Les chemins sont montés depuis
+// l'ancienne racine, déplacée dans un premier tmpfs, pour qu'aucun ne soit masqué
+// par un autre montage.
+func setupRoot(config *helperConfig) error {
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("mount namespace: %v", err)
+	}
+	if err := unix.Mount("tmpfs", "/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=0755"); err != nil {
+		return fmt.Errorf("mount tmpfs: %v", err)
+	}
+	for _, dir := range []string{"/tmp/newroot", "/tmp/oldroot"} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := unix.PivotRoot("/tmp", "/tmp/oldroot"); err != nil {
+		return fmt.Errorf("pivot_root: %v", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return err
+	}
+	// La nouvelle racine doit être un point de montage
+	if err := unix.Mount("/newroot", "/newroot", "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("mount root: %v", err)
+	}
+
+	if err := os.MkdirAll("/newroot/proc", 0o755); err != nil {
+		return err
+	}
+	if err := unix.Mount("proc", "/newroot/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %v", err)
+	}
+	if err := setupDev(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll("/newroot/tmp", 0o755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", "/newroot/tmp", "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+		return fmt.Errorf("mount /tmp: %v", err)
+	}
+
+	// Après /tmp, qui masquerait un répertoire du build qui s'y trouve
+	for _, path := range config.ReadOnly {
+		if err := bind(path, true); err != nil {
+			return err
+		}
+	}
+	for _, path := range config.Writable {
+		if err := bind(path, false); err != nil {
+			return err
+		}
+	}
+
+	// La racine devient /newroot, l'ancienne est démontée avec tout ce qu'elle contient
+	if err := os.Chdir("/newroot"); err != nil {
+		return err
+	}
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %v", err)
+	}
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("unmount old root: %v", err)
+	}
+	return os.Chdir("/")
+}
+
+// bind rend path visible dans la nouvelle racine, au même chemin. Un lien
+// symbolique, comme /bin vers usr/bin, est recréé tel quel.
+func bind(path string, readOnly bool) error {
+	source := "/oldroot" + path
+	target := "/newroot" + path
+	info, err := os.Lstat(source)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		link, err := os.Readlink(source)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	case info.IsDir():
+		err = os.MkdirAll(target, 0o755)
+	default:
+		err = os.WriteFile(target, nil, 0o644)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := unix.Mount(source, target, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("mount %s: %v", path, err)
+	}
+	if readOnly {
+		if err := remountReadOnly(target); err != nil {
+			return fmt.Errorf("mount %s read-only: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// remountReadOnly rend le montage target, et ceux qu'il contient, accessibles en
+// lecture seule. Sans mount_setattr (Linux < 5.12), seul target l'est.
+func remountReadOnly(target string) error {
+	attr := &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY}
+	err := unix.MountSetattr(-1, target, unix.AT_RECURSIVE, attr)
+	if !errors.Is(err, unix.ENOSYS) {
+		return err
+	}
+
+	// Les options du montage d'origine (nosuid, nodev...) ne peuvent pas être
+	// retirées dans un espace de noms utilisateur : elles sont reprises
+	var stat unix.Statfs_t
+	if err := unix.Statfs(target, &stat); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_BIND | unix.MS_REMOUNT | unix.MS_RDONLY)
+	for st, ms := range map[int64]uintptr{
+		unix.ST_NOSUID:      unix.MS_NOSUID,
+		unix.ST_NODEV:       unix.MS_NODEV,
+		unix.ST_NOEXEC:      unix.MS_NOEXEC,
+		unix.ST_NOATIME:     unix.MS_NOATIME,
+		unix.ST_NODIRATIME:  unix.MS_NODIRATIME,
+		unix.ST_RELATIME:    unix.MS_RELATIME,
+		unix.ST_SYNCHRONOUS: unix.MS_SYNCHRONOUS,
+	} {
+		if stat.Flags&st != 0 {
+			flags |= ms
+		}
+	}
+	return unix.Mount("", target, "", flags, "")
+}
+
+// setupDev crée le /dev du sandbox : un tmpfs avec les seuls périphériques devices
+func setupDev() error {
+	if err := os.MkdirAll("/newroot/dev", 0o755); err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", "/newroot/dev", "tmpfs", unix.MS_NOSUID|unix.MS_NOEXEC, "mode=0755"); err != nil {
+		return fmt.Errorf("mount /dev: %v", err)
+	}
+	for _, device := range devices {
+		source := "/oldroot/dev/" + device
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		target := "/newroot/dev/" + device
+		if err := os.WriteFile(target, nil, 0o644); err != nil {
+			return err
+		}
+		if err := unix.Mount(source, target, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("mount /dev/%s: %v", device, err)
+		}
+	}
+	for name, link := range map[string]string{
+		"fd":     "/proc/self/fd",
+		"stdin":  "/proc/self/fd/0",
+		"stdout": "/proc/self/fd/1",
+		"stderr": "/proc/self/fd/2",
+	} {
+		if err := os.Symlink(link, "/newroot/dev/"+name); err != nil {
+			return err
+		}
+	}
+	return os.Mkdir("/newroot/dev/shm", os.ModeSticky|0o777)
+}
+
+// setLimits applique les limites de la politique, héritées par tous les processus
+// du sandbox. Le temps CPU a une limite stricte une seconde plus loin, pour les
+// processus qui ignorent SIGXCPU ; les core dumps sont désactivés. RLIMIT_NPROC
+// est comparé par le noyau au nombre de processus de l'UID réel, tous sandbox
+// confondus : c'est une limite de l'utilisateur du serveur, pas du sandbox.
+func setLimits(policy Policy) error {
+	cpu := uint64(policy.CPUSeconds)
+	memory := uint64(policy.MemoryMB) << 20
+	files := uint64(policy.OpenFiles)
+	processes := uint64(policy.Processes)
+	for _, limit := range []struct {
+		resource int
+		name     string
+		value    unix.Rlimit
+	}{
+		{unix.RLIMIT_CPU, "CPU time", unix.Rlimit{Cur: cpu, Max: cpu + 1}},
+		{unix.RLIMIT_DATA, "memory", unix.Rlimit{Cur: memory, Max: memory}},
+		{unix.RLIMIT_NOFILE, "open files", unix.Rlimit{Cur: files, Max: files}},
+		{unix.RLIMIT_NPROC, "processes", unix.Rlimit{Cur: processes, Max: processes}},
+		{unix.RLIMIT_CORE, "core dumps", unix.Rlimit{}},
+	} {
+		if err := unix.Setrlimit(limit.resource, &limit.value); err != nil {
+			return fmt.Errorf("set %s limit: %v", limit.name, err)
+		}
+	}
+	return nil
+}
+
+// securebits verrouille la perte des capacités (voir capabilities(7)) : root
+// n'obtient pas de capacités à l'exécution d'un programme (SECBIT_NOROOT), ni ne
+// les perd ou garde en changeant d'UID (SECBIT_NO_SETUID_FIXUP, SECBIT_KEEP_CAPS
+// verrouillé désactivé), et aucune capacité ambiante ne peut être levée
+// (SECBIT_NO_CAP_AMBIENT_RAISE). Chaque option est suivie de son verrou.
+const securebits = 1<<0 | 1<<1 | 1<<2 | 1<<3 | 1<<5 | 1<<6 | 1<<7
+
+// dropPrivileges retire les capacités du processus, pour que la commande ne
+// puisse ni modifier les montages ni lever ses limites, et empêche qu'elle en
+// regagne avec un programme setuid. La commande est root dans l'espace de noms
+// utilisateur : sans capacités héritables et avec SECBIT_NOROOT, elle n'en
+// retrouve aucune à son exécution.
+func dropPrivileges() error {
+	if err := unix.Prctl(unix.PR_SET_SECUREBITS, securebits, 0, 0, 0); err != nil {
+		return fmt.Errorf("lock securebits: %v", err)
+	}
+	data, err := os.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return err
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return err
+	}
+	for capability := 0; capability <= last; capability++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(capability), 0, 0, 0); err != nil {
+			return fmt.Errorf("drop capability %d: %v", capability, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_CAP_AMBIENT, unix.PR_CAP_AMBIENT_CLEAR_ALL, 0, 0, 0); err != nil {
+		return fmt.Errorf("clear ambient capabilities: %v", err)
+	}
+
+	// Les capacités héritables, effectives et permises du thread, qui exécute la commande
+	header := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var caps [2]unix.CapUserData
+	if err := unix.Capset(&header, &caps[0]); err != nil {
+		return fmt.Errorf("clear capabilities: %v", err)
+	}
+	return unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0)
+}
+
+// cpuLimited indique si le processus a été tué par la limite de temps CPU de
+// seconds secondes : par SIGXCPU, ou par SIGKILL après avoir consommé ce temps.
+// La commande est le processus 1 de son espace de noms, qui ne reçoit pas SIGXCPU
+// sans le gérer : elle est tuée par SIGKILL à la limite stricte.
+func cpuLimited(exitErr *exec.ExitError, seconds int) bool {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return false
+	}
+	switch status.Signal() {
+	case syscall.SIGXCPU:
+		return true
+	case syscall.SIGKILL:
+		return exitErr.UserTime()+exitErr.SystemTime() >= time.Duration(seconds)*time.Second
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requireSandbox saute le test si le système ne permet pas de créer le sandbox
+func requireSandbox(t *testing.T) {
+	if err := (&Sandbox{}).Check(context.Background()); err != nil {
+		t.Skipf("Sandbox indisponible : %v", err)
+	}
+}
+
+// run exécute une commande shell dans le sandbox et retourne sa sortie
+func run(t *testing.T, s *Sandbox, dir, script string) (string, error) {
+	cmd, err := s.Command(context.Background(), dir, "sh", "-c", script)
+	require.NoError(t, err)
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err = cmd.Run()
+	return output.String(), s.Cause(err, output.String())
+}
+
+func TestSandboxFilesystem(t *testing.T) {
+	requireSandbox(t)
+
+	buildDir := t.TempDir()
+	cacheDir := t.TempDir()
+	private := filepath.Join(t.TempDir(), "data.db")
+	require.NoError(t, os.WriteFile(private, []byte("secret"), 0o600))
+
+	s := &Sandbox{Writable: []string{buildDir, cacheDir}}
+	output, err := run(t, s, buildDir, `
+		echo built > out.txt
+		echo cached > `+cacheDir+`/entry
+		test -e `+private+` && echo "private visible"
+		touch /usr/gip-sandbox-test 2>/dev/null && echo "/usr writable"
+		test -e /etc/shadow && echo "/etc/shadow visible"
+		pwd
+		echo "pid $$"
+	`)
+	require.NoError(t, err, output)
+
+	assert.NotContains(t, output, "visible")
+	assert.NotContains(t, output, "writable")
+	assert.Contains(t, output, buildDir)
+	// Le sandbox a son propre espace de processus
+	assert.Contains(t, output, "pid 1")
+
+	content, err := os.ReadFile(filepath.Join(buildDir, "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "built\n", string(content))
+	assert.FileExists(t, filepath.Join(cacheDir, "entry"))
+}
+
+func TestSandboxCannotRegainPrivileges(t *testing.T) {
+	requireSandbox(t)
+
+	buildDir := t.TempDir()
+	s := &Sandbox{Writable: []string{buildDir}}
+	output, err := run(t, s, buildDir, "grep -E '^(Cap|NoNewPrivs)' /proc/self/status")
+	require.NoError(t, err, output)
+	for _, set := range []string{"CapInh", "CapPrm", "CapEff", "CapBnd", "CapAmb"} {
+		assert.Contains(t, output, set+":\t0000000000000000")
+	}
+	assert.Contains(t, output, "NoNewPrivs:\t1")
+}
+
+func TestSandboxOffline(t *testing.T) {
+	requireSandbox(t)
+
+	buildDir := t.TempDir()
+	s := &Sandbox{Writable: []string{buildDir}, Offline: true}
+	output, err := run(t, s, buildDir, "cat /proc/net/dev")
+	require.NoError(t, err, output)
+	// Seule l'interface lo existe, et elle est active
+	assert.Len(t, strings.Split(strings.TrimSpace(output), "\n"), 3, output)
+	assert.Contains(t, output, "lo:")
+
+	// Le binaire de test, relancé dans le sandbox, écoute sur lo mais n'atteint pas le réseau
+	test, err := os.Executable()
+	require.NoError(t, err)
+	s.ReadOnly = []string{filepath.Dir(test)}
+	cmd, err := s.Command(context.Background(), buildDir, test, "-test.run=^TestNetworkHelper$", "-test.v")
+	require.NoError(t, err)
+	cmd.Env = []string{"GIP_SANDBOX_NETWORK_HELPER=1"}
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	assert.Contains(t, string(out), "network is unreachable")
+}
+
+// TestNetworkHelper est exécuté dans le sandbox par TestSandboxOffline
+func TestNetworkHelper(t *testing.T) {
+	if os.Getenv("GIP_SANDBOX_NETWORK_HELPER") != "1" {
+		t.Skip("Exécuté dans le sandbox par TestSandboxOffline")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+
+	_, err = net.DialTimeout("tcp", "192.0.2.1:443", time.Second)
+	require.Error(t, err)
+	t.Log(err)
+}
+
+func TestSandboxLimits(t *testing.T) {
+	requireSandbox(t)
+
+	buildDir := t.TempDir()
+	s := &Sandbox{
+		Policy:   Policy{CPUSeconds: 1, MemoryMB: 512, OpenFiles: 64, Processes: 100},
+		Writable: []string{buildDir},
+	}
+	output, err := run(t, s, buildDir, "ulimit -t; ulimit -d; ulimit -n; ulimit -p")
+	require.NoError(t, err, output)
+	assert.Equal(t, []string{"1", "524288", "64", "100"}, strings.Fields(output))
+
+	// Une boucle infinie est tuée au-delà de son temps CPU
+	_, err = run(t, s, buildDir, "while :; do :; done")
+	var limitErr *LimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, "sandbox CPU time limit exceeded (1s)", err.Error())
+	var exitErr *exec.ExitError
+	assert.ErrorAs(t, err, &exitErr)
+}
+
+func TestSandboxSetupError(t *testing.T) {
+	requireSandbox(t)
+
+	s := &Sandbox{Writable: []string{filepath.Join(t.TempDir(), "missing")}}
+	err := s.Check(context.Background())
+	var setupErr *SetupError
+	require.ErrorAs(t, err, &setupErr)
+	assert.Contains(t, err.Error(), "sandbox setup failed: ")
+	assert.Contains(t, err.Error(), "missing")
+
+	// Une commande introuvable n'est pas un échec du sandbox
+	buildDir := t.TempDir()
+	s = &Sandbox{Writable: []string{buildDir}}
+	cmd, err := s.Command(context.Background(), buildDir, "gip-missing-command")
+	require.NoError(t, err)
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 127, exitErr.ExitCode())
+	assert.NoError(t, s.Cause(nil, string(output)))
+	assert.Equal(t, err, s.Cause(err, string(output)))
+}
+
+func TestCause(t *testing.T) {
+	// Erreur d'une commande terminée avec le code 2
+	exitErr := exec.Command("sh", "-c", "exit 2").Run()
+	require.Error(t, exitErr)
+
+	s := &Sandbox{Policy: Policy{MemoryMB: 256}}
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{"fatal error: runtime: out of memory\n", "sandbox memory limit exceeded (256 MB)"},
+		{"open main.go: too many open files\n", "sandbox open files limit exceeded (4096)"},
+		{"fork/exec /usr/bin/git: resource temporarily unavailable\n", "sandbox processes limit exceeded (4096)"},
+		{"runtime: failed to create new OS thread (have 11 already; errno=11)\n", "sandbox processes limit exceeded (4096)"},
+		{"compile: signal: CPU time limit exceeded\n", "sandbox CPU time limit exceeded (600s)"},
+		// Un EAGAIN sans rapport avec la création d'un processus, ou un SIGKILL
+		// d'origine inconnue, ne sont pas attribués à une limite
+		{"read /dev/ptmx: resource temporarily unavailable\n", "exit status 2"},
+		{"compile: signal: killed\n", "exit status 2"},
+		{"./main.go:3:1: syntax error\n", "exit status 2"},
+	}
+	for _, tt := range tests {
+		assert.EqualError(t, s.Cause(exitErr, tt.output), tt.expected, tt.output)
+	}
+
+	// Une commande tuée par SIGKILL sans avoir consommé son temps CPU n'a pas atteint la limite
+	killed := exec.Command("sh", "-c", "kill -9 $$").Run()
+	require.Error(t, killed)
+	assert.Equal(t, killed, s.Cause(killed, ""))
+}
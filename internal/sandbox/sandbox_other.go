@@ -0,0 +1,28 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// errUnsupported est retourné sur les systèmes sans espaces de noms
+var errUnsupported = fmt.Errorf("sandbox is not supported on %s", runtime.GOOS)
+
+// Command retourne une erreur : le sandbox n'est disponible que sous Linux
+func (s *Sandbox) Command(ctx context.Context, dir, name string, args ...string) (*exec.Cmd, error) {
+	return nil, errUnsupported
+}
+
+// Check retourne une erreur : le sandbox n'est disponible que sous Linux
+func (s *Sandbox) Check(ctx context.Context) error {
+	return errUnsupported
+}
+
+// cpuLimited retourne false : sans sandbox, aucune limite n'est appliquée
+func cpuLimited(exitErr *exec.ExitError, seconds int) bool {
+	return false
+}
@@ -0,0 +1,130 @@
+// Package secrets chiffre les secrets des projets avant leur stockage dans la
+// base SQLite.
+//
+// Les valeurs sont chiffrées avec AES-256-GCM et la clé maître du serveur.
+// Chaque valeur est liée à son projet et à son nom (données authentifiées) :
+// une valeur copiée vers un autre secret ne peut pas être déchiffrée.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// KeySize est la taille de la clé maître en octets (AES-256)
+const KeySize = 32
+
+// Cipher chiffre et déchiffre les secrets avec la clé maître
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher crée un Cipher à partir d'une clé maître de KeySize octets
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid master key: expected %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// NewRandomCipher crée un Cipher avec une clé éphémère, pour les tests : les
+// secrets chiffrés ne peuvent plus être lus après l'arrêt du processus
+func NewRandomCipher() *Cipher {
+	c, err := NewCipher(GenerateKey())
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Encrypt chiffre une valeur pour le secret name du projet projectID.
+// Le nonce aléatoire précède le texte chiffré.
+func (c *Cipher) Encrypt(projectID int, name, value string) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, []byte(value), associatedData(projectID, name)), nil
+}
+
+// Decrypt déchiffre la valeur du secret name du projet projectID
+func (c *Cipher) Decrypt(projectID int, name string, sealed []byte) (string, error) {
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("invalid encrypted secret")
+	}
+	value, err := c.aead.Open(nil, sealed[:size], sealed[size:], associatedData(projectID, name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %s: wrong master key or corrupted value", name)
+	}
+	return string(value), nil
+}
+
+func associatedData(projectID int, name string) []byte {
+	return fmt.Appendf(nil, "project:%d/secret:%s", projectID, name)
+}
+
+// GenerateKey génère une clé maître aléatoire
+func GenerateKey() []byte {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// ParseKey décode une clé maître encodée en base64
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid master key: expected %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+// LoadOrCreateKey lit la clé maître encodée en base64 dans le fichier path, ou
+// la génère et l'y écrit (permissions 0600) si le fichier n'existe pas.
+// created indique si la clé vient d'être générée.
+func LoadOrCreateKey(path string) (key []byte, created bool, err error) {
+	content, err := os.ReadFile(path)
+	if err == nil {
+		key, err := ParseKey(string(content))
+		return key, false, err
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, err
+	}
+
+	key = GenerateKey()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, false, err
+	}
+	// O_EXCL : ne jamais écraser une clé créée entre temps
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+	if _, err := file.WriteString(base64.StdEncoding.EncodeToString(key) + "\n"); err != nil {
+		return nil, false, err
+	}
+	return key, true, file.Close()
+}
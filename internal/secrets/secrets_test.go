@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	c, err := NewCipher(GenerateKey())
+	require.NoError(t, err)
+
+	sealed, err := c.Encrypt(1, "NPM_TOKEN", "s3cr3t")
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "s3cr3t")
+
+	value, err := c.Decrypt(1, "NPM_TOKEN", sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", value)
+
+	// Deux chiffrements de la même valeur diffèrent (nonce aléatoire)
+	again, err := c.Encrypt(1, "NPM_TOKEN", "s3cr3t")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again)
+
+	// La valeur est liée à son projet et à son nom
+	_, err = c.Decrypt(2, "NPM_TOKEN", sealed)
+	assert.Error(t, err)
+	_, err = c.Decrypt(1, "OTHER", sealed)
+	assert.Error(t, err)
+
+	// Une autre clé ne peut pas la déchiffrer
+	_, err = NewRandomCipher().Decrypt(1, "NPM_TOKEN", sealed)
+	assert.EqualError(t, err, "failed to decrypt secret NPM_TOKEN: wrong master key or corrupted value")
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys", "master.key")
+
+	key, created, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.Len(t, key, KeySize)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	loaded, created, err := LoadOrCreateKey(path)
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, key, loaded)
+
+	require.NoError(t, os.WriteFile(path, []byte("dG9vIHNob3J0\n"), 0o600))
+	_, _, err = LoadOrCreateKey(path)
+	assert.EqualError(t, err, "invalid master key: expected 32 bytes, got 9")
+}
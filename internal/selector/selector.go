@@ -0,0 +1,186 @@
+// Package selector implémente les sélecteurs de labels utilisés pour choisir
+// les agents capables d'exécuter les builds d'un projet.
+//
+// Un sélecteur est une liste de conditions séparées par des virgules, toutes
+// obligatoires :
+//
+//	os=linux,arch in (amd64,arm64),gpu!=true
+//
+// Opérateurs supportés :
+//   - key=value (ou key==value) : le label doit exister et valoir value
+//   - key!=value : le label est absent ou a une autre valeur
+//   - key in (a,b) : le label doit exister et valoir l'une des valeurs
+//   - key notin (a,b) : le label est absent ou ne vaut aucune des valeurs
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Operator est l'opérateur d'une condition
+type Operator string
+
+const (
+	Equals    Operator = "="
+	NotEquals Operator = "!="
+	In        Operator = "in"
+	NotIn     Operator = "notin"
+)
+
+// Requirement est une condition portant sur un label
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Selector est un ensemble de conditions qui doivent toutes être satisfaites.
+// Un sélecteur vide accepte tous les agents.
+type Selector []Requirement
+
+var (
+	tokenPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+	setPattern   = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+// Parse analyse un sélecteur au format texte
+func Parse(s string) (Selector, error) {
+	var sel Selector
+
+	for _, term := range splitTerms(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+
+	return sel, nil
+}
+
+// splitTerms découpe le sélecteur sur les virgules qui ne sont pas dans un ensemble
+func splitTerms(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(terms, s[start:])
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	if m := setPattern.FindStringSubmatch(term); m != nil {
+		req := Requirement{Key: m[1], Operator: Operator(m[2])}
+		for _, value := range strings.Split(m[3], ",") {
+			req.Values = append(req.Values, strings.TrimSpace(value))
+		}
+		return req, req.validate(term)
+	}
+
+	var req Requirement
+	var key, value string
+	switch {
+	case strings.Contains(term, "!="):
+		key, value, _ = strings.Cut(term, "!=")
+		req.Operator = NotEquals
+	case strings.Contains(term, "=="):
+		key, value, _ = strings.Cut(term, "==")
+		req.Operator = Equals
+	case strings.Contains(term, "="):
+		key, value, _ = strings.Cut(term, "=")
+		req.Operator = Equals
+	default:
+		return Requirement{}, fmt.Errorf("invalid selector requirement %q: expected key=value, key!=value, key in (...) or key notin (...)", term)
+	}
+
+	req.Key = strings.TrimSpace(key)
+	req.Values = []string{strings.TrimSpace(value)}
+	return req, req.validate(term)
+}
+
+func (r Requirement) validate(term string) error {
+	if !tokenPattern.MatchString(r.Key) {
+		return fmt.Errorf("invalid label key in selector requirement %q", term)
+	}
+	for _, value := range r.Values {
+		if !tokenPattern.MatchString(value) {
+			return fmt.Errorf("invalid label value in selector requirement %q", term)
+		}
+	}
+	return nil
+}
+
+// Matches indique si les labels satisfont la condition
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+
+	switch r.Operator {
+	case Equals, In:
+		return ok && r.hasValue(value)
+	case NotEquals, NotIn:
+		return !ok || !r.hasValue(value)
+	}
+	return false
+}
+
+func (r Requirement) hasValue(value string) bool {
+	for _, v := range r.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// String retourne la condition au format texte
+func (r Requirement) String() string {
+	switch r.Operator {
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+	return r.Key + string(r.Operator) + strings.Join(r.Values, ",")
+}
+
+// Matches indique si les labels satisfont toutes les conditions du sélecteur
+func (s Selector) Matches(labels map[string]string) bool {
+	return len(s.Unsatisfied(labels)) == 0
+}
+
+// Unsatisfied retourne les conditions que les labels ne satisfont pas
+func (s Selector) Unsatisfied(labels map[string]string) []Requirement {
+	var unsatisfied []Requirement
+	for _, req := range s {
+		if !req.Matches(labels) {
+			unsatisfied = append(unsatisfied, req)
+		}
+	}
+	return unsatisfied
+}
+
+// String retourne le sélecteur sous sa forme normalisée
+func (s Selector) String() string {
+	terms := make([]string, len(s))
+	for i, req := range s {
+		terms[i] = req.String()
+	}
+	return strings.Join(terms, ",")
+}
@@ -0,0 +1,56 @@
+package selector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	sel, err := Parse("os=linux, arch in (amd64, arm64),gpu!=true,region notin (us-east)")
+	require.NoError(t, err)
+	require.Len(t, sel, 4)
+
+	assert.Equal(t, Requirement{Key: "os", Operator: Equals, Values: []string{"linux"}}, sel[0])
+	assert.Equal(t, Requirement{Key: "arch", Operator: In, Values: []string{"amd64", "arm64"}}, sel[1])
+	assert.Equal(t, Requirement{Key: "gpu", Operator: NotEquals, Values: []string{"true"}}, sel[2])
+	assert.Equal(t, Requirement{Key: "region", Operator: NotIn, Values: []string{"us-east"}}, sel[3])
+
+	assert.Equal(t, "os=linux,arch in (amd64,arm64),gpu!=true,region notin (us-east)", sel.String())
+}
+
+func TestParseEmpty(t *testing.T) {
+	sel, err := Parse("  ")
+	require.NoError(t, err)
+	assert.Empty(t, sel)
+	assert.True(t, sel.Matches(nil), "Un sélecteur vide accepte tous les agents")
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"linux", "=linux", "os=", "arch in ()", "arch in (amd64,)", "os=li nux", "arch between (a,b)"} {
+		_, err := Parse(s)
+		assert.Error(t, err, "Le sélecteur %q devrait être refusé", s)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	sel, err := Parse("os=linux,arch in (amd64,arm64),gpu!=true")
+	require.NoError(t, err)
+
+	assert.True(t, sel.Matches(map[string]string{"os": "linux", "arch": "arm64"}))
+	assert.True(t, sel.Matches(map[string]string{"os": "linux", "arch": "amd64", "gpu": "false"}))
+	assert.False(t, sel.Matches(map[string]string{"os": "linux", "arch": "arm64", "gpu": "true"}))
+	assert.False(t, sel.Matches(map[string]string{"os": "darwin", "arch": "arm64"}))
+	assert.False(t, sel.Matches(map[string]string{"os": "linux"}), "Un label absent ne satisfait pas l'égalité")
+}
+
+func TestUnsatisfied(t *testing.T) {
+	sel, err := Parse("os=linux,arch=arm64,gpu!=true")
+	require.NoError(t, err)
+
+	unsatisfied := sel.Unsatisfied(map[string]string{"os": "linux", "arch": "amd64", "gpu": "true"})
+	require.Len(t, unsatisfied, 2)
+	assert.Equal(t, "arch=arm64", unsatisfied[0].String())
+	assert.Equal(t, "gpu!=true", unsatisfied[1].String())
+}
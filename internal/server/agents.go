@@ -1,9 +1,11 @@
 package server
 
 import (
+	"crypto/subtle"
 	"database/sql"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"forgeronvirtuel/gip/internal/database"
@@ -14,6 +16,8 @@ import (
 
 type AgentHandler struct {
 	DB *sql.DB
+	// registrationToken, s'il est défini, doit être présenté pour enregistrer un agent
+	registrationToken string
 }
 
 type CreateAgentRequest struct {
@@ -21,6 +25,13 @@ type CreateAgentRequest struct {
 	Labels map[string]string `json:"labels"`
 }
 
+// AgentRegistrationResponse décrit l'agent enregistré et son jeton, qui
+// authentifie ses appels suivants. Le jeton n'est transmis qu'à l'enregistrement.
+type AgentRegistrationResponse struct {
+	*database.Agent
+	Token string `json:"token"`
+}
+
 type UpdateAgentStatusRequest struct {
 	Status string `json:"status" binding:"required,oneof=ONLINE OFFLINE DRAINING"`
 }
@@ -29,8 +40,15 @@ type UpdateAgentLabelsRequest struct {
 	Labels map[string]string `json:"labels" binding:"required"`
 }
 
-// CreateAgent crée un nouvel agent
+// CreateAgent crée un nouvel agent et lui délivre son jeton d'authentification.
+// Un agent enregistré avant l'authentification des agents, qui n'a donc pas de
+// jeton, en reçoit un à sa prochaine demande d'enregistrement.
 func (h *AgentHandler) CreateAgent(c *gin.Context) {
+	if h.registrationToken != "" && subtle.ConstantTimeCompare([]byte(bearerToken(c)), []byte(h.registrationToken)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid registration token"})
+		return
+	}
+
 	var req CreateAgentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
@@ -38,22 +56,41 @@ func (h *AgentHandler) CreateAgent(c *gin.Context) {
 	}
 
 	// Vérifier si un agent avec ce nom existe déjà
-	existingAgent, err := database.GetAgentByName(h.DB, req.Name)
-	if err == nil && existingAgent != nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Agent with this name already exists"})
-		return
+	status := http.StatusCreated
+	agent, err := database.GetAgentByName(h.DB, req.Name)
+	if err == nil && agent != nil {
+		hasToken, err := database.AgentHasToken(h.DB, agent.ID)
+		if err != nil {
+			log.Error().Err(err).Msg("Erreur lors de la lecture du jeton de l'agent")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
+			return
+		}
+		// Un agent enregistré avant l'authentification des agents ne peut être
+		// repris qu'avec le jeton d'enregistrement du serveur, vérifié plus haut
+		if hasToken || h.registrationToken == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Agent with this name already exists"})
+			return
+		}
+		status = http.StatusOK
+	} else {
+		// Créer l'agent
+		agent, err = database.CreateAgent(h.DB, req.Name, req.Labels)
+		if err != nil {
+			log.Error().Err(err).Msg("Erreur lors de la création de l'agent")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
+			return
+		}
 	}
 
-	// Créer l'agent
-	agent, err := database.CreateAgent(h.DB, req.Name, req.Labels)
+	token, err := database.IssueAgentToken(h.DB, agent.ID)
 	if err != nil {
-		log.Error().Err(err).Msg("Erreur lors de la création de l'agent")
+		log.Error().Err(err).Int("agent_id", agent.ID).Msg("Erreur lors de la création du jeton de l'agent")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create agent"})
 		return
 	}
 
-	log.Info().Int("agent_id", agent.ID).Str("name", agent.Name).Msg("Agent créé avec succès")
-	c.JSON(http.StatusCreated, agent)
+	log.Info().Int("agent_id", agent.ID).Str("name", agent.Name).Msg("Agent enregistré avec succès")
+	c.JSON(status, AgentRegistrationResponse{Agent: agent, Token: token})
 }
 
 // GetAgent récupère un agent par ID
@@ -235,17 +272,70 @@ func (h *AgentHandler) DeleteAgent(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Agent deleted successfully"})
 }
 
-// setupAgentRoutes configure les routes pour les agents
-func setupAgentRoutes(router gin.IRouter, db *sql.DB) {
-	handler := &AgentHandler{DB: db}
+// bearerToken retourne le jeton de l'en-tête Authorization: Bearer de la requête
+func bearerToken(c *gin.Context) string {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// requireAgentToken refuse les requêtes sur un agent (/:id) qui ne présentent pas
+// le jeton délivré à son enregistrement
+func requireAgentToken(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+			return
+		}
+
+		ok, err := database.AuthenticateAgent(db, id, bearerToken(c))
+		if err != nil {
+			log.Error().Err(err).Int("agent_id", id).Msg("Erreur lors de l'authentification de l'agent")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate agent"})
+			return
+		}
+		if !ok {
+			log.Warn().Int("agent_id", id).Str("path", c.FullPath()).Msg("Requête d'agent refusée : jeton invalide")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid agent token"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// requireAgentOrRegistrationToken refuse les requêtes de gestion d'un agent (/:id)
+// qui ne présentent ni le jeton de cet agent, ni le jeton d'enregistrement du
+// serveur s'il est défini
+func requireAgentOrRegistrationToken(db *sql.DB, registrationToken string) gin.HandlerFunc {
+	agentToken := requireAgentToken(db)
+	return func(c *gin.Context) {
+		if registrationToken != "" && subtle.ConstantTimeCompare([]byte(bearerToken(c)), []byte(registrationToken)) == 1 {
+			c.Next()
+			return
+		}
+		agentToken(c)
+	}
+}
+
+// setupAgentRoutes configure les routes pour les agents. Si registrationToken est
+// défini, seuls les agents qui le présentent peuvent s'enregistrer. Les labels
+// désignent les builds, et leurs secrets, confiés à un agent : seuls l'agent et
+// les détenteurs du jeton d'enregistrement peuvent les modifier ou le supprimer.
+func setupAgentRoutes(router gin.IRouter, db *sql.DB, registrationToken string) {
+	handler := &AgentHandler{DB: db, registrationToken: registrationToken}
+	manage := requireAgentOrRegistrationToken(db, registrationToken)
 	agents := router.Group("/api/agents")
 	{
 		agents.POST("/register", handler.CreateAgent) // Créer un agent
 		agents.GET("", handler.GetAllAgents)          // Lister tous les agents (avec filtre status optionnel)
 		agents.GET("/:id", handler.GetAgent)          // Récupérer un agent par ID
 		// agents.PUT("/:id/status", handler.UpdateAgentStatus) // Mettre à jour le statut
-		agents.PUT("/:id/labels", handler.UpdateAgentLabels) // Mettre à jour les labels
-		agents.POST("/:id/heartbeat", handler.Heartbeat)     // Heartbeat
-		agents.DELETE("/:id", handler.DeleteAgent)           // Supprimer un agent
+		agents.PUT("/:id/labels", manage, handler.UpdateAgentLabels)            // Mettre à jour les labels
+		agents.POST("/:id/heartbeat", requireAgentToken(db), handler.Heartbeat) // Heartbeat
+		agents.DELETE("/:id", manage, handler.DeleteAgent)                      // Supprimer un agent
 	}
 }
@@ -1,40 +1,46 @@
 package server
 
 import (
-	"bytes"
-	"context"
 	"database/sql"
 	"fmt"
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/coverage"
 	"forgeronvirtuel/gip/internal/database"
-	"io"
+	"forgeronvirtuel/gip/internal/platform"
 	"net/http"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-git/go-git/v6"
+	"github.com/rs/zerolog/log"
 )
 
+// streamKeepAlive est l'intervalle des commentaires envoyés pour garder ouverte
+// la connexion d'un flux de logs quand le build ne produit rien
+const streamKeepAlive = 15 * time.Second
+
 type BuildHandler struct {
 	DB        *sql.DB
 	workspace string
+	pool      *builder.Pool
+	logs      *builder.LogHub
 }
 
 type CreateBuildRequest struct {
-	ProjectID int `json:"project_id" binding:"required"`
+	ProjectID int    `json:"project_id" binding:"required"`
+	Ref       string `json:"ref"`    // Branche ou tag, branche du projet par défaut
+	Commit    string `json:"commit"` // SHA complet ou abrégé, prioritaire sur ref
 }
 
-func (h *BuildHandler) CreateBuild(c *gin.Context) {
-
-	logBuf := &bytes.Buffer{}
-	logWriter := io.MultiWriter(logBuf)
-
-	// Global timeout for the whole pipeline
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
+var (
+	refPattern    = regexp.MustCompile(`^[A-Za-z0-9._][A-Za-z0-9._/-]*$`)
+	commitPattern = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+)
 
+func (h *BuildHandler) CreateBuild(c *gin.Context) {
 	var req CreateBuildRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(400, gin.H{"error": "Invalid request payload"})
@@ -47,164 +53,343 @@ func (h *BuildHandler) CreateBuild(c *gin.Context) {
 		return
 	}
 
-	// Create build record in database with pending status
-	build, err := database.CreateBuild(h.DB, req.ProjectID, project.Branch)
+	ref := project.Branch
+	if req.Ref != "" {
+		if !refPattern.MatchString(req.Ref) || strings.Contains(req.Ref, "..") {
+			c.JSON(400, gin.H{"error": "Invalid ref"})
+			return
+		}
+		ref = req.Ref
+	}
+	if req.Commit != "" && !commitPattern.MatchString(req.Commit) {
+		c.JSON(400, gin.H{"error": "Invalid commit, expected a hexadecimal SHA"})
+		return
+	}
+
+	// Create build record in database with pending status: it is the queue
+	// from which the workers take their builds
+	build, err := database.CreateBuildAt(h.DB, req.ProjectID, ref, strings.ToLower(req.Commit))
 	if err != nil {
 		c.JSON(500, gin.H{"error": "Failed to create build record"})
 		return
 	}
 
-	// Update status to building
-	err = database.UpdateBuildStatus(h.DB, build.ID, "building", "")
+	if h.pool != nil {
+		h.pool.Notify()
+	}
+
+	c.JSON(http.StatusAccepted, h.buildResponse(build, builder.NewWaitingReasons(h.DB)))
+}
+
+// DownloadBinary permet de télécharger le binaire d'un build réussi pour le
+// binaire (?binary=server) et la plateforme (?platform=linux/arm64) demandés,
+// ou son premier artefact à défaut
+func (h *BuildHandler) DownloadBinary(c *gin.Context) {
+	buildID := c.Param("id")
+
+	// Récupérer le build depuis la DB
+	build, err := database.GetBuildByID(h.DB, buildID)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to update build status"})
+		c.JSON(404, gin.H{"error": "Build not found"})
 		return
 	}
 
-	// Clone the repository into the workspace directory
-	// Always use absolute path
-	absWorkspace, err := filepath.Abs(h.workspace)
+	// Vérifier que le build est en succès
+	if build.Status != "success" {
+		c.JSON(400, gin.H{"error": "Build is not successful, cannot download binary"})
+		return
+	}
+
+	artifacts, err := database.GetArtifactsByBuildID(h.DB, build.ID)
 	if err != nil {
-		database.UpdateBuildStatus(h.DB, build.ID, "failed", "Failed to get absolute workspace path")
-		c.JSON(500, gin.H{"error": "Failed to get absolute workspace path"})
+		c.JSON(500, gin.H{"error": "Failed to fetch artifacts"})
+		return
+	}
+	if len(artifacts) == 0 {
+		c.JSON(404, gin.H{"error": "Build has no artifact"})
+		return
+	}
+
+	binary := c.Query("binary")
+	query := c.Query("platform")
+	if binary == "" && query == "" {
+		serveArtifact(c, &artifacts[0])
 		return
 	}
 
-	repoPath := filepath.Join(absWorkspace, fmt.Sprintf("project-%d", project.ID))
-	err = os.RemoveAll(repoPath)
+	var target platform.Platform
+	if query != "" {
+		target, err = platform.Parse(query)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid platform", "details": err.Error()})
+			return
+		}
+	}
+	for _, artifact := range artifacts {
+		if (binary == "" || artifact.Binary == binary) && (query == "" || artifact.Platform() == target) {
+			serveArtifact(c, &artifact)
+			return
+		}
+	}
+
+	switch {
+	case query == "":
+		c.JSON(404, gin.H{"error": fmt.Sprintf("No artifact for binary %s", binary)})
+	case binary == "":
+		c.JSON(404, gin.H{"error": fmt.Sprintf("No artifact for platform %s", target)})
+	default:
+		c.JSON(404, gin.H{"error": fmt.Sprintf("No artifact for binary %s and platform %s", binary, target)})
+	}
+}
+
+// ListArtifacts liste les artefacts produits par un build
+func (h *BuildHandler) ListArtifacts(c *gin.Context) {
+	build, err := database.GetBuildByID(h.DB, c.Param("id"))
 	if err != nil {
-		database.UpdateBuildStatus(h.DB, build.ID, "failed", "Failed to clean workspace")
-		c.JSON(500, gin.H{"error": "Failed to clean workspace"})
+		c.JSON(404, gin.H{"error": "Build not found"})
 		return
 	}
 
-	_, err = git.PlainClone(repoPath, &git.CloneOptions{
-		URL: project.RepoURL,
-	})
+	artifacts, err := database.GetArtifactsByBuildID(h.DB, build.ID)
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to clone repository"})
+		c.JSON(500, gin.H{"error": "Failed to fetch artifacts"})
 		return
 	}
 
-	sourceDir := repoPath
-	if project.Subdir != "" {
-		sourceDir = filepath.Join(repoPath, project.Subdir)
+	response := []gin.H{}
+	for _, artifact := range artifacts {
+		response = append(response, artifactResponse(&artifact))
 	}
 
-	// Check if a file in "cmd/main.go" exists
-	mainGoPath := filepath.Join(sourceDir, "cmd", "main.go")
-	if _, err := os.Stat(mainGoPath); os.IsNotExist(err) {
-		c.JSON(400, gin.H{"error": "cmd/main.go not found in the repository"})
+	c.JSON(200, response)
+}
+
+// ListTests retourne les résultats des tests d'un build, par test et par
+// package, et leur résumé
+func (h *BuildHandler) ListTests(c *gin.Context) {
+	build, err := database.GetBuildByID(h.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Build not found"})
 		return
 	}
 
-	// Step 2 (optional but recommended): download Go modules
-	if err := runCmd(ctx, sourceDir, logWriter, "go", "mod", "download"); err != nil {
-		// Not fatal in all cases, but usually indicates a real problem
-		c.JSON(400, gin.H{"error": err.Error(), "logs": logBuf.String()})
+	results, err := database.GetTestResults(h.DB, build.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to fetch test results"})
 		return
 	}
 
-	// Step 3: build the binary
-	outDir := filepath.Join(repoPath, "out")
-	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		database.UpdateBuildStatus(h.DB, build.ID, "failed", logBuf.String())
-		c.JSON(400, gin.H{"error": err.Error(), "logs": logBuf.String()})
+	c.JSON(200, gin.H{
+		"summary": database.SummarizeTests(results),
+		"results": results,
+	})
+}
+
+// GetBuildCoverage retourne la couverture des tests d'un build : le total, puis
+// chaque package et chaque fichier
+func (h *BuildHandler) GetBuildCoverage(c *gin.Context) {
+	build, err := database.GetBuildByID(h.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Build not found"})
 		return
 	}
 
-	// Generate binary name from project name
-	binaryName := fmt.Sprintf("%s-%d", project.Name, build.ID)
-	binaryPath := filepath.Join(outDir, binaryName)
-	// binaryPath is already absolute since repoPath is absolute
+	report, err := database.GetBuildCoverage(h.DB, build.ID)
+	if err == sql.ErrNoRows {
+		c.JSON(404, gin.H{"error": "Build has no coverage"})
+		return
+	}
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to fetch coverage"})
+		return
+	}
 
-	buildArgs := []string{
-		"build",
-		"-o", binaryPath,
-		"./cmd/main.go",
+	packages := []gin.H{}
+	for _, entry := range report.Packages {
+		packages = append(packages, coverageEntryResponse(entry))
+	}
+	files := []gin.H{}
+	for _, entry := range report.Files {
+		files = append(files, coverageEntryResponse(entry))
 	}
 
-	if err := runCmd(ctx, sourceDir, logWriter, "go", buildArgs...); err != nil {
-		database.UpdateBuildStatus(h.DB, build.ID, "failed", logBuf.String())
-		c.JSON(400, gin.H{"error": err.Error(), "logs": logBuf.String()})
+	c.JSON(200, gin.H{
+		"build_id": build.ID,
+		"total":    coverageEntryResponse(report.Total),
+		"packages": packages,
+		"files":    files,
+	})
+}
+
+// coverageEntryResponse construit la représentation JSON de la couverture d'un
+// fichier, d'un package ou d'un build, avec son pourcentage
+func coverageEntryResponse(entry coverage.Entry) gin.H {
+	return gin.H{
+		"package":    entry.Package,
+		"file":       entry.File,
+		"statements": entry.Statements,
+		"covered":    entry.Covered,
+		"percent":    entry.Percent(),
+	}
+}
+
+// DownloadArtifact permet de télécharger un artefact d'un build
+func (h *BuildHandler) DownloadArtifact(c *gin.Context) {
+	build, err := database.GetBuildByID(h.DB, c.Param("id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Build not found"})
 		return
 	}
 
-	// Update build status to success with binary path stored in log_output
-	err = database.UpdateBuildStatus(h.DB, build.ID, "success", fmt.Sprintf("Binary: %s\n\n%s", binaryPath, logBuf.String()))
+	artifactID, err := strconv.Atoi(c.Param("artifact_id"))
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Build succeeded but failed to update status"})
+		c.JSON(400, gin.H{"error": "Invalid artifact ID"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"build_id":     build.ID,
-		"status":       "success",
-		"binary_path":  binaryPath,
-		"download_url": fmt.Sprintf("/api/builds/%d/download", build.ID),
-	})
+	artifact, err := database.GetArtifactByID(h.DB, build.ID, artifactID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Artifact not found"})
+		return
+	}
+
+	serveArtifact(c, artifact)
 }
 
-// DownloadBinary permet de télécharger le binaire généré par un build
-func (h *BuildHandler) DownloadBinary(c *gin.Context) {
+// serveArtifact envoie le fichier d'un artefact en pièce jointe
+func serveArtifact(c *gin.Context, artifact *database.Artifact) {
+	// Vérifier que le fichier existe
+	if _, err := os.Stat(artifact.Path); os.IsNotExist(err) {
+		c.JSON(404, gin.H{"error": "Binary file not found on disk"})
+		return
+	}
+
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", artifact.Name))
+	c.Header("Content-Type", artifact.ContentType)
+	c.File(artifact.Path)
+}
+
+// artifactResponse construit la représentation JSON d'un artefact. Le chemin sur
+// le disque du serveur n'est pas exposé : le fichier se télécharge via download_url.
+func artifactResponse(artifact *database.Artifact) gin.H {
+	return gin.H{
+		"id":           artifact.ID,
+		"build_id":     artifact.BuildID,
+		"name":         artifact.Name,
+		"binary":       artifact.Binary,
+		"size":         artifact.Size,
+		"sha256":       artifact.SHA256,
+		"os":           artifact.OS,
+		"arch":         artifact.Arch,
+		"variant":      artifact.Variant,
+		"platform":     artifact.Platform().String(),
+		"content_type": artifact.ContentType,
+		"created_at":   artifact.CreatedAt,
+		"download_url": fmt.Sprintf("/v1/api/builds/%d/artifacts/%d/download", artifact.BuildID, artifact.ID),
+	}
+}
+
+// GetBuild récupère les détails d'un build spécifique
+func (h *BuildHandler) GetBuild(c *gin.Context) {
 	buildID := c.Param("id")
 
-	// Récupérer le build depuis la DB
 	build, err := database.GetBuildByID(h.DB, buildID)
 	if err != nil {
 		c.JSON(404, gin.H{"error": "Build not found"})
 		return
 	}
 
-	// Vérifier que le build est en succès
-	if build.Status != "success" {
-		c.JSON(400, gin.H{"error": "Build is not successful, cannot download binary"})
+	steps, err := database.GetBuildSteps(h.DB, build.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to fetch build steps"})
 		return
 	}
 
-	// Extraire le chemin du binaire depuis log_output (première ligne)
-	var binaryPath string
+	targets, err := database.GetBuildTargets(h.DB, build.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to fetch build targets"})
+		return
+	}
 
-	if len(build.LogOutput) > 8 && build.LogOutput[:8] == "Binary: " {
-		// Extraire le chemin (jusqu'au premier \n)
-		for i := 8; i < len(build.LogOutput); i++ {
-			if build.LogOutput[i] == '\n' {
-				binaryPath = build.LogOutput[8:i]
-				break
-			}
+	submodules, err := database.GetBuildSubmodules(h.DB, build.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to fetch build submodules"})
+		return
+	}
+
+	response := h.buildResponse(build, builder.NewWaitingReasons(h.DB))
+	response["log_output"] = build.LogOutput
+	response["steps"] = steps
+	response["targets"] = targets
+	response["submodules"] = submodules
+
+	// Build de vérification et comparaison de chaque artefact
+	if reproducibility, ok := response["reproducibility"].(gin.H); ok {
+		rebuildID, err := database.GetRebuildID(h.DB, build.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch rebuild"})
+			return
 		}
+		artifacts, err := database.GetReproducibilityArtifacts(h.DB, build.ID)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to fetch reproducibility check"})
+			return
+		}
+		reproducibility["rebuild_id"] = rebuildID
+		reproducibility["artifacts"] = artifacts
 	}
+	c.JSON(200, response)
+}
+
+// CancelBuild annule un build en attente ou en cours
+func (h *BuildHandler) CancelBuild(c *gin.Context) {
+	buildID := c.Param("id")
 
-	if binaryPath == "" {
-		c.JSON(500, gin.H{"error": "Binary path not found in build logs"})
+	build, err := database.GetBuildByID(h.DB, buildID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "Build not found"})
 		return
 	}
 
-	// Vérifier que le fichier existe
-	if _, err := os.Stat(binaryPath); os.IsNotExist(err) {
-		c.JSON(404, gin.H{"error": "Binary file not found on disk"})
+	cancelled, err := database.CancelBuild(h.DB, build.ID)
+	if err != nil {
+		log.Error().Err(err).Int("build_id", build.ID).Msg("Erreur lors de l'annulation du build")
+		c.JSON(500, gin.H{"error": "Failed to cancel build"})
+		return
+	}
+	if !cancelled {
+		c.JSON(409, gin.H{"error": "Build is already finished"})
 		return
 	}
 
-	// Récupérer le projet pour obtenir le nom
-	project, err := database.GetProjectByID(h.DB, build.ProjectID)
+	// Un worker local tue les processus du build puis termine le flux de logs.
+	// Un agent apprend l'annulation au prochain envoi de logs et arrête les siens.
+	if h.pool == nil || !h.pool.Cancel(build.ID) {
+		h.logs.Close(build.ID, "cancelled", builder.ErrBuildCancelled.Error())
+	}
+
+	build, err = database.GetBuildByID(h.DB, buildID)
 	if err != nil {
-		// Fallback si on ne peut pas récupérer le projet
-		c.File(binaryPath)
+		c.JSON(500, gin.H{"error": "Failed to fetch build"})
 		return
 	}
 
-	// Définir le nom du fichier pour le téléchargement
-	filename := fmt.Sprintf("%s-%d", project.Name, build.ID)
-	c.Header("Content-Description", "File Transfer")
-	c.Header("Content-Transfer-Encoding", "binary")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	c.Header("Content-Type", "application/octet-stream")
-	c.File(binaryPath)
+	// Un build de vérification annulé fait échouer la vérification
+	if build.RebuildOf.Valid {
+		if err := builder.CheckReproducibility(h.DB, build); err != nil {
+			log.Error().Err(err).Int("build_id", build.ID).Msg("Erreur lors de la vérification de la reproductibilité du build")
+		}
+	}
+
+	c.JSON(200, h.buildResponse(build, builder.NewWaitingReasons(h.DB)))
 }
 
-// GetBuild récupère les détails d'un build spécifique
-func (h *BuildHandler) GetBuild(c *gin.Context) {
+// StreamLogs diffuse la sortie d'un build en Server-Sent Events : un événement
+// "log" par ligne, déjà produite ou à venir, puis un événement "end" portant le
+// statut final du build.
+func (h *BuildHandler) StreamLogs(c *gin.Context) {
 	buildID := c.Param("id")
 
 	build, err := database.GetBuildByID(h.DB, buildID)
@@ -213,16 +398,69 @@ func (h *BuildHandler) GetBuild(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"id":         build.ID,
-		"project_id": build.ProjectID,
-		"branch":     build.Branch,
-		"status":     build.Status,
-		"log_output": build.LogOutput,
-		"started_at": build.StartedAt,
-		"ended_at":   build.EndedAt,
-		"created_at": build.CreatedAt,
-	})
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	if database.IsTerminalBuildStatus(build.Status) {
+		streamFinishedBuild(c, build)
+		return
+	}
+
+	history, events, unsubscribe := h.logs.Subscribe(build.ID)
+	defer unsubscribe()
+
+	// Le build a pu se terminer entre sa lecture et l'abonnement
+	if build, err = database.GetBuildByID(h.DB, buildID); err == nil && database.IsTerminalBuildStatus(build.Status) {
+		streamFinishedBuild(c, build)
+		return
+	}
+
+	for _, line := range history {
+		c.SSEvent("log", gin.H{"line": line})
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				// Abonné déconnecté sans événement final : le client se reconnectera
+				// sauf si le build est terminé entre temps
+				if build, err := database.GetBuildByID(h.DB, buildID); err == nil && database.IsTerminalBuildStatus(build.Status) {
+					c.SSEvent("end", gin.H{"status": build.Status, "error": build.Error})
+				}
+				return
+			}
+			if event.Done {
+				c.SSEvent("end", gin.H{"status": event.Status, "error": event.Error})
+				c.Writer.Flush()
+				return
+			}
+			c.SSEvent("log", gin.H{"line": event.Line})
+			c.Writer.Flush()
+		}
+	}
+}
+
+// streamFinishedBuild envoie les logs enregistrés d'un build terminé puis l'événement final
+func streamFinishedBuild(c *gin.Context, build *database.Build) {
+	if output := strings.TrimSuffix(build.LogOutput, "\n"); output != "" {
+		for _, line := range strings.Split(output, "\n") {
+			c.SSEvent("log", gin.H{"line": line})
+		}
+	}
+	c.SSEvent("end", gin.H{"status": build.Status, "error": build.Error})
+	c.Writer.Flush()
 }
 
 // GetBuildsByProject récupère tous les builds d'un projet
@@ -244,63 +482,93 @@ func (h *BuildHandler) GetBuildsByProject(c *gin.Context) {
 
 	// Convertir les builds en réponse JSON
 	var response []gin.H
+	reasons := builder.NewWaitingReasons(h.DB)
 	for _, build := range builds {
-		response = append(response, gin.H{
-			"id":         build.ID,
-			"project_id": build.ProjectID,
-			"branch":     build.Branch,
-			"status":     build.Status,
-			"started_at": build.StartedAt,
-			"ended_at":   build.EndedAt,
-			"created_at": build.CreatedAt,
-		})
+		response = append(response, h.buildResponse(&build, reasons))
 	}
 
 	c.JSON(200, response)
 }
 
-func setupBuildRoutes(router *gin.RouterGroup, db *sql.DB, workspace string) {
-	handler := BuildHandler{DB: db, workspace: workspace}
-	builds := router.Group("/api/builds")
-	{
-		builds.POST("/", handler.CreateBuild)
-		builds.GET("/:id", handler.GetBuild)
-		builds.GET("/:id/download", handler.DownloadBinary)
-		builds.GET("/project/:project_id", handler.GetBuildsByProject)
+// buildResponse construit la représentation JSON commune d'un build.
+// Un build en attente qu'aucun agent ne peut prendre indique pourquoi dans waiting_reason.
+// Les builds d'une même réponse partagent reasons, qui ne charge qu'une fois les agents.
+func (h *BuildHandler) buildResponse(build *database.Build, reasons *builder.WaitingReasons) gin.H {
+	// null pour les builds exécutés par le control plane
+	var agentID any
+	if build.AgentID.Valid {
+		agentID = build.AgentID.Int64
+	}
+
+	// null tant que le commit n'est pas extrait
+	var commit any
+	if build.CommitSHA != "" {
+		commit = gin.H{
+			"sha":     build.CommitSHA,
+			"author":  build.CommitAuthor,
+			"date":    build.CommitDate.Time,
+			"message": build.CommitMessage,
+		}
 	}
-}
 
-// runCmd runs a command with a controlled environment and logs its output.
-// It avoids using any shell ("bash -c") to prevent injection issues.
-func runCmd(ctx context.Context, workDir string, log io.Writer, name string, args ...string) error {
-	fmt.Fprintf(log, "==> Running: %s %v (in %s)\n", name, args, workDir)
+	// null pour un build ordinaire
+	var rebuildOf any
+	if build.RebuildOf.Valid {
+		rebuildOf = build.RebuildOf.Int64
+	}
 
-	// Convert to absolute path to ensure GOCACHE and GOMODCACHE are absolute
-	absWorkDir, err := filepath.Abs(workDir)
-	if err != nil {
-		return err
+	// null si la reproductibilité du build n'est pas vérifiée
+	var reproducibility any
+	if build.Reproducibility != "" {
+		reproducibility = gin.H{
+			"status": build.Reproducibility,
+			"error":  build.ReproducibilityError,
+		}
 	}
 
-	cmd := exec.CommandContext(ctx, name, args...)
-	cmd.Dir = absWorkDir
+	response := gin.H{
+		"id":               build.ID,
+		"project_id":       build.ProjectID,
+		"branch":           build.Branch,
+		"requested_commit": build.RequestedCommit,
+		"commit":           commit,
+		"version":          build.Version,
+		"go_version":       build.GoVersion,
+		"hermetic":         build.Hermetic,
+		"rebuild_of":       rebuildOf,
+		"reproducibility":  reproducibility,
+		"status":           build.Status,
+		"error":            build.Error,
+		"agent_id":         agentID,
+		"started_at":       build.StartedAt,
+		"ended_at":         build.EndedAt,
+		"created_at":       build.CreatedAt,
+	}
 
-	// Minimal, controlled environment:
-	// - PATH is kept from parent (to find git/go)
-	// - HOME is set to workDir (avoid polluting real home)
-	// - No proxy, no extra env from the parent process.
-	env := []string{
-		"PATH=" + os.Getenv("PATH"),
-		"HOME=" + absWorkDir,
-		"GOMODCACHE=" + filepath.Join(absWorkDir, ".gomodcache"),
-		"GOCACHE=" + filepath.Join(absWorkDir, ".gocache"),
+	reason, err := reasons.Reason(build)
+	if err != nil {
+		log.Error().Err(err).Int("build_id", build.ID).Msg("Erreur lors du calcul de la raison d'attente du build")
+	}
+	if reason != "" {
+		response["waiting_reason"] = reason
 	}
-	cmd.Env = env
 
-	cmd.Stdout = log
-	cmd.Stderr = log
+	return response
+}
 
-	if err := cmd.Run(); err != nil {
-		return err
+func setupBuildRoutes(router *gin.RouterGroup, db *sql.DB, workspace string, pool *builder.Pool, logs *builder.LogHub) {
+	handler := BuildHandler{DB: db, workspace: workspace, pool: pool, logs: logs}
+	builds := router.Group("/api/builds")
+	{
+		builds.POST("/", handler.CreateBuild)
+		builds.GET("/:id", handler.GetBuild)
+		builds.GET("/:id/download", handler.DownloadBinary)
+		builds.GET("/:id/artifacts", handler.ListArtifacts)
+		builds.GET("/:id/artifacts/:artifact_id/download", handler.DownloadArtifact)
+		builds.GET("/:id/tests", handler.ListTests)
+		builds.GET("/:id/coverage", handler.GetBuildCoverage)
+		builds.GET("/:id/logs/stream", handler.StreamLogs)
+		builds.POST("/:id/cancel", handler.CancelBuild)
+		builds.GET("/project/:project_id", handler.GetBuildsByProject)
 	}
-	return nil
 }
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/platform"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent est un événement lu dans un flux Server-Sent Events
+type sseEvent struct {
+	Name string
+	Data map[string]string
+}
+
+// nextSSE lit le prochain événement d'un flux. Retourne false à la fin du flux.
+func nextSSE(t *testing.T, body *bufio.Reader) (sseEvent, bool) {
+	var event sseEvent
+
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			return event, false
+		}
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event.Name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &event.Data))
+		case line == "" && event.Name != "":
+			return event, true
+		}
+	}
+}
+
+// readSSE lit les événements d'un flux jusqu'à sa fermeture
+func readSSE(t *testing.T, body *bufio.Reader) []sseEvent {
+	var events []sseEvent
+	for {
+		event, ok := nextSSE(t, body)
+		if !ok {
+			return events
+		}
+		events = append(events, event)
+	}
+}
+
+func TestStreamLogsFinishedBuild(t *testing.T) {
+	db, router, _, build := setupRunnerTest(t)
+	require.NoError(t, database.FinishBuild(db, build.ID, "failed", "==> Cloning\nfatal: not found\n", "Failed to clone repository"))
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/logs/stream", baseUrl, build.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"))
+	assert.Equal(t, []sseEvent{
+		{Name: "log", Data: map[string]string{"line": "==> Cloning"}},
+		{Name: "log", Data: map[string]string{"line": "fatal: not found"}},
+		{Name: "end", Data: map[string]string{"status": "failed", "error": "Failed to clone repository"}},
+	}, readSSE(t, bufio.NewReader(w.Body)))
+}
+
+func TestStreamLogsLiveAgentBuild(t *testing.T) {
+	_, router, agent, build := setupRunnerTest(t)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	logsPath := fmt.Sprintf("/api/agents/%d/builds/%d/logs", agent.ID, build.ID)
+	w = postAgent(router, agent, logsPath, "text/plain", bytes.NewBufferString("==> Cloning\n==> Running: go"))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	resp, err := http.Get(fmt.Sprintf("%s%s/api/builds/%d/logs/stream", server.URL, baseUrl, build.ID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body := bufio.NewReader(resp.Body)
+
+	// L'historique est envoyé dès l'abonnement
+	event, ok := nextSSE(t, body)
+	require.True(t, ok)
+	assert.Equal(t, sseEvent{Name: "log", Data: map[string]string{"line": "==> Cloning"}}, event)
+
+	// La suite est diffusée au fil de l'eau, puis le statut final
+	w = postAgent(router, agent, logsPath, "text/plain", bytes.NewBufferString(" build\n"))
+	require.Equal(t, http.StatusOK, w.Code)
+	payload, _ := json.Marshal(CompleteBuildRequest{Error: "exit status 1"})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	assert.Equal(t, []sseEvent{
+		{Name: "log", Data: map[string]string{"line": "==> Running: go build"}},
+		{Name: "end", Data: map[string]string{"status": "failed", "error": "exit status 1"}},
+	}, readSSE(t, body))
+}
+
+func TestCancelPendingBuild(t *testing.T) {
+	_, router, _, build := setupRunnerTest(t)
+	cancelPath := fmt.Sprintf("/api/builds/%d/cancel", build.ID)
+
+	w := postRunner(router, cancelPath, "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "cancelled", response["status"])
+	assert.Equal(t, "Build cancelled", response["error"])
+	assert.Equal(t, true, response["ended_at"].(map[string]interface{})["Valid"])
+
+	// Déjà terminé
+	w = postRunner(router, cancelPath, "application/json", &bytes.Buffer{})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = postRunner(router, "/api/builds/999/cancel", "application/json", &bytes.Buffer{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCancelAgentBuild(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = postRunner(router, fmt.Sprintf("/api/builds/%d/cancel", build.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// L'agent apprend l'annulation au prochain envoi de logs
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/logs", agent.ID, build.ID), "text/plain", bytes.NewBufferString("signal: killed\n"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	payload, _ := json.Marshal(CompleteBuildRequest{Error: "signal: killed"})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	stored, err := database.GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", stored.Status)
+
+	// Le flux de logs se termine avec le statut final
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/logs/stream", baseUrl, build.ID), nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, []sseEvent{
+		{Name: "end", Data: map[string]string{"status": "cancelled", "error": "Build cancelled"}},
+	}, readSSE(t, bufio.NewReader(rec.Body)))
+}
+
+func TestListAndDownloadArtifacts(t *testing.T) {
+	db, router, _, build := setupRunnerTest(t)
+
+	dir := t.TempDir()
+	var artifacts []database.Artifact
+	for _, target := range []struct {
+		name     string
+		binary   string
+		platform platform.Platform
+	}{
+		{"api-linux-amd64", "api", platform.Platform{OS: "linux", Arch: "amd64"}},
+		{"api-windows-amd64.exe", "api", platform.Platform{OS: "windows", Arch: "amd64"}},
+		{"api-linux-arm-v7", "api", platform.Platform{OS: "linux", Arch: "arm", Variant: "v7"}},
+		{"worker-linux-arm-v7", "worker", platform.Platform{OS: "linux", Arch: "arm", Variant: "v7"}},
+	} {
+		path := filepath.Join(dir, target.name)
+		require.NoError(t, os.WriteFile(path, []byte(target.name), 0o755))
+		artifact, err := database.NewArtifactFromFile(path, target.binary, target.platform)
+		require.NoError(t, err)
+		artifacts = append(artifacts, *artifact)
+	}
+	require.NoError(t, database.FinishSuccessfulBuild(db, build.ID, "==> Running: go build\n", artifacts))
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/artifacts", baseUrl, build.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response, 4)
+	assert.Equal(t, "api-windows-amd64.exe", response[1]["name"])
+	assert.Equal(t, "api", response[1]["binary"])
+	assert.Equal(t, "windows/amd64", response[1]["platform"])
+	assert.Equal(t, "windows", response[1]["os"])
+	assert.Equal(t, float64(len("api-windows-amd64.exe")), response[1]["size"])
+	assert.Len(t, response[1]["sha256"], 64)
+	assert.NotContains(t, response[1], "path", "Le chemin sur le serveur ne doit pas être exposé")
+
+	req, _ = http.NewRequest("GET", response[1]["download_url"].(string), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "api-windows-amd64.exe", w.Body.String())
+	assert.Equal(t, "attachment; filename=api-windows-amd64.exe", w.Header().Get("Content-Disposition"))
+
+	// L'ancien endpoint télécharge le premier artefact
+	req, _ = http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/download", baseUrl, build.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "api-linux-amd64", w.Body.String())
+
+	// Choix du binaire et de la plateforme
+	for query, expected := range map[string]int{
+		"?platform=linux/arm/v7":                http.StatusOK,
+		"?platform=darwin/arm64":                http.StatusNotFound,
+		"?platform=linux/arm/v9":                http.StatusBadRequest,
+		"?platform=windows/amd64":               http.StatusOK,
+		"?binary=worker":                        http.StatusOK,
+		"?binary=migrate":                       http.StatusNotFound,
+		"?binary=worker&platform=linux/arm/v7":  http.StatusOK,
+		"?binary=worker&platform=windows/amd64": http.StatusNotFound,
+	} {
+		req, _ = http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/download%s", baseUrl, build.ID, query), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Code, query)
+	}
+	for query, expected := range map[string]string{
+		"?platform=linux/arm/v7":               "api-linux-arm-v7",
+		"?binary=worker&platform=linux/arm/v7": "worker-linux-arm-v7",
+	} {
+		req, _ = http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/download%s", baseUrl, build.ID, query), nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Body.String(), query)
+	}
+
+	// Un artefact d'un autre build n'est pas accessible
+	req, _ = http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/artifacts/%d/download", baseUrl, build.ID+1, artifacts[0].ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateBuildRevision(t *testing.T) {
+	db, router, _, build := setupRunnerTest(t)
+
+	create := func(payload CreateBuildRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		return postRunner(router, "/api/builds/", "application/json", bytes.NewBuffer(body))
+	}
+
+	w := create(CreateBuildRequest{ProjectID: build.ProjectID, Ref: "v1.2.0", Commit: "0A1B2C3D"})
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "v1.2.0", response["branch"])
+	assert.Equal(t, "0a1b2c3d", response["requested_commit"])
+	assert.Nil(t, response["commit"], "Le commit n'est connu qu'après l'extraction")
+
+	// Sans ref, la branche du projet est utilisée
+	w = create(CreateBuildRequest{ProjectID: build.ProjectID})
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "main", response["branch"])
+
+	for _, invalid := range []CreateBuildRequest{
+		{ProjectID: build.ProjectID, Ref: "-x"},
+		{ProjectID: build.ProjectID, Ref: "main..dev"},
+		{ProjectID: build.ProjectID, Ref: "feature branch"},
+		{ProjectID: build.ProjectID, Commit: "HEAD~1"},
+		{ProjectID: build.ProjectID, Commit: "abc"},
+	} {
+		assert.Equal(t, http.StatusBadRequest, create(invalid).Code, "%+v", invalid)
+	}
+
+	// Le commit compilé est exposé une fois enregistré
+	date := time.Date(2025, 11, 8, 10, 30, 0, 0, time.UTC)
+	require.NoError(t, database.SaveBuildCommit(db, build.ID, &database.CommitInfo{
+		SHA: "4f2a9c0e8d7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f", Author: "Ada <ada@example.com>", Date: date, Message: "Fix login",
+	}, "v1.4.0-2-g4f2a9c0"))
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d", baseUrl, build.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]interface{}{
+		"sha":     "4f2a9c0e8d7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f",
+		"author":  "Ada <ada@example.com>",
+		"date":    "2025-11-08T10:30:00Z",
+		"message": "Fix login",
+	}, response["commit"])
+	assert.Equal(t, "v1.4.0-2-g4f2a9c0", response["version"])
+	assert.Equal(t, false, response["hermetic"])
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"forgeronvirtuel/gip/internal/gocache"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// CacheHandler administre le cache Go partagé par les builds
+type CacheHandler struct {
+	cache *gocache.Cache
+}
+
+// enabled répond 404 et retourne false si le serveur n'a pas de cache partagé
+func (h *CacheHandler) enabled(c *gin.Context) bool {
+	if h.cache == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "shared Go cache is disabled"})
+		return false
+	}
+	return true
+}
+
+// GetCache retourne l'occupation des caches des modules et de compilation
+func (h *CacheHandler) GetCache(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	stats, err := h.cache.Stats()
+	if err != nil {
+		log.Error().Err(err).Str("cache", h.cache.Dir()).Msg("Erreur lors de la lecture du cache Go")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to inspect cache"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// TrimCache supprime les entrées les plus anciennes des caches qui dépassent leur taille maximale
+func (h *CacheHandler) TrimCache(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	report, err := h.cache.Trim()
+	if err != nil {
+		h.cacheError(c, err, "unable to trim cache")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// PurgeCache vide le cache demandé (?cache=mod ou build), ou les deux
+func (h *CacheHandler) PurgeCache(c *gin.Context) {
+	if !h.enabled(c) {
+		return
+	}
+
+	var kinds []gocache.Kind
+	if name := c.Query("cache"); name != "" {
+		kind, err := gocache.ParseKind(name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid cache",
+				"details": err.Error(),
+			})
+			return
+		}
+		kinds = append(kinds, kind)
+	}
+
+	freed, err := h.cache.Purge(kinds...)
+	if err != nil {
+		h.cacheError(c, err, "unable to purge cache")
+		return
+	}
+
+	log.Info().Str("cache", h.cache.Dir()).Int64("freed", freed).Msg("Cache Go purgé par l'API")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "cache purged successfully",
+		"freed":   freed,
+	})
+}
+
+// cacheError répond 409 si des builds utilisent le cache, 500 sinon
+func (h *CacheHandler) cacheError(c *gin.Context, err error, message string) {
+	if errors.Is(err, gocache.ErrInUse) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	log.Error().Err(err).Str("cache", h.cache.Dir()).Msg("Erreur lors du nettoyage du cache Go")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// setupCacheRoutes configure les routes d'administration du cache Go partagé
+func setupCacheRoutes(router *gin.RouterGroup, cache *gocache.Cache) {
+	handler := &CacheHandler{cache: cache}
+	admin := router.Group("/api/admin/cache")
+	{
+		admin.GET("", handler.GetCache)        // Occupation des caches
+		admin.POST("/trim", handler.TrimCache) // Appliquer les tailles maximales
+		admin.DELETE("", handler.PurgeCache)   // Vider un cache ou les deux
+	}
+}
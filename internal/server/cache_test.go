@@ -0,0 +1,59 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/gocache"
+	"forgeronvirtuel/gip/internal/secrets"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheEndpoints(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+
+	// Sans cache partagé
+	w := requestJSON(SetupRouter(db, ""), "GET", "/api/admin/cache", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	cache, err := gocache.New(t.TempDir(), gocache.Limits{ModBytes: 1 << 30})
+	require.NoError(t, err)
+	entry := filepath.Join(cache.Dir(), "build", "ab", "ab01-a")
+	require.NoError(t, os.MkdirAll(filepath.Dir(entry), 0o755))
+	require.NoError(t, os.WriteFile(entry, []byte("compiled"), 0o644))
+	router := setupRouter(db, t.TempDir(), cache, nil, builder.NewLogHub(), secrets.NewRandomCipher(), "")
+
+	w = requestJSON(router, "GET", "/api/admin/cache", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats gocache.Stats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, int64(8), stats.Build.Size)
+	assert.Equal(t, 1, stats.Build.Entries)
+	assert.Equal(t, int64(1<<30), stats.Mod.Limit)
+
+	// Un build en cours empêche la purge
+	release := cache.Acquire()
+	w = requestJSON(router, "DELETE", "/api/admin/cache", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "cache is in use by running builds")
+	w = requestJSON(router, "POST", "/api/admin/cache/trim", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	release()
+
+	w = requestJSON(router, "DELETE", "/api/admin/cache?cache=everything", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = requestJSON(router, "DELETE", "/api/admin/cache?cache=build", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"freed":8`)
+	assert.NoFileExists(t, entry)
+}
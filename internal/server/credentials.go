@@ -0,0 +1,130 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/gitauth"
+	"forgeronvirtuel/gip/internal/secrets"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// CredentialHandler gère les identifiants d'accès aux dépôts privés des projets.
+// Le secret est chiffré avec la clé maître du serveur et n'est jamais renvoyé par l'API.
+type CredentialHandler struct {
+	DB     *sql.DB
+	cipher *secrets.Cipher
+}
+
+type SetCredentialsRequest struct {
+	Kind       gitauth.Kind `json:"kind" binding:"required"`
+	Username   string       `json:"username"`
+	Secret     string       `json:"secret"`
+	KnownHosts string       `json:"known_hosts"`
+	GoPrivate  string       `json:"goprivate"`
+}
+
+// GetCredentials retourne les identifiants d'un projet, sans leur secret
+func (h *CredentialHandler) GetCredentials(c *gin.Context) {
+	project, ok := routeProject(c, h.DB)
+	if !ok {
+		return
+	}
+
+	creds, err := database.GetProjectCredentials(h.DB, project.ID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "credentials not found"})
+			return
+		}
+		log.Error().Err(err).Int("project_id", project.ID).Msg("Erreur lors de la récupération des identifiants")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to fetch credentials"})
+		return
+	}
+
+	c.JSON(http.StatusOK, creds)
+}
+
+// SetCredentials crée ou remplace les identifiants d'un projet
+func (h *CredentialHandler) SetCredentials(c *gin.Context) {
+	project, ok := routeProject(c, h.DB)
+	if !ok {
+		return
+	}
+
+	var req SetCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	creds := gitauth.Credentials(req)
+	if err := creds.Validate(project.RepoURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid credentials",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sealed, err := h.cipher.Encrypt(project.ID, gitauth.CipherName, creds.Secret)
+	if err != nil {
+		log.Error().Err(err).Int("project_id", project.ID).Msg("Erreur lors du chiffrement des identifiants")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to store credentials"})
+		return
+	}
+
+	stored, err := database.SetProjectCredentials(h.DB, database.ProjectCredentials{
+		ProjectID:  project.ID,
+		Kind:       string(creds.Kind),
+		Host:       gitauth.Host(project.RepoURL),
+		Username:   creds.Username,
+		KnownHosts: creds.KnownHosts,
+		GoPrivate:  creds.GoPrivate,
+	}, sealed)
+	if err != nil {
+		log.Error().Err(err).Int("project_id", project.ID).Msg("Erreur lors de l'enregistrement des identifiants")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to store credentials"})
+		return
+	}
+
+	log.Info().Int("project_id", project.ID).Str("kind", stored.Kind).Msg("Identifiants enregistrés")
+	c.JSON(http.StatusOK, stored)
+}
+
+// DeleteCredentials supprime les identifiants d'un projet
+func (h *CredentialHandler) DeleteCredentials(c *gin.Context) {
+	project, ok := routeProject(c, h.DB)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteProjectCredentials(h.DB, project.ID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "credentials not found"})
+			return
+		}
+		log.Error().Err(err).Int("project_id", project.ID).Msg("Erreur lors de la suppression des identifiants")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to delete credentials"})
+		return
+	}
+
+	log.Info().Int("project_id", project.ID).Msg("Identifiants supprimés")
+	c.JSON(http.StatusOK, gin.H{"message": "credentials deleted successfully"})
+}
+
+// setupCredentialRoutes configure les routes de gestion des identifiants des dépôts
+func setupCredentialRoutes(router *gin.RouterGroup, db *sql.DB, cipher *secrets.Cipher) {
+	handler := &CredentialHandler{DB: db, cipher: cipher}
+	projectCredentials := router.Group("/api/projects/:id/credentials")
+	{
+		projectCredentials.GET("", handler.GetCredentials)       // Identifiants du dépôt (sans secret)
+		projectCredentials.PUT("", handler.SetCredentials)       // Créer ou remplacer les identifiants
+		projectCredentials.DELETE("", handler.DeleteCredentials) // Supprimer les identifiants
+	}
+}
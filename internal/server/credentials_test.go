@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/gitauth"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectCredentialsEndpoints(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+	path := fmt.Sprintf("/api/projects/%d/credentials", build.ProjectID)
+
+	assert.Equal(t, http.StatusNotFound, requestJSON(router, "GET", path, nil).Code)
+
+	w := requestJSON(router, "PUT", path, SetCredentialsRequest{Kind: gitauth.KindToken, Secret: "ghp_s3cr3t", GoPrivate: "github.com/user"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "ghp_s3cr3t")
+
+	// Les identifiants sont renvoyés sans le secret
+	w = requestJSON(router, "GET", path, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "ghp_s3cr3t")
+	var creds database.ProjectCredentials
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &creds))
+	assert.Equal(t, "token", creds.Kind)
+	assert.Equal(t, "github.com/user", creds.GoPrivate)
+	assert.Equal(t, "github.com", creds.Host)
+
+	// Le secret est chiffré en base
+	_, sealed, err := database.GetProjectCredentialsSecret(db, build.ProjectID)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed), "ghp_s3cr3t")
+
+	// Le job transmis à l'agent contient les identifiants déchiffrés
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+	var job builder.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, &gitauth.Credentials{Kind: gitauth.KindToken, Secret: "ghp_s3cr3t", GoPrivate: "github.com/user"}, job.Credentials)
+
+	// Les identifiants ne suivent pas le dépôt sur un autre hôte : le build échoue sans eux
+	_, err = database.UpdateProject(db, build.ProjectID, "api", "https://evil.example.com/user/api.git", "main", "")
+	require.NoError(t, err)
+	moved, err := database.CreateBuild(db, build.ProjectID, "main")
+	require.NoError(t, err)
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotContains(t, w.Body.String(), "ghp_s3cr3t")
+	moved, err = database.GetBuildByID(db, fmt.Sprint(moved.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "failed", moved.Status)
+	assert.Equal(t, "Repository credentials were saved for github.com, not evil.example.com: set them again", moved.Error)
+	_, err = database.UpdateProject(db, build.ProjectID, "api", "https://github.com/user/api.git", "main", "")
+	require.NoError(t, err)
+
+	for _, invalid := range []SetCredentialsRequest{
+		{Secret: "x"},
+		{Kind: gitauth.KindToken},
+		{Kind: gitauth.KindPassword, Secret: "pw"},
+		{Kind: gitauth.KindSSH, Secret: "not a key", KnownHosts: "github.com ssh-ed25519 AAAA"},
+	} {
+		w := requestJSON(router, "PUT", path, invalid)
+		assert.Equal(t, http.StatusBadRequest, w.Code, string(invalid.Kind))
+	}
+	assert.Equal(t, http.StatusNotFound, requestJSON(router, "PUT", "/api/projects/999/credentials", SetCredentialsRequest{Kind: gitauth.KindToken, Secret: "x"}).Code)
+
+	assert.Equal(t, http.StatusOK, requestJSON(router, "DELETE", path, nil).Code)
+	assert.Equal(t, http.StatusNotFound, requestJSON(router, "DELETE", path, nil).Code)
+}
@@ -5,24 +5,200 @@ import (
 	"net/http"
 	"strconv"
 
+	"forgeronvirtuel/gip/internal/buildenv"
 	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/ldflags"
+	"forgeronvirtuel/gip/internal/mainpkg"
+	"forgeronvirtuel/gip/internal/platform"
+	"forgeronvirtuel/gip/internal/sandbox"
+	"forgeronvirtuel/gip/internal/selector"
+	"forgeronvirtuel/gip/internal/toolchain"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
 )
 
 type CreateProjectRequest struct {
-	Name    string `json:"name" binding:"required"`
-	RepoURL string `json:"repo_url" binding:"required"`
-	Branch  string `json:"branch"`
-	Subdir  string `json:"subdir"`
+	Name             string            `json:"name" binding:"required"`
+	RepoURL          string            `json:"repo_url" binding:"required"`
+	Branch           string            `json:"branch"`
+	Subdir           string            `json:"subdir"`
+	AgentSelector    string            `json:"agent_selector"`
+	Platforms        []string          `json:"platforms"`
+	Binaries         []mainpkg.Binary  `json:"binaries"`
+	DiscoverBinaries bool              `json:"discover_binaries"`
+	Ldflags          string            `json:"ldflags"`
+	Env              map[string]string `json:"env"`
+	RunTests         bool              `json:"run_tests"`
+	Coverage         bool              `json:"coverage"`
+	CloneDepth       int               `json:"clone_depth"`
+	Submodules       bool              `json:"submodules"`
+	LFS              bool              `json:"lfs"`
+	Sandbox          *sandbox.Policy   `json:"sandbox"`
+	Hermetic         bool              `json:"hermetic"`
+	Reproducible     bool              `json:"reproducible"`
+	GoToolchain      string            `json:"go_toolchain"`
 }
 
 type UpdateProjectRequest struct {
-	Name    string `json:"name" binding:"required"`
-	RepoURL string `json:"repo_url" binding:"required"`
-	Branch  string `json:"branch" binding:"required"`
-	Subdir  string `json:"subdir"`
+	Name             string            `json:"name" binding:"required"`
+	RepoURL          string            `json:"repo_url" binding:"required"`
+	Branch           string            `json:"branch" binding:"required"`
+	Subdir           string            `json:"subdir"`
+	AgentSelector    string            `json:"agent_selector"`
+	Platforms        []string          `json:"platforms"`
+	Binaries         []mainpkg.Binary  `json:"binaries"`
+	DiscoverBinaries bool              `json:"discover_binaries"`
+	Ldflags          string            `json:"ldflags"`
+	Env              map[string]string `json:"env"`
+	RunTests         bool              `json:"run_tests"`
+	Coverage         bool              `json:"coverage"`
+	CloneDepth       int               `json:"clone_depth"`
+	Submodules       bool              `json:"submodules"`
+	LFS              bool              `json:"lfs"`
+	Sandbox          *sandbox.Policy   `json:"sandbox"`
+	Hermetic         bool              `json:"hermetic"`
+	Reproducible     bool              `json:"reproducible"`
+	GoToolchain      string            `json:"go_toolchain"`
+}
+
+// normalizeAgentSelector valide un sélecteur d'agents et retourne sa forme normalisée.
+// Répond 400 et retourne false si le sélecteur est invalide.
+func normalizeAgentSelector(c *gin.Context, agentSelector string) (string, bool) {
+	sel, err := selector.Parse(agentSelector)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid agent selector",
+			"details": err.Error(),
+		})
+		return "", false
+	}
+	return sel.String(), true
+}
+
+// normalizePlatforms valide les plateformes cibles d'un projet et retourne leurs
+// formes normalisées. Répond 400 et retourne false si une plateforme est invalide.
+func normalizePlatforms(c *gin.Context, list []string) ([]string, bool) {
+	platforms, err := platform.ParseList(list)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid platforms",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return platform.Strings(platforms), true
+}
+
+// normalizeBinaries valide les binaires d'un projet et retourne leurs formes
+// normalisées. Répond 400 et retourne false si un binaire est invalide.
+func normalizeBinaries(c *gin.Context, list []mainpkg.Binary) ([]mainpkg.Binary, bool) {
+	binaries, err := mainpkg.ParseList(list)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid binaries",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return binaries, true
+}
+
+// normalizeLdflags valide le modèle -ldflags d'un projet et retourne sa forme
+// normalisée. Répond 400 et retourne false si le modèle est invalide.
+func normalizeLdflags(c *gin.Context, text string) (string, bool) {
+	text, err := ldflags.Validate(text)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid ldflags",
+			"details": err.Error(),
+		})
+		return "", false
+	}
+	return text, true
+}
+
+// normalizeEnv valide les variables d'environnement d'un projet.
+// Répond 400 et retourne false si une variable est invalide.
+func normalizeEnv(c *gin.Context, env map[string]string) (map[string]string, bool) {
+	vars, err := buildenv.Validate(env)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid env",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return vars, true
+}
+
+const (
+	// defaultCoverageTrendLimit est le nombre de builds retournés par défaut pour
+	// l'évolution de la couverture d'un projet
+	defaultCoverageTrendLimit = 50
+
+	// maxCoverageTrendLimit est le nombre maximal de builds demandés
+	maxCoverageTrendLimit = 500
+)
+
+// checkSandbox vérifie la politique de sandbox d'un projet, nil sans sandbox.
+// Répond 400 et retourne false si elle est invalide.
+func checkSandbox(c *gin.Context, policy *sandbox.Policy) bool {
+	if policy == nil {
+		return true
+	}
+	if err := policy.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid sandbox policy",
+			"details": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// normalizeGoToolchain valide la toolchain Go d'un projet et retourne sa forme
+// normalisée. Répond 400 et retourne false si elle est invalide.
+func normalizeGoToolchain(c *gin.Context, setting string) (string, bool) {
+	setting, err := toolchain.ParseSetting(setting)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid go toolchain",
+			"details": err.Error(),
+		})
+		return "", false
+	}
+	return setting, true
+}
+
+// maxCloneDepth est la profondeur maximale d'un clone superficiel ; au-delà,
+// autant récupérer tout l'historique
+const maxCloneDepth = 1 << 20
+
+// checkCloneDepth vérifie la profondeur du clone d'un projet.
+// Répond 400 et retourne false si elle est invalide.
+func checkCloneDepth(c *gin.Context, depth int) bool {
+	if depth < 0 || depth > maxCloneDepth {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid clone depth",
+			"details": "clone_depth must be between 0 (full history) and 1048576",
+		})
+		return false
+	}
+	return true
+}
+
+// checkTests vérifie les options de tests d'un projet : la couverture est mesurée
+// par les tests. Répond 400 et retourne false si elles sont incohérentes.
+func checkTests(c *gin.Context, runTests, coverage bool) bool {
+	if coverage && !runTests {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid test settings",
+			"details": "coverage requires run_tests",
+		})
+		return false
+	}
+	return true
 }
 
 func setupProjectRoutes(router *gin.RouterGroup, db *sql.DB) {
@@ -111,6 +287,48 @@ func setupProjectRoutes(router *gin.RouterGroup, db *sql.DB) {
 				req.Branch = "main"
 			}
 
+			agentSelector, ok := normalizeAgentSelector(c, req.AgentSelector)
+			if !ok {
+				return
+			}
+
+			platforms, ok := normalizePlatforms(c, req.Platforms)
+			if !ok {
+				return
+			}
+
+			binaries, ok := normalizeBinaries(c, req.Binaries)
+			if !ok {
+				return
+			}
+
+			ldflagsTemplate, ok := normalizeLdflags(c, req.Ldflags)
+			if !ok {
+				return
+			}
+
+			env, ok := normalizeEnv(c, req.Env)
+			if !ok {
+				return
+			}
+
+			if !checkTests(c, req.RunTests, req.Coverage) {
+				return
+			}
+
+			if !checkCloneDepth(c, req.CloneDepth) {
+				return
+			}
+
+			if !checkSandbox(c, req.Sandbox) {
+				return
+			}
+
+			goToolchain, ok := normalizeGoToolchain(c, req.GoToolchain)
+			if !ok {
+				return
+			}
+
 			project, err := database.CreateProject(db, req.Name, req.RepoURL, req.Branch, req.Subdir)
 			if err != nil {
 				log.Error().Err(err).Str("name", req.Name).Msg("Erreur lors de la création du projet")
@@ -121,10 +339,196 @@ func setupProjectRoutes(router *gin.RouterGroup, db *sql.DB) {
 				return
 			}
 
+			if agentSelector != "" {
+				if err := database.UpdateProjectAgentSelector(db, project.ID, agentSelector); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement du sélecteur d'agents")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.AgentSelector = agentSelector
+			}
+
+			if len(platforms) > 0 {
+				if err := database.UpdateProjectPlatforms(db, project.ID, platforms); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement des plateformes cibles")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Platforms = platforms
+			}
+
+			if len(binaries) > 0 || req.DiscoverBinaries {
+				if err := database.UpdateProjectBinaries(db, project.ID, binaries, req.DiscoverBinaries); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement des binaires")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Binaries = binaries
+				project.DiscoverBinaries = req.DiscoverBinaries
+			}
+
+			if ldflagsTemplate != "" {
+				if err := database.UpdateProjectLdflags(db, project.ID, ldflagsTemplate); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement du modèle ldflags")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Ldflags = ldflagsTemplate
+			}
+
+			if len(env) > 0 {
+				if err := database.UpdateProjectEnv(db, project.ID, env); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement des variables d'environnement")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Env = env
+			}
+
+			if req.RunTests {
+				if err := database.UpdateProjectTests(db, project.ID, true, req.Coverage); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'activation des tests")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.RunTests = true
+				project.Coverage = req.Coverage
+			}
+
+			if req.CloneDepth > 0 {
+				if err := database.UpdateProjectCloneDepth(db, project.ID, req.CloneDepth); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement de la profondeur du clone")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.CloneDepth = req.CloneDepth
+			}
+
+			if req.Submodules || req.LFS {
+				if err := database.UpdateProjectCheckout(db, project.ID, req.Submodules, req.LFS); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement des options d'extraction")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Submodules = req.Submodules
+				project.LFS = req.LFS
+			}
+
+			if req.Sandbox != nil {
+				if err := database.UpdateProjectSandbox(db, project.ID, req.Sandbox); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement de la politique de sandbox")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Sandbox = req.Sandbox
+			}
+
+			if req.Hermetic {
+				if err := database.UpdateProjectHermetic(db, project.ID, true); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'activation des builds hermétiques")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Hermetic = true
+			}
+
+			if req.Reproducible {
+				if err := database.UpdateProjectReproducible(db, project.ID, true); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'activation de la vérification de la reproductibilité")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.Reproducible = true
+			}
+
+			if goToolchain != "" {
+				if err := database.UpdateProjectGoToolchain(db, project.ID, goToolchain); err != nil {
+					log.Error().Err(err).Int("id", project.ID).Msg("Erreur lors de l'enregistrement de la toolchain Go")
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": "unable to create project",
+					})
+					return
+				}
+				project.GoToolchain = goToolchain
+			}
+
 			log.Info().Int("id", project.ID).Str("name", project.Name).Msg("Projet créé avec succès")
 			c.JSON(http.StatusCreated, project)
 		})
 
+		// GET /api/projects/:id/coverage - Évolution de la couverture des builds réussis
+		projects.GET("/:id/coverage", func(c *gin.Context) {
+			id, err := strconv.Atoi(c.Param("id"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "invalid project id",
+				})
+				return
+			}
+
+			limit := defaultCoverageTrendLimit
+			if value := c.Query("limit"); value != "" {
+				limit, err = strconv.Atoi(value)
+				if err != nil || limit < 1 || limit > maxCoverageTrendLimit {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error": "invalid limit",
+					})
+					return
+				}
+			}
+
+			if _, err := database.GetProjectByID(db, id); err != nil {
+				if err == sql.ErrNoRows {
+					c.JSON(http.StatusNotFound, gin.H{
+						"error": "project not found",
+					})
+					return
+				}
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to fetch project",
+				})
+				return
+			}
+
+			branch := c.Query("branch")
+			points, err := database.GetProjectCoverageTrend(db, id, branch, limit)
+			if err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la récupération de la couverture du projet")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to fetch coverage",
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"project_id": id,
+				"branch":     branch,
+				"points":     points,
+			})
+		})
+
 		// PUT /api/projects/:id - Met à jour un projet
 		projects.PUT("/:id", func(c *gin.Context) {
 			id, err := strconv.Atoi(c.Param("id"))
@@ -159,6 +563,48 @@ func setupProjectRoutes(router *gin.RouterGroup, db *sql.DB) {
 				return
 			}
 
+			agentSelector, ok := normalizeAgentSelector(c, req.AgentSelector)
+			if !ok {
+				return
+			}
+
+			platforms, ok := normalizePlatforms(c, req.Platforms)
+			if !ok {
+				return
+			}
+
+			binaries, ok := normalizeBinaries(c, req.Binaries)
+			if !ok {
+				return
+			}
+
+			ldflagsTemplate, ok := normalizeLdflags(c, req.Ldflags)
+			if !ok {
+				return
+			}
+
+			env, ok := normalizeEnv(c, req.Env)
+			if !ok {
+				return
+			}
+
+			if !checkTests(c, req.RunTests, req.Coverage) {
+				return
+			}
+
+			if !checkCloneDepth(c, req.CloneDepth) {
+				return
+			}
+
+			if !checkSandbox(c, req.Sandbox) {
+				return
+			}
+
+			goToolchain, ok := normalizeGoToolchain(c, req.GoToolchain)
+			if !ok {
+				return
+			}
+
 			project, err := database.UpdateProject(db, id, req.Name, req.RepoURL, req.Branch, req.Subdir)
 			if err != nil {
 				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour du projet")
@@ -169,6 +615,117 @@ func setupProjectRoutes(router *gin.RouterGroup, db *sql.DB) {
 				return
 			}
 
+			if err := database.UpdateProjectAgentSelector(db, id, agentSelector); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour du sélecteur d'agents")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.AgentSelector = agentSelector
+
+			if err := database.UpdateProjectPlatforms(db, id, platforms); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour des plateformes cibles")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Platforms = platforms
+
+			if err := database.UpdateProjectBinaries(db, id, binaries, req.DiscoverBinaries); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour des binaires")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Binaries = binaries
+			project.DiscoverBinaries = req.DiscoverBinaries
+
+			if err := database.UpdateProjectLdflags(db, id, ldflagsTemplate); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour du modèle ldflags")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Ldflags = ldflagsTemplate
+
+			if err := database.UpdateProjectEnv(db, id, env); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour des variables d'environnement")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Env = env
+
+			if err := database.UpdateProjectTests(db, id, req.RunTests, req.Coverage); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour de l'exécution des tests")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.RunTests = req.RunTests
+			project.Coverage = req.Coverage
+
+			if err := database.UpdateProjectCloneDepth(db, id, req.CloneDepth); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour de la profondeur du clone")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.CloneDepth = req.CloneDepth
+
+			if err := database.UpdateProjectCheckout(db, id, req.Submodules, req.LFS); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour des options d'extraction")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Submodules = req.Submodules
+			project.LFS = req.LFS
+
+			if err := database.UpdateProjectSandbox(db, id, req.Sandbox); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour de la politique de sandbox")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Sandbox = req.Sandbox
+
+			if err := database.UpdateProjectHermetic(db, id, req.Hermetic); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour des builds hermétiques")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Hermetic = req.Hermetic
+
+			if err := database.UpdateProjectReproducible(db, id, req.Reproducible); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour de la vérification de la reproductibilité")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.Reproducible = req.Reproducible
+
+			if err := database.UpdateProjectGoToolchain(db, id, goToolchain); err != nil {
+				log.Error().Err(err).Int("id", id).Msg("Erreur lors de la mise à jour de la toolchain Go")
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "unable to update project",
+				})
+				return
+			}
+			project.GoToolchain = goToolchain
+
 			log.Info().Int("id", project.ID).Str("name", project.Name).Msg("Projet mis à jour avec succès")
 			c.JSON(http.StatusOK, project)
 		})
@@ -6,9 +6,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
 
 	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/mainpkg"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/mattn/go-sqlite3"
@@ -250,6 +252,47 @@ func TestCreateProjectInvalidRequest(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestCreateProjectAgentSelector(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	reqBody := CreateProjectRequest{
+		Name:          "gpu-project",
+		RepoURL:       "https://github.com/user/gpu.git",
+		AgentSelector: "os = linux, arch in (amd64, arm64), gpu!=true",
+	}
+
+	jsonBody, _ := json.Marshal(reqBody)
+	req, _ := http.NewRequest("POST", baseUrl+"/api/projects", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "os=linux,arch in (amd64,arm64),gpu!=true", response.AgentSelector, "Le sélecteur devrait être normalisé")
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.AgentSelector, stored.AgentSelector)
+
+	// Sélecteur invalide
+	reqBody.Name = "invalid-selector"
+	reqBody.AgentSelector = "arch in amd64"
+	jsonBody, _ = json.Marshal(reqBody)
+	req, _ = http.NewRequest("POST", baseUrl+"/api/projects", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestUpdateProjectNotFound(t *testing.T) {
 	db := setupProjectTestDB(t)
 	defer db.Close()
@@ -286,3 +329,295 @@ func TestDeleteProjectNotFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func TestCreateProjectPlatforms(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	post := func(name string, platforms []string) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(CreateProjectRequest{Name: name, RepoURL: "https://github.com/user/tool.git", Platforms: platforms})
+		req, _ := http.NewRequest("POST", baseUrl+"/api/projects", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("tool", []string{" linux/amd64", "linux/arm/v7", "windows/amd64"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, []string{"linux/amd64", "linux/arm/v7", "windows/amd64"}, response.Platforms)
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Platforms, stored.Platforms)
+
+	// Sans matrice, la liste est vide
+	w = post("host-only", nil)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"platforms":[]`)
+
+	assert.Equal(t, http.StatusBadRequest, post("invalid", []string{"linux/arm64/v8"}).Code)
+	assert.Equal(t, http.StatusBadRequest, post("duplicate", []string{"linux/amd64", "linux/amd64"}).Code)
+}
+
+func TestCreateProjectBinaries(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	post := func(name string, binaries []mainpkg.Binary, discover bool) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(CreateProjectRequest{Name: name, RepoURL: "https://github.com/user/tool.git", Binaries: binaries, DiscoverBinaries: discover})
+		req, _ := http.NewRequest("POST", baseUrl+"/api/projects", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("tools", []mainpkg.Binary{{Package: "cmd/server"}, {Package: "./cmd/cli", Name: "tool"}}, true)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, []mainpkg.Binary{{Package: "./cmd/server", Name: "server"}, {Package: "./cmd/cli", Name: "tool"}}, response.Binaries)
+	assert.True(t, response.DiscoverBinaries)
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Binaries, stored.Binaries)
+	assert.True(t, stored.DiscoverBinaries)
+
+	// Sans binaires, la liste est vide et cmd/main.go est compilé
+	w = post("single", nil, false)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"binaries":[]`)
+	assert.Contains(t, w.Body.String(), `"discover_binaries":false`)
+
+	assert.Equal(t, http.StatusBadRequest, post("escape", []mainpkg.Binary{{Package: "../other"}}, false).Code)
+	assert.Equal(t, http.StatusBadRequest, post("duplicate", []mainpkg.Binary{{Package: "cmd/tool"}, {Package: "tools/tool"}}, false).Code)
+}
+
+func TestCreateProjectLdflags(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	post := func(name, ldflags string) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(CreateProjectRequest{Name: name, RepoURL: "https://github.com/user/tool.git", Ldflags: ldflags})
+		req, _ := http.NewRequest("POST", baseUrl+"/api/projects", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("tool", " -X main.version={{.Tag}} -X main.commit={{.Commit}}\n")
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "-X main.version={{.Tag}} -X main.commit={{.Commit}}", response.Ldflags)
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Ldflags, stored.Ldflags)
+
+	w = post("unknown-variable", "-X main.version={{.Release}}")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid ldflags")
+	assert.Equal(t, http.StatusBadRequest, post("syntax", "-X main.version={{.Tag").Code)
+}
+
+func TestCreateProjectCloneDepth(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	post := func(name string, depth int) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(CreateProjectRequest{Name: name, RepoURL: "https://github.com/user/tool.git", CloneDepth: depth})
+		req, _ := http.NewRequest("POST", baseUrl+"/api/projects", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("tool", 1)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, 1, response.CloneDepth)
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.CloneDepth)
+
+	w = post("negative", -1)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid clone depth")
+}
+
+func TestProjectCheckoutOptions(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	jsonBody, _ := json.Marshal(CreateProjectRequest{Name: "tool", RepoURL: "https://github.com/user/tool.git", Submodules: true, LFS: true})
+	req, _ := http.NewRequest("POST", baseUrl+"/api/projects", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.True(t, response.Submodules)
+	assert.True(t, response.LFS)
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Submodules)
+	assert.True(t, stored.LFS)
+
+	// Une mise à jour sans les options les désactive
+	jsonBody, _ = json.Marshal(UpdateProjectRequest{Name: "tool", RepoURL: "https://github.com/user/tool.git", Branch: "main", Submodules: true})
+	req, _ = http.NewRequest("PUT", baseUrl+"/api/projects/"+strconv.Itoa(response.ID), bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stored, err = database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.True(t, stored.Submodules)
+	assert.False(t, stored.LFS)
+}
+
+func TestProjectSandbox(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	send := func(method, path string, body any) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, baseUrl+path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/projects", map[string]any{
+		"name":         "tool",
+		"repo_url":     "https://github.com/user/tool.git",
+		"sandbox":      map[string]int{"cpu_seconds": 120, "memory_mb": 1024},
+		"hermetic":     true,
+		"reproducible": true,
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	require.NotNil(t, response.Sandbox)
+	assert.Equal(t, 120, response.Sandbox.CPUSeconds)
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Sandbox, stored.Sandbox)
+	assert.True(t, stored.Hermetic)
+	assert.True(t, stored.Reproducible)
+
+	w = send("POST", "/api/projects", map[string]any{
+		"name":     "negative",
+		"repo_url": "https://github.com/user/tool.git",
+		"sandbox":  map[string]int{"open_files": -1},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid sandbox policy")
+
+	// Une mise à jour sans politique désactive le sandbox, les builds hermétiques et reproductibles
+	w = send("PUT", "/api/projects/"+strconv.Itoa(response.ID), map[string]any{
+		"name":     "tool",
+		"repo_url": "https://github.com/user/tool.git",
+		"branch":   "main",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	stored, err = database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.Sandbox)
+	assert.False(t, stored.Hermetic)
+	assert.False(t, stored.Reproducible)
+}
+
+func TestProjectGoToolchain(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	send := func(method, path string, body any) *httptest.ResponseRecorder {
+		jsonBody, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, baseUrl+path, bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/projects", map[string]any{
+		"name":         "tool",
+		"repo_url":     "https://github.com/user/tool.git",
+		"go_toolchain": "1.22.3",
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "go1.22.3", response.GoToolchain)
+
+	w = send("POST", "/api/projects", map[string]any{
+		"name":         "latest",
+		"repo_url":     "https://github.com/user/tool.git",
+		"go_toolchain": "latest",
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid go toolchain")
+
+	w = send("PUT", "/api/projects/"+strconv.Itoa(response.ID), map[string]any{
+		"name":         "tool",
+		"repo_url":     "https://github.com/user/tool.git",
+		"branch":       "main",
+		"go_toolchain": "go.mod",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "go.mod", stored.GoToolchain)
+
+	// Sans toolchain, les builds utilisent la commande go du PATH
+	w = send("PUT", "/api/projects/"+strconv.Itoa(response.ID), map[string]any{
+		"name":     "tool",
+		"repo_url": "https://github.com/user/tool.git",
+		"branch":   "main",
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	stored, err = database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.GoToolchain)
+}
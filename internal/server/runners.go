@@ -0,0 +1,287 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/secrets"
+	"forgeronvirtuel/gip/internal/selector"
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// RunnerHandler expose les endpoints utilisés par `gip runner` pour exécuter les builds
+type RunnerHandler struct {
+	DB        *sql.DB
+	workspace string
+	logs      *builder.LogHub
+	cipher    *secrets.Cipher
+}
+
+type CompleteBuildRequest struct {
+	Error  string          `json:"error"`
+	Result *builder.Result `json:"result"`
+}
+
+// LeaseBuild attribue le prochain build en attente à l'agent.
+// Répond 204 s'il n'y a rien à exécuter. Le job contient les secrets du projet :
+// la route n'est accessible qu'avec le jeton de l'agent.
+func (h *RunnerHandler) LeaseBuild(c *gin.Context) {
+	agentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+		return
+	}
+
+	agent, err := database.GetAgentByID(h.DB, agentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Agent not found"})
+		return
+	}
+
+	// Un agent en drainage termine ses builds mais n'en reçoit plus de nouveaux
+	if agent.Status == "DRAINING" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	// Demander un build vaut heartbeat
+	if err := database.UpdateAgentHeartbeat(h.DB, agentID); err != nil {
+		log.Error().Err(err).Msg("Erreur lors de la mise à jour du heartbeat de l'agent")
+	}
+	if agent.Status == "OFFLINE" {
+		if err := database.UpdateAgentStatus(h.DB, agentID, "ONLINE"); err != nil {
+			log.Error().Err(err).Msg("Erreur lors de la mise à jour du statut de l'agent")
+		}
+	}
+
+	build, err := database.LeaseNextBuild(h.DB, agent, builder.LeaseDuration)
+	if err != nil {
+		log.Error().Err(err).Int("agent_id", agentID).Msg("Erreur lors de l'attribution d'un build")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lease build"})
+		return
+	}
+	if build == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	// Le sélecteur du projet ou les labels de l'agent ont pu changer depuis la
+	// lecture des candidats : un agent qui ne le satisfait pas ne reçoit pas le job
+	if !h.acceptsBuild(build, agentID) {
+		if err := database.ReleaseBuildLease(h.DB, build.ID, agentID); err != nil {
+			log.Error().Err(err).Int("build_id", build.ID).Msg("Impossible de remettre le build en attente")
+		}
+		log.Warn().Int("build_id", build.ID).Int("agent_id", agentID).Msg("Build refusé à un agent qui ne satisfait pas le sélecteur du projet")
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	job, err := builder.NewJob(h.DB, h.cipher, build)
+	if err != nil {
+		database.FinishBuild(h.DB, build.ID, "failed", "", err.Error())
+		h.logs.Close(build.ID, "failed", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare build"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// AppendBuildLogs ajoute la sortie envoyée par l'agent aux logs du build et
+// renouvelle son bail. Un corps vide renouvelle simplement le bail.
+func (h *RunnerHandler) AppendBuildLogs(c *gin.Context) {
+	agentID, buildID, ok := h.parseIDs(c)
+	if !ok {
+		return
+	}
+
+	chunk, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	renewed, err := database.RenewBuildLease(h.DB, buildID, agentID, string(chunk), builder.LeaseDuration)
+	if err != nil {
+		log.Error().Err(err).Int("build_id", buildID).Msg("Erreur lors de l'ajout des logs du build")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to append logs"})
+		return
+	}
+	if !renewed {
+		c.JSON(http.StatusConflict, gin.H{"error": "Build is not assigned to this agent"})
+		return
+	}
+	h.logs.Publish(buildID, chunk)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logs appended"})
+}
+
+// UploadArtifact reçoit un artefact produit par l'agent
+func (h *RunnerHandler) UploadArtifact(c *gin.Context) {
+	agentID, buildID, ok := h.parseIDs(c)
+	if !ok {
+		return
+	}
+
+	build, ok := h.leasedBuild(c, agentID, buildID)
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+		return
+	}
+
+	dir, err := h.artifactsDir(build)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get artifacts directory"})
+		return
+	}
+
+	name := filepath.Base(file.Filename)
+	if err := c.SaveUploadedFile(file, filepath.Join(dir, name)); err != nil {
+		log.Error().Err(err).Int("build_id", buildID).Msg("Erreur lors de l'enregistrement de l'artefact")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store artifact"})
+		return
+	}
+
+	log.Info().Int("build_id", buildID).Int("agent_id", agentID).Str("name", name).Msg("Artefact reçu de l'agent")
+	c.JSON(http.StatusCreated, gin.H{"message": "Artifact uploaded", "name": name})
+}
+
+// CompleteBuild enregistre le résultat final du build exécuté par l'agent
+func (h *RunnerHandler) CompleteBuild(c *gin.Context) {
+	agentID, buildID, ok := h.parseIDs(c)
+	if !ok {
+		return
+	}
+
+	var req CompleteBuildRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	build, ok := h.leasedBuild(c, agentID, buildID)
+	if !ok {
+		return
+	}
+
+	var runErr error
+	if req.Error != "" {
+		runErr = errors.New(req.Error)
+	} else if req.Result != nil {
+		// Les artefacts ont été envoyés via UploadArtifact : on les décrit à partir
+		// des copies locales plutôt que de faire confiance aux empreintes de l'agent
+		dir, err := h.artifactsDir(build)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get artifacts directory"})
+			return
+		}
+		for i, artifact := range req.Result.Artifacts {
+			local, err := database.NewArtifactFromFile(filepath.Join(dir, filepath.Base(artifact.Path)), artifact.Binary, artifact.Platform())
+			if err != nil {
+				runErr = fmt.Errorf("Artifact %s was not uploaded", artifact.Name)
+				break
+			}
+			local.Name = artifact.Name
+			req.Result.Artifacts[i] = *local
+		}
+	}
+
+	if err := builder.Finish(h.DB, h.logs, buildID, build.LogOutput, req.Result, runErr); err != nil {
+		log.Error().Err(err).Int("build_id", buildID).Msg("Impossible d'enregistrer le résultat du build")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete build"})
+		return
+	}
+
+	log.Info().Int("build_id", buildID).Int("agent_id", agentID).Msg("Build terminé par l'agent")
+	c.JSON(http.StatusOK, gin.H{"message": "Build completed"})
+}
+
+// acceptsBuild vérifie que les labels actuels de l'agent satisfont le sélecteur
+// d'agents actuel du projet du build
+func (h *RunnerHandler) acceptsBuild(build *database.Build, agentID int) bool {
+	project, err := database.GetProjectByID(h.DB, build.ProjectID)
+	if err != nil {
+		return false
+	}
+	agent, err := database.GetAgentByID(h.DB, agentID)
+	if err != nil {
+		return false
+	}
+	sel, err := selector.Parse(project.AgentSelector)
+	return err == nil && sel.Matches(agent.Labels)
+}
+
+// parseIDs lit les identifiants d'agent et de build de l'URL
+func (h *RunnerHandler) parseIDs(c *gin.Context) (int, int, bool) {
+	agentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid agent ID"})
+		return 0, 0, false
+	}
+
+	buildID, err := strconv.Atoi(c.Param("build_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid build ID"})
+		return 0, 0, false
+	}
+
+	return agentID, buildID, true
+}
+
+// leasedBuild récupère un build en vérifiant qu'il est en cours sur l'agent
+func (h *RunnerHandler) leasedBuild(c *gin.Context, agentID, buildID int) (*database.Build, bool) {
+	build, err := database.GetBuildByID(h.DB, strconv.Itoa(buildID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Build not found"})
+		return nil, false
+	}
+
+	if build.Status != "building" || !build.AgentID.Valid || int(build.AgentID.Int64) != agentID {
+		c.JSON(http.StatusConflict, gin.H{"error": "Build is not assigned to this agent"})
+		return nil, false
+	}
+
+	return build, true
+}
+
+// artifactsDir retourne le répertoire où sont stockés les artefacts envoyés pour un build
+func (h *RunnerHandler) artifactsDir(build *database.Build) (string, error) {
+	absWorkspace, err := filepath.Abs(h.workspace)
+	if err != nil {
+		return "", err
+	}
+
+	dir := workspacemanager.BuildArtifactsDir(absWorkspace, build.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// setupRunnerRoutes configure les routes utilisées par les agents pour exécuter les builds
+func setupRunnerRoutes(router gin.IRouter, db *sql.DB, workspace string, logs *builder.LogHub, cipher *secrets.Cipher) {
+	handler := &RunnerHandler{DB: db, workspace: workspace, logs: logs, cipher: cipher}
+	agents := router.Group("/api/agents", requireAgentToken(db))
+	{
+		agents.POST("/:id/lease", handler.LeaseBuild)                         // Obtenir un build à exécuter
+		agents.POST("/:id/builds/:build_id/logs", handler.AppendBuildLogs)    // Envoyer des logs / renouveler le bail
+		agents.POST("/:id/builds/:build_id/artifact", handler.UploadArtifact) // Envoyer un artefact
+		agents.POST("/:id/builds/:build_id/complete", handler.CompleteBuild)  // Terminer le build
+	}
+}
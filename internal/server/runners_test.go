@@ -0,0 +1,569 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/coverage"
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/secrets"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runnerAgent est un agent de test et le jeton qui authentifie ses requêtes
+type runnerAgent struct {
+	*database.Agent
+	token string
+}
+
+// newRunnerAgent crée un agent et lui délivre un jeton
+func newRunnerAgent(t *testing.T, db *sql.DB, name string, labels map[string]string) *runnerAgent {
+	agent, err := database.CreateAgent(db, name, labels)
+	require.NoError(t, err)
+	token, err := database.IssueAgentToken(db, agent.ID)
+	require.NoError(t, err)
+	return &runnerAgent{Agent: agent, token: token}
+}
+
+// setupRunnerTest crée une base complète, un projet, un agent et un build en attente
+func setupRunnerTest(t *testing.T) (*sql.DB, *gin.Engine, *runnerAgent, *database.Build) {
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	project, err := database.CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	require.NoError(t, err)
+
+	agent := newRunnerAgent(t, db, "runner-1", map[string]string{"os": "linux"})
+
+	build, err := database.CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, t.TempDir())
+
+	return db, router, agent, build
+}
+
+func postRunner(router *gin.Engine, path, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", baseUrl+path, body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// postAgent envoie une requête d'agent authentifiée par son jeton
+func postAgent(router *gin.Engine, agent *runnerAgent, path, contentType string, body *bytes.Buffer) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", baseUrl+path, body)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+agent.token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAgentAuthentication(t *testing.T) {
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	project, err := database.CreateProject(db, "api", "https://github.com/user/api.git", "main", "")
+	require.NoError(t, err)
+	_, err = database.CreateBuild(db, project.ID, "main")
+	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	router := setupRouter(db, t.TempDir(), nil, nil, builder.NewLogHub(), secrets.NewRandomCipher(), "registration-secret")
+
+	register := func(name, token string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", baseUrl+"/api/agents/register", strings.NewReader(`{"name":"`+name+`","labels":{"os":"linux"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Sans le jeton d'enregistrement du serveur, l'agent est refusé
+	assert.Equal(t, http.StatusUnauthorized, register("runner-1", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, register("runner-1", "wrong").Code)
+
+	w := register("runner-1", "registration-secret")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var registered struct {
+		ID    int    `json:"id"`
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	require.NotEmpty(t, registered.Token)
+	agent := &runnerAgent{Agent: &database.Agent{ID: registered.ID}, token: registered.Token}
+
+	// Le jeton n'est délivré qu'une fois
+	assert.Equal(t, http.StatusConflict, register("runner-1", "registration-secret").Code)
+
+	// Sans jeton, ou avec celui d'un autre agent, le build et ses secrets ne sont pas transmis
+	leasePath := fmt.Sprintf("/api/agents/%d/lease", agent.ID)
+	assert.Equal(t, http.StatusUnauthorized, postRunner(router, leasePath, "application/json", &bytes.Buffer{}).Code)
+	other := newRunnerAgent(t, db, "runner-2", map[string]string{"os": "linux"})
+	assert.Equal(t, http.StatusUnauthorized, postAgent(router, other, leasePath, "application/json", &bytes.Buffer{}).Code)
+	heartbeatPath := fmt.Sprintf("/api/agents/%d/heartbeat", agent.ID)
+	assert.Equal(t, http.StatusUnauthorized, postRunner(router, heartbeatPath, "application/json", &bytes.Buffer{}).Code)
+
+	assert.Equal(t, http.StatusOK, postAgent(router, agent, heartbeatPath, "application/json", &bytes.Buffer{}).Code)
+	w = postAgent(router, agent, leasePath, "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+	var job builder.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+
+	// Les routes du build exigent aussi le jeton
+	for _, action := range []string{"logs", "artifact", "complete"} {
+		path := fmt.Sprintf("/api/agents/%d/builds/%d/%s", agent.ID, job.BuildID, action)
+		assert.Equal(t, http.StatusUnauthorized, postRunner(router, path, "application/json", &bytes.Buffer{}).Code, action)
+	}
+
+	// Les labels et la suppression d'un agent exigent son jeton ou le jeton d'enregistrement
+	manage := func(method, path, token string) int {
+		req, _ := http.NewRequest(method, baseUrl+path, strings.NewReader(`{"labels":{"os":"linux","secrets":"yes"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	labelsPath := fmt.Sprintf("/api/agents/%d/labels", agent.ID)
+	agentPath := fmt.Sprintf("/api/agents/%d", agent.ID)
+	assert.Equal(t, http.StatusUnauthorized, manage("PUT", labelsPath, ""))
+	assert.Equal(t, http.StatusUnauthorized, manage("PUT", labelsPath, other.token))
+	assert.Equal(t, http.StatusUnauthorized, manage("DELETE", agentPath, ""))
+	assert.Equal(t, http.StatusOK, manage("PUT", labelsPath, agent.token))
+	assert.Equal(t, http.StatusOK, manage("PUT", labelsPath, "registration-secret"))
+	assert.Equal(t, http.StatusOK, manage("DELETE", fmt.Sprintf("/api/agents/%d", other.ID), other.token))
+	assert.Equal(t, http.StatusOK, manage("DELETE", agentPath, "registration-secret"))
+
+	// Un agent enregistré avant l'authentification des agents reçoit un jeton à sa
+	// prochaine demande d'enregistrement, avec le jeton d'enregistrement
+	legacy, err := database.CreateAgent(db, "runner-legacy", nil)
+	require.NoError(t, err)
+	w = register("runner-legacy", "registration-secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &registered))
+	assert.Equal(t, legacy.ID, registered.ID)
+	assert.NotEmpty(t, registered.Token)
+
+	// Sans jeton d'enregistrement sur le serveur, personne ne peut le reprendre
+	open := setupRouter(db, t.TempDir(), nil, nil, builder.NewLogHub(), secrets.NewRandomCipher(), "")
+	_, err = database.CreateAgent(db, "runner-legacy-2", nil)
+	require.NoError(t, err)
+	req, _ := http.NewRequest("POST", baseUrl+"/api/agents/register", strings.NewReader(`{"name":"runner-legacy-2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	open.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestLeaseBuildEndpoint(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var job builder.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, build.ID, job.BuildID)
+	assert.Equal(t, "api", job.ProjectName)
+	assert.Equal(t, "https://github.com/user/api.git", job.RepoURL)
+
+	stored, err := database.GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "building", stored.Status)
+	assert.Equal(t, int64(agent.ID), stored.AgentID.Int64)
+
+	// L'agent est considéré en ligne dès qu'il demande du travail
+	updated, _ := database.GetAgentByID(db, agent.ID)
+	assert.Equal(t, "ONLINE", updated.Status)
+
+	// Plus rien à exécuter
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestLeaseBuildDrainingAgent(t *testing.T) {
+	db, router, agent, _ := setupRunnerTest(t)
+	require.NoError(t, database.UpdateAgentStatus(db, agent.ID, "DRAINING"))
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestAgentBuildLogsAndFailure(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+	other := newRunnerAgent(t, db, "runner-2", nil)
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	logsPath := fmt.Sprintf("/api/agents/%d/builds/%d/logs", agent.ID, build.ID)
+	w = postAgent(router, agent, logsPath, "text/plain", bytes.NewBufferString("==> Running: go mod download\n"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postAgent(router, agent, logsPath, "text/plain", bytes.NewBufferString("==> Running: go build\n"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Un autre agent ne peut pas écrire dans ce build
+	w = postAgent(router, other, fmt.Sprintf("/api/agents/%d/builds/%d/logs", other.ID, build.ID), "text/plain", bytes.NewBufferString("intrus"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	payload, _ := json.Marshal(CompleteBuildRequest{Error: "exit status 1"})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := database.GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "failed", stored.Status)
+	assert.Equal(t, "exit status 1", stored.Error)
+	assert.Equal(t, "==> Running: go mod download\n==> Running: go build\n", stored.LogOutput)
+
+	// Le build est terminé : le bail ne peut plus être renouvelé
+	w = postAgent(router, agent, logsPath, "text/plain", &bytes.Buffer{})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestAgentBuildUploadAndDownload(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, _ := form.CreateFormFile("file", "api-1")
+	part.Write([]byte("binary content"))
+	form.Close()
+
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/artifact", agent.ID, build.ID), form.FormDataContentType(), body)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	payload, _ := json.Marshal(CompleteBuildRequest{Result: &builder.Result{Artifacts: []database.Artifact{
+		{Name: "api-1", Path: "/runner-workspace/artifacts/build-1/api-1", Size: 1, SHA256: "forged", OS: "linux", Arch: "arm64"},
+	}}})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := database.GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "success", stored.Status)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/download", baseUrl, build.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "binary content"))
+
+	// L'empreinte est calculée sur la copie reçue par le control plane
+	artifacts, err := database.GetArtifactsByBuildID(db, build.ID)
+	require.NoError(t, err)
+	require.Len(t, artifacts, 1)
+	assert.Equal(t, int64(len("binary content")), artifacts[0].Size)
+	sum := sha256.Sum256([]byte("binary content"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), artifacts[0].SHA256)
+	assert.Equal(t, "arm64", artifacts[0].Arch)
+}
+
+func TestAgentReproducibilityCheck(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+	require.NoError(t, database.UpdateProjectReproducible(db, build.ProjectID, true))
+	commit := &database.CommitInfo{SHA: "4f2a9c0e8d7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f", Date: time.Date(2025, 11, 8, 10, 30, 0, 0, time.UTC)}
+
+	// run exécute le prochain build sur l'agent, avec la version de Go goVersion,
+	// qui produit un artefact de contenu content
+	run := func(content, goVersion string) builder.Job {
+		w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+		require.Equal(t, http.StatusOK, w.Code)
+		var job builder.Job
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("file", "api-1")
+		part.Write([]byte(content))
+		form.Close()
+		w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/artifact", agent.ID, job.BuildID), form.FormDataContentType(), body)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		payload, _ := json.Marshal(CompleteBuildRequest{Result: &builder.Result{
+			Commit:    commit,
+			GoVersion: goVersion,
+			Artifacts: []database.Artifact{{Name: "api-1", Binary: "api", Path: "/out/api-1", OS: "linux", Arch: "amd64"}},
+		}})
+		w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, job.BuildID), "application/json", bytes.NewBuffer(payload))
+		require.Equal(t, http.StatusOK, w.Code)
+		return job
+	}
+
+	job := run("binary content", "go1.22.3")
+	assert.True(t, job.Reproducible)
+	assert.Zero(t, job.RebuildOf)
+
+	// Le build réussi met en file son build de vérification, du même commit
+	rebuild := run("binary content, rebuilt", "go1.22.5")
+	assert.True(t, rebuild.Reproducible)
+	assert.Equal(t, build.ID, rebuild.RebuildOf)
+	assert.Equal(t, commit.SHA, rebuild.Commit)
+
+	// Le build de vérification n'est pas lui-même vérifié
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d", baseUrl, build.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		GoVersion       string `json:"go_version"`
+		Reproducibility struct {
+			Status    string                             `json:"status"`
+			Error     string                             `json:"error"`
+			RebuildID int                                `json:"rebuild_id"`
+			Artifacts []database.ReproducibilityArtifact `json:"artifacts"`
+		} `json:"reproducibility"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "failed", response.Reproducibility.Status)
+	assert.Equal(t, "1 of 1 artifacts differ: api-1: (file) (14 -> 23 bytes) (built with go1.22.3, rebuilt with go1.22.5)", response.Reproducibility.Error)
+	assert.Equal(t, "go1.22.3", response.GoVersion)
+	assert.Equal(t, rebuild.BuildID, response.Reproducibility.RebuildID)
+	require.Len(t, response.Reproducibility.Artifacts, 1)
+	assert.Equal(t, "linux/amd64", response.Reproducibility.Artifacts[0].Platform)
+	assert.False(t, response.Reproducibility.Artifacts[0].Identical)
+}
+
+func TestAgentBuildMissingArtifact(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	payload, _ := json.Marshal(CompleteBuildRequest{Result: &builder.Result{Artifacts: []database.Artifact{{Name: "api-1", Path: "/out/api-1"}}}})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := database.GetBuildByID(db, strconv.Itoa(build.ID))
+	require.NoError(t, err)
+	assert.Equal(t, "failed", stored.Status)
+	assert.Equal(t, "Artifact api-1 was not uploaded", stored.Error)
+}
+
+func TestBuildWaitingReason(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+	require.NoError(t, database.UpdateProjectAgentSelector(db, build.ProjectID, "os=linux,arch=arm64"))
+	require.NoError(t, database.UpdateAgentStatus(db, agent.ID, "ONLINE"))
+
+	getBuild := func() map[string]interface{} {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d", baseUrl, build.ID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response
+	}
+
+	// L'agent ONLINE ne satisfait pas le sélecteur : il ne reçoit pas le build
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, `No ONLINE agent matches selector "os=linux,arch=arm64" (runner-1 does not satisfy arch=arm64)`, getBuild()["waiting_reason"])
+
+	// Un agent compatible mais hors ligne
+	armAgent := newRunnerAgent(t, db, "runner-arm", map[string]string{"os": "linux", "arch": "arm64"})
+	assert.Equal(t, `No ONLINE agent matches selector "os=linux,arch=arm64": matching agents are unavailable (runner-arm is OFFLINE)`, getBuild()["waiting_reason"])
+
+	// La liste des builds du projet donne la même raison
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/project/%d", baseUrl, build.ProjectID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var builds []map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &builds))
+	require.Len(t, builds, 1)
+	assert.Equal(t, getBuild()["waiting_reason"], builds[0]["waiting_reason"])
+
+	// Dès qu'il demande du travail, l'agent compatible reçoit le build
+	w = postAgent(router, armAgent, fmt.Sprintf("/api/agents/%d/lease", armAgent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+	response := getBuild()
+	assert.Equal(t, "building", response["status"])
+	assert.NotContains(t, response, "waiting_reason")
+}
+
+func TestAgentBuildTestResults(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+	require.NoError(t, database.UpdateProjectTests(db, build.ProjectID, true, false))
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+	var job builder.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.True(t, job.RunTests)
+
+	payload, _ := json.Marshal(CompleteBuildRequest{
+		Result: &builder.Result{Tests: []database.TestResult{
+			{Package: "example.com/api", Test: "TestOK", Status: "pass", Duration: 0.01},
+			{Package: "example.com/api", Test: "TestBroken", Status: "fail", Duration: 0.02, Output: "api_test.go:9: boom\n"},
+			{Package: "example.com/api", Status: "fail", Duration: 0.1},
+		}},
+		Error: "Tests failed in example.com/api",
+	})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d/tests", baseUrl, build.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Summary database.TestSummary  `json:"summary"`
+		Results []database.TestResult `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, database.TestSummary{Packages: 1, FailedPackages: 1, Total: 2, Passed: 1, Failed: 1, Duration: 0.1}, response.Summary)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, "api_test.go:9: boom\n", response.Results[1].Output)
+
+	req, _ = http.NewRequest("GET", baseUrl+"/api/builds/999/tests", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAgentBuildSteps(t *testing.T) {
+	_, router, agent, build := setupRunnerTest(t)
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	start := time.Now().UTC().Truncate(time.Second)
+	payload, _ := json.Marshal(CompleteBuildRequest{
+		Result: &builder.Result{Steps: []database.BuildStep{
+			{Name: "clone", Command: "git clone https://github.com/user/api.git", Status: "success", StartedAt: start, EndedAt: start.Add(time.Second)},
+			{Name: "go mod download", Command: "go mod download", Status: "failed", ExitCode: 1, LogOutput: "go: missing go.sum entry\n",
+				Error: "exit status 1", StartedAt: start.Add(time.Second), EndedAt: start.Add(4 * time.Second)},
+		}},
+		Error: "exit status 1",
+	})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s/api/builds/%d", baseUrl, build.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Status string               `json:"status"`
+		Steps  []database.BuildStep `json:"steps"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "failed", response.Status)
+	require.Len(t, response.Steps, 2)
+	assert.Equal(t, "clone", response.Steps[0].Name)
+	assert.Equal(t, "go mod download", response.Steps[1].Name)
+	assert.Equal(t, 1, response.Steps[1].ExitCode)
+	assert.Equal(t, "go: missing go.sum entry\n", response.Steps[1].LogOutput)
+	assert.Equal(t, 3*time.Second, response.Steps[1].EndedAt.Sub(response.Steps[1].StartedAt))
+}
+
+func TestAgentBuildCoverage(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+	require.NoError(t, database.UpdateProjectTests(db, build.ProjectID, true, true))
+
+	w := postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+	var job builder.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.True(t, job.Coverage)
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, _ := form.CreateFormFile("file", "api-1")
+	part.Write([]byte("binary content"))
+	form.Close()
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/artifact", agent.ID, build.ID), form.FormDataContentType(), body)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	payload, _ := json.Marshal(CompleteBuildRequest{Result: &builder.Result{
+		Artifacts: []database.Artifact{{Name: "api-1", Path: "/runner-workspace/artifacts/build-1/api-1"}},
+		Coverage: &coverage.Report{
+			Total:    coverage.Entry{Statements: 8, Covered: 6},
+			Packages: []coverage.Entry{{Package: "example.com/api", Statements: 8, Covered: 6}},
+			Files: []coverage.Entry{
+				{Package: "example.com/api", File: "example.com/api/a.go", Statements: 4, Covered: 4},
+				{Package: "example.com/api", File: "example.com/api/b.go", Statements: 4, Covered: 2},
+			},
+		},
+	}})
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/builds/%d/complete", agent.ID, build.ID), "application/json", bytes.NewBuffer(payload))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", baseUrl+path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w = get(fmt.Sprintf("/api/builds/%d/coverage", build.ID))
+	require.Equal(t, http.StatusOK, w.Code)
+	var report struct {
+		Total map[string]interface{}   `json:"total"`
+		Files []map[string]interface{} `json:"files"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 75.0, report.Total["percent"])
+	require.Len(t, report.Files, 2)
+	assert.Equal(t, "example.com/api/b.go", report.Files[1]["file"])
+	assert.Equal(t, 50.0, report.Files[1]["percent"])
+
+	w = get(fmt.Sprintf("/api/projects/%d/coverage?branch=main", build.ProjectID))
+	require.Equal(t, http.StatusOK, w.Code)
+	var trend struct {
+		Points []database.CoveragePoint `json:"points"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trend))
+	require.Len(t, trend.Points, 1)
+	assert.Equal(t, build.ID, trend.Points[0].BuildID)
+	assert.Equal(t, 75.0, trend.Points[0].Percent)
+
+	assert.Equal(t, http.StatusBadRequest, get(fmt.Sprintf("/api/projects/%d/coverage?limit=0", build.ProjectID)).Code)
+	assert.Equal(t, http.StatusNotFound, get("/api/projects/999/coverage").Code)
+
+	other, err := database.CreateBuild(db, build.ProjectID, "main")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, get(fmt.Sprintf("/api/builds/%d/coverage", other.ID)).Code)
+
+	// La couverture est mesurée par les tests
+	w = requestJSON(router, "POST", "/api/projects", CreateProjectRequest{
+		Name: "no-tests", RepoURL: "https://github.com/user/tool.git", Coverage: true,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "coverage requires run_tests")
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"forgeronvirtuel/gip/internal/buildenv"
+	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/secrets"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// SecretHandler gère les secrets des projets. Les valeurs sont chiffrées avec
+// la clé maître du serveur et ne sont jamais renvoyées par l'API.
+type SecretHandler struct {
+	DB     *sql.DB
+	cipher *secrets.Cipher
+}
+
+type SetSecretRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Value string `json:"value"`
+}
+
+// routeProject récupère le projet de la route. Répond 400 ou 404 et retourne false
+// si le projet n'existe pas.
+func routeProject(c *gin.Context, db *sql.DB) (*database.Project, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid project id"})
+		return nil, false
+	}
+
+	project, err := database.GetProjectByID(db, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to fetch project"})
+		return nil, false
+	}
+	return project, true
+}
+
+// ListSecrets liste les noms des secrets d'un projet
+func (h *SecretHandler) ListSecrets(c *gin.Context) {
+	project, ok := routeProject(c, h.DB)
+	if !ok {
+		return
+	}
+
+	list, err := database.GetProjectSecrets(h.DB, project.ID)
+	if err != nil {
+		log.Error().Err(err).Int("project_id", project.ID).Msg("Erreur lors de la récupération des secrets")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to fetch secrets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secrets": list,
+		"count":   len(list),
+	})
+}
+
+// SetSecret crée ou remplace un secret d'un projet
+func (h *SecretHandler) SetSecret(c *gin.Context) {
+	project, ok := routeProject(c, h.DB)
+	if !ok {
+		return
+	}
+
+	var req SetSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := buildenv.ValidateName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid secret name",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sealed, err := h.cipher.Encrypt(project.ID, req.Name, req.Value)
+	if err != nil {
+		log.Error().Err(err).Int("project_id", project.ID).Msg("Erreur lors du chiffrement du secret")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to store secret"})
+		return
+	}
+
+	secret, err := database.SetProjectSecret(h.DB, project.ID, req.Name, sealed)
+	if err != nil {
+		log.Error().Err(err).Int("project_id", project.ID).Str("name", req.Name).Msg("Erreur lors de l'enregistrement du secret")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to store secret"})
+		return
+	}
+
+	log.Info().Int("project_id", project.ID).Str("name", secret.Name).Msg("Secret enregistré")
+	c.JSON(http.StatusOK, secret)
+}
+
+// DeleteSecret supprime un secret d'un projet
+func (h *SecretHandler) DeleteSecret(c *gin.Context) {
+	project, ok := routeProject(c, h.DB)
+	if !ok {
+		return
+	}
+
+	name := c.Param("name")
+	if err := database.DeleteProjectSecret(h.DB, project.ID, name); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "secret not found"})
+			return
+		}
+		log.Error().Err(err).Int("project_id", project.ID).Str("name", name).Msg("Erreur lors de la suppression du secret")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to delete secret"})
+		return
+	}
+
+	log.Info().Int("project_id", project.ID).Str("name", name).Msg("Secret supprimé")
+	c.JSON(http.StatusOK, gin.H{
+		"message": "secret deleted successfully",
+		"name":    name,
+	})
+}
+
+// setupSecretRoutes configure les routes de gestion des secrets des projets
+func setupSecretRoutes(router *gin.RouterGroup, db *sql.DB, cipher *secrets.Cipher) {
+	handler := &SecretHandler{DB: db, cipher: cipher}
+	projectSecrets := router.Group("/api/projects/:id/secrets")
+	{
+		projectSecrets.GET("", handler.ListSecrets)           // Lister les secrets (sans valeurs)
+		projectSecrets.POST("", handler.SetSecret)            // Créer ou remplacer un secret
+		projectSecrets.DELETE("/:name", handler.DeleteSecret) // Supprimer un secret
+	}
+}
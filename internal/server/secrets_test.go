@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requestJSON(router *gin.Engine, method, path string, payload any) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req, _ := http.NewRequest(method, baseUrl+path, bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProjectSecretsEndpoints(t *testing.T) {
+	db, router, agent, build := setupRunnerTest(t)
+	path := fmt.Sprintf("/api/projects/%d/secrets", build.ProjectID)
+
+	w := requestJSON(router, "POST", path, SetSecretRequest{Name: "NPM_TOKEN", Value: "npm_s3cr3t"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "npm_s3cr3t")
+
+	w = requestJSON(router, "POST", path, SetSecretRequest{Name: "DEPLOY_KEY", Value: "first"})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = requestJSON(router, "POST", path, SetSecretRequest{Name: "DEPLOY_KEY", Value: "replaced"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// La liste ne contient que les noms
+	req, _ := http.NewRequest("GET", baseUrl+path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "npm_s3cr3t")
+	assert.NotContains(t, w.Body.String(), "replaced")
+	var response struct {
+		Secrets []database.ProjectSecret `json:"secrets"`
+		Count   int                      `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Equal(t, 2, response.Count)
+	assert.Equal(t, "DEPLOY_KEY", response.Secrets[0].Name)
+	assert.Equal(t, "NPM_TOKEN", response.Secrets[1].Name)
+
+	// La valeur est chiffrée en base
+	sealed, err := database.GetProjectSecretValues(db, build.ProjectID)
+	require.NoError(t, err)
+	assert.NotContains(t, string(sealed["NPM_TOKEN"]), "npm_s3cr3t")
+
+	// Le job transmis à l'agent contient les secrets déchiffrés
+	w = postAgent(router, agent, fmt.Sprintf("/api/agents/%d/lease", agent.ID), "application/json", &bytes.Buffer{})
+	require.Equal(t, http.StatusOK, w.Code)
+	var job builder.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, map[string]string{"NPM_TOKEN": "npm_s3cr3t", "DEPLOY_KEY": "replaced"}, job.Secrets)
+
+	for _, invalid := range []SetSecretRequest{{Name: "PATH", Value: "/tmp"}, {Name: "MY-TOKEN", Value: "x"}, {Value: "x"}} {
+		assert.Equal(t, http.StatusBadRequest, requestJSON(router, "POST", path, invalid).Code, invalid.Name)
+	}
+	assert.Equal(t, http.StatusNotFound, requestJSON(router, "POST", "/api/projects/999/secrets", SetSecretRequest{Name: "A", Value: "x"}).Code)
+
+	req, _ = http.NewRequest("DELETE", baseUrl+path+"/NPM_TOKEN", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("DELETE", baseUrl+path+"/NPM_TOKEN", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateProjectEnv(t *testing.T) {
+	db := setupProjectTestDB(t)
+	defer db.Close()
+
+	gin.SetMode(gin.TestMode)
+	router := SetupRouter(db, "")
+
+	w := requestJSON(router, "POST", "/api/projects", CreateProjectRequest{
+		Name: "tool", RepoURL: "https://github.com/user/tool.git",
+		Env: map[string]string{"CGO_ENABLED": "0", "GOPRIVATE": "github.com/acme/*"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response database.Project
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, map[string]string{"CGO_ENABLED": "0", "GOPRIVATE": "github.com/acme/*"}, response.Env)
+
+	stored, err := database.GetProjectByID(db, response.ID)
+	require.NoError(t, err)
+	assert.Equal(t, response.Env, stored.Env)
+
+	w = requestJSON(router, "POST", "/api/projects", CreateProjectRequest{
+		Name: "override", RepoURL: "https://github.com/user/tool.git",
+		Env: map[string]string{"GOCACHE": "/tmp"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid env")
+}
@@ -2,6 +2,9 @@ package server
 
 import (
 	"database/sql"
+	"forgeronvirtuel/gip/internal/builder"
+	"forgeronvirtuel/gip/internal/gocache"
+	"forgeronvirtuel/gip/internal/secrets"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -28,8 +31,15 @@ func (h *HealthHandler) Health(c *gin.Context) {
 	})
 }
 
-// SetupRouter crée et configure le router Gin avec toutes les routes
+// SetupRouter crée et configure le router Gin avec toutes les routes.
+// Sans pool de workers, les builds créés restent en attente dans la base.
+// Les secrets sont chiffrés avec une clé éphémère, le cache Go partagé est désactivé
+// et tout agent peut s'enregistrer.
 func SetupRouter(db *sql.DB, workspace string) *gin.Engine {
+	return setupRouter(db, workspace, nil, nil, builder.NewLogHub(), secrets.NewRandomCipher(), "")
+}
+
+func setupRouter(db *sql.DB, workspace string, cache *gocache.Cache, pool *builder.Pool, logs *builder.LogHub, cipher *secrets.Cipher, registrationToken string) *gin.Engine {
 	if workspace == "" {
 		workspace = "./workspace"
 	}
@@ -45,16 +55,32 @@ func SetupRouter(db *sql.DB, workspace string) *gin.Engine {
 	v1.GET("/health", healthHandler.Health)
 
 	setupProjectRoutes(v1, db)
-	setupBuildRoutes(v1, db, workspace)
-	setupAgentRoutes(v1, db)
+	setupSecretRoutes(v1, db, cipher)
+	setupCredentialRoutes(v1, db, cipher)
+	setupBuildRoutes(v1, db, workspace, pool, logs)
+	setupAgentRoutes(v1, db, registrationToken)
+	setupRunnerRoutes(v1, db, workspace, logs, cipher)
+	setupCacheRoutes(v1, cache)
+	setupToolchainRoutes(v1, workspace)
 
 	return router
 }
 
-func Start(port string, db *sql.DB, workspace string) {
+// Start démarre le pool de workers de build puis le serveur HTTP.
+// Les workers partagent le cache Go cache ; cipher chiffre les secrets des
+// projets avec la clé maître du serveur. Si registrationToken est défini, seuls
+// les agents qui le présentent peuvent s'enregistrer.
+func Start(port string, db *sql.DB, workspace string, cache *gocache.Cache, workers int, cipher *secrets.Cipher, registrationToken string) {
 	gin.SetMode(gin.ReleaseMode)
 
-	router := SetupRouter(db, workspace)
+	logs := builder.NewLogHub()
+	pool := builder.NewPool(db, workspace, cache, workers, logs, cipher)
+	if err := pool.Start(); err != nil {
+		log.Fatal().Err(err).Msg("Impossible de démarrer le pool de workers")
+	}
+	defer pool.Stop()
+
+	router := setupRouter(db, workspace, cache, pool, logs, cipher, registrationToken)
 
 	log.Info().Str("port", port).Msg("Serveur HTTP démarré")
 	if err := router.Run(":" + port); err != nil {
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"forgeronvirtuel/gip/internal/toolchain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+)
+
+// ToolchainHandler administre les toolchains Go installées dans le workspace
+type ToolchainHandler struct {
+	dir string
+}
+
+// ListToolchains retourne les toolchains installées, de la plus récente à la plus ancienne
+func (h *ToolchainHandler) ListToolchains(c *gin.Context) {
+	toolchains, err := toolchain.List(h.dir)
+	if err != nil {
+		log.Error().Err(err).Str("dir", h.dir).Msg("Erreur lors de la lecture des toolchains Go")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to list toolchains"})
+		return
+	}
+	c.JSON(http.StatusOK, toolchains)
+}
+
+// InstallToolchain installe la toolchain de l'archive envoyée (champ file), au
+// format d'une distribution de Go
+func (h *ToolchainHandler) InstallToolchain(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+		return
+	}
+	name := filepath.Base(file.Filename)
+	if !toolchain.IsArchive(name) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid toolchain archive",
+			"details": "expected a Go distribution archive (.tar.gz or .zip)",
+		})
+		return
+	}
+
+	// L'archive est extraite depuis un fichier temporaire, qui garde son extension
+	tmp, err := os.MkdirTemp("", "gip-toolchain-")
+	if err != nil {
+		log.Error().Err(err).Msg("Impossible de créer le répertoire temporaire de l'archive")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to install toolchain"})
+		return
+	}
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, name)
+	if err := c.SaveUploadedFile(file, archive); err != nil {
+		log.Error().Err(err).Str("archive", name).Msg("Erreur lors de l'enregistrement de l'archive")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unable to install toolchain"})
+		return
+	}
+
+	t, installed, err := toolchain.Install(h.dir, archive)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "invalid toolchain archive",
+			"details": err.Error(),
+		})
+		return
+	}
+	if !installed {
+		c.JSON(http.StatusOK, t)
+		return
+	}
+
+	log.Info().Str("version", t.Version).Str("archive", name).Msg("Toolchain Go installée par l'API")
+	c.JSON(http.StatusCreated, t)
+}
+
+// setupToolchainRoutes configure les routes d'administration des toolchains Go
+// du workspace
+func setupToolchainRoutes(router *gin.RouterGroup, workspace string) {
+	dir, err := filepath.Abs(toolchain.Dir(workspace))
+	if err != nil {
+		dir = toolchain.Dir(workspace)
+	}
+	handler := &ToolchainHandler{dir: dir}
+	admin := router.Group("/api/admin/toolchains")
+	{
+		admin.GET("", handler.ListToolchains)    // Toolchains installées
+		admin.POST("", handler.InstallToolchain) // Installer une archive
+	}
+}
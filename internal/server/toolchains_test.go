@@ -0,0 +1,90 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/toolchain"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// goArchive retourne une fausse distribution de Go de la plateforme courante, au format .tar.gz
+func goArchive(t *testing.T, version string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		"go/VERSION": version + "\n",
+		"go/bin/go":  "#!/bin/sh\necho " + version + "\n",
+		"go/pkg/tool/" + runtime.GOOS + "_" + runtime.GOARCH + "/compile": "",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestToolchainEndpoints(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("La commande go d'une toolchain Windows est go.exe")
+	}
+	db := setupTestDB(t)
+	defer db.Close()
+	gin.SetMode(gin.TestMode)
+	workspace := t.TempDir()
+	router := SetupRouter(db, workspace)
+
+	upload := func(name string, content []byte) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		form := multipart.NewWriter(body)
+		part, _ := form.CreateFormFile("file", name)
+		part.Write(content)
+		form.Close()
+		req, _ := http.NewRequest("POST", baseUrl+"/api/admin/toolchains", body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := requestJSON(router, "GET", "/api/admin/toolchains", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, "[]", w.Body.String())
+
+	w = upload("go1.22.3.linux-amd64.tar.gz", goArchive(t, "go1.22.3"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var installed toolchain.Toolchain
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &installed))
+	assert.Equal(t, "go1.22.3", installed.Version)
+	assert.Equal(t, filepath.Join(workspace, "toolchains", "go1.22.3"), installed.Root)
+
+	// Une version déjà installée n'est pas remplacée
+	w = upload("go1.22.3.tar.gz", goArchive(t, "go1.22.3"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = upload("go1.22.3.pkg", []byte("installer"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid toolchain archive")
+	w = upload("broken.tar.gz", []byte("not gzip"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = requestJSON(router, "GET", "/api/admin/toolchains", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var toolchains []toolchain.Toolchain
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &toolchains))
+	assert.Equal(t, []toolchain.Toolchain{installed}, toolchains)
+}
@@ -0,0 +1,247 @@
+package toolchain
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"go/version"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// goExe est le nom de la commande go d'une toolchain
+var goExe = "go"
+
+func init() {
+	if runtime.GOOS == "windows" {
+		goExe = "go.exe"
+	}
+}
+
+// hostTools est le répertoire des outils d'une toolchain de la plateforme
+// courante, pkg/tool/<os>_<arch> dans son GOROOT
+var hostTools = path.Join("pkg", "tool", runtime.GOOS+"_"+runtime.GOARCH)
+
+// IsArchive indique si le nom de fichier name est celui d'une archive qu'Install
+// sait lire
+func IsArchive(name string) bool {
+	return strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz") || strings.HasSuffix(name, ".zip")
+}
+
+// Install installe dans le répertoire dir la toolchain de l'archive archive,
+// au format d'une distribution de Go : un répertoire go/ racine, qui contient le
+// fichier VERSION. La toolchain doit être celle de la plateforme courante.
+// Retourne la toolchain, et false si sa version était déjà installée.
+func Install(dir, archive string) (*Toolchain, bool, error) {
+	if !IsArchive(archive) {
+		return nil, false, fmt.Errorf("%s: unsupported archive, expected .tar.gz or .zip", filepath.Base(archive))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, false, err
+	}
+
+	// L'archive est extraite dans un répertoire caché, ignoré par List, puis
+	// renommée une fois complète
+	tmp, err := os.MkdirTemp(dir, ".install-")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(tmp)
+
+	if strings.HasSuffix(archive, ".zip") {
+		err = extractZip(archive, tmp)
+	} else {
+		err = extractTarGz(archive, tmp)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", filepath.Base(archive), err)
+	}
+
+	root := filepath.Join(tmp, "go")
+	v, err := readVersion(root)
+	if err != nil {
+		return nil, false, fmt.Errorf("%s: %w", filepath.Base(archive), err)
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(hostTools))); err != nil {
+		return nil, false, fmt.Errorf("%s: %s is not a %s/%s toolchain", filepath.Base(archive), v, runtime.GOOS, runtime.GOARCH)
+	}
+	if _, err := os.Stat(filepath.Join(root, "bin", goExe)); err != nil {
+		return nil, false, fmt.Errorf("%s: bin/%s not found", filepath.Base(archive), goExe)
+	}
+
+	t := &Toolchain{Version: v, Root: filepath.Join(dir, v)}
+	if _, err := os.Stat(t.Root); err == nil {
+		return t, false, nil
+	}
+	if err := os.Rename(root, t.Root); err != nil {
+		return nil, false, err
+	}
+	return t, true, nil
+}
+
+// Seed installe dans le répertoire dir chaque archive du répertoire archives
+// dont la version n'est pas encore installée, et retourne les toolchains
+// installées. Une archive invalide n'empêche pas d'installer les suivantes.
+func Seed(dir, archives string) ([]Toolchain, error) {
+	entries, err := os.ReadDir(archives)
+	if err != nil {
+		return nil, err
+	}
+
+	installed := []Toolchain{}
+	var errs []error
+	for _, entry := range entries {
+		if entry.IsDir() || !IsArchive(entry.Name()) {
+			continue
+		}
+		t, isNew, err := Install(dir, filepath.Join(archives, entry.Name()))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if isNew {
+			log.Info().Str("version", t.Version).Str("archive", entry.Name()).Msg("Toolchain Go installée")
+			installed = append(installed, *t)
+		}
+	}
+	return installed, errors.Join(errs...)
+}
+
+// readVersion lit la version d'une toolchain, la première ligne du fichier
+// VERSION de son GOROOT
+func readVersion(root string) (string, error) {
+	f, err := os.Open(filepath.Join(root, "VERSION"))
+	if err != nil {
+		return "", errors.New("go/VERSION not found, not a Go distribution")
+	}
+	defer f.Close()
+
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	v := strings.TrimSpace(line)
+	if !version.IsValid(v) {
+		return "", fmt.Errorf("invalid Go version %q in go/VERSION", v)
+	}
+	return v, nil
+}
+
+// extractTarGz extrait une archive .tar.gz dans le répertoire dest
+func extractTarGz(archive, dest string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = extractDir(root, header.Name)
+		case tar.TypeReg:
+			err = extractFile(root, header.Name, header.FileInfo().Mode(), tr)
+		case tar.TypeSymlink:
+			err = extractSymlink(root, header.Name, header.Linkname)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip extrait une archive .zip dans le répertoire dest
+func extractZip(archive, dest string) error {
+	r, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	root, err := os.OpenRoot(dest)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	for _, file := range r.File {
+		if file.FileInfo().IsDir() {
+			if err := extractDir(root, file.Name); err != nil {
+				return err
+			}
+			continue
+		}
+		content, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = extractFile(root, file.Name, file.Mode(), content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractDir crée le répertoire name de l'archive dans root, qui refuse les
+// chemins qui en sortent
+func extractDir(root *os.Root, name string) error {
+	return root.MkdirAll(filepath.FromSlash(name), 0o755)
+}
+
+// extractFile écrit le fichier name de l'archive dans root
+func extractFile(root *os.Root, name string, mode fs.FileMode, content io.Reader) error {
+	name = filepath.FromSlash(name)
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := root.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0o200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// extractSymlink crée le lien symbolique name de l'archive dans root, s'il
+// désigne un fichier de l'archive
+func extractSymlink(root *os.Root, name, target string) error {
+	name = filepath.FromSlash(name)
+	if filepath.IsAbs(target) || !filepath.IsLocal(filepath.Join(filepath.Dir(name), target)) {
+		return fmt.Errorf("symbolic link %s points outside the archive", name)
+	}
+	if err := root.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return root.Symlink(target, name)
+}
@@ -0,0 +1,168 @@
+// Package toolchain gère les toolchains Go installées dans un workspace, dans
+// workspace/toolchains/<version> (go1.22.3...), et choisit celle d'un build.
+//
+// Les toolchains sont installées à partir des archives des distributions de Go
+// (go1.22.3.linux-amd64.tar.gz, go1.22.3.windows-amd64.zip) : le serveur ne les
+// télécharge pas. Un projet épingle une version, ou suit la ligne go ou toolchain
+// de son go.mod ; sans réglage, les builds utilisent la commande go du PATH.
+package toolchain
+
+import (
+	"errors"
+	"fmt"
+	"go/version"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// GoMod est le réglage d'un projet qui suit la ligne go ou toolchain de son go.mod
+const GoMod = "go.mod"
+
+// Toolchain est une toolchain Go installée
+type Toolchain struct {
+	Version string `json:"version"` // go1.22.3
+	Root    string `json:"root"`    // GOROOT
+}
+
+// Bin retourne le répertoire de la commande go de la toolchain
+func (t *Toolchain) Bin() string {
+	return filepath.Join(t.Root, "bin")
+}
+
+// Dir retourne le répertoire des toolchains d'un workspace
+func Dir(workspace string) string {
+	return filepath.Join(workspace, "toolchains")
+}
+
+// ParseSetting valide le réglage de toolchain d'un projet et retourne sa forme
+// normalisée : vide pour la commande go du PATH, GoMod, ou une version de Go
+// (1.22, 1.22.3, go1.23rc1), retournée avec le préfixe go.
+func ParseSetting(setting string) (string, error) {
+	setting = strings.TrimSpace(setting)
+	if setting == "" || setting == GoMod {
+		return setting, nil
+	}
+	v := "go" + strings.TrimPrefix(setting, "go")
+	if !version.IsValid(v) {
+		return "", fmt.Errorf("%q is neither %q nor a Go version such as 1.22 or 1.22.3", setting, GoMod)
+	}
+	return v, nil
+}
+
+// Request est la version de Go demandée pour un build
+type Request struct {
+	Version string // go1.22.3, ou une version du langage (go1.22)
+	// La version doit être installée telle quelle ; sinon, la dernière version
+	// installée de la même version du langage, au moins égale, convient
+	Exact  bool
+	Source string // Origine de la demande, pour les messages : "the project", "go.mod"
+}
+
+// String décrit la demande
+func (r Request) String() string {
+	if r.Exact {
+		return r.Version
+	}
+	return r.Version + " or a later " + version.Lang(r.Version) + " release"
+}
+
+// Resolve retourne la version demandée par le réglage setting d'un projet (voir
+// ParseSetting), nil pour la commande go du PATH. Pour GoMod, la version est lue
+// dans le fichier go.mod goMod : la ligne toolchain désigne une version exacte,
+// la ligne go une version minimale, comme pour la commande go.
+func Resolve(setting, goMod string) (*Request, error) {
+	setting, err := ParseSetting(setting)
+	if err != nil {
+		return nil, err
+	}
+	switch setting {
+	case "":
+		return nil, nil
+	case GoMod:
+		return readGoMod(goMod)
+	}
+	// Une version du langage (go1.22) accepte chacune de ses versions
+	return &Request{Version: setting, Exact: version.Lang(setting) != setting, Source: "the project"}, nil
+}
+
+// readGoMod retourne la version demandée par les lignes go et toolchain d'un go.mod
+func readGoMod(path string) (*Request, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.New("go.mod not found, cannot select the Go toolchain")
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := modfile.Parse(path, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid go.mod: %v", err)
+	}
+	if file.Go == nil {
+		return nil, errors.New("go.mod has no go line, cannot select the Go toolchain")
+	}
+
+	goVersion := "go" + file.Go.Version
+	// Une ligne toolchain plus ancienne que la ligne go est ignorée par la commande go
+	if file.Toolchain != nil && file.Toolchain.Name != "default" && version.Compare(file.Toolchain.Name, goVersion) >= 0 {
+		return &Request{Version: file.Toolchain.Name, Exact: true, Source: GoMod}, nil
+	}
+	return &Request{Version: goVersion, Source: GoMod}, nil
+}
+
+// List retourne les toolchains installées dans le répertoire dir, de la plus
+// récente à la plus ancienne
+func List(dir string) ([]Toolchain, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Toolchain{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	toolchains := []Toolchain{}
+	for _, entry := range entries {
+		// Les installations en cours sont dans des répertoires cachés
+		if !version.IsValid(entry.Name()) {
+			continue
+		}
+		root := filepath.Join(dir, entry.Name())
+		if _, err := os.Stat(filepath.Join(root, "bin", goExe)); err != nil {
+			continue
+		}
+		toolchains = append(toolchains, Toolchain{Version: entry.Name(), Root: root})
+	}
+	slices.SortFunc(toolchains, func(a, b Toolchain) int {
+		return version.Compare(b.Version, a.Version)
+	})
+	return toolchains, nil
+}
+
+// Select retourne la toolchain de dir qui satisfait la demande request : la
+// version exacte, ou la plus récente de la même version du langage
+func Select(dir string, request Request) (*Toolchain, error) {
+	toolchains, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range toolchains {
+		if t.Version == request.Version ||
+			!request.Exact && version.Lang(t.Version) == version.Lang(request.Version) && version.Compare(t.Version, request.Version) >= 0 {
+			return &t, nil
+		}
+	}
+
+	installed := make([]string, len(toolchains))
+	for i, t := range toolchains {
+		installed[i] = t.Version
+	}
+	if len(installed) == 0 {
+		installed = append(installed, "none")
+	}
+	return nil, fmt.Errorf("Go toolchain %s required by %s is not installed (installed: %s)", request, request.Source, strings.Join(installed, ", "))
+}
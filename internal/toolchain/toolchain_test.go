@@ -0,0 +1,207 @@
+package toolchain
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// distribution retourne les fichiers d'une fausse distribution de Go
+func distribution(v, platform string) map[string]string {
+	return map[string]string{
+		"go/VERSION":                           v + "\ntime 2025-01-01T00:00:00Z\n",
+		"go/bin/" + goExe:                      "#!/bin/sh\necho " + v + "\n",
+		"go/pkg/tool/" + platform + "/compile": "",
+		"go/src/runtime/extern.go":             "package runtime\n",
+	}
+}
+
+// hostPlatform est la plateforme des toolchains installables
+var hostPlatform = runtime.GOOS + "_" + runtime.GOARCH
+
+// writeTarGz écrit une archive .tar.gz des fichiers files dans dir
+func writeTarGz(t *testing.T, dir, name string, files map[string]string) string {
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for file, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: file, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return path
+}
+
+// writeZip écrit une archive .zip des fichiers files dans dir
+func writeZip(t *testing.T, dir, name string, files map[string]string) string {
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for file, content := range files {
+		w, err := zw.Create(file)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return path
+}
+
+func TestParseSetting(t *testing.T) {
+	tests := []struct {
+		setting  string
+		expected string
+		valid    bool
+	}{
+		{"", "", true},
+		{"go.mod", "go.mod", true},
+		{"1.22", "go1.22", true},
+		{" 1.22.3 ", "go1.22.3", true},
+		{"go1.23rc1", "go1.23rc1", true},
+		{"latest", "", false},
+		{"1.22.x", "", false},
+	}
+	for _, tt := range tests {
+		setting, err := ParseSetting(tt.setting)
+		if !tt.valid {
+			assert.Error(t, err, tt.setting)
+			continue
+		}
+		require.NoError(t, err, tt.setting)
+		assert.Equal(t, tt.expected, setting)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	goMod := filepath.Join(dir, "go.mod")
+
+	request, err := Resolve("", goMod)
+	require.NoError(t, err)
+	assert.Nil(t, request)
+
+	request, err = Resolve("1.22", goMod)
+	require.NoError(t, err)
+	assert.Equal(t, &Request{Version: "go1.22", Source: "the project"}, request)
+	request, err = Resolve("1.22.3", goMod)
+	require.NoError(t, err)
+	assert.Equal(t, &Request{Version: "go1.22.3", Exact: true, Source: "the project"}, request)
+
+	_, err = Resolve(GoMod, goMod)
+	assert.EqualError(t, err, "go.mod not found, cannot select the Go toolchain")
+
+	tests := []struct {
+		goMod    string
+		expected Request
+	}{
+		{"module example.com/a\n\ngo 1.21\n", Request{Version: "go1.21", Source: GoMod}},
+		{"module example.com/a\n\ngo 1.22.1\n\ntoolchain go1.23.4\n", Request{Version: "go1.23.4", Exact: true, Source: GoMod}},
+		// La ligne toolchain ne peut pas demander une version plus ancienne que la ligne go
+		{"module example.com/a\n\ngo 1.22.1\n\ntoolchain go1.21.0\n", Request{Version: "go1.22.1", Source: GoMod}},
+		{"module example.com/a\n\ngo 1.22.1\n\ntoolchain default\n", Request{Version: "go1.22.1", Source: GoMod}},
+	}
+	for _, tt := range tests {
+		require.NoError(t, os.WriteFile(goMod, []byte(tt.goMod), 0o644))
+		request, err := Resolve(GoMod, goMod)
+		require.NoError(t, err, tt.goMod)
+		assert.Equal(t, tt.expected, *request, tt.goMod)
+	}
+
+	require.NoError(t, os.WriteFile(goMod, []byte("module example.com/a\n"), 0o644))
+	_, err = Resolve(GoMod, goMod)
+	assert.EqualError(t, err, "go.mod has no go line, cannot select the Go toolchain")
+}
+
+func TestInstallAndSelect(t *testing.T) {
+	archives := t.TempDir()
+	dir := filepath.Join(t.TempDir(), "toolchains")
+
+	writeTarGz(t, archives, "go1.22.1.tar.gz", distribution("go1.22.1", hostPlatform))
+	writeZip(t, archives, "go1.22.5.zip", distribution("go1.22.5", hostPlatform))
+	writeTarGz(t, archives, "go1.23.0.tar.gz", distribution("go1.23.0", hostPlatform))
+	writeTarGz(t, archives, "go1.24.0.other.tar.gz", distribution("go1.24.0", "plan9_mips"))
+	require.NoError(t, os.WriteFile(filepath.Join(archives, "README"), []byte("not an archive"), 0o644))
+
+	installed, err := Seed(dir, archives)
+	assert.EqualError(t, err, "go1.24.0.other.tar.gz: go1.24.0 is not a "+runtime.GOOS+"/"+runtime.GOARCH+" toolchain")
+	require.Len(t, installed, 3)
+
+	toolchains, err := List(dir)
+	require.NoError(t, err)
+	versions := []string{}
+	for _, tc := range toolchains {
+		versions = append(versions, tc.Version)
+	}
+	assert.Equal(t, []string{"go1.23.0", "go1.22.5", "go1.22.1"}, versions)
+	content, err := os.ReadFile(filepath.Join(toolchains[0].Bin(), goExe))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "echo go1.23.0")
+	info, err := os.Stat(filepath.Join(toolchains[0].Bin(), goExe))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&0o100, "go doit être exécutable")
+
+	// Une version déjà installée n'est pas remplacée
+	installed, err = Seed(dir, archives)
+	require.Error(t, err)
+	assert.Empty(t, installed)
+	tc, isNew, err := Install(dir, filepath.Join(archives, "go1.22.1.tar.gz"))
+	require.NoError(t, err)
+	assert.False(t, isNew)
+	assert.Equal(t, filepath.Join(dir, "go1.22.1"), tc.Root)
+
+	tests := []struct {
+		request  Request
+		expected string
+	}{
+		{Request{Version: "go1.22.1", Exact: true}, "go1.22.1"},
+		{Request{Version: "go1.22"}, "go1.22.5"},
+		{Request{Version: "go1.22.2"}, "go1.22.5"},
+		{Request{Version: "go1.23"}, "go1.23.0"},
+	}
+	for _, tt := range tests {
+		tc, err := Select(dir, tt.request)
+		require.NoError(t, err, tt.request)
+		assert.Equal(t, tt.expected, tc.Version, tt.request)
+	}
+
+	_, err = Select(dir, Request{Version: "go1.22.3", Exact: true, Source: GoMod})
+	assert.EqualError(t, err, "Go toolchain go1.22.3 required by go.mod is not installed (installed: go1.23.0, go1.22.5, go1.22.1)")
+	_, err = Select(dir, Request{Version: "go1.21", Source: "the project"})
+	assert.EqualError(t, err, "Go toolchain go1.21 or a later go1.21 release required by the project is not installed (installed: go1.23.0, go1.22.5, go1.22.1)")
+	_, err = Select(t.TempDir(), Request{Version: "go1.23.6", Exact: true, Source: GoMod})
+	assert.EqualError(t, err, "Go toolchain go1.23.6 required by go.mod is not installed (installed: none)")
+}
+
+func TestInstallInvalidArchive(t *testing.T) {
+	archives := t.TempDir()
+	dir := t.TempDir()
+
+	_, _, err := Install(dir, writeTarGz(t, archives, "empty.tar.gz", map[string]string{"README": ""}))
+	assert.EqualError(t, err, "empty.tar.gz: go/VERSION not found, not a Go distribution")
+
+	_, _, err = Install(dir, writeTarGz(t, archives, "escape.tar.gz", map[string]string{"../outside": ""}))
+	require.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "outside"))
+
+	_, _, err = Install(dir, filepath.Join(archives, "go1.22.1.pkg"))
+	assert.EqualError(t, err, "go1.22.1.pkg: unsupported archive, expected .tar.gz or .zip")
+
+	// Les installations qui ont échoué ne laissent rien
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
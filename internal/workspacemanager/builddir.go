@@ -0,0 +1,170 @@
+package workspacemanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ArtifactsDir est le répertoire des artefacts des builds terminés, dans le workspace
+const ArtifactsDir = "artifacts"
+
+// ErrBuildDirInUse indique que le répertoire d'un build est déjà réservé
+var ErrBuildDirInUse = errors.New("build directory is already in use")
+
+// buildDirs liste les répertoires de build réservés par ce processus
+var buildDirs = struct {
+	sync.Mutex
+	inUse map[string]bool
+}{inUse: make(map[string]bool)}
+
+// BuildDir est le répertoire de travail d'un build, réservé par AllocateBuildDir
+// jusqu'à Release. Les builds d'un même projet ont chacun le leur et peuvent donc
+// s'exécuter en même temps.
+type BuildDir struct {
+	Path string
+}
+
+// SourceDir retourne le répertoire du clone du dépôt : <Path>/src
+func (d *BuildDir) SourceDir() string {
+	return filepath.Join(d.Path, "src")
+}
+
+// HomeDir retourne le HOME des commandes du build : <Path>/home. Il contient les
+// fichiers d'identifiants lus par go et git, hors du dépôt.
+func (d *BuildDir) HomeDir() string {
+	return filepath.Join(d.Path, "home")
+}
+
+// GoCacheDir retourne le cache de compilation Go propre au build : <Path>/gocache.
+// Un build hermétique l'utilise à la place du cache partagé, que go mod verify ne
+// vérifie pas.
+func (d *BuildDir) GoCacheDir() string {
+	return filepath.Join(d.Path, "gocache")
+}
+
+// BuildDirPath retourne le répertoire de travail d'un build : workspace/project-<id>/build-<bid>
+func BuildDirPath(workspace string, projectID, buildID int) string {
+	return filepath.Join(workspace, fmt.Sprintf("project-%d", projectID), fmt.Sprintf("build-%d", buildID))
+}
+
+// AllocateBuildDir réserve le répertoire de travail d'un build. Les restes d'une
+// exécution précédente du build, interrompue, sont supprimés : au retour, le
+// répertoire ne contient que HomeDir, accessible au seul utilisateur du
+// processus ; SourceDir est créé par le clone du dépôt.
+// Retourne ErrBuildDirInUse si le build s'exécute déjà dans ce processus.
+func AllocateBuildDir(workspace string, projectID, buildID int) (*BuildDir, error) {
+	absWorkspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, err
+	}
+	path := BuildDirPath(absWorkspace, projectID, buildID)
+
+	buildDirs.Lock()
+	defer buildDirs.Unlock()
+	if buildDirs.inUse[path] {
+		return nil, ErrBuildDirInUse
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return nil, err
+	}
+	dir := &BuildDir{Path: path}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Mkdir(dir.HomeDir(), 0o700); err != nil {
+		return nil, err
+	}
+
+	buildDirs.inUse[path] = true
+	return dir, nil
+}
+
+// Release supprime le répertoire du build et libère sa réservation. Le répertoire
+// du projet est supprimé s'il ne contient plus d'autre build.
+func (d *BuildDir) Release() error {
+	err := os.RemoveAll(d.Path)
+	// Échoue tant qu'un autre build du projet utilise le répertoire
+	os.Remove(filepath.Dir(d.Path))
+
+	buildDirs.Lock()
+	delete(buildDirs.inUse, d.Path)
+	buildDirs.Unlock()
+	return err
+}
+
+// BuildArtifactsDir retourne le répertoire des artefacts d'un build : workspace/artifacts/build-<bid>
+func BuildArtifactsDir(workspace string, buildID int) string {
+	return filepath.Join(workspace, ArtifactsDir, fmt.Sprintf("build-%d", buildID))
+}
+
+// StoreArtifact déplace un fichier produit par un build dans le répertoire des
+// artefacts du build, où il reste après la suppression du répertoire de travail,
+// et retourne son nouveau chemin
+func StoreArtifact(workspace string, buildID int, path string) (string, error) {
+	absWorkspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", err
+	}
+
+	dir := BuildArtifactsDir(absWorkspace, buildID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	dest := filepath.Join(dir, filepath.Base(path))
+	if err := os.Rename(path, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// RemoveArtifacts supprime les artefacts d'un build, par exemple une fois qu'un
+// agent les a envoyés au control plane
+func RemoveArtifacts(workspace string, buildID int) error {
+	absWorkspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(BuildArtifactsDir(absWorkspace, buildID))
+}
+
+// Cleanup supprime les répertoires de build laissés par un arrêt brutal, sauf
+// ceux réservés par ce processus, et retourne leur nombre. Les répertoires des
+// projets vides sont aussi supprimés.
+func Cleanup(workspace string) (int, error) {
+	absWorkspace, err := filepath.Abs(workspace)
+	if err != nil {
+		return 0, err
+	}
+	projects, err := filepath.Glob(filepath.Join(absWorkspace, "project-*"))
+	if err != nil {
+		return 0, err
+	}
+
+	buildDirs.Lock()
+	defer buildDirs.Unlock()
+
+	removed := 0
+	for _, project := range projects {
+		entries, err := os.ReadDir(project)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(project, entry.Name())
+			if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "build-") || buildDirs.inUse[path] {
+				continue
+			}
+			if err := os.RemoveAll(path); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		os.Remove(project)
+	}
+	return removed, nil
+}
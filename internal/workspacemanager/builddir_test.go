@@ -0,0 +1,92 @@
+package workspacemanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateBuildDir(t *testing.T) {
+	workspace := t.TempDir()
+
+	// Restes d'une exécution interrompue du build
+	stale := BuildDirPath(workspace, 1, 7)
+	require.NoError(t, os.MkdirAll(filepath.Join(stale, "out"), 0o755))
+
+	first, err := AllocateBuildDir(workspace, 1, 7)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(workspace, "project-1", "build-7"), first.Path)
+	assert.NoDirExists(t, filepath.Join(first.Path, "out"))
+	assert.NoDirExists(t, first.SourceDir())
+	info, err := os.Stat(first.HomeDir())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	_, err = AllocateBuildDir(workspace, 1, 7)
+	assert.ErrorIs(t, err, ErrBuildDirInUse)
+
+	// Un autre build du même projet a son propre répertoire
+	second, err := AllocateBuildDir(workspace, 1, 8)
+	require.NoError(t, err)
+
+	require.NoError(t, first.Release())
+	assert.NoDirExists(t, first.Path)
+	assert.DirExists(t, second.Path, "Le build en cours n'est pas touché")
+
+	require.NoError(t, second.Release())
+	assert.NoDirExists(t, filepath.Join(workspace, "project-1"), "Le répertoire du projet vide est supprimé")
+
+	again, err := AllocateBuildDir(workspace, 1, 7)
+	require.NoError(t, err, "Le répertoire libéré peut être réservé à nouveau")
+	require.NoError(t, again.Release())
+}
+
+func TestStoreArtifact(t *testing.T) {
+	workspace := t.TempDir()
+	dir, err := AllocateBuildDir(workspace, 2, 3)
+	require.NoError(t, err)
+
+	binary := filepath.Join(dir.Path, "out", "api-3")
+	require.NoError(t, os.MkdirAll(filepath.Dir(binary), 0o755))
+	require.NoError(t, os.WriteFile(binary, []byte("binary"), 0o755))
+
+	path, err := StoreArtifact(workspace, 3, binary)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(workspace, "artifacts", "build-3", "api-3"), path)
+	require.NoError(t, dir.Release())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(content), "L'artefact survit à la suppression du répertoire du build")
+
+	require.NoError(t, RemoveArtifacts(workspace, 3))
+	assert.NoDirExists(t, filepath.Dir(path))
+}
+
+func TestCleanup(t *testing.T) {
+	workspace := t.TempDir()
+
+	running, err := AllocateBuildDir(workspace, 1, 1)
+	require.NoError(t, err)
+	defer running.Release()
+	require.NoError(t, os.MkdirAll(running.Path, 0o755))
+
+	for _, dir := range []string{
+		BuildDirPath(workspace, 1, 2),
+		BuildDirPath(workspace, 2, 3),
+		filepath.Join(workspace, "project-3", ".git"), // Ancienne structure, conservée
+	} {
+		require.NoError(t, os.MkdirAll(dir, 0o755))
+	}
+
+	removed, err := Cleanup(workspace)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.DirExists(t, running.Path)
+	assert.NoDirExists(t, BuildDirPath(workspace, 1, 2))
+	assert.NoDirExists(t, filepath.Join(workspace, "project-2"))
+	assert.DirExists(t, filepath.Join(workspace, "project-3", ".git"))
+}
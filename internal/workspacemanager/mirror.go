@@ -0,0 +1,97 @@
+package workspacemanager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+)
+
+// MirrorsDir est le répertoire des miroirs des dépôts, dans le workspace
+const MirrorsDir = "mirrors"
+
+// mirrorRefSpec recopie toutes les références du dépôt distant, comme `git clone --mirror`
+const mirrorRefSpec = config.RefSpec("+refs/*:refs/*")
+
+// mirrorLocks sérialise les mises à jour de chaque miroir, par répertoire
+var mirrorLocks sync.Map
+
+// MirrorDir retourne le répertoire du miroir nu du dépôt url dans workspace.
+// Un clone superficiel (depth > 0) a son propre miroir, pour ne pas tronquer
+// l'historique du miroir complet.
+func MirrorDir(workspace, url string, depth int) string {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:8])
+	if depth > 0 {
+		name += fmt.Sprintf("-depth%d", depth)
+	}
+	return filepath.Join(workspace, MirrorsDir, name+".git")
+}
+
+// UpdateMirror crée le miroir nu du dépôt url dans workspace, ou le met à jour
+// par un fetch de toutes ses références, et retourne son répertoire.
+// depth > 0 limite l'historique récupéré au nombre de commits indiqué depuis
+// chaque référence ; il n'est respecté que par les transports réseau (http,
+// ssh, git), les dépôts locaux étant toujours copiés en entier.
+// auth authentifie l'accès à un dépôt privé, nil pour un dépôt public.
+// Un miroir qui ne peut pas être ouvert, comme après un premier clone interrompu,
+// est recréé.
+func UpdateMirror(ctx context.Context, workspace, url string, depth int, auth transport.AuthMethod) (string, error) {
+	dir := MirrorDir(workspace, url, depth)
+	lock, _ := mirrorLocks.LoadOrStore(dir, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	repo, err := git.PlainOpen(dir)
+	if err == nil {
+		// Un fetch ne fait qu'ajouter des objets : les builds qui lisent déjà le
+		// miroir ne sont pas perturbés
+		err = repo.FetchContext(ctx, &git.FetchOptions{
+			RemoteName: git.DefaultRemoteName,
+			RefSpecs:   []config.RefSpec{mirrorRefSpec},
+			Auth:       auth,
+			Depth:      depth,
+			Tags:       git.AllTags,
+			Force:      true,
+			Prune:      true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return "", err
+		}
+		return dir, nil
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	_, err = git.PlainCloneContext(ctx, dir, &git.CloneOptions{
+		URL:    url,
+		Mirror: true,
+		Depth:  depth,
+		Auth:   auth,
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return dir, nil
+}
+
+// CloneFromMirror clone le miroir mirrorDir dans dest, sans extraire de fichiers.
+// Le clone partage les objets du miroir au lieu de les copier : ses branches sont
+// sous refs/remotes/origin, comme pour un clone du dépôt distant.
+func CloneFromMirror(ctx context.Context, mirrorDir, dest string) (*git.Repository, error) {
+	return git.PlainCloneContext(ctx, dest, &git.CloneOptions{
+		URL:        mirrorDir,
+		Shared:     true,
+		NoCheckout: true,
+	})
+}
@@ -0,0 +1,158 @@
+package workspacemanager
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitCmd exécute une commande git dans dir et retourne sa sortie
+func gitCmd(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Ada", "GIT_AUTHOR_EMAIL=ada@example.com",
+		"GIT_COMMITTER_NAME=Ada", "GIT_COMMITTER_EMAIL=ada@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %v: %s", args, output)
+	return strings.TrimSpace(string(output))
+}
+
+// commitFile écrit un fichier et le commite, puis retourne le SHA du commit
+func commitFile(t *testing.T, dir, name, content, message string) string {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	gitCmd(t, dir, "add", name)
+	gitCmd(t, dir, "commit", "-m", message)
+	return gitCmd(t, dir, "rev-parse", "HEAD")
+}
+
+func TestMirrorDir(t *testing.T) {
+	full := MirrorDir("/ws", "https://example.com/a.git", 0)
+	assert.Equal(t, "/ws/mirrors", filepath.Dir(full))
+	assert.True(t, strings.HasSuffix(full, ".git"))
+	assert.Equal(t, full, MirrorDir("/ws", "https://example.com/a.git", 0))
+	assert.NotEqual(t, full, MirrorDir("/ws", "https://example.com/b.git", 0))
+	assert.Equal(t, strings.TrimSuffix(full, ".git")+"-depth1.git", MirrorDir("/ws", "https://example.com/a.git", 1))
+}
+
+func TestUpdateMirror(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "-b", "master")
+	first := commitFile(t, origin, "version.txt", "1", "Version 1")
+
+	dir, err := UpdateMirror(ctx, workspace, origin, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, MirrorDir(workspace, origin, 0), dir)
+	assert.FileExists(t, filepath.Join(dir, "HEAD"))
+
+	// Le clone lit ses objets dans le miroir
+	clone := filepath.Join(t.TempDir(), "clone")
+	repo, err := CloneFromMirror(ctx, dir, clone)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(clone, ".git", "objects", "info", "alternates"))
+	hash, err := repo.ResolveRevision("refs/remotes/origin/master")
+	require.NoError(t, err)
+	assert.Equal(t, first, hash.String())
+
+	// Les nouveaux commits, branches et tags sont récupérés par un fetch, les
+	// branches supprimées disparaissent du miroir
+	gitCmd(t, origin, "branch", "old")
+	_, err = UpdateMirror(ctx, workspace, origin, 0, nil)
+	require.NoError(t, err)
+	gitCmd(t, origin, "branch", "-D", "old")
+	second := commitFile(t, origin, "version.txt", "2", "Version 2")
+	gitCmd(t, origin, "tag", "v2")
+
+	_, err = UpdateMirror(ctx, workspace, origin, 0, nil)
+	require.NoError(t, err)
+	_, err = UpdateMirror(ctx, workspace, origin, 0, nil)
+	require.NoError(t, err, "un miroir à jour n'est pas une erreur")
+
+	mirror, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	for ref, want := range map[string]string{"refs/heads/master": second, "refs/tags/v2": second} {
+		hash, err := mirror.ResolveRevision(plumbing.Revision(ref))
+		require.NoError(t, err, ref)
+		assert.Equal(t, want, hash.String(), ref)
+	}
+	_, err = mirror.Reference("refs/heads/old", false)
+	assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+
+	repo, err = CloneFromMirror(ctx, dir, filepath.Join(t.TempDir(), "clone"))
+	require.NoError(t, err)
+	hash, err = repo.ResolveRevision("refs/remotes/origin/master")
+	require.NoError(t, err)
+	assert.Equal(t, second, hash.String())
+}
+
+func TestUpdateMirrorRecreatesBrokenMirror(t *testing.T) {
+	workspace := t.TempDir()
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "-b", "master")
+	sha := commitFile(t, origin, "version.txt", "1", "Version 1")
+
+	// Premier clone interrompu
+	dir := MirrorDir(workspace, origin, 0)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "objects"), 0o755))
+
+	_, err := UpdateMirror(context.Background(), workspace, origin, 0, nil)
+	require.NoError(t, err)
+	mirror, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	hash, err := mirror.ResolveRevision("refs/heads/master")
+	require.NoError(t, err)
+	assert.Equal(t, sha, hash.String())
+}
+
+func TestUpdateMirrorError(t *testing.T) {
+	workspace := t.TempDir()
+	url := filepath.Join(t.TempDir(), "missing")
+
+	_, err := UpdateMirror(context.Background(), workspace, url, 0, nil)
+	assert.Error(t, err)
+	assert.NoDirExists(t, MirrorDir(workspace, url, 0), "un clone en échec ne laisse pas de miroir")
+}
+
+func TestUpdateMirrorConcurrent(t *testing.T) {
+	ctx := context.Background()
+	workspace := t.TempDir()
+	origin := t.TempDir()
+	gitCmd(t, origin, "init", "-b", "master")
+	sha := commitFile(t, origin, "version.txt", "1", "Version 1")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dir, err := UpdateMirror(ctx, workspace, origin, 0, nil)
+			if err == nil {
+				_, err = CloneFromMirror(ctx, dir, filepath.Join(workspace, "build", string(rune('a'+i))))
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	mirror, err := git.PlainOpen(MirrorDir(workspace, origin, 0))
+	require.NoError(t, err)
+	hash, err := mirror.ResolveRevision("refs/heads/master")
+	require.NoError(t, err)
+	assert.Equal(t, sha, hash.String())
+}
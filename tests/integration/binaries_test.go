@@ -0,0 +1,66 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildMultipleBinaries compile un binaire configuré et les packages main
+// découverts d'un dépôt sans cmd/main.go, pour deux plateformes
+func TestBuildMultipleBinaries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":               "module example.com/multi\n\ngo 1.21\n",
+		"cmd/server/main.go":   "package main\n\nfunc main() {}\n",
+		"cmd/worker/main.go":   "package main\n\nfunc main() {}\n",
+		"internal/lib/lib.go":  "package lib\n",
+		"tools/migrate/mig.go": "package main\n\nfunc main() {}\n",
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":              "multi-test",
+		"repo_url":          repoDir,
+		"branch":            "master",
+		"platforms":         []string{"linux/amd64", "linux/arm64"},
+		"binaries":          []map[string]string{{"package": "tools/migrate", "name": "db-migrate"}},
+		"discover_binaries": true,
+	}, http.StatusCreated)
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	buildID := int(build["id"].(float64))
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/artifacts", baseURL, buildID))
+	require.NoError(t, err)
+	var artifacts []map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&artifacts))
+	resp.Body.Close()
+
+	var names []string
+	for _, artifact := range artifacts {
+		names = append(names, artifact["name"].(string))
+	}
+	assert.ElementsMatch(t, []string{
+		fmt.Sprintf("db-migrate-%d-linux-amd64", buildID),
+		fmt.Sprintf("server-%d-linux-amd64", buildID),
+		fmt.Sprintf("worker-%d-linux-amd64", buildID),
+		fmt.Sprintf("db-migrate-%d-linux-arm64", buildID),
+		fmt.Sprintf("server-%d-linux-arm64", buildID),
+		fmt.Sprintf("worker-%d-linux-arm64", buildID),
+	}, names)
+
+	resp, err = http.Get(fmt.Sprintf("%s/api/builds/%d/download?binary=worker&platform=linux/arm64", baseURL, buildID))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), fmt.Sprintf("worker-%d-linux-arm64", buildID))
+}
@@ -3,6 +3,7 @@ package integration
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -47,18 +48,22 @@ func TestIntegrationBuildAndDownload(t *testing.T) {
 	require.NoError(t, err)
 	defer resp.Body.Close()
 
-	// Le build peut échouer si le timeout est court ou si le repo n'a pas cmd/main.go
-	// On vérifie juste que l'endpoint répond
-	assert.True(t, resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusBadRequest)
+	// Le build est mis en file d'attente puis exécuté par un worker
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	var buildResult map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&buildResult)
+	buildID := int(buildResult["id"].(float64))
+	assert.Equal(t, "pending", buildResult["status"])
 
-	if resp.StatusCode == http.StatusCreated {
-		var buildResult map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&buildResult)
+	t.Logf("Build created with ID: %d", buildID)
 
-		buildID := int(buildResult["build_id"].(float64))
-		downloadURL := buildResult["download_url"].(string)
+	// Le build peut échouer si le timeout est court ou si le repo n'a pas cmd/main.go
+	// On vérifie juste qu'il se termine
+	build := waitForBuild(t, buildID)
 
-		t.Logf("Build created with ID: %d", buildID)
+	if build["status"] == "success" {
+		downloadURL := fmt.Sprintf("/api/builds/%d/download", buildID)
 		t.Logf("Download URL: %s", downloadURL)
 
 		// 3. Tenter de télécharger le binaire
@@ -0,0 +1,59 @@
+package integration
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requestCache appelle l'API d'administration du cache Go partagé
+func requestCache(t *testing.T, method, query string, expectedStatus int) map[string]interface{} {
+	t.Helper()
+
+	req, err := http.NewRequest(method, baseURL+"/api/admin/cache"+query, nil)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	require.Equal(t, expectedStatus, resp.StatusCode, "Réponse: %v", body)
+	return body
+}
+
+// TestSharedGoCache vérifie que les builds remplissent le cache Go du workspace,
+// conservé d'un build à l'autre, et qu'il peut être purgé
+func TestSharedGoCache(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/cached\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"cached\") }\n",
+	})
+
+	build := buildProject(t, "cache-test", repoDir)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+
+	stats := requestCache(t, http.MethodGet, "", http.StatusOK)
+	assert.Equal(t, float64(0), stats["in_use"])
+	buildCache := stats["build"].(map[string]interface{})
+	assert.Greater(t, buildCache["size"], float64(0), "Le cache de compilation est partagé dans le workspace")
+	assert.Greater(t, buildCache["entries"], float64(0))
+
+	requestCache(t, http.MethodDelete, "?cache=all", http.StatusBadRequest)
+
+	purged := requestCache(t, http.MethodDelete, "?cache=build", http.StatusOK)
+	assert.Equal(t, buildCache["size"], purged["freed"])
+
+	stats = requestCache(t, http.MethodGet, "", http.StatusOK)
+	assert.Equal(t, float64(0), stats["build"].(map[string]interface{})["size"])
+
+	report := requestCache(t, http.MethodPost, "/trim", http.StatusOK)
+	assert.Equal(t, float64(0), report["evicted"], "Sans taille maximale, rien n'est supprimé")
+}
@@ -0,0 +1,84 @@
+package integration
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildSubmodulesAndLFS compile un projet dont une dépendance est un
+// sous-module, qui embarque avec go:embed un fichier stocké par Git LFS
+func TestBuildSubmodulesAndLFS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	// L'objet LFS est dans le stockage local du dépôt, comme après un git lfs push
+	asset := "embedded asset from LFS\n"
+	sum := sha256.Sum256([]byte(asset))
+	oid := hex.EncodeToString(sum[:])
+	pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(asset))
+
+	libDir := createGitRepo(t, map[string]string{
+		"go.mod":         "module example.com/checkout/lib\n\ngo 1.21\n",
+		".gitattributes": "*.txt filter=lfs diff=lfs merge=lfs -text\n",
+		"asset.txt":      pointer,
+		"lib.go":         "package lib\n\nimport _ \"embed\"\n\n//go:embed asset.txt\nvar Asset string\n",
+	})
+	objectDir := filepath.Join(libDir, ".git", "lfs", "objects", oid[0:2], oid[2:4])
+	require.NoError(t, os.MkdirAll(objectDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(objectDir, oid), []byte(asset), 0o644))
+	libSHA := git(t, libDir, "rev-parse", "HEAD")
+
+	appDir := createGitRepo(t, map[string]string{
+		"go.mod":           "module example.com/checkout/app\n\ngo 1.21\n\nrequire example.com/checkout/lib v0.0.0\n\nreplace example.com/checkout/lib => ./third_party/lib\n",
+		"cmd/main.go":      "package main\n\nimport \"example.com/checkout/lib\"\n\nfunc main() { print(lib.Asset) }\n",
+		"cmd/main_test.go": fmt.Sprintf("package main\n\nimport (\n\t\"testing\"\n\n\t\"example.com/checkout/lib\"\n)\n\nfunc TestAsset(t *testing.T) {\n\tif lib.Asset != %q {\n\t\tt.Fatalf(\"asset = %%q\", lib.Asset)\n\t}\n}\n", asset),
+	})
+	git(t, appDir, "-c", "protocol.file.allow=always", "submodule", "add", libDir, "third_party/lib")
+	git(t, appDir, "-c", "user.name=Test User", "-c", "user.email=test@example.com", "commit", "-m", "Add lib")
+
+	// Sans LFS, le binaire embarque le pointeur : le test le détecte
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":       "checkout-pointer-test",
+		"repo_url":   appDir,
+		"branch":     "master",
+		"run_tests":  true,
+		"submodules": true,
+	}, http.StatusCreated)
+	assert.Equal(t, true, project["submodules"])
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "oid sha256:"+oid)
+
+	project = postJSON(t, "/api/projects", map[string]interface{}{
+		"name":       "checkout-test",
+		"repo_url":   appDir,
+		"branch":     "master",
+		"run_tests":  true,
+		"submodules": true,
+		"lfs":        true,
+	}, http.StatusCreated)
+	projectID := int(project["id"].(float64))
+	assert.Equal(t, true, project["lfs"])
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "==> 1 LFS files fetched")
+
+	submodules := build["submodules"].([]interface{})
+	require.Len(t, submodules, 1)
+	submodule := submodules[0].(map[string]interface{})
+	assert.Equal(t, "third_party/lib", submodule["path"])
+	assert.Equal(t, libDir, submodule["url"])
+	assert.Equal(t, libSHA, submodule["sha"])
+}
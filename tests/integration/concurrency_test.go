@@ -0,0 +1,95 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConcurrentBuildsOfOneProject lance deux builds du même projet : chacun a son
+// propre répertoire de travail, et ses artefacts restent disponibles une fois ce
+// répertoire supprimé
+func TestConcurrentBuildsOfOneProject(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/concurrent\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		"tools/wait/main.go": `package main
+
+import "time"
+
+func main() { time.Sleep(3 * time.Second) }
+`,
+		".gip.yml": `steps:
+  - name: wait
+    run: go run ./tools/wait
+  - uses: go-build
+`,
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "concurrent-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+	}, http.StatusCreated)
+
+	var ids []int
+	for i := 0; i < 2; i++ {
+		build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+		ids = append(ids, int(build["id"].(float64)))
+	}
+
+	// Les deux builds ont leur répertoire en même temps
+	workspace := mustAbs(t, "./test-workspace")
+	projectID := int(project["id"].(float64))
+	concurrent := false
+	for deadline := time.Now().Add(time.Minute); time.Now().Before(deadline) && !concurrent; {
+		concurrent = dirExists(workspacemanager.BuildDirPath(workspace, projectID, ids[0])) &&
+			dirExists(workspacemanager.BuildDirPath(workspace, projectID, ids[1]))
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.True(t, concurrent, "Les builds d'un même projet s'exécutent en même temps")
+
+	var wg sync.WaitGroup
+	builds := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			builds[i] = waitForBuild(t, id)
+		}()
+	}
+	wg.Wait()
+
+	for i, build := range builds {
+		require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+		assert.NoDirExists(t, workspacemanager.BuildDirPath(workspace, projectID, ids[i]), "Le répertoire du build est supprimé")
+		assert.FileExists(t, filepath.Join(workspacemanager.BuildArtifactsDir(workspace, ids[i]), fmt.Sprintf("concurrent-test-%d", ids[i])))
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/download", baseURL, ids[i]))
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEmpty(t, body)
+	}
+}
+
+// dirExists indique si path est un répertoire existant
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
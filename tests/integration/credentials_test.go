@@ -0,0 +1,111 @@
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// privateGitServer sert en HTTP les dépôts nus de root, comme un hébergeur de
+// dépôts privés : seules les requêtes avec le jeton token sont acceptées
+func privateGitServer(t *testing.T, root, token string) *httptest.Server {
+	execPath, err := exec.Command("git", "--exec-path").Output()
+	require.NoError(t, err)
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(execPath)), "git-http-backend"),
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, password, ok := r.BasicAuth(); !ok || password != token {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// TestBuildPrivateRepository compile un dépôt privé qui dépend d'un module privé,
+// servis par un serveur git HTTP qui exige un jeton
+func TestBuildPrivateRepository(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	root := t.TempDir()
+	libDir := createGitRepo(t, map[string]string{
+		"go.mod": "module example.com/private/lib.git\n\ngo 1.21\n",
+		"lib.go": "package lib\n\nfunc Greeting() string { return \"hello from private lib\" }\n",
+	})
+	git(t, libDir, "tag", "v1.0.0")
+	git(t, root, "clone", "--bare", libDir, "lib.git")
+
+	appDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/app\n\ngo 1.21\n\nrequire example.com/private/lib.git v1.0.0\n",
+		"cmd/main.go": "package main\n\nimport lib \"example.com/private/lib.git\"\n\nfunc main() { println(lib.Greeting()) }\n",
+	})
+	git(t, root, "clone", "--bare", appDir, "app.git")
+
+	server := privateGitServer(t, root, "s3cret-token")
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "private-test",
+		"repo_url": server.URL + "/app.git",
+		"branch":   "master",
+		"env": map[string]string{
+			// Le module privé example.com/private/... est servi par le serveur de test
+			"GIT_CONFIG_COUNT":   "1",
+			"GIT_CONFIG_KEY_0":   "url." + server.URL + "/.insteadOf",
+			"GIT_CONFIG_VALUE_0": "https://example.com/private/",
+			// go.sum est complété pendant le build
+			"GOFLAGS": "-mod=mod",
+		},
+	}, http.StatusCreated)
+	projectID := int(project["id"].(float64))
+
+	// Sans identifiants, le dépôt ne peut pas être cloné
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"])
+	assert.Equal(t, "Failed to clone repository", build["error"])
+
+	path := fmt.Sprintf("/api/projects/%d/credentials", projectID)
+	// Une clé SSH ne convient pas à un dépôt https
+	putCredentials(t, path, map[string]string{"kind": "ssh", "secret": "key"}, http.StatusBadRequest)
+	creds := putCredentials(t, path, map[string]string{"kind": "token", "secret": "s3cret-token", "goprivate": "example.com/private"}, http.StatusOK)
+	assert.NotContains(t, creds, "secret")
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	// Le dépôt et le module privé ont été téléchargés avec le jeton
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.NotContains(t, build["log_output"], "s3cret-token")
+}
+
+// putCredentials enregistre les identifiants d'un projet et décode la réponse
+func putCredentials(t *testing.T, path string, payload map[string]string, expectedStatus int) map[string]interface{} {
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, baseURL+path, bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, expectedStatus, resp.StatusCode, "Réponse: %v", result)
+	return result
+}
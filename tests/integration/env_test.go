@@ -0,0 +1,83 @@
+package integration
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildWithEnvAndSecrets compile un dépôt dont le package main n'existe qu'avec un
+// build tag passé par un secret du projet
+func TestBuildWithEnvAndSecrets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":           "module example.com/tagged\n\ngo 1.21\n",
+		"cmd/tool/main.go": "//go:build ci\n\npackage main\n\nfunc main() {}\n",
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "env-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+		"binaries": []map[string]string{{"package": "./cmd/tool"}},
+		"env":      map[string]string{"CGO_ENABLED": "0"},
+	}, http.StatusCreated)
+	projectID := int(project["id"].(float64))
+
+	// Sans le tag, aucun fichier Go n'est compilé
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+
+	secret := postJSON(t, fmt.Sprintf("/api/projects/%d/secrets", projectID), map[string]interface{}{
+		"name":  "GOFLAGS",
+		"value": "-tags=ci",
+	}, http.StatusOK)
+	require.NotContains(t, secret, "value")
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+}
+
+// TestBuildMasksSecrets vérifie qu'un secret affiché par go est masqué dans les
+// logs et l'erreur du build
+func TestBuildMasksSecrets(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/masked\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "masked-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+	}, http.StatusCreated)
+	projectID := int(project["id"].(float64))
+
+	// go refuse ce GOFLAGS en affichant sa valeur
+	const secret = "-token-s3cr3t-value"
+	postJSON(t, fmt.Sprintf("/api/projects/%d/secrets", projectID), map[string]interface{}{
+		"name":  "GOFLAGS",
+		"value": secret,
+	}, http.StatusOK)
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"])
+
+	logOutput := build["log_output"].(string)
+	assert.Contains(t, logOutput, "go: parsing $GOFLAGS: unknown flag ***")
+	assert.NotContains(t, logOutput, secret)
+	assert.NotContains(t, fmt.Sprint(build["error"]), secret)
+}
@@ -3,6 +3,7 @@ package integration
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -128,24 +129,27 @@ go 1.21
 	bodyBytes, err := io.ReadAll(resp.Body)
 	require.NoError(t, err)
 
-	if resp.StatusCode != http.StatusCreated {
+	if resp.StatusCode != http.StatusAccepted {
 		t.Logf("Réponse du build: %s", string(bodyBytes))
-		require.Equal(t, http.StatusCreated, resp.StatusCode, "Le build devrait réussir")
+		require.Equal(t, http.StatusAccepted, resp.StatusCode, "Le build devrait être mis en file d'attente")
 	}
 
 	var buildResult map[string]interface{}
 	err = json.Unmarshal(bodyBytes, &buildResult)
 	require.NoError(t, err)
 
-	buildID := int(buildResult["build_id"].(float64))
-	buildStatus := buildResult["status"].(string)
-	downloadURL := buildResult["download_url"].(string)
-
-	t.Logf("Build créé avec ID: %d, Status: %s", buildID, buildStatus)
-	t.Logf("URL de téléchargement: %s", downloadURL)
+	buildID := int(buildResult["id"].(float64))
+	t.Logf("Build créé avec ID: %d, Status: %s", buildID, buildResult["status"])
 
+	build := waitForBuild(t, buildID)
+	buildStatus := build["status"].(string)
+	if buildStatus != "success" {
+		t.Logf("Logs du build: %s", build["log_output"])
+	}
 	require.Equal(t, "success", buildStatus, "Le build devrait être en succès")
-	require.NotEmpty(t, downloadURL, "L'URL de téléchargement devrait être fournie")
+
+	downloadURL := fmt.Sprintf("/api/builds/%d/download", buildID)
+	t.Logf("URL de téléchargement: %s", downloadURL)
 
 	// === ÉTAPE 5: Télécharger le binaire ===
 	t.Log("Téléchargement du binaire...")
@@ -297,19 +301,17 @@ go 1.21
 	require.NoError(t, err)
 	defer resp.Body.Close()
 
-	bodyBytes, err := io.ReadAll(resp.Body)
-	require.NoError(t, err)
-
-	if resp.StatusCode != http.StatusCreated {
-		t.Logf("Build response: %s", string(bodyBytes))
-		t.FailNow()
-	}
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
 	var buildResult map[string]interface{}
-	err = json.Unmarshal(bodyBytes, &buildResult)
+	err = json.NewDecoder(resp.Body).Decode(&buildResult)
 	require.NoError(t, err)
 
-	downloadURL := buildResult["download_url"].(string)
+	buildID := int(buildResult["id"].(float64))
+	build := waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+
+	downloadURL := fmt.Sprintf("/api/builds/%d/download", buildID)
 
 	// Télécharger et exécuter
 	resp, err = http.Get(baseURL + downloadURL)
@@ -408,12 +410,15 @@ go 1.21
 		require.NoError(t, err)
 		defer resp.Body.Close()
 
-		// Le build devrait échouer
-		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "Le build devrait échouer sans cmd/main.go")
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
 		var buildResult map[string]interface{}
 		json.NewDecoder(resp.Body).Decode(&buildResult)
-		assert.Contains(t, buildResult["error"], "cmd/main.go not found")
+
+		// Le build devrait échouer
+		build := waitForBuild(t, int(buildResult["id"].(float64)))
+		assert.Equal(t, "failed", build["status"], "Le build devrait échouer sans cmd/main.go")
+		assert.Contains(t, build["error"], "cmd/main.go not found")
 	})
 
 	// Test 2: Code avec erreur de compilation
@@ -489,15 +494,17 @@ go 1.21
 		require.NoError(t, err)
 		defer resp.Body.Close()
 
-		// Le build devrait échouer
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "Le build devrait échouer avec erreur de compilation")
+		require.Equal(t, http.StatusAccepted, resp.StatusCode)
 
 		var buildResult map[string]interface{}
-		json.Unmarshal(bodyBytes, &buildResult)
+		json.NewDecoder(resp.Body).Decode(&buildResult)
+
+		// Le build devrait échouer
+		build := waitForBuild(t, int(buildResult["id"].(float64)))
+		assert.Equal(t, "failed", build["status"], "Le build devrait échouer avec erreur de compilation")
 
 		// Les logs devraient contenir des infos sur l'erreur
-		if logs, ok := buildResult["logs"].(string); ok {
+		if logs, ok := build["log_output"].(string); ok {
 			t.Logf("Logs d'erreur: %s", logs)
 		}
 	})
@@ -0,0 +1,147 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildProject crée un projet sur le dépôt repoDir, lance un build et attend sa fin
+func buildProject(t *testing.T, name, repoDir string) map[string]interface{} {
+	t.Helper()
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     name,
+		"repo_url": repoDir,
+		"branch":   "master",
+	}, http.StatusCreated)
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	return waitForBuild(t, int(build["id"].(float64)))
+}
+
+// TestBuildWithPipelineFile exécute les étapes du .gip.yml d'un dépôt
+func TestBuildWithPipelineFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/piped\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		"tools/check/main.go": `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if os.Getenv("STAGE") != "ci" {
+		fmt.Println("STAGE is not ci")
+		os.Exit(1)
+	}
+}
+`,
+		".gip.yml": `steps:
+  - name: check
+    run: go run ./tools/check
+    env:
+      STAGE: ci
+  - name: flaky
+    run: go run ./tools/check
+    continue_on_error: true
+  - uses: go-build
+`,
+	})
+
+	build := buildProject(t, "gipfile-test", repoDir)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "==> Pipeline from .gip.yml (3 steps)")
+	assert.Contains(t, build["log_output"], "==> Step flaky failed, continuing: Step flaky failed: exit status 1")
+	assert.NotContains(t, build["log_output"], "go [mod download]", "Les étapes du fichier remplacent le pipeline par défaut")
+
+	// Chaque étape est enregistrée avec son statut, son code de sortie et ses logs
+	steps := build["steps"].([]interface{})
+	require.Len(t, steps, 5)
+	var names []string
+	for _, s := range steps {
+		names = append(names, s.(map[string]interface{})["name"].(string))
+	}
+	assert.Equal(t, []string{"clone", "prepare", "check", "flaky", "go-build"}, names)
+	flaky := steps[3].(map[string]interface{})
+	assert.Equal(t, "failed", flaky["status"])
+	assert.Equal(t, float64(1), flaky["exit_code"])
+	assert.Equal(t, true, flaky["continue_on_error"])
+	assert.Equal(t, "go run ./tools/check", flaky["command"])
+	assert.Contains(t, flaky["log_output"], "STAGE is not ci")
+	assert.NotContains(t, steps[2].(map[string]interface{})["log_output"], "STAGE is not ci")
+	assert.Equal(t, "success", steps[4].(map[string]interface{})["status"])
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/artifacts", baseURL, int(build["id"].(float64))))
+	require.NoError(t, err)
+	var artifacts []map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&artifacts))
+	resp.Body.Close()
+	assert.Len(t, artifacts, 1)
+}
+
+// TestBuildWithoutBuildStep réussit sans artefact quand le .gip.yml ne compile pas
+func TestBuildWithoutBuildStep(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/lint\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		".gip.yml":    "steps:\n  - name: lint\n    run: go vet ./...\n",
+	})
+
+	build := buildProject(t, "gipfile-lint-test", repoDir)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/artifacts", baseURL, int(build["id"].(float64))))
+	require.NoError(t, err)
+	var artifacts []map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&artifacts))
+	resp.Body.Close()
+	assert.Empty(t, artifacts)
+}
+
+// TestBuildWithInvalidPipelineFile fait échouer le build sur un .gip.yml invalide
+// ou une étape trop longue
+func TestBuildWithInvalidPipelineFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	files := map[string]string{
+		"go.mod":      "module example.com/invalid\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		".gip.yml":    "steps:\n  - name: lint\n    command: go vet ./...\n",
+	}
+	build := buildProject(t, "gipfile-invalid-test", createGitRepo(t, files))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, "Invalid .gip.yml: line 3: unknown field command", build["error"])
+	steps := build["steps"].([]interface{})
+	require.Len(t, steps, 2)
+	prepare := steps[1].(map[string]interface{})
+	assert.Equal(t, "prepare", prepare["name"])
+	assert.Equal(t, "failed", prepare["status"])
+	assert.Equal(t, "Invalid .gip.yml: line 3: unknown field command", prepare["error"])
+
+	files[".gip.yml"] = "steps:\n  - name: wait\n    run: sleep 30\n    timeout: 1s\n  - uses: go-build\n"
+	build = buildProject(t, "gipfile-timeout-test", createGitRepo(t, files))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, "Step wait timed out after 1s", build["error"])
+	steps = build["steps"].([]interface{})
+	require.Len(t, steps, 3, "Les étapes suivantes ne sont pas exécutées")
+	wait := steps[2].(map[string]interface{})
+	assert.Equal(t, "failed", wait["status"])
+	assert.Equal(t, float64(-1), wait["exit_code"])
+}
@@ -0,0 +1,87 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/sandbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestHermeticBuild compile et teste un projet hors ligne, puis fait échouer un
+// build dont une étape tente de télécharger un module
+func TestHermeticBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+	if err := (&sandbox.Sandbox{Offline: true}).Check(context.Background()); err != nil {
+		t.Skipf("Sandbox unavailable: %v", err)
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":           "module example.com/hermetic\n\ngo 1.21\n\nrequire example.com/hermetic/lib v0.0.0\n\nreplace example.com/hermetic/lib => ./lib\n",
+		"lib/go.mod":       "module example.com/hermetic/lib\n\ngo 1.21\n",
+		"lib/lib.go":       "package lib\n\nconst Name = \"hermetic\"\n",
+		"cmd/main.go":      "package main\n\nimport \"example.com/hermetic/lib\"\n\nfunc main() { println(lib.Name) }\n",
+		"cmd/main_test.go": "package main\n\nimport (\n\t\"net\"\n\t\"testing\"\n)\n\nfunc TestLoopback(t *testing.T) {\n\tl, err := net.Listen(\"tcp\", \"127.0.0.1:0\")\n\tif err != nil {\n\t\tt.Fatal(err)\n\t}\n\tl.Close()\n}\n",
+	})
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":      "hermetic-test",
+		"repo_url":  repoDir,
+		"branch":    "master",
+		"run_tests": true,
+		"hermetic":  true,
+	}, http.StatusCreated)
+	assert.Equal(t, true, project["hermetic"])
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, true, build["hermetic"])
+	assert.Contains(t, build["log_output"], "==> Running: go [mod verify]")
+	assert.Contains(t, build["log_output"], "==> Hermetic build: offline sandbox")
+
+	steps := build["steps"].([]interface{})
+	require.Len(t, steps, 6)
+	assert.Equal(t, "prepare", steps[1].(map[string]interface{})["name"])
+	assert.Equal(t, "fetch modules", steps[2].(map[string]interface{})["name"])
+
+	// Hors ligne, le cache des modules vérifiés est en lecture seule et le cache de
+	// compilation est propre au build
+	repoDir = createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/caches\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		".gip.yml":    "steps:\n  - name: caches\n    run: sh -c 'touch \"$GOMODCACHE/poison\" && exit 1; case \"$GOCACHE\" in */build-*/gocache) ;; *) exit 2 ;; esac'\n  - uses: go-build\n",
+	})
+	project = postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "hermetic-caches-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+		"hermetic": true,
+	}, http.StatusCreated)
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "Read-only file system")
+
+	repoDir = createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/fetching\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		".gip.yml":    "steps:\n  - name: generate\n    run: go run example.com/tool@v1.0.0\n  - uses: go-build\n",
+	})
+	project = postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "hermetic-fetch-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+		"hermetic": true,
+	}, http.StatusCreated)
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, "Step generate failed: hermetic build tried to fetch from the network: go: example.com/tool@v1.0.0: module lookup disabled by GOPROXY=off", build["error"])
+	assert.Equal(t, true, build["hermetic"])
+}
@@ -1,15 +1,21 @@
 package integration
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"forgeronvirtuel/gip/internal/database"
+	"forgeronvirtuel/gip/internal/gocache"
+	"forgeronvirtuel/gip/internal/secrets"
 	"forgeronvirtuel/gip/internal/server"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -37,9 +43,15 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	cache, err := gocache.New("./test-workspace/cache", gocache.Limits{})
+	if err != nil {
+		fmt.Printf("Erreur lors de l'ouverture du cache Go: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Démarrer le serveur dans une goroutine
 	go func() {
-		server.Start(testPort, testDB, "./test-workspace")
+		server.Start(testPort, testDB, "./test-workspace", cache, 2, secrets.NewRandomCipher(), "")
 	}()
 
 	// Attendre que le serveur démarre
@@ -149,3 +161,80 @@ func TestIntegrationConcurrentRequests(t *testing.T) {
 
 	assert.Equal(t, numRequests, successCount, "Toutes les requêtes devraient réussir")
 }
+
+// createGitRepo crée un dépôt git local contenant les fichiers donnés, en un seul commit
+func createGitRepo(t *testing.T, files map[string]string) string {
+	repoDir := filepath.Join(t.TempDir(), "repo")
+
+	for name, content := range files {
+		path := filepath.Join(repoDir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	for _, args := range [][]string{
+		{"init", "-b", "master"},
+		{"config", "user.email", "test@example.com"},
+		{"config", "user.name", "Test User"},
+		{"add", "."},
+		{"commit", "-m", "Initial commit"},
+	} {
+		git(t, repoDir, args...)
+	}
+
+	return repoDir
+}
+
+// postJSON envoie une requête POST JSON et décode la réponse
+func postJSON(t *testing.T, path string, payload any, expectedStatus int) map[string]interface{} {
+	payloadBytes, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	resp, err := http.Post(baseURL+path, "application/json", bytes.NewBuffer(payloadBytes))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Equal(t, expectedStatus, resp.StatusCode, "Réponse: %v", result)
+	return result
+}
+
+// git exécute une commande git dans dir et retourne sa sortie, sans les blancs
+// qui l'entourent
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	require.NoError(t, err, "git %v: %s", args, stderr.String())
+	return strings.TrimSpace(string(output))
+}
+
+// waitForBuild interroge l'API jusqu'à ce que le build atteigne un statut final
+func waitForBuild(t *testing.T, buildID int) map[string]interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Minute)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d", baseURL, buildID))
+		require.NoError(t, err)
+
+		var build map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&build)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		switch build["status"] {
+		case "success", "failed", "cancelled":
+			return build
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	t.Fatalf("Le build %d n'est pas terminé à temps", buildID)
+	return nil
+}
@@ -0,0 +1,96 @@
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildMatrix compile un projet pour plusieurs plateformes et télécharge chaque binaire
+func TestBuildMatrix(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/matrix\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"matrix\")\n}\n",
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":      "matrix-test",
+		"repo_url":  repoDir,
+		"branch":    "master",
+		"platforms": []string{"linux/amd64", "linux/arm/v7", "windows/amd64/v3"},
+	}, http.StatusCreated)
+	assert.Equal(t, []interface{}{"linux/amd64", "linux/arm/v7", "windows/amd64/v3"}, project["platforms"])
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	buildID := int(build["id"].(float64))
+
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+
+	targets := build["targets"].([]interface{})
+	require.Len(t, targets, 3)
+	for _, target := range targets {
+		assert.Equal(t, "success", target.(map[string]interface{})["status"])
+		assert.Contains(t, target.(map[string]interface{})["log_output"], "==> Running: go [build")
+	}
+
+	// Chaque binaire a le format de sa plateforme
+	for platform, magic := range map[string]string{
+		"linux/arm/v7":     "\x7fELF",
+		"windows/amd64/v3": "MZ",
+	} {
+		resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/download?platform=%s", baseURL, buildID, platform))
+		require.NoError(t, err)
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode, platform)
+		assert.True(t, bytes.HasPrefix(content, []byte(magic)), "Le binaire %s devrait commencer par %q", platform, magic)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/download?platform=darwin/arm64", baseURL, buildID))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+// TestBuildMatrixTargetFailure vérifie qu'une plateforme en échec fait échouer le
+// build sans empêcher la compilation des autres
+func TestBuildMatrixTargetFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	// Le code ne compile que sous Linux
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/linuxonly\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nimport \"syscall\"\n\nvar _ = syscall.Sysinfo\n\nfunc main() {}\n",
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":      "matrix-failure-test",
+		"repo_url":  repoDir,
+		"branch":    "master",
+		"platforms": []string{"darwin/arm64", "linux/arm64"},
+	}, http.StatusCreated)
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"])
+	assert.Equal(t, "Build failed for 1 of 2 targets: darwin/arm64", build["error"])
+
+	targets := build["targets"].([]interface{})
+	require.Len(t, targets, 2)
+	assert.Equal(t, "failed", targets[0].(map[string]interface{})["status"])
+	assert.Contains(t, targets[0].(map[string]interface{})["log_output"], "undefined: syscall.Sysinfo")
+	assert.Equal(t, "success", targets[1].(map[string]interface{})["status"])
+}
@@ -0,0 +1,57 @@
+package integration
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/workspacemanager"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildFromMirror vérifie que les builds d'un dépôt partagent son miroir,
+// mis à jour avant chaque build
+func TestBuildFromMirror(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/mirrored\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "mirror-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+	}, http.StatusCreated)
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "==> Updating mirror of "+repoDir)
+
+	mirrorDir := workspacemanager.MirrorDir(mustAbs(t, "./test-workspace"), repoDir, 0)
+	assert.FileExists(t, filepath.Join(mirrorDir, "HEAD"), "Le miroir nu est conservé dans le workspace")
+
+	// Le build suivant récupère le nouveau commit par un fetch du miroir
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "cmd", "main.go"), []byte("package main\n\nfunc main() { println(2) }\n"), 0o644))
+	git(t, repoDir, "commit", "-am", "Version 2")
+	head := git(t, repoDir, "rev-parse", "HEAD")
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, head, build["commit"].(map[string]interface{})["sha"])
+}
+
+// mustAbs retourne le chemin absolu de path
+func mustAbs(t *testing.T, path string) string {
+	abs, err := filepath.Abs(path)
+	require.NoError(t, err)
+	return abs
+}
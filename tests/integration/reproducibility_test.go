@@ -0,0 +1,80 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitForReproducibility attend la fin de la vérification de la reproductibilité
+// d'un build terminé, et retourne son résultat
+func waitForReproducibility(t *testing.T, buildID int) map[string]interface{} {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Minute)
+	for time.Now().Before(deadline) {
+		resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d", baseURL, buildID))
+		require.NoError(t, err)
+
+		var build map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&build)
+		resp.Body.Close()
+		require.NoError(t, err)
+
+		if reproducibility, ok := build["reproducibility"].(map[string]interface{}); ok && reproducibility["status"] != "pending" {
+			return reproducibility
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	t.Fatalf("La reproductibilité du build %d n'est pas vérifiée à temps", buildID)
+	return nil
+}
+
+// TestReproducibleBuild compile un projet reproductible, dont le build de
+// vérification recompile le même commit et produit des artefacts identiques
+func TestReproducibleBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/reproducible\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() { println(\"reproducible\") }\n",
+	})
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":         "reproducible-test",
+		"repo_url":     repoDir,
+		"branch":       "master",
+		"reproducible": true,
+	}, http.StatusCreated)
+	assert.Equal(t, true, project["reproducible"])
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	buildID := int(build["id"].(float64))
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Nil(t, build["rebuild_of"])
+	assert.Contains(t, build["log_output"], "-trimpath")
+
+	reproducibility := waitForReproducibility(t, buildID)
+	require.Equal(t, "passed", reproducibility["status"], "Error: %s", reproducibility["error"])
+	artifacts := reproducibility["artifacts"].([]interface{})
+	require.Len(t, artifacts, 1)
+	artifact := artifacts[0].(map[string]interface{})
+	assert.Equal(t, true, artifact["identical"])
+	assert.Equal(t, artifact["sha256"], artifact["rebuild_sha256"])
+
+	rebuild := waitForBuild(t, int(reproducibility["rebuild_id"].(float64)))
+	assert.Equal(t, "success", rebuild["status"])
+	assert.Equal(t, float64(buildID), rebuild["rebuild_of"])
+	assert.Equal(t, build["commit"].(map[string]interface{})["sha"], rebuild["commit"].(map[string]interface{})["sha"])
+	assert.Equal(t, build["version"], rebuild["version"])
+	assert.Contains(t, rebuild["log_output"], fmt.Sprintf("==> Rebuild of build #%d", buildID))
+	assert.Nil(t, rebuild["reproducibility"])
+}
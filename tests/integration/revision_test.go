@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildTagRecordsCommit compile un tag plus ancien que la branche et vérifie
+// que le binaire et le commit enregistré correspondent au tag
+func TestBuildTagRecordsCommit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/revision\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {\n\tprintln(\"v1\")\n}\n",
+	})
+	git(t, repoDir, "tag", "v1.0.0")
+	tagged := git(t, repoDir, "rev-parse", "HEAD")
+
+	require.NoError(t, os.WriteFile(filepath.Join(repoDir, "cmd", "main.go"), []byte("package main\n\nfunc main() {\n\tprintln(\"v2\")\n}\n"), 0644))
+	git(t, repoDir, "commit", "-am", "Version 2")
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "revision-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+	}, http.StatusCreated)
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"], "ref": "v1.0.0"}, http.StatusAccepted)
+	buildID := int(build["id"].(float64))
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+
+	commit := build["commit"].(map[string]interface{})
+	assert.Equal(t, tagged, commit["sha"])
+	assert.Equal(t, "Test User <test@example.com>", commit["author"])
+	assert.Equal(t, "Initial commit", commit["message"])
+	assert.Equal(t, "v1.0.0", build["branch"])
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/download", baseURL, buildID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	binaryPath := filepath.Join(t.TempDir(), "revision")
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(binaryPath, content, 0755))
+
+	output, err := exec.Command(binaryPath).CombinedOutput()
+	require.NoError(t, err)
+	assert.Equal(t, "v1\n", string(output))
+
+	// Une référence inconnue fait échouer le build avec un message clair
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"], "ref": "v9.9.9"}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	assert.Equal(t, "failed", build["status"])
+	assert.Equal(t, "Branch or tag v9.9.9 not found in the repository", build["error"])
+}
+
+// TestBuildVersionStamping inscrit la version du build dans le binaire via -ldflags
+func TestBuildVersionStamping(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/stamped\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nimport \"fmt\"\n\nvar version, commit, name = \"dev\", \"none\", \"\"\n\nfunc main() {\n\tfmt.Println(name, version, commit)\n}\n",
+	})
+	git(t, repoDir, "tag", "-a", "v2.1.0", "-m", "v2.1.0")
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "stamped-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+		"ldflags":  "-s -w -X main.version={{.Tag}} -X main.commit={{.ShortCommit}} -X main.name={{.Project}}",
+	}, http.StatusCreated)
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	buildID := int(build["id"].(float64))
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, "v2.1.0", build["version"])
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/download", baseURL, buildID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	binaryPath := filepath.Join(t.TempDir(), "stamped")
+	content, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(binaryPath, content, 0755))
+
+	output, err := exec.Command(binaryPath).CombinedOutput()
+	require.NoError(t, err)
+	sha := build["commit"].(map[string]interface{})["sha"].(string)
+	assert.Equal(t, fmt.Sprintf("stamped-test v2.1.0 %s\n", sha[:7]), string(output))
+}
@@ -0,0 +1,70 @@
+package integration
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"forgeronvirtuel/gip/internal/sandbox"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBuildInSandbox compile un projet dans le sandbox, qui ne voit pas le reste
+// de l'espace de travail, puis fait échouer un build qui dépasse son temps CPU
+func TestBuildInSandbox(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+	if err := (&sandbox.Sandbox{}).Check(context.Background()); err != nil {
+		t.Skipf("Sandbox unavailable: %v", err)
+	}
+
+	mirrors, err := filepath.Abs("./test-workspace/mirrors")
+	require.NoError(t, err)
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/sandboxed\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		".gip.yml": "steps:\n" +
+			"  - name: isolated\n" +
+			"    run: sh -c \"test ! -e " + mirrors + "\"\n" +
+			"  - uses: go-build\n",
+	})
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "sandbox-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+		"sandbox":  map[string]interface{}{"memory_mb": 2048},
+	}, http.StatusCreated)
+	assert.Equal(t, map[string]interface{}{
+		"cpu_seconds": float64(0),
+		"memory_mb":   float64(2048),
+		"open_files":  float64(0),
+		"processes":   float64(0),
+	}, project["sandbox"])
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "==> Sandbox: CPU 600s, memory 2048 MB, 4096 open files, 4096 processes")
+
+	repoDir = createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/spinning\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+		".gip.yml":    "steps:\n  - name: spin\n    run: sh -c \"while :; do :; done\"\n",
+	})
+	project = postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "sandbox-cpu-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+		"sandbox":  map[string]interface{}{"cpu_seconds": 1},
+	}, http.StatusCreated)
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, "Step spin failed: sandbox CPU time limit exceeded (1s)", build["error"])
+}
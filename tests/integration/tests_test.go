@@ -0,0 +1,159 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildTests récupère les résultats des tests d'un build
+func buildTests(t *testing.T, buildID int) map[string]interface{} {
+	t.Helper()
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/tests", baseURL, buildID))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var tests map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&tests))
+	return tests
+}
+
+// TestBuildRunsTests exécute les tests d'un projet : un test en échec fait échouer
+// le build, qui réussit une fois le test corrigé (ici par un secret du projet)
+func TestBuildRunsTests(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":       "module example.com/tested\n\ngo 1.21\n",
+		"cmd/main.go":  "package main\n\nfunc main() {}\n",
+		"calc/calc.go": "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+		"calc/calc_test.go": `package calc
+
+import (
+	"os"
+	"testing"
+)
+
+func TestAdd(t *testing.T) {
+	if Add(1, 2) != 3 {
+		t.Fatal("bad sum")
+	}
+}
+
+func TestReady(t *testing.T) {
+	if os.Getenv("READY") != "yes" {
+		t.Fatal("READY is not set")
+	}
+}
+`,
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":      "tests-test",
+		"repo_url":  repoDir,
+		"branch":    "master",
+		"run_tests": true,
+	}, http.StatusCreated)
+	projectID := int(project["id"].(float64))
+	assert.Equal(t, true, project["run_tests"])
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	buildID := int(build["id"].(float64))
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, "Tests failed in example.com/tested/calc", build["error"])
+	assert.Contains(t, build["log_output"], "READY is not set")
+	assert.NotContains(t, build["log_output"], `"Action"`, "Les logs contiennent la sortie lisible des tests")
+
+	tests := buildTests(t, buildID)
+	summary := tests["summary"].(map[string]interface{})
+	assert.Equal(t, float64(2), summary["total"])
+	assert.Equal(t, float64(1), summary["passed"])
+	assert.Equal(t, float64(1), summary["failed"])
+
+	var failed []string
+	for _, r := range tests["results"].([]interface{}) {
+		result := r.(map[string]interface{})
+		if result["test"] != "" && result["status"] == "fail" {
+			failed = append(failed, result["test"].(string))
+			assert.Contains(t, result["output"], "READY is not set")
+		}
+	}
+	assert.Equal(t, []string{"TestReady"}, failed)
+
+	postJSON(t, fmt.Sprintf("/api/projects/%d/secrets", projectID), map[string]interface{}{
+		"name":  "READY",
+		"value": "yes",
+	}, http.StatusOK)
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	buildID = int(build["id"].(float64))
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+
+	summary = buildTests(t, buildID)["summary"].(map[string]interface{})
+	assert.Equal(t, float64(2), summary["passed"])
+	assert.Equal(t, float64(0), summary["failed"])
+}
+
+// TestBuildCoverage mesure la couverture d'un projet dont une fonction sur deux est testée
+func TestBuildCoverage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":            "module example.com/covered\n\ngo 1.21\n",
+		"cmd/main.go":       "package main\n\nfunc main() {}\n",
+		"calc/add.go":       "package calc\n\nfunc Add(a, b int) int { return a + b }\n",
+		"calc/sub.go":       "package calc\n\nfunc Sub(a, b int) int { return a - b }\n",
+		"calc/calc_test.go": "package calc\n\nimport \"testing\"\n\nfunc TestAdd(t *testing.T) {\n\tif Add(1, 2) != 3 {\n\t\tt.Fatal(\"bad sum\")\n\t}\n}\n",
+	})
+
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":      "coverage-test",
+		"repo_url":  repoDir,
+		"branch":    "master",
+		"run_tests": true,
+		"coverage":  true,
+	}, http.StatusCreated)
+	projectID := int(project["id"].(float64))
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": projectID}, http.StatusAccepted)
+	buildID := int(build["id"].(float64))
+	build = waitForBuild(t, buildID)
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "==> Coverage ")
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/builds/%d/coverage", baseURL, buildID))
+	require.NoError(t, err)
+	var report map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, "Réponse: %v", report)
+
+	percents := map[string]float64{}
+	for _, f := range report["files"].([]interface{}) {
+		file := f.(map[string]interface{})
+		percents[file["file"].(string)] = file["percent"].(float64)
+	}
+	assert.Equal(t, 100.0, percents["example.com/covered/calc/add.go"])
+	assert.Equal(t, 0.0, percents["example.com/covered/calc/sub.go"])
+
+	resp, err = http.Get(fmt.Sprintf("%s/api/projects/%d/coverage?branch=master", baseURL, projectID))
+	require.NoError(t, err)
+	var trend map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&trend))
+	resp.Body.Close()
+	points := trend["points"].([]interface{})
+	require.Len(t, points, 1)
+	assert.Equal(t, float64(buildID), points[0].(map[string]interface{})["build_id"])
+}
@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wrapperToolchain retourne l'archive d'une toolchain go1.99.0 dont la commande go
+// exécute celle du PATH, en annonçant sa propre version
+func wrapperToolchain(t *testing.T) []byte {
+	goPath, err := exec.LookPath("go")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		"go/VERSION": "go1.99.0\n",
+		"go/bin/go":  "#!/bin/sh\nif [ \"$*\" = \"env GOVERSION\" ]; then echo go1.99.0; exit 0; fi\nexec " + goPath + " \"$@\"\n",
+		"go/pkg/tool/" + runtime.GOOS + "_" + runtime.GOARCH + "/compile": "",
+	} {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// TestBuildGoToolchain installe une toolchain par l'API, puis compile un projet
+// qui l'épingle, un projet dont le go.mod demande une toolchain absente, et un
+// projet qui utilise la commande go du PATH
+func TestBuildGoToolchain(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping long test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("La toolchain de test est un script shell")
+	}
+
+	body := &bytes.Buffer{}
+	form := multipart.NewWriter(body)
+	part, err := form.CreateFormFile("file", "go1.99.0.linux-amd64.tar.gz")
+	require.NoError(t, err)
+	part.Write(wrapperToolchain(t))
+	form.Close()
+	resp, err := http.Post(baseURL+"/api/admin/toolchains", form.FormDataContentType(), body)
+	require.NoError(t, err)
+	var installed map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&installed))
+	resp.Body.Close()
+	require.Contains(t, []int{http.StatusCreated, http.StatusOK}, resp.StatusCode, installed)
+	assert.Equal(t, "go1.99.0", installed["version"])
+
+	repoDir := createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/pinned\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+	})
+	project := postJSON(t, "/api/projects", map[string]interface{}{
+		"name":         "toolchain-pinned-test",
+		"repo_url":     repoDir,
+		"branch":       "master",
+		"go_toolchain": "1.99",
+	}, http.StatusCreated)
+	assert.Equal(t, "go1.99", project["go_toolchain"])
+
+	build := postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, "go1.99.0", build["go_version"])
+	assert.Contains(t, build["log_output"], "==> Go toolchain go1.99 or a later go1.99 release required by the project\n")
+	assert.Contains(t, build["log_output"], "==> Go go1.99.0 from "+installed["root"].(string)+"\n")
+
+	repoDir = createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/gomod\n\ngo 1.21\n\ntoolchain go1.98.0\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+	})
+	project = postJSON(t, "/api/projects", map[string]interface{}{
+		"name":         "toolchain-gomod-test",
+		"repo_url":     repoDir,
+		"branch":       "master",
+		"go_toolchain": "go.mod",
+	}, http.StatusCreated)
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.True(t, strings.HasPrefix(build["error"].(string), "Go toolchain go1.98.0 required by go.mod is not installed (installed: "), build["error"])
+	assert.Equal(t, "", build["go_version"])
+	steps := build["steps"].([]interface{})
+	require.Len(t, steps, 2)
+	prepare := steps[1].(map[string]interface{})
+	assert.Equal(t, "prepare", prepare["name"])
+	assert.Equal(t, "failed", prepare["status"])
+	assert.Equal(t, build["error"], prepare["error"])
+
+	// Sans réglage, la version de la commande go du PATH est enregistrée
+	output, err := exec.Command("go", "env", "GOVERSION").Output()
+	require.NoError(t, err)
+	repoDir = createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/path\n\ngo 1.21\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+	})
+	project = postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "toolchain-path-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+	}, http.StatusCreated)
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "success", build["status"], "Logs: %s", build["log_output"])
+	assert.Equal(t, strings.TrimSpace(string(output)), build["go_version"])
+	assert.Contains(t, build["log_output"], "from the PATH\n")
+
+	// Sans réglage non plus, go ne télécharge pas la toolchain demandée par le go.mod
+	repoDir = createGitRepo(t, map[string]string{
+		"go.mod":      "module example.com/newer\n\ngo 1.99.0\n",
+		"cmd/main.go": "package main\n\nfunc main() {}\n",
+	})
+	project = postJSON(t, "/api/projects", map[string]interface{}{
+		"name":     "toolchain-local-test",
+		"repo_url": repoDir,
+		"branch":   "master",
+	}, http.StatusCreated)
+
+	build = postJSON(t, "/api/builds/", map[string]interface{}{"project_id": project["id"]}, http.StatusAccepted)
+	build = waitForBuild(t, int(build["id"].(float64)))
+	require.Equal(t, "failed", build["status"], "Logs: %s", build["log_output"])
+	assert.Contains(t, build["log_output"], "go: go.mod requires go >= 1.99.0 (running go ")
+	assert.Contains(t, build["log_output"], "; GOTOOLCHAIN=local)")
+	assert.NotContains(t, build["log_output"], "downloading go1.99.0")
+}
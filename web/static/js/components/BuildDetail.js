@@ -1,7 +1,109 @@
+// Couleurs ANSI standard (30-37 / 40-47) et vives (90-97 / 100-107)
+const ANSI_COLORS = [
+  "#4b5563",
+  "#f87171",
+  "#4ade80",
+  "#facc15",
+  "#60a5fa",
+  "#e879f9",
+  "#22d3ee",
+  "#e5e7eb",
+];
+const ANSI_BRIGHT_COLORS = [
+  "#9ca3af",
+  "#fca5a5",
+  "#86efac",
+  "#fde047",
+  "#93c5fd",
+  "#f0abfc",
+  "#67e8f9",
+  "#ffffff",
+];
+
+// applyAnsiCodes applique une séquence SGR ("1;31") au style courant
+const applyAnsiCodes = (style, params) => {
+  const codes = params === "" ? [0] : params.split(";").map(Number);
+  let next = { ...style };
+  for (let i = 0; i < codes.length; i++) {
+    const code = codes[i];
+    if (code === 0) next = {};
+    else if (code === 1) next.fontWeight = "bold";
+    else if (code === 22) delete next.fontWeight;
+    else if (code >= 30 && code <= 37) next.color = ANSI_COLORS[code - 30];
+    else if (code === 39) delete next.color;
+    else if (code >= 40 && code <= 47)
+      next.backgroundColor = ANSI_COLORS[code - 40];
+    else if (code === 49) delete next.backgroundColor;
+    else if (code >= 90 && code <= 97)
+      next.color = ANSI_BRIGHT_COLORS[code - 90];
+    else if (code >= 100 && code <= 107)
+      next.backgroundColor = ANSI_BRIGHT_COLORS[code - 100];
+    else if ((code === 38 || code === 48) && codes[i + 1] === 2) {
+      const key = code === 38 ? "color" : "backgroundColor";
+      next[key] = `rgb(${codes[i + 2]}, ${codes[i + 3]}, ${codes[i + 4]})`;
+      i += 4;
+    } else if ((code === 38 || code === 48) && codes[i + 1] === 5) {
+      i += 2; // Palette 256 couleurs non supportée
+    }
+  }
+  return next;
+};
+
+// parseAnsiLines découpe les lignes en segments stylés. Le style se propage
+// d'une ligne à l'autre comme dans un terminal.
+const parseAnsiLines = (lines) => {
+  const escape = /\x1b\[([0-9;?]*)([A-Za-z])/g;
+  let style = {};
+  return lines.map((line) => {
+    const segments = [];
+    let last = 0;
+    let match;
+    escape.lastIndex = 0;
+    while ((match = escape.exec(line)) !== null) {
+      if (match.index > last) {
+        segments.push({ text: line.slice(last, match.index), style });
+      }
+      if (match[2] === "m") {
+        style = applyAnsiCodes(style, match[1]);
+      }
+      last = escape.lastIndex;
+    }
+    if (last < line.length) {
+      segments.push({ text: line.slice(last), style });
+    }
+    return segments;
+  });
+};
+
+// Composant AnsiLog - Affiche des logs avec leurs couleurs ANSI
+function AnsiLog({ lines }) {
+  const parsed = React.useMemo(() => parseAnsiLines(lines), [lines]);
+  return parsed.map((segments, i) => (
+    <span key={i} className="block">
+      {segments.length === 0
+        ? "\u00a0"
+        : segments.map((segment, j) => (
+            <span key={j} style={segment.style}>
+              {segment.text}
+            </span>
+          ))}
+    </span>
+  ));
+}
+
 // Composant BuildDetail - Détails d'un build
 function BuildDetail({ build, project, onMessage, onBack }) {
   const [buildData, setBuildData] = React.useState(build);
   const [loading, setLoading] = React.useState(false);
+  const [logLines, setLogLines] = React.useState([]);
+  const [streaming, setStreaming] = React.useState(false);
+  const [artifacts, setArtifacts] = React.useState([]);
+  const [openTarget, setOpenTarget] = React.useState(null);
+  const [openStep, setOpenStep] = React.useState(null);
+  const [tests, setTests] = React.useState(null);
+  const [openTest, setOpenTest] = React.useState(null);
+  const logContainer = React.useRef(null);
+  const autoScroll = React.useRef(true);
 
   const refreshBuild = async () => {
     setLoading(true);
@@ -27,13 +129,107 @@ function BuildDetail({ build, project, onMessage, onBack }) {
   };
 
   React.useEffect(() => {
-    // Auto-refresh si le build est en cours
+    // Auto-refresh du statut si le build est en cours (les logs arrivent par le flux)
     if (buildData.status === "building" || buildData.status === "pending") {
       const interval = setInterval(refreshBuild, 3000);
       return () => clearInterval(interval);
     }
   }, [buildData.status]);
 
+  React.useEffect(() => {
+    // Logs en direct : le flux envoie l'historique puis chaque nouvelle ligne,
+    // et se termine par un événement "end" portant le statut final
+    console.log("📡 [BuildDetail] Abonnement aux logs du build", build.id);
+    const source = new EventSource(`/v1/api/builds/${build.id}/logs/stream`);
+    setStreaming(true);
+
+    source.onopen = () => {
+      // (Re)connexion : l'historique complet va être renvoyé
+      setLogLines([]);
+    };
+    source.addEventListener("log", (e) => {
+      const { line } = JSON.parse(e.data);
+      setLogLines((lines) => [...lines, line]);
+    });
+    source.addEventListener("end", (e) => {
+      const { status } = JSON.parse(e.data);
+      console.log("🏁 [BuildDetail] Fin du flux de logs, statut:", status);
+      source.close();
+      setStreaming(false);
+      refreshBuild();
+    });
+    source.onerror = () => {
+      if (source.readyState === EventSource.CLOSED) {
+        setStreaming(false);
+      }
+    };
+
+    return () => source.close();
+  }, [build.id]);
+
+  React.useEffect(() => {
+    // Les artefacts n'existent qu'une fois le build réussi
+    if (buildData.status !== "success") return;
+    const loadArtifacts = async () => {
+      try {
+        const response = await fetch(`/v1/api/builds/${build.id}/artifacts`);
+        const data = await response.json();
+        if (response.ok) {
+          console.log("📦 [BuildDetail] Artefacts:", data);
+          setArtifacts(data);
+        } else {
+          console.error("❌ [BuildDetail] Erreur HTTP:", response.status, data);
+        }
+      } catch (error) {
+        console.error("❌ [BuildDetail] Erreur réseau:", error);
+      }
+    };
+    loadArtifacts();
+  }, [build.id, buildData.status]);
+
+  React.useEffect(() => {
+    // Les résultats des tests sont enregistrés à la fin du build
+    if (buildData.status !== "success" && buildData.status !== "failed") return;
+    const loadTests = async () => {
+      try {
+        const response = await fetch(`/v1/api/builds/${build.id}/tests`);
+        const data = await response.json();
+        if (response.ok) {
+          console.log("🧪 [BuildDetail] Tests:", data.summary);
+          setTests(data.results.length > 0 ? data : null);
+        } else {
+          console.error("❌ [BuildDetail] Erreur HTTP:", response.status, data);
+        }
+      } catch (error) {
+        console.error("❌ [BuildDetail] Erreur réseau:", error);
+      }
+    };
+    loadTests();
+  }, [build.id, buildData.status]);
+
+  const getTestStatusColor = (status) => {
+    switch (status) {
+      case "pass":
+        return "bg-green-100 text-green-800 border-green-300";
+      case "fail":
+        return "bg-red-100 text-red-800 border-red-300";
+      default:
+        return "bg-gray-100 text-gray-800 border-gray-300";
+    }
+  };
+
+  React.useEffect(() => {
+    // Suivre la fin des logs tant que l'utilisateur n'est pas remonté
+    if (autoScroll.current && logContainer.current) {
+      logContainer.current.scrollTop = logContainer.current.scrollHeight;
+    }
+  }, [logLines]);
+
+  const handleLogScroll = () => {
+    const el = logContainer.current;
+    autoScroll.current = el.scrollHeight - el.scrollTop - el.clientHeight < 20;
+  };
+
   const getStatusColor = (status) => {
     switch (status) {
       case "success":
@@ -42,6 +238,8 @@ function BuildDetail({ build, project, onMessage, onBack }) {
         return "bg-red-100 text-red-800 border-red-300";
       case "building":
         return "bg-yellow-100 text-yellow-800 border-yellow-300";
+      case "cancelled":
+        return "bg-orange-100 text-orange-800 border-orange-300";
       default:
         return "bg-gray-100 text-gray-800 border-gray-300";
     }
@@ -55,16 +253,57 @@ function BuildDetail({ build, project, onMessage, onBack }) {
         return "❌";
       case "building":
         return "⚙️";
+      case "cancelled":
+        return "🚫";
       default:
         return "⏳";
     }
   };
 
-  const handleDownload = () => {
-    window.location.href = `/v1/api/builds/${buildData.id}/download`;
-    onMessage("📥 Téléchargement lancé...");
+  const handleCancel = async () => {
+    if (!confirm(`Annuler le build #${buildData.id} ?`)) return;
+    try {
+      console.log("🛑 [BuildDetail] Annulation du build", buildData.id);
+      const response = await fetch(`/v1/api/builds/${buildData.id}/cancel`, {
+        method: "POST",
+      });
+      const data = await response.json();
+
+      if (response.ok) {
+        onMessage("🚫 Build #" + buildData.id + " annulé");
+        setBuildData((current) => ({ ...current, ...data }));
+      } else {
+        console.error("❌ [BuildDetail] Erreur:", data);
+        onMessage("❌ Erreur: " + (data.error || "Erreur inconnue"));
+      }
+    } catch (error) {
+      console.error("❌ [BuildDetail] Erreur réseau:", error);
+      onMessage("❌ Erreur réseau: " + error.message);
+    }
+  };
+
+  const handleDownload = (artifact) => {
+    window.location.href = artifact.download_url;
+    onMessage("📥 Téléchargement de " + artifact.name + " lancé...");
+  };
+
+  const stepDuration = (step) =>
+    (new Date(step.ended_at) - new Date(step.started_at)) / 1000;
+
+  const formatDuration = (seconds) => {
+    if (seconds < 60) return seconds.toFixed(1) + "s";
+    return Math.floor(seconds / 60) + "min " + Math.round(seconds % 60) + "s";
+  };
+
+  const formatSize = (bytes) => {
+    if (bytes < 1024) return bytes + " o";
+    if (bytes < 1024 * 1024) return (bytes / 1024).toFixed(1) + " Ko";
+    return (bytes / (1024 * 1024)).toFixed(1) + " Mo";
   };
 
+  const steps = buildData.steps || [];
+  const stepsTotal = steps.reduce((total, step) => total + stepDuration(step), 0);
+
   return (
     <div className="space-y-6">
       {/* En-tête */}
@@ -83,13 +322,24 @@ function BuildDetail({ build, project, onMessage, onBack }) {
                 Projet: <span className="font-semibold">{project.name}</span>
               </p>
             </div>
-            <button
-              onClick={refreshBuild}
-              disabled={loading}
-              className="bg-white/20 hover:bg-white/30 text-white px-4 py-2 rounded-lg"
-            >
-              🔄 Rafraîchir
-            </button>
+            <div className="flex gap-2">
+              {(buildData.status === "pending" ||
+                buildData.status === "building") && (
+                <button
+                  onClick={handleCancel}
+                  className="bg-red-600 hover:bg-red-700 text-white px-4 py-2 rounded-lg"
+                >
+                  🛑 Annuler
+                </button>
+              )}
+              <button
+                onClick={refreshBuild}
+                disabled={loading}
+                className="bg-white/20 hover:bg-white/30 text-white px-4 py-2 rounded-lg"
+              >
+                🔄 Rafraîchir
+              </button>
+            </div>
           </div>
         </div>
       </div>
@@ -117,9 +367,59 @@ function BuildDetail({ build, project, onMessage, onBack }) {
               <span className="inline-block bg-blue-100 text-blue-800 px-4 py-2 rounded-lg font-mono">
                 🌿 {buildData.branch}
               </span>
+              {buildData.version && (
+                <span className="ml-2 inline-block bg-green-100 text-green-800 px-4 py-2 rounded-lg font-mono">
+                  🏷️ {buildData.version}
+                </span>
+              )}
+              {buildData.go_version && (
+                <span
+                  className="ml-2 inline-block bg-cyan-100 text-cyan-800 px-4 py-2 rounded-lg font-mono"
+                  title="Version de Go utilisée pour ce build"
+                >
+                  🐹 {buildData.go_version}
+                </span>
+              )}
+              {buildData.requested_commit && (
+                <span className="ml-2 inline-block bg-gray-100 text-gray-800 px-4 py-2 rounded-lg font-mono">
+                  📌 {buildData.requested_commit}
+                </span>
+              )}
+              {buildData.hermetic && (
+                <span
+                  className="ml-2 inline-block bg-purple-100 text-purple-800 px-4 py-2 rounded-lg"
+                  title="Compilé et testé hors ligne, à partir des modules vérifiés par go.sum"
+                >
+                  🔐 hermétique
+                </span>
+              )}
+              {buildData.rebuild_of && (
+                <span
+                  className="ml-2 inline-block bg-teal-100 text-teal-800 px-4 py-2 rounded-lg"
+                  title="Recompilation du même commit, pour vérifier la reproductibilité du build"
+                >
+                  ♻️ vérification du build #{buildData.rebuild_of}
+                </span>
+              )}
             </div>
           </div>
 
+          {buildData.commit && (
+            <div className="bg-gray-50 border border-gray-200 rounded-lg p-4">
+              <p className="text-sm text-gray-600 mb-1">Commit</p>
+              <p className="font-mono text-gray-800" title={buildData.commit.sha}>
+                🔖 {buildData.commit.sha.substring(0, 12)}{" "}
+                <span className="font-sans">
+                  {buildData.commit.message.split("\n")[0]}
+                </span>
+              </p>
+              <p className="text-sm text-gray-600 mt-1">
+                ✍️ {buildData.commit.author} —{" "}
+                {new Date(buildData.commit.date).toLocaleString("fr-FR")}
+              </p>
+            </div>
+          )}
+
           <div className="grid grid-cols-2 gap-6">
             <div>
               <p className="text-sm text-gray-600 mb-1">Créé le</p>
@@ -137,6 +437,17 @@ function BuildDetail({ build, project, onMessage, onBack }) {
             )}
           </div>
 
+          {buildData.waiting_reason && (
+            <div className="bg-orange-50 border border-orange-200 rounded-lg p-4">
+              <p className="text-sm font-semibold text-orange-800 mb-1">
+                ⏳ Build en attente d'un agent compatible
+              </p>
+              <p className="text-sm text-orange-700 font-mono">
+                {buildData.waiting_reason}
+              </p>
+            </div>
+          )}
+
           {buildData.ended_at && (
             <div>
               <p className="text-sm text-gray-600 mb-1">Terminé le</p>
@@ -156,29 +467,367 @@ function BuildDetail({ build, project, onMessage, onBack }) {
               📥 Téléchargement
             </h3>
           </div>
-          <div className="p-6">
-            <button
-              onClick={handleDownload}
-              className="btn-primary w-full bg-purple-600 text-white py-4 rounded-lg font-semibold hover:bg-purple-700 text-lg"
-            >
-              📥 Télécharger le binaire
-            </button>
-            <p className="text-sm text-gray-600 mt-3 text-center">
-              Le binaire compilé sera téléchargé sur votre machine
-            </p>
+          <div className="p-6 space-y-3">
+            {artifacts.length === 0 && (
+              <p className="text-sm text-gray-600 text-center">
+                Aucun artefact pour ce build
+              </p>
+            )}
+            {artifacts.map((artifact) => (
+              <div
+                key={artifact.id}
+                className="flex justify-between items-center p-4 border rounded-lg"
+              >
+                <div>
+                  <p className="font-semibold text-gray-800">
+                    📦 {artifact.name}
+                  </p>
+                  <p className="text-sm text-gray-600">
+                    {artifact.binary ? `${artifact.binary} · ` : ""}
+                    {artifact.os && artifact.arch
+                      ? `${artifact.platform} · `
+                      : ""}
+                    {formatSize(artifact.size)}
+                  </p>
+                  {artifact.sha256 && (
+                    <p
+                      className="text-xs text-gray-500 font-mono break-all"
+                      title="SHA-256"
+                    >
+                      sha256: {artifact.sha256}
+                    </p>
+                  )}
+                </div>
+                <button
+                  onClick={() => handleDownload(artifact)}
+                  className="btn-primary bg-purple-600 text-white px-4 py-2 rounded-lg font-semibold hover:bg-purple-700 whitespace-nowrap ml-4"
+                >
+                  📥 Télécharger
+                </button>
+              </div>
+            ))}
+          </div>
+        </div>
+      )}
+
+      {/* Étapes */}
+      {steps.length > 0 && (
+        <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
+          <div className="p-6 border-b bg-gray-50 flex justify-between items-center">
+            <h3 className="text-xl font-bold text-gray-800">⏱️ Étapes</h3>
+            <span className="text-sm text-gray-600">
+              {formatDuration(stepsTotal)}
+            </span>
+          </div>
+          <div className="divide-y">
+            {steps.map((step, index) => {
+              // Position de l'étape sur la frise : durée cumulée des étapes précédentes
+              const offset = steps
+                .slice(0, index)
+                .reduce((total, s) => total + stepDuration(s), 0);
+              return (
+                <div key={step.id}>
+                  <button
+                    onClick={() => setOpenStep(openStep === step.id ? null : step.id)}
+                    className="w-full p-4 flex items-center gap-4 hover:bg-gray-50 text-left"
+                  >
+                    <div className="w-1/3 flex items-center gap-3 min-w-0">
+                      <span className="text-lg">
+                        {step.status === "failed" && step.continue_on_error
+                          ? "⚠️"
+                          : getStatusIcon(step.status)}
+                      </span>
+                      <div className="min-w-0">
+                        <p className="font-semibold text-gray-800 truncate">
+                          {step.name}
+                        </p>
+                        {step.status === "failed" && (
+                          <p className="text-xs text-red-700 truncate">
+                            {step.exit_code >= 0 ? `code ${step.exit_code} · ` : ""}
+                            {step.continue_on_error ? "ignoré · " : ""}
+                            {step.error}
+                          </p>
+                        )}
+                      </div>
+                    </div>
+                    <div className="flex-1 h-3 bg-gray-100 rounded relative">
+                      <div
+                        className={`absolute h-3 rounded ${
+                          step.status === "success"
+                            ? "bg-green-400"
+                            : step.continue_on_error
+                            ? "bg-yellow-400"
+                            : "bg-red-400"
+                        }`}
+                        style={{
+                          left: stepsTotal > 0 ? `${(offset / stepsTotal) * 100}%` : 0,
+                          width:
+                            stepsTotal > 0
+                              ? `${Math.max((stepDuration(step) / stepsTotal) * 100, 0.5)}%`
+                              : "100%",
+                        }}
+                      ></div>
+                    </div>
+                    <span className="w-20 text-right text-sm text-gray-600 font-mono">
+                      {formatDuration(stepDuration(step))}
+                    </span>
+                    <span className="text-gray-400">
+                      {openStep === step.id ? "▲" : "▼"}
+                    </span>
+                  </button>
+                  {openStep === step.id && (
+                    <div className="p-4 bg-gray-900 max-h-80 overflow-y-auto">
+                      {step.command && (
+                        <pre className="text-xs text-gray-400 font-mono whitespace-pre-wrap mb-2">
+                          {step.command
+                            .split("\n")
+                            .map((command) => "$ " + command)
+                            .join("\n")}
+                        </pre>
+                      )}
+                      <pre className="text-xs text-green-400 font-mono whitespace-pre-wrap">
+                        <AnsiLog lines={step.log_output.split("\n")} />
+                      </pre>
+                    </div>
+                  )}
+                </div>
+              );
+            })}
+          </div>
+        </div>
+      )}
+
+      {/* Tests */}
+      {tests && (
+        <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
+          <div className="p-6 border-b bg-gray-50 flex justify-between items-center">
+            <h3 className="text-xl font-bold text-gray-800">🧪 Tests</h3>
+            <div className="flex gap-2 text-sm">
+              <span className="px-3 py-1 rounded-full border bg-green-100 text-green-800 border-green-300">
+                ✅ {tests.summary.passed} réussi(s)
+              </span>
+              <span className="px-3 py-1 rounded-full border bg-red-100 text-red-800 border-red-300">
+                ❌ {tests.summary.failed} en échec
+              </span>
+              <span className="px-3 py-1 rounded-full border bg-gray-100 text-gray-800 border-gray-300">
+                ⏭️ {tests.summary.skipped} ignoré(s)
+              </span>
+              <span className="px-3 py-1 text-gray-600">
+                {tests.summary.packages} package(s) ·{" "}
+                {tests.summary.duration.toFixed(2)}s
+              </span>
+            </div>
+          </div>
+          <div className="divide-y">
+            {tests.results
+              .filter((result) => result.test === "" || result.status === "fail")
+              .map((result) => (
+                <div key={result.id}>
+                  <button
+                    onClick={() =>
+                      setOpenTest(openTest === result.id ? null : result.id)
+                    }
+                    className={`w-full p-4 flex justify-between items-center hover:bg-gray-50 text-left ${
+                      result.test ? "pl-10" : ""
+                    }`}
+                  >
+                    <div className="flex items-center gap-3">
+                      <span
+                        className={`px-3 py-1 rounded-full text-sm font-semibold border ${getTestStatusColor(
+                          result.status
+                        )}`}
+                      >
+                        {result.status}
+                      </span>
+                      <span className="font-mono text-gray-800">
+                        {result.test || result.package}
+                      </span>
+                      <span className="text-xs text-gray-500">
+                        {result.duration.toFixed(2)}s
+                      </span>
+                    </div>
+                    <span className="text-gray-400">
+                      {openTest === result.id ? "▲" : "▼"}
+                    </span>
+                  </button>
+                  {openTest === result.id && (
+                    <div className="p-4 bg-gray-900 max-h-80 overflow-y-auto">
+                      <pre className="text-xs text-green-400 font-mono whitespace-pre-wrap">
+                        <AnsiLog lines={result.output.split("\n")} />
+                      </pre>
+                    </div>
+                  )}
+                </div>
+              ))}
+          </div>
+        </div>
+      )}
+
+      {/* Reproductibilité */}
+      {buildData.reproducibility && (
+        <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
+          <div
+            className={`p-6 border-b ${
+              buildData.reproducibility.status === "passed"
+                ? "bg-green-50"
+                : buildData.reproducibility.status === "failed"
+                ? "bg-red-50"
+                : "bg-gray-50"
+            }`}
+          >
+            <h3 className="text-xl font-bold text-gray-800">
+              ♻️ Reproductibilité :{" "}
+              {buildData.reproducibility.status === "passed"
+                ? "✅ binaires identiques"
+                : buildData.reproducibility.status === "failed"
+                ? "❌ échec"
+                : "⏳ en cours"}
+            </h3>
+            {buildData.reproducibility.rebuild_id > 0 && (
+              <p className="text-sm text-gray-600 mt-1">
+                Build de vérification #{buildData.reproducibility.rebuild_id}
+              </p>
+            )}
+            {buildData.reproducibility.error && (
+              <p className="text-sm text-red-700 font-mono mt-2 break-all">
+                {buildData.reproducibility.error}
+              </p>
+            )}
+          </div>
+          <div className="divide-y">
+            {(buildData.reproducibility.artifacts || []).map((artifact) => (
+              <div key={artifact.id} className="p-4">
+                <div className="flex justify-between items-center gap-4">
+                  <span className="font-mono text-gray-800">
+                    {artifact.identical ? "✅" : "❌"} {artifact.name}
+                  </span>
+                  <span className="text-sm text-gray-500">
+                    {artifact.platform}
+                  </span>
+                </div>
+                <p className="font-mono text-xs text-gray-500 mt-1 break-all">
+                  {artifact.sha256}
+                  {!artifact.identical &&
+                    ` ≠ ${artifact.rebuild_sha256 || "non recompilé"}`}
+                </p>
+                {artifact.sections.length > 0 && (
+                  <ul className="mt-2 text-sm text-gray-700 font-mono">
+                    {artifact.sections.map((section) => (
+                      <li key={section.name}>
+                        {section.name}{" "}
+                        <span className="text-gray-500">
+                          {section.size < 0
+                            ? `(ajoutée, ${section.other_size} octets)`
+                            : section.other_size < 0
+                            ? `(supprimée, ${section.size} octets)`
+                            : section.size !== section.other_size
+                            ? `(${section.size} → ${section.other_size} octets)`
+                            : ""}
+                        </span>
+                      </li>
+                    ))}
+                  </ul>
+                )}
+              </div>
+            ))}
+          </div>
+        </div>
+      )}
+
+      {/* Sous-modules */}
+      {buildData.submodules && buildData.submodules.length > 0 && (
+        <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
+          <div className="p-6 border-b bg-gray-50">
+            <h3 className="text-xl font-bold text-gray-800">🧩 Sous-modules</h3>
+          </div>
+          <div className="divide-y">
+            {buildData.submodules.map((submodule) => (
+              <div
+                key={submodule.id}
+                className="p-4 flex justify-between items-center gap-4"
+              >
+                <div>
+                  <div className="font-mono text-gray-800">{submodule.path}</div>
+                  <div className="text-sm text-gray-500 break-all">
+                    {submodule.url}
+                  </div>
+                </div>
+                <span className="font-mono text-sm text-gray-600">
+                  {submodule.sha.substring(0, 7)}
+                </span>
+              </div>
+            ))}
+          </div>
+        </div>
+      )}
+
+      {/* Plateformes */}
+      {buildData.targets && buildData.targets.length > 0 && (
+        <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
+          <div className="p-6 border-b bg-gray-50">
+            <h3 className="text-xl font-bold text-gray-800">🖥️ Plateformes</h3>
+          </div>
+          <div className="divide-y">
+            {buildData.targets.map((target) => (
+              <div key={target.id}>
+                <button
+                  onClick={() =>
+                    setOpenTarget(openTarget === target.id ? null : target.id)
+                  }
+                  className="w-full p-4 flex justify-between items-center hover:bg-gray-50 text-left"
+                >
+                  <div className="flex items-center gap-3">
+                    <span
+                      className={`px-3 py-1 rounded-full text-sm font-semibold border ${getStatusColor(
+                        target.status
+                      )}`}
+                    >
+                      {getStatusIcon(target.status)} {target.status}
+                    </span>
+                    <span className="font-mono text-gray-800">
+                      {target.platform}
+                    </span>
+                    {target.error && (
+                      <span className="text-sm text-red-700">
+                        {target.error}
+                      </span>
+                    )}
+                  </div>
+                  <span className="text-gray-400">
+                    {openTarget === target.id ? "▲" : "▼"}
+                  </span>
+                </button>
+                {openTarget === target.id && (
+                  <div className="p-4 bg-gray-900 max-h-80 overflow-y-auto">
+                    <pre className="text-xs text-green-400 font-mono whitespace-pre-wrap">
+                      <AnsiLog lines={target.log_output.split("\n")} />
+                    </pre>
+                  </div>
+                )}
+              </div>
+            ))}
           </div>
         </div>
       )}
 
       {/* Logs */}
-      {buildData.log_output && (
+      {(logLines.length > 0 || streaming) && (
         <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
-          <div className="p-6 border-b bg-gray-800 text-white">
+          <div className="p-6 border-b bg-gray-800 text-white flex justify-between items-center">
             <h3 className="text-xl font-bold">📜 Logs du build</h3>
+            {streaming && buildData.status !== "pending" && (
+              <span className="text-xs bg-red-600 px-2 py-1 rounded animate-pulse">
+                ● EN DIRECT
+              </span>
+            )}
           </div>
-          <div className="p-6 bg-gray-900">
-            <pre className="text-xs text-green-400 font-mono overflow-x-auto whitespace-pre-wrap">
-              {buildData.log_output}
+          <div
+            ref={logContainer}
+            onScroll={handleLogScroll}
+            className="p-6 bg-gray-900 max-h-[32rem] overflow-y-auto"
+          >
+            <pre className="text-xs text-green-400 font-mono whitespace-pre-wrap">
+              <AnsiLog lines={logLines} />
             </pre>
           </div>
         </div>
@@ -190,7 +839,7 @@ function BuildDetail({ build, project, onMessage, onBack }) {
             <div className="animate-spin inline-block w-12 h-12 border-4 border-yellow-400 border-t-transparent rounded-full mb-4"></div>
             <p className="text-gray-600 font-semibold">Build en cours...</p>
             <p className="text-sm text-gray-500 mt-2">
-              Cette page se rafraîchit automatiquement
+              Les logs s'affichent en direct ci-dessus
             </p>
           </div>
         </div>
@@ -0,0 +1,234 @@
+// coverageColor retourne la couleur d'un pourcentage de couverture
+const coverageColor = (percent) => {
+  if (percent >= 80) return "bg-green-500";
+  if (percent >= 50) return "bg-yellow-400";
+  return "bg-red-500";
+};
+
+// Composant CoverageBar - Barre de progression d'un pourcentage de couverture
+function CoverageBar({ percent }) {
+  return (
+    <div className="flex items-center gap-2">
+      <div className="w-32 h-2 bg-gray-200 rounded">
+        <div
+          className={`h-2 rounded ${coverageColor(percent)}`}
+          style={{ width: `${Math.min(percent, 100)}%` }}
+        ></div>
+      </div>
+      <span className="font-mono text-sm text-gray-700 w-14 text-right">
+        {percent.toFixed(1)}%
+      </span>
+    </div>
+  );
+}
+
+// Composant CoverageChart - Courbe de la couverture totale des builds
+function CoverageChart({ points, selected, onSelect }) {
+  const width = 640;
+  const height = 180;
+  const padding = 30;
+  const x = (i) =>
+    points.length === 1
+      ? width / 2
+      : padding + (i * (width - 2 * padding)) / (points.length - 1);
+  const y = (percent) => height - padding - (percent * (height - 2 * padding)) / 100;
+  const path = points
+    .map((p, i) => `${i === 0 ? "M" : "L"} ${x(i)} ${y(p.percent)}`)
+    .join(" ");
+
+  return (
+    <svg viewBox={`0 0 ${width} ${height}`} className="w-full h-48">
+      {[0, 50, 100].map((tick) => (
+        <g key={tick}>
+          <line
+            x1={padding}
+            x2={width - padding}
+            y1={y(tick)}
+            y2={y(tick)}
+            stroke="#e5e7eb"
+          />
+          <text x={2} y={y(tick) + 4} fontSize="10" fill="#6b7280">
+            {tick}%
+          </text>
+        </g>
+      ))}
+      <path d={path} fill="none" stroke="#2563eb" strokeWidth="2" />
+      {points.map((p, i) => (
+        <circle
+          key={p.build_id}
+          cx={x(i)}
+          cy={y(p.percent)}
+          r={selected === p.build_id ? 6 : 4}
+          fill={selected === p.build_id ? "#1d4ed8" : "#60a5fa"}
+          className="cursor-pointer"
+          onClick={() => onSelect(p.build_id)}
+        >
+          <title>
+            {`Build #${p.build_id} (${p.branch}${
+              p.version ? " · " + p.version : ""
+            }) : ${p.percent.toFixed(1)}%`}
+          </title>
+        </circle>
+      ))}
+    </svg>
+  );
+}
+
+// Composant CoverageTrend - Évolution de la couverture d'un projet et détail par fichier
+function CoverageTrend({ project }) {
+  const [branch, setBranch] = React.useState(project.branch);
+  const [points, setPoints] = React.useState([]);
+  const [selected, setSelected] = React.useState(null);
+  const [report, setReport] = React.useState(null);
+  const [openPackage, setOpenPackage] = React.useState(null);
+
+  React.useEffect(() => {
+    const loadTrend = async () => {
+      try {
+        const response = await fetch(
+          `/v1/api/projects/${project.id}/coverage?branch=${encodeURIComponent(
+            branch
+          )}`
+        );
+        const data = await response.json();
+        if (response.ok) {
+          console.log("📈 [CoverageTrend] Points:", data.points.length);
+          setPoints(data.points);
+          // Le dernier build est détaillé par défaut
+          setSelected(
+            data.points.length > 0
+              ? data.points[data.points.length - 1].build_id
+              : null
+          );
+        } else {
+          console.error("❌ [CoverageTrend] Erreur HTTP:", response.status, data);
+          setPoints([]);
+        }
+      } catch (error) {
+        console.error("❌ [CoverageTrend] Erreur réseau:", error);
+        setPoints([]);
+      }
+    };
+    loadTrend();
+  }, [project.id, branch]);
+
+  React.useEffect(() => {
+    if (selected === null) {
+      setReport(null);
+      return;
+    }
+    const loadReport = async () => {
+      try {
+        const response = await fetch(`/v1/api/builds/${selected}/coverage`);
+        const data = await response.json();
+        if (response.ok) {
+          setReport(data);
+        } else {
+          console.error("❌ [CoverageTrend] Erreur HTTP:", response.status, data);
+          setReport(null);
+        }
+      } catch (error) {
+        console.error("❌ [CoverageTrend] Erreur réseau:", error);
+        setReport(null);
+      }
+    };
+    loadReport();
+  }, [selected]);
+
+  if (!project.coverage && points.length === 0) return null;
+
+  const delta =
+    points.length > 1
+      ? points[points.length - 1].percent - points[points.length - 2].percent
+      : null;
+
+  return (
+    <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
+      <div className="p-6 border-b flex justify-between items-center">
+        <div>
+          <h3 className="text-2xl font-bold text-gray-800">📈 Couverture</h3>
+          {delta !== null && (
+            <p
+              className={`text-sm mt-1 ${
+                delta < 0 ? "text-red-700" : "text-green-700"
+              }`}
+            >
+              {delta < 0 ? "▼" : "▲"} {Math.abs(delta).toFixed(1)} point(s)
+              depuis le build précédent
+            </p>
+          )}
+        </div>
+        <input
+          type="text"
+          value={branch}
+          onChange={(e) => setBranch(e.target.value.trim())}
+          className="form-input px-3 py-1 border border-gray-300 rounded-lg font-mono text-sm"
+          placeholder="toutes les branches"
+          title="Branche"
+        />
+      </div>
+
+      <div className="p-6 space-y-4">
+        {points.length === 0 ? (
+          <p className="text-gray-500">
+            Aucun build réussi avec couverture sur cette branche
+          </p>
+        ) : (
+          <CoverageChart
+            points={points}
+            selected={selected}
+            onSelect={setSelected}
+          />
+        )}
+
+        {report && (
+          <div>
+            <div className="flex justify-between items-center mb-2">
+              <p className="font-semibold text-gray-800">
+                Build #{report.build_id} · {report.total.covered}/
+                {report.total.statements} instructions
+              </p>
+              <CoverageBar percent={report.total.percent} />
+            </div>
+            <div className="divide-y border rounded-lg">
+              {report.packages.map((pkg) => (
+                <div key={pkg.package}>
+                  <button
+                    onClick={() =>
+                      setOpenPackage(
+                        openPackage === pkg.package ? null : pkg.package
+                      )
+                    }
+                    className="w-full px-4 py-2 flex justify-between items-center hover:bg-gray-50 text-left"
+                  >
+                    <span className="font-mono text-sm text-gray-800">
+                      {openPackage === pkg.package ? "▼" : "▶"} {pkg.package}
+                    </span>
+                    <CoverageBar percent={pkg.percent} />
+                  </button>
+                  {openPackage === pkg.package &&
+                    report.files
+                      .filter((file) => file.package === pkg.package)
+                      .map((file) => (
+                        <div
+                          key={file.file}
+                          className="pl-10 pr-4 py-1 flex justify-between items-center bg-gray-50"
+                        >
+                          <span className="font-mono text-xs text-gray-600">
+                            {file.file.substring(pkg.package.length + 1)}
+                            <span className="text-gray-400 ml-2">
+                              {file.covered}/{file.statements}
+                            </span>
+                          </span>
+                          <CoverageBar percent={file.percent} />
+                        </div>
+                      ))}
+                </div>
+              ))}
+            </div>
+          </div>
+        )}
+      </div>
+    </div>
+  );
+}
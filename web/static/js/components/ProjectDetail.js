@@ -4,6 +4,7 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
   const [loading, setLoading] = React.useState(true);
   const [showBuildForm, setShowBuildForm] = React.useState(false);
   const [buildBranch, setBuildBranch] = React.useState(project.branch);
+  const [buildCommit, setBuildCommit] = React.useState("");
 
   const loadBuilds = async () => {
     try {
@@ -47,15 +48,18 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
       console.log(
         "🔨 [ProjectDetail] Création d'un build pour projet",
         project.id,
-        "branche:",
-        buildBranch
+        "ref:",
+        buildBranch,
+        "commit:",
+        buildCommit
       );
       const response = await fetch("/v1/api/builds/", {
         method: "POST",
         headers: { "Content-Type": "application/json" },
         body: JSON.stringify({
           project_id: project.id,
-          branch: buildBranch,
+          ref: buildBranch,
+          commit: buildCommit,
         }),
       });
       const data = await response.json();
@@ -63,7 +67,7 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
 
       if (response.ok) {
         console.log("✅ [ProjectDetail] Build créé avec ID:", data.id);
-        onMessage("✅ Build lancé avec succès! ID: " + data.id);
+        onMessage("✅ Build mis en file d'attente! ID: " + data.id);
         setShowBuildForm(false);
         loadBuilds();
       } else {
@@ -76,6 +80,30 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
     }
   };
 
+  const handleCancelBuild = async (e, build) => {
+    // Ne pas ouvrir le détail du build
+    e.stopPropagation();
+    if (!confirm(`Annuler le build #${build.id} ?`)) return;
+    try {
+      console.log("🛑 [ProjectDetail] Annulation du build", build.id);
+      const response = await fetch(`/v1/api/builds/${build.id}/cancel`, {
+        method: "POST",
+      });
+      const data = await response.json();
+
+      if (response.ok) {
+        onMessage("🚫 Build #" + build.id + " annulé");
+        loadBuilds();
+      } else {
+        console.error("❌ [ProjectDetail] Erreur:", data);
+        onMessage("❌ Erreur: " + (data.error || "Erreur inconnue"));
+      }
+    } catch (error) {
+      console.error("❌ [ProjectDetail] Erreur réseau:", error);
+      onMessage("❌ Erreur réseau: " + error.message);
+    }
+  };
+
   const getStatusColor = (status) => {
     switch (status) {
       case "success":
@@ -84,6 +112,8 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
         return "bg-red-100 text-red-800";
       case "building":
         return "bg-yellow-100 text-yellow-800";
+      case "cancelled":
+        return "bg-orange-100 text-orange-800";
       default:
         return "bg-gray-100 text-gray-800";
     }
@@ -97,6 +127,8 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
         return "❌";
       case "building":
         return "⚙️";
+      case "cancelled":
+        return "🚫";
       default:
         return "⏳";
     }
@@ -126,6 +158,77 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
                 📂 {project.subdir}
               </span>
             )}
+            {project.agent_selector && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm font-mono">
+                🏷️ {project.agent_selector}
+              </span>
+            )}
+            {project.go_toolchain && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm font-mono">
+                🐹 {project.go_toolchain}
+              </span>
+            )}
+            {project.platforms && project.platforms.length > 0 && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm font-mono">
+                🖥️ {project.platforms.join(", ")}
+              </span>
+            )}
+            {project.binaries && project.binaries.length > 0 && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm font-mono">
+                📦 {project.binaries.map((b) => b.name).join(", ")}
+              </span>
+            )}
+            {project.ldflags && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm font-mono">
+                🔖 {project.ldflags}
+              </span>
+            )}
+            {project.env && Object.keys(project.env).length > 0 && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm font-mono">
+                🌱 {Object.keys(project.env).sort().join(", ")}
+              </span>
+            )}
+            {project.run_tests && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                🧪 tests{project.coverage ? " + couverture" : ""}
+              </span>
+            )}
+            {project.discover_binaries && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                🔍 découverte des packages main
+              </span>
+            )}
+            {project.clone_depth > 0 && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                🌿 clone superficiel ({project.clone_depth} commit
+                {project.clone_depth > 1 ? "s" : ""})
+              </span>
+            )}
+            {project.submodules && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                🧩 sous-modules
+              </span>
+            )}
+            {project.lfs && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                📦 Git LFS
+              </span>
+            )}
+            {project.sandbox && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                🔒 sandbox
+              </span>
+            )}
+            {project.hermetic && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                🔐 hermétique
+              </span>
+            )}
+            {project.reproducible && (
+              <span className="bg-white/20 text-white px-3 py-1 rounded text-sm">
+                ♻️ reproductible
+              </span>
+            )}
           </div>
         </div>
 
@@ -142,7 +245,7 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
             <form onSubmit={handleCreateBuild} className="mt-4 space-y-4">
               <div>
                 <label className="block text-sm font-medium text-gray-700 mb-2">
-                  Branche à builder
+                  Branche ou tag à builder
                 </label>
                 <input
                   type="text"
@@ -152,6 +255,18 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
                   placeholder="main"
                 />
               </div>
+              <div>
+                <label className="block text-sm font-medium text-gray-700 mb-2">
+                  Commit (optionnel, prioritaire sur la branche)
+                </label>
+                <input
+                  type="text"
+                  value={buildCommit}
+                  onChange={(e) => setBuildCommit(e.target.value.trim())}
+                  className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg font-mono"
+                  placeholder="a1b2c3d"
+                />
+              </div>
               <button
                 type="submit"
                 className="btn-primary bg-green-600 text-white px-6 py-2 rounded-lg font-semibold hover:bg-green-700"
@@ -163,6 +278,15 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
         </div>
       </div>
 
+      {/* Couverture des tests */}
+      <CoverageTrend project={project} />
+
+      {/* Secrets du projet */}
+      <ProjectSecrets project={project} onMessage={onMessage} />
+
+      {/* Identifiants du dépôt */}
+      <ProjectCredentials project={project} onMessage={onMessage} />
+
       {/* Liste des builds */}
       <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
         <div className="p-6 border-b">
@@ -205,12 +329,41 @@ function ProjectDetail({ project, onMessage, onBack, onBuildSelect }) {
                       <p className="text-sm text-gray-600 mt-1">
                         🌿 Branche:{" "}
                         <span className="font-mono">{build.branch}</span>
+                        {build.commit && (
+                          <span
+                            className="font-mono text-gray-500 ml-2"
+                            title={build.commit.message}
+                          >
+                            @ {build.commit.sha.substring(0, 7)}
+                          </span>
+                        )}
+                        {build.version && (
+                          <span className="font-mono text-gray-500 ml-2">
+                            🏷️ {build.version}
+                          </span>
+                        )}
                       </p>
                       <p className="text-xs text-gray-500 mt-1">
                         📅 {new Date(build.created_at).toLocaleString("fr-FR")}
                       </p>
+                      {build.waiting_reason && (
+                        <p className="text-xs text-orange-700 mt-1">
+                          ⏳ {build.waiting_reason}
+                        </p>
+                      )}
+                    </div>
+                    <div className="flex items-center gap-3">
+                      {(build.status === "pending" ||
+                        build.status === "building") && (
+                        <button
+                          onClick={(e) => handleCancelBuild(e, build)}
+                          className="text-sm bg-red-600 hover:bg-red-700 text-white px-3 py-1 rounded-lg"
+                        >
+                          🛑 Annuler
+                        </button>
+                      )}
+                      <span className="text-gray-400 text-2xl">→</span>
                     </div>
-                    <span className="text-gray-400 text-2xl">→</span>
                   </div>
                 </div>
               ))}
@@ -103,6 +103,26 @@ function ProjectsList({ onMessage, onProjectSelect }) {
                             📂 {project.subdir}
                           </span>
                         )}
+                        {project.agent_selector && (
+                          <span className="text-xs bg-orange-100 text-orange-800 px-2 py-1 rounded font-mono">
+                            🏷️ {project.agent_selector}
+                          </span>
+                        )}
+                        {project.platforms && project.platforms.length > 0 && (
+                          <span className="text-xs bg-teal-100 text-teal-800 px-2 py-1 rounded font-mono">
+                            🖥️ {project.platforms.join(", ")}
+                          </span>
+                        )}
+                        {project.binaries && project.binaries.length > 0 && (
+                          <span className="text-xs bg-indigo-100 text-indigo-800 px-2 py-1 rounded font-mono">
+                            📦 {project.binaries.map((b) => b.name).join(", ")}
+                          </span>
+                        )}
+                        {project.discover_binaries && (
+                          <span className="text-xs bg-indigo-100 text-indigo-800 px-2 py-1 rounded">
+                            🔍 découverte
+                          </span>
+                        )}
                       </div>
                     </div>
                     <span className="text-gray-400 text-2xl">→</span>
@@ -123,6 +143,27 @@ function ProjectForm({ onMessage, onSuccess }) {
   const [repoUrl, setRepoUrl] = React.useState("");
   const [branch, setBranch] = React.useState("main");
   const [subdir, setSubdir] = React.useState("");
+  const [agentSelector, setAgentSelector] = React.useState("");
+  const [goToolchain, setGoToolchain] = React.useState("");
+  const [platforms, setPlatforms] = React.useState("");
+  const [binaries, setBinaries] = React.useState("");
+  const [discoverBinaries, setDiscoverBinaries] = React.useState(false);
+  const [ldflags, setLdflags] = React.useState("");
+  const [env, setEnv] = React.useState("");
+  const [runTests, setRunTests] = React.useState(false);
+  const [coverage, setCoverage] = React.useState(false);
+  const [cloneDepth, setCloneDepth] = React.useState("");
+  const [submodules, setSubmodules] = React.useState(false);
+  const [lfs, setLfs] = React.useState(false);
+  const [sandbox, setSandbox] = React.useState(false);
+  const [hermetic, setHermetic] = React.useState(false);
+  const [reproducible, setReproducible] = React.useState(false);
+  const [sandboxLimits, setSandboxLimits] = React.useState({
+    cpu_seconds: "",
+    memory_mb: "",
+    open_files: "",
+    processes: "",
+  });
 
   const handleSubmit = async (e) => {
     e.preventDefault();
@@ -139,6 +180,50 @@ function ProjectForm({ onMessage, onSuccess }) {
           repo_url: repoUrl,
           branch: branch,
           subdir: subdir || undefined,
+          agent_selector: agentSelector || undefined,
+          go_toolchain: goToolchain || undefined,
+          platforms: platforms
+            .split(",")
+            .map((p) => p.trim())
+            .filter((p) => p !== ""),
+          // "./cmd/tool" ou "./cmd/tool:nom" : le nom est optionnel
+          binaries: binaries
+            .split(",")
+            .map((b) => b.trim())
+            .filter((b) => b !== "")
+            .map((b) => {
+              const [pkg, name] = b.split(":");
+              return { package: pkg.trim(), name: (name || "").trim() };
+            }),
+          discover_binaries: discoverBinaries,
+          ldflags: ldflags || undefined,
+          // Une variable par ligne, au format NOM=valeur
+          env: Object.fromEntries(
+            env
+              .split("\n")
+              .map((line) => line.trim())
+              .filter((line) => line !== "" && line.includes("="))
+              .map((line) => {
+                const i = line.indexOf("=");
+                return [line.slice(0, i).trim(), line.slice(i + 1)];
+              })
+          ),
+          run_tests: runTests,
+          coverage: runTests && coverage,
+          clone_depth: parseInt(cloneDepth, 10) || 0,
+          submodules: submodules,
+          lfs: lfs,
+          // Une limite vide prend la valeur par défaut du serveur
+          sandbox: sandbox
+            ? Object.fromEntries(
+                Object.entries(sandboxLimits).map(([k, v]) => [
+                  k,
+                  parseInt(v, 10) || 0,
+                ])
+              )
+            : undefined,
+          hermetic: hermetic,
+          reproducible: reproducible,
         }),
       });
       const data = await response.json();
@@ -151,10 +236,35 @@ function ProjectForm({ onMessage, onSuccess }) {
         setRepoUrl("");
         setBranch("main");
         setSubdir("");
+        setAgentSelector("");
+        setGoToolchain("");
+        setPlatforms("");
+        setBinaries("");
+        setDiscoverBinaries(false);
+        setLdflags("");
+        setEnv("");
+        setRunTests(false);
+        setCoverage(false);
+        setCloneDepth("");
+        setSubmodules(false);
+        setLfs(false);
+        setSandbox(false);
+        setHermetic(false);
+        setReproducible(false);
+        setSandboxLimits({
+          cpu_seconds: "",
+          memory_mb: "",
+          open_files: "",
+          processes: "",
+        });
         if (onSuccess) onSuccess();
       } else {
         console.error("❌ [ProjectForm] Erreur:", data);
-        onMessage("❌ Erreur: " + (data.error || "Erreur inconnue"));
+        onMessage(
+          "❌ Erreur: " +
+            (data.error || "Erreur inconnue") +
+            (data.details ? " (" + data.details + ")" : "")
+        );
       }
     } catch (error) {
       console.error("❌ [ProjectForm] Erreur réseau:", error);
@@ -224,6 +334,232 @@ function ProjectForm({ onMessage, onSuccess }) {
           </div>
         </div>
 
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-2">
+            Sélecteur d'agents (optionnel)
+          </label>
+          <input
+            type="text"
+            value={agentSelector}
+            onChange={(e) => setAgentSelector(e.target.value)}
+            className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder="os=linux,arch in (amd64,arm64),gpu!=true"
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Seuls les agents ONLINE dont les labels satisfont ce sélecteur
+            exécuteront les builds du projet
+          </p>
+        </div>
+
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-2">
+            Toolchain Go (optionnel)
+          </label>
+          <input
+            type="text"
+            value={goToolchain}
+            onChange={(e) => setGoToolchain(e.target.value)}
+            className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder="1.22, 1.22.3 ou go.mod"
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Version épinglée, ou go.mod pour suivre ses lignes go et toolchain.
+            Vide : la commande go du PATH du serveur
+          </p>
+        </div>
+
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-2">
+            Plateformes cibles (optionnel)
+          </label>
+          <input
+            type="text"
+            value={platforms}
+            onChange={(e) => setPlatforms(e.target.value)}
+            className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder="linux/amd64, linux/arm64, linux/arm/v7, darwin/arm64, windows/amd64"
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Un binaire est compilé par plateforme (os/arch, avec une variante
+            GOARM ou GOAMD64 optionnelle). Vide : plateforme de l'exécutant
+          </p>
+        </div>
+
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-2">
+            Binaires (optionnel)
+          </label>
+          <input
+            type="text"
+            value={binaries}
+            onChange={(e) => setBinaries(e.target.value)}
+            className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder="./cmd/server, ./tools/migrate:db-migrate"
+          />
+          <label className="flex items-center gap-2 text-sm text-gray-700 mt-2">
+            <input
+              type="checkbox"
+              checked={discoverBinaries}
+              onChange={(e) => setDiscoverBinaries(e.target.checked)}
+            />
+            Compiler aussi chaque package main trouvé dans le sous-répertoire
+          </label>
+          <p className="text-xs text-gray-500 mt-1">
+            Un package main par binaire, suivi de ":nom" pour changer le nom du
+            binaire. Vide : ./cmd/main.go, sous le nom du projet
+          </p>
+        </div>
+
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-2">
+            Modèle -ldflags (optionnel)
+          </label>
+          <input
+            type="text"
+            value={ldflags}
+            onChange={(e) => setLdflags(e.target.value)}
+            className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder="-X main.version={{.Version}} -X main.commit={{.Commit}}"
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Variables : {"{{.Commit}}"}, {"{{.ShortCommit}}"}, {"{{.Tag}}"},{" "}
+            {"{{.Version}}"}, {"{{.Branch}}"}, {"{{.BuildID}}"}, {"{{.Date}}"},{" "}
+            {"{{.Project}}"}
+          </p>
+        </div>
+
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-2">
+            Variables d'environnement (optionnel)
+          </label>
+          <textarea
+            value={env}
+            onChange={(e) => setEnv(e.target.value)}
+            rows={3}
+            className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder={"CGO_ENABLED=0\nGOFLAGS=-mod=vendor"}
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Une variable NOM=valeur par ligne, ajoutée aux commandes de build.
+            Les valeurs sensibles se déclarent comme secrets, depuis la page du
+            projet
+          </p>
+        </div>
+
+        <div>
+          <label className="block text-sm font-medium text-gray-700 mb-2">
+            Profondeur du clone (optionnel)
+          </label>
+          <input
+            type="number"
+            min="0"
+            value={cloneDepth}
+            onChange={(e) => setCloneDepth(e.target.value)}
+            className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg"
+            placeholder="1"
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Nombre de commits récupérés depuis chaque branche et tag. Vide :
+            tout l'historique, nécessaire pour déduire la version des tags
+          </p>
+        </div>
+
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={submodules}
+            onChange={(e) => setSubmodules(e.target.checked)}
+          />
+          Extraire les sous-modules Git, récursivement
+        </label>
+
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={lfs}
+            onChange={(e) => setLfs(e.target.checked)}
+          />
+          Télécharger les fichiers Git LFS
+        </label>
+
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={sandbox}
+            onChange={(e) => setSandbox(e.target.checked)}
+          />
+          Isoler les commandes du build dans un sandbox (Linux)
+        </label>
+        {sandbox && (
+          <div className="ml-6 grid grid-cols-2 gap-4">
+            {[
+              ["cpu_seconds", "Temps CPU (s)", "600"],
+              ["memory_mb", "Mémoire (Mo)", "4096"],
+              ["open_files", "Fichiers ouverts", "4096"],
+              ["processes", "Processus", "4096"],
+            ].map(([key, label, placeholder]) => (
+              <div key={key}>
+                <label className="block text-sm font-medium text-gray-700 mb-2">
+                  {label}
+                </label>
+                <input
+                  type="number"
+                  min="0"
+                  value={sandboxLimits[key]}
+                  onChange={(e) =>
+                    setSandboxLimits({ ...sandboxLimits, [key]: e.target.value })
+                  }
+                  className="form-input w-full px-4 py-2 border border-gray-300 rounded-lg"
+                  placeholder={placeholder}
+                />
+              </div>
+            ))}
+          </div>
+        )}
+
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={hermetic}
+            onChange={(e) => setHermetic(e.target.checked)}
+          />
+          Build hermétique : télécharger et vérifier les modules, puis compiler
+          et tester hors ligne (Linux)
+        </label>
+
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={reproducible}
+            onChange={(e) => setReproducible(e.target.checked)}
+          />
+          Build reproductible : recompiler chaque commit une seconde fois et
+          vérifier que les binaires sont identiques
+        </label>
+
+        <label className="flex items-center gap-2 text-sm text-gray-700">
+          <input
+            type="checkbox"
+            checked={runTests}
+            onChange={(e) => setRunTests(e.target.checked)}
+          />
+          Exécuter les tests (go test ./...) avant la compilation : un test en
+          échec fait échouer le build
+        </label>
+        <label
+          className={`flex items-center gap-2 text-sm ml-6 ${
+            runTests ? "text-gray-700" : "text-gray-400"
+          }`}
+        >
+          <input
+            type="checkbox"
+            checked={runTests && coverage}
+            disabled={!runTests}
+            onChange={(e) => setCoverage(e.target.checked)}
+          />
+          Mesurer la couverture des tests
+        </label>
+
         <button
           type="submit"
           className="btn-primary w-full bg-blue-600 text-white py-3 rounded-lg font-semibold hover:bg-blue-700"
@@ -0,0 +1,147 @@
+// Composant ProjectSecrets - Secrets d'un projet (les valeurs ne sont jamais affichées)
+function ProjectSecrets({ project, onMessage }) {
+  const [secrets, setSecrets] = React.useState([]);
+  const [name, setName] = React.useState("");
+  const [value, setValue] = React.useState("");
+
+  const loadSecrets = async () => {
+    try {
+      const response = await fetch(`/v1/api/projects/${project.id}/secrets`);
+      const data = await response.json();
+      if (response.ok) {
+        setSecrets(data.secrets || []);
+      } else {
+        console.error("❌ [ProjectSecrets] Erreur HTTP:", response.status, data);
+        setSecrets([]);
+      }
+    } catch (error) {
+      console.error("❌ [ProjectSecrets] Erreur réseau:", error);
+      setSecrets([]);
+    }
+  };
+
+  React.useEffect(() => {
+    loadSecrets();
+  }, [project.id]);
+
+  const handleSetSecret = async (e) => {
+    e.preventDefault();
+    try {
+      console.log("🔐 [ProjectSecrets] Enregistrement du secret", name);
+      const response = await fetch(`/v1/api/projects/${project.id}/secrets`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ name: name, value: value }),
+      });
+      const data = await response.json();
+
+      if (response.ok) {
+        onMessage("🔐 Secret " + data.name + " enregistré");
+        setName("");
+        setValue("");
+        loadSecrets();
+      } else {
+        console.error("❌ [ProjectSecrets] Erreur:", data);
+        onMessage(
+          "❌ Erreur: " +
+            (data.error || "Erreur inconnue") +
+            (data.details ? " (" + data.details + ")" : "")
+        );
+      }
+    } catch (error) {
+      console.error("❌ [ProjectSecrets] Erreur réseau:", error);
+      onMessage("❌ Erreur réseau: " + error.message);
+    }
+  };
+
+  const handleDeleteSecret = async (secret) => {
+    if (!confirm(`Supprimer le secret ${secret.name} ?`)) return;
+    try {
+      const response = await fetch(
+        `/v1/api/projects/${project.id}/secrets/${encodeURIComponent(secret.name)}`,
+        { method: "DELETE" }
+      );
+      const data = await response.json();
+
+      if (response.ok) {
+        onMessage("🗑️ Secret " + secret.name + " supprimé");
+        loadSecrets();
+      } else {
+        console.error("❌ [ProjectSecrets] Erreur:", data);
+        onMessage("❌ Erreur: " + (data.error || "Erreur inconnue"));
+      }
+    } catch (error) {
+      console.error("❌ [ProjectSecrets] Erreur réseau:", error);
+      onMessage("❌ Erreur réseau: " + error.message);
+    }
+  };
+
+  return (
+    <div className="card bg-white rounded-lg shadow-lg overflow-hidden">
+      <div className="p-6 border-b">
+        <h3 className="text-2xl font-bold text-gray-800">🔐 Secrets</h3>
+        <p className="text-sm text-gray-500 mt-1">
+          Ajoutés comme variables d'environnement aux commandes de build. Les
+          valeurs sont chiffrées et ne peuvent pas être relues
+        </p>
+      </div>
+
+      <div className="p-6 space-y-4">
+        {secrets.length === 0 ? (
+          <p className="text-gray-500">Aucun secret pour ce projet</p>
+        ) : (
+          <div className="space-y-2">
+            {secrets.map((secret) => (
+              <div
+                key={secret.id}
+                className="flex justify-between items-center border rounded-lg px-4 py-2"
+              >
+                <div>
+                  <span className="font-mono font-semibold text-gray-700">
+                    {secret.name}
+                  </span>
+                  <span className="font-mono text-gray-400 ml-3">••••••</span>
+                  <span className="text-xs text-gray-500 ml-3">
+                    modifié le{" "}
+                    {new Date(secret.updated_at).toLocaleString("fr-FR")}
+                  </span>
+                </div>
+                <button
+                  onClick={() => handleDeleteSecret(secret)}
+                  className="text-sm bg-red-600 hover:bg-red-700 text-white px-3 py-1 rounded-lg"
+                >
+                  🗑️ Supprimer
+                </button>
+              </div>
+            ))}
+          </div>
+        )}
+
+        <form onSubmit={handleSetSecret} className="grid grid-cols-3 gap-4">
+          <input
+            type="text"
+            required
+            value={name}
+            onChange={(e) => setName(e.target.value.trim())}
+            className="form-input px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder="NPM_TOKEN"
+          />
+          <input
+            type="password"
+            value={value}
+            onChange={(e) => setValue(e.target.value)}
+            className="form-input px-4 py-2 border border-gray-300 rounded-lg font-mono"
+            placeholder="valeur"
+            autoComplete="new-password"
+          />
+          <button
+            type="submit"
+            className="btn-primary bg-blue-600 text-white px-4 py-2 rounded-lg font-semibold hover:bg-blue-700"
+          >
+            💾 Ajouter ou remplacer
+          </button>
+        </form>
+      </div>
+    </div>
+  );
+}